@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/interfaces/control"
+)
+
+const ctlUsage = `uso: aiceberg_agent ctl <comando> [flags]
+
+  collect [-collector nome]          antecipa a coleta (sem -collector, todos)
+  flush                              envia agora os outboxes e o dead-letter marcado
+  pause                              pausa a coleta (Paused nas prefs)
+  resume                             retoma a coleta
+  log-level <debug|info|warn|error>  troca o nível do log até o próximo restart ou reload
+  tail [-kind K] [-sub S] [-pretty]  imprime os envelopes gravados a partir de agora (Ctrl-C encerra)
+
+Fala com o agente em execução pelo socket de controle (CONTROL_SOCKET),
+autenticando com o token em CONTROL_TOKEN_PATH: rode com o mesmo usuário
+do serviço (ou root/administrador).
+`
+
+// runCtl implementa o subcomando "ctl" e retorna o exit code.
+func runCtl(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, ctlUsage)
+		return 2
+	}
+	op := args[0]
+	fs := flag.NewFlagSet("ctl "+op, flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	collector := fs.String("collector", "", "collector (collect); vazio = todos")
+	kind := fs.String("kind", "", "só envelopes deste kind (tail)")
+	sub := fs.String("sub", "", "só envelopes deste sub (tail)")
+	pretty := fs.Bool("pretty", false, "JSON indentado (tail)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	var callArgs any
+	switch op {
+	case "collect":
+		callArgs = control.CollectArgs{Collector: *collector}
+	case "flush", "pause", "resume":
+	case "log-level":
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, ctlUsage)
+			return 2
+		}
+		callArgs = control.LogLevelArgs{Level: fs.Arg(0)}
+	case "tail":
+	default:
+		fmt.Fprintf(os.Stderr, "comando desconhecido %q\n\n%s", op, ctlUsage)
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	cl, ok := controlClient(cfg)
+	if !ok {
+		return 1
+	}
+
+	if op == "tail" {
+		return ctlTail(cl, control.TailArgs{Kind: *kind, Sub: *sub}, *pretty)
+	}
+	// O flush espera o envio terminar, limitado a 30s no agente.
+	ctx, cancel := context.WithTimeout(context.Background(), 40*time.Second)
+	defer cancel()
+	var out json.RawMessage
+	if err := cl.Call(ctx, op, callArgs, &out); err != nil {
+		fmt.Fprintf(os.Stderr, "ctl %s: %v\n", op, err)
+		return 1
+	}
+	if len(out) > 0 && string(out) != "null" {
+		fmt.Printf("%s: %s\n", op, out)
+	} else {
+		fmt.Printf("%s: ok\n", op)
+	}
+	return 0
+}
+
+// ctlTail imprime o stream até Ctrl-C ou o agente encerrar.
+func ctlTail(cl control.Client, args control.TailArgs, pretty bool) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	enc := json.NewEncoder(os.Stdout)
+	if pretty {
+		enc.SetIndent("", "  ")
+	}
+	err := cl.Stream(ctx, "tail", args, func(raw json.RawMessage) error {
+		return enc.Encode(raw)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ctl tail: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// controlClient monta o cliente do socket de controle, explicando no
+// stderr por que não dá (socket desativado, token ilegível).
+func controlClient(cfg config.Config) (control.Client, bool) {
+	if cfg.ControlSocket == "" {
+		fmt.Fprintln(os.Stderr, "socket de controle desativado (CONTROL_SOCKET vazio)")
+		return control.Client{}, false
+	}
+	token, err := control.ReadToken(cfg.ControlTokenPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		fmt.Fprintf(os.Stderr, "token de controle %s não existe: o agente já rodou com esta config?\n", cfg.ControlTokenPath)
+		return control.Client{}, false
+	case errors.Is(err, os.ErrPermission):
+		fmt.Fprintf(os.Stderr, "sem permissão para ler %s: rode com o usuário do agente ou como root\n", cfg.ControlTokenPath)
+		return control.Client{}, false
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "token de controle: %v\n", err)
+		return control.Client{}, false
+	}
+	return control.Client{Addr: cfg.ControlSocket, Token: token}, true
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+)
+
+const deadLetterUsage = `uso: aiceberg_agent deadletter <list|export|replay|purge> [flags]
+
+  list               lista envelopes no dead-letter
+  export [-o file]   exporta as entradas em JSON lines (stdout por padrão)
+  replay [-id a,b]   marca entradas (todas, sem -id) para reenvio pelo agente
+  purge  [-id a,b]   remove entradas (todas, sem -id)
+`
+
+// runDeadLetter implementa o subcomando "deadletter" e retorna o exit code.
+func runDeadLetter(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, deadLetterUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("deadletter "+args[0], flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config.yml")
+	out := fs.String("o", "", "arquivo de saída (export)")
+	idList := fs.String("id", "", "lista de envelope_id separados por vírgula")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	// Token ausente não impede inspecionar o dead-letter local.
+	cfg, _ := config.Load(*cfgPath)
+	store := outbox.NewDeadLetterStore(cfg.DeadLetterPath, cfg.DeadLetterMaxItems)
+	var ids []string
+	for _, id := range strings.Split(*idList, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	switch args[0] {
+	case "list":
+		entries, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "deadletter list: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ENVELOPE_ID\tQUEUE\tKIND\tSTATUS\tATTEMPTS\tFIRST_SEEN\tLAST_SEEN\tREPLAY\tREASON")
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%t\t%s\n",
+				e.Envelope.ID, e.Queue, e.Envelope.Kind, e.Status, e.Attempts,
+				e.FirstSeen.Format(time.RFC3339), e.LastSeen.Format(time.RFC3339), e.Replay, oneLine(e.Reason, 80))
+		}
+		_ = tw.Flush()
+		fmt.Printf("total=%d\n", len(entries))
+	case "export":
+		entries, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "deadletter export: %v\n", err)
+			return 1
+		}
+		var w io.Writer = os.Stdout
+		if *out != "" {
+			f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "deadletter export: %v\n", err)
+				return 1
+			}
+			defer f.Close()
+			w = f
+		}
+		enc := json.NewEncoder(w)
+		for _, e := range entries {
+			// O header de autenticação não sai do host.
+			e.Auth = ""
+			if err := enc.Encode(e); err != nil {
+				fmt.Fprintf(os.Stderr, "deadletter export: %v\n", err)
+				return 1
+			}
+		}
+		if *out != "" {
+			fmt.Printf("exported %d entries to %s\n", len(entries), *out)
+		}
+	case "replay":
+		n, err := store.MarkReplay(ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "deadletter replay: %v\n", err)
+			return 1
+		}
+		fmt.Printf("marked %d entries for replay; the agent re-queues them on its next flush cycle\n", n)
+	case "purge":
+		n, err := store.Delete(ids)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "deadletter purge: %v\n", err)
+			return 1
+		}
+		fmt.Printf("purged %d entries\n", n)
+	default:
+		fmt.Fprint(os.Stderr, deadLetterUsage)
+		return 2
+	}
+	return 0
+}
+
+func oneLine(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
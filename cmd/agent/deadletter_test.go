@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+func TestDeadLetter(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    []string
+		wantErr    string
+		wantLeft   []string
+		wantReplay []string
+	}{
+		{
+			name:     "list",
+			args:     []string{"list"},
+			wantOut:  []string{"ENVELOPE_ID", "d1", "main", "422", "schema mismatch on field x", "d2", "oslogs", "total=2"},
+			wantLeft: []string{"d1", "d2"},
+		},
+		{name: "export", args: []string{"export"}, wantOut: []string{`"envelope_id":"d1"`, `"envelope_id":"d2"`}, wantLeft: []string{"d1", "d2"}},
+		{name: "export para arquivo", args: []string{"export", "-o", "OUT"}, wantOut: []string{"exported 2 entries to "}, wantLeft: []string{"d1", "d2"}},
+		{name: "replay de um", args: []string{"replay", "-id", " d2 ,"}, wantOut: []string{"marked 1 entries for replay"}, wantLeft: []string{"d1", "d2"}, wantReplay: []string{"d2"}},
+		{name: "replay de todos", args: []string{"replay"}, wantOut: []string{"marked 2 entries for replay"}, wantLeft: []string{"d1", "d2"}, wantReplay: []string{"d1", "d2"}},
+		{name: "purge de um", args: []string{"purge", "-id", "d1"}, wantOut: []string{"purged 1 entries"}, wantLeft: []string{"d2"}},
+		{name: "purge de todos", args: []string{"purge"}, wantOut: []string{"purged 2 entries"}},
+		{name: "subcomando desconhecido", args: []string{"drop"}, wantCode: 2, wantErr: "uso: aiceberg_agent deadletter", wantLeft: []string{"d1", "d2"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "deadletter.db")
+			store := outbox.NewDeadLetterStore(path, 100)
+			if err := store.Put("main", entities.Envelope{ID: "d1", Kind: "metric", AuthHeader: "Token relayed-secret"}, "schema\nmismatch on field x", 422); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Put("oslogs", entities.Envelope{ID: "d2", Kind: "metric", Sub: "oslogs"}, "too large", 413); err != nil {
+				t.Fatal(err)
+			}
+			cfg := writeConfig(t, dir, "queue:\n  deadletter_path: "+path+"\n")
+			outFile := filepath.Join(dir, "export.jsonl")
+			args := append([]string(nil), tc.args...)
+			for i, a := range args {
+				if a == "OUT" {
+					args[i] = outFile
+				}
+			}
+			code, out, stderr := run(t, runDeadLetter, append(args, "-config", cfg)...)
+			if code != tc.wantCode || !strings.Contains(stderr, tc.wantErr) {
+				t.Fatalf("exit %d, stderr %q", code, stderr)
+			}
+			for _, w := range tc.wantOut {
+				if !strings.Contains(out, w) {
+					t.Fatalf("stdout %q lacks %q", out, w)
+				}
+			}
+			if strings.Contains(out, "relayed-secret") {
+				t.Fatal("output leaks the relayed credential")
+			}
+			if slices.Contains(tc.args, "OUT") {
+				raw, err := os.ReadFile(outFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var e outbox.DeadLetterEntry
+				if err := json.Unmarshal(raw[:strings.IndexByte(string(raw), '\n')], &e); err != nil || e.Envelope.ID != "d1" || e.Auth != "" {
+					t.Fatalf("exported entry = %+v (%v)", e, err)
+				}
+			}
+
+			entries, err := store.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left, replay []string
+			for _, e := range entries {
+				left = append(left, e.Envelope.ID)
+				if e.Replay {
+					replay = append(replay, e.Envelope.ID)
+				}
+			}
+			if !slices.Equal(left, tc.wantLeft) || !slices.Equal(replay, tc.wantReplay) {
+				t.Fatalf("entries = %v (replay %v), want %v (replay %v)", left, replay, tc.wantLeft, tc.wantReplay)
+			}
+		})
+	}
+}
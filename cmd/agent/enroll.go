@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	app "github.com/you/aiceberg_agent/internal/bootstrap"
+)
+
+// runEnroll implementa "enroll": faz o bootstrap no backend (pedindo o
+// token se não vier por flag, env ou arquivo) e persiste token e estado,
+// para o serviço subir já registrado.
+func runEnroll(args []string) int {
+	fs := flag.NewFlagSet("enroll", flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	token := fs.String("token", "", "token do agente (padrão: AGENT_TOKEN, arquivo de token ou pergunta)")
+	api := fs.String("api", "", "base da API (padrão: API_BASE_URL)")
+	force := fs.Bool("force", false, "refaz o registro mesmo com estado salvo")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	if *api != "" {
+		cfg.APIBaseURL = *api
+	}
+	if *token != "" {
+		cfg.Agent.Token = *token
+	}
+	if cfg.Agent.Token == "" {
+		fmt.Fprint(os.Stderr, "Token do agente: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		cfg.Agent.Token = strings.TrimSpace(line)
+	}
+	if cfg.Agent.Token == "" {
+		fmt.Fprintln(os.Stderr, "enroll: token obrigatório")
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	log := cliLogger(cfg, "info")
+	if err := app.Enroll(ctx, cfg, log, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "enroll: %v\n", err)
+		return 1
+	}
+	fmt.Printf("enrolled on %s: token in %s, state in %s\n", cfg.APIBaseURL, cfg.Agent.TokenPath, cfg.Agent.StatePath)
+	return 0
+}
@@ -1,30 +1,138 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"strings"
 
 	app "github.com/you/aiceberg_agent/internal/bootstrap"
 	"github.com/you/aiceberg_agent/internal/common/config"
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/version"
 )
 
-var configPath = flag.String("config", "./configs/config.example.yml", "path to config.yml")
+var configPath = flag.String("config", "", "path to config file (YAML or JSON); env vars override its values")
+
+const usage = `uso: aiceberg_agent [comando] [flags]
+
+comandos:
+  run               executa o agente (padrão quando nenhum comando é dado)
+  once              roda cada collector uma vez e imprime os envelopes
+  status            consulta o agente em execução pelo socket de controle
+  ctl               collect|flush|pause|resume|log-level|tail no agente em execução
+  queue             dump|purge do outbox local
+  enroll            registra o host no backend e salva token e estado
+  test-connection   testa DNS, TLS e os endpoints de bootstrap, ping, config e ingest
+  validate-config   valida arquivo + env sem iniciar o agente
+  deadletter        list|export|replay|purge do dead-letter
+  version           mostra a versão
+
+Use "aiceberg_agent <comando> -h" para as flags de cada um.
+`
 
 func main() {
-	flag.Parse()
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "run":
+		os.Exit(runAgent(args))
+	case "once":
+		os.Exit(runOnce(args))
+	case "status":
+		os.Exit(runStatus(args))
+	case "ctl":
+		os.Exit(runCtl(args))
+	case "queue":
+		os.Exit(runQueue(args))
+	case "enroll":
+		os.Exit(runEnroll(args))
+	case "test-connection":
+		os.Exit(runTestConnection(args))
+	case "deadletter":
+		os.Exit(runDeadLetter(args))
+	case "validate-config":
+		os.Exit(runValidateConfig(args))
+	case "version":
+		fmt.Printf("aiceberg_agent %s (%s %s/%s)\n", version.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "comando desconhecido %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
+
+// runAgent implementa "run": o agente em primeiro plano até SIGTERM/SIGINT.
+func runAgent(args []string) int {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage+"\nflags de run:\n")
+		flag.PrintDefaults()
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return 2
+	}
 
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		fmt.Printf("config load error: %v\n", err)
-		os.Exit(1)
+		return 1
 	}
 
-	log := logger.New(cfg.Agent.LogLevel)
+	log, err := newLogger(cfg)
+	if err != nil {
+		fmt.Printf("logger init error: %v\n", err)
+		return 1
+	}
 	defer log.Sync()
 
-	if err := app.Run(cfg, log); err != nil {
+	err = app.Run(cfg, *configPath, log)
+	if errors.Is(err, app.ErrRestart) {
+		// Código não-zero: systemd (Restart=always) e o SCM reiniciam o serviço.
+		log.Info("exiting for restart")
+		return 3
+	}
+	if err != nil {
 		log.Fatal("app run failed", "err", err)
 	}
+	return 0
+}
+
+func newLogger(cfg config.Config) (logger.Logger, error) {
+	return logger.New(logger.Options{
+		Level:      cfg.Agent.LogLevel,
+		Format:     cfg.Agent.LogFormat,
+		File:       cfg.Agent.LogFile,
+		MaxSizeMB:  cfg.Agent.LogMaxSizeMB,
+		MaxBackups: cfg.Agent.LogMaxBackups,
+		MaxAge:     cfg.Agent.LogMaxAge,
+		Secrets:    cfg.Secrets(),
+	})
+}
+
+// cliLogger é o log dos subcomandos: texto em stderr, sem o arquivo do
+// agente (que pode pertencer a outro usuário).
+func cliLogger(cfg config.Config, level string) logger.Logger {
+	log, err := logger.New(logger.Options{Level: level, Secrets: cfg.Secrets()})
+	if err != nil {
+		log, _ = logger.New(logger.Options{Secrets: cfg.Secrets()})
+	}
+	return log
+}
+
+// loadConfig carrega a config para os subcomandos; token ausente não é
+// erro (status, queue e enroll funcionam sem ele).
+func loadConfig(path string) (config.Config, error) {
+	cfg, err := config.Load(path)
+	var problems []error
+	for _, e := range flatten(err) {
+		if !errors.Is(e, config.ErrNoToken) {
+			problems = append(problems, e)
+		}
+	}
+	return cfg, errors.Join(problems...)
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// run chama um subcomando com stdout e stderr capturados e devolve o exit
+// code e as saídas.
+func run(t *testing.T, cmd func([]string) int, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	read := func(f **os.File) (func() string, func()) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := *f
+		*f = w
+		out := make(chan string, 1)
+		go func() {
+			b, _ := io.ReadAll(r)
+			out <- string(b)
+		}()
+		return func() string { _ = w.Close(); return <-out }, func() { *f = orig }
+	}
+	outText, restoreOut := read(&os.Stdout)
+	errText, restoreErr := read(&os.Stderr)
+	code = cmd(args)
+	restoreOut()
+	restoreErr()
+	return code, outText(), errText()
+}
+
+// writeConfig grava cfg (YAML) em dir e devolve o caminho.
+func writeConfig(t *testing.T, dir, cfg string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/you/aiceberg_agent/internal/data/local/prefs"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+	"github.com/you/aiceberg_agent/internal/domain/usecase"
+	"github.com/you/aiceberg_agent/internal/platform/collectors/journald"
+	"github.com/you/aiceberg_agent/internal/platform/collectors/oslogs"
+	"github.com/you/aiceberg_agent/internal/platform/collectors/sysmetrics"
+)
+
+// runOnce implementa "once": roda cada collector habilitado uma vez e
+// imprime os envelopes em JSON lines, sem gravar no outbox nem enviar.
+func runOnce(args []string) int {
+	fs := flag.NewFlagSet("once", flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	only := fs.String("collector", "", "roda só este collector (sysmetrics, oslogs, journald)")
+	journalFile := fs.String("journal-file", "", "journald: lê este export do journalctl -o json em vez do journal do sistema")
+	pretty := fs.Bool("pretty", false, "JSON indentado")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+
+	// Os cursores do oslogs e do journald são copiados: a saída mostra o que
+	// o agente enviaria a seguir, mas o cursor real não anda.
+	if cfg.OSLogEnabled {
+		if cfg.OSLogCursorPath, err = tempCursor(cfg.OSLogCursorPath, "oslogs"); err != nil {
+			fmt.Fprintf(os.Stderr, "oslogs: %v\n", err)
+			return 1
+		}
+		defer os.Remove(cfg.OSLogCursorPath)
+	}
+	if *journalFile != "" {
+		// Com o export, o cursor do agente (de outro journal) não serve.
+		cfg.JournaldEnabled, cfg.JournaldCursorPath = true, ""
+	}
+	if cfg.JournaldEnabled && cfg.JournaldCursorPath != "" {
+		if cfg.JournaldCursorPath, err = tempCursor(cfg.JournaldCursorPath, "journald"); err != nil {
+			fmt.Fprintf(os.Stderr, "journald: %v\n", err)
+			return 1
+		}
+		defer os.Remove(cfg.JournaldCursorPath)
+	}
+
+	var collectors []ports.Collector
+	if cfg.SysmetricsEnabled {
+		p := prefs.NewStore(cfg.PrefsPath)
+		_, _ = p.Load()
+		// Sem acesso ao outbox (o agente pode estar com ele aberto): backlog zerado.
+		collectors = append(collectors, sysmetrics.New(cfg.SysmetricsInterval,
+			func() (int, int64) { return 0, 0 }, func() int64 { return 0 }, p.Get))
+	}
+	if cfg.OSLogEnabled && len(cfg.OSLogFiles) > 0 {
+		collectors = append(collectors, oslogs.New(cfg))
+	}
+	if *journalFile != "" {
+		collectors = append(collectors, journald.NewWithRunner(cfg, journald.FixtureRunner(*journalFile)))
+	} else if cfg.JournaldEnabled {
+		collectors = append(collectors, journald.New(cfg))
+	}
+
+	out := &printOutbox{enc: json.NewEncoder(os.Stdout)}
+	if *pretty {
+		out.enc.SetIndent("", "  ")
+	}
+	log := cliLogger(cfg, "warn")
+	ran, failed := 0, 0
+	for _, c := range collectors {
+		if *only != "" && c.Name() != *only {
+			continue
+		}
+		ran++
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.CollectTimeout)
+		err := usecase.NewCollectAndBuffer(c, out, log, "").Execute(ctx)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", c.Name(), err)
+			failed++
+		}
+	}
+	if ran == 0 {
+		fmt.Fprintln(os.Stderr, "nenhum collector habilitado"+forCollector(*only))
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// tempCursor copia o cursor em path para um arquivo temporário novo (nome
+// aleatório, só do dono) e devolve o caminho da cópia. Sem cursor, a cópia
+// fica vazia, o que os collectors tratam como cursor ausente.
+func tempCursor(path, name string) (string, error) {
+	f, err := os.CreateTemp("", "aiceberg-once-*."+name+".cursor")
+	if err != nil {
+		return "", err
+	}
+	if raw, err := os.ReadFile(path); err == nil {
+		_, err = f.Write(raw)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			_ = os.Remove(f.Name())
+			return "", err
+		}
+		return f.Name(), nil
+	}
+	return f.Name(), f.Close()
+}
+
+func forCollector(name string) string {
+	if name == "" {
+		return ""
+	}
+	return " com o nome " + strconv.Quote(name)
+}
+
+// printOutbox é um OutboxRepo que só imprime o que recebe.
+type printOutbox struct {
+	enc *json.Encoder
+}
+
+// Garante conformidade.
+var _ ports.OutboxRepo = (*printOutbox)(nil)
+
+func (p *printOutbox) Append(env entities.Envelope) error { return p.enc.Encode(env) }
+
+func (p *printOutbox) ReadBatch(int) ([]entities.Envelope, error) { return nil, nil }
+
+func (p *printOutbox) Ack([]string) error { return nil }
+
+func (p *printOutbox) Len() (int, int64) { return 0, 0 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+func TestTempCursor(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	src := filepath.Join(t.TempDir(), "oslogs.cursor")
+	if err := os.WriteFile(src, []byte(`{"/var/log/x":{"offset":10}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := tempCursor(src, "oslogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tempCursor(src, "oslogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Fatalf("two copies share the path %s", a)
+	}
+	for _, p := range []string{a, b} {
+		raw, err := os.ReadFile(p)
+		if err != nil || string(raw) != `{"/var/log/x":{"offset":10}}` {
+			t.Fatalf("copy %s = %q (%v)", p, raw, err)
+		}
+		if st, _ := os.Stat(p); st.Mode().Perm()&0o077 != 0 {
+			t.Fatalf("copy %s mode = %o", p, st.Mode().Perm())
+		}
+		if !strings.HasSuffix(p, ".oslogs.cursor") {
+			t.Fatalf("copy name = %s", p)
+		}
+	}
+
+	// Sem cursor, a cópia existe e está vazia.
+	c, err := tempCursor(filepath.Join(t.TempDir(), "missing"), "journald")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw, err := os.ReadFile(c); err != nil || len(raw) != 0 {
+		t.Fatalf("copy of a missing cursor = %q (%v)", raw, err)
+	}
+}
+
+func TestOnce(t *testing.T) {
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(logFile, []byte("first line\nsecond line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cursor := filepath.Join(dir, "oslogs.cursor")
+	cfg := writeConfig(t, dir, `
+agent:
+  token: tok
+  prefs_path: `+filepath.Join(dir, "prefs.json")+`
+modules:
+  noc:
+    sysmetrics:
+      enabled: true
+  soc:
+    oslogs:
+      enabled: true
+      files: [`+logFile+`]
+      cursor_path: `+cursor+`
+      inotify: false
+    journald:
+      enabled: false
+`)
+	cases := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantSub  string // sub dos envelopes impressos
+		wantOut  string
+		wantErr  string
+	}{
+		{name: "oslogs", args: []string{"-collector", "oslogs"}, wantSub: "oslogs", wantOut: "second line"},
+		{name: "sysmetrics", args: []string{"-collector", "sysmetrics"}, wantSub: "sysmetrics"},
+		{name: "collector desconhecido", args: []string{"-collector", "nope"}, wantCode: 1, wantErr: `nenhum collector habilitado com o nome "nope"`},
+		{name: "flag inválida", args: []string{"-bogus"}, wantCode: 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			code, out, stderr := run(t, runOnce, append([]string{"-config", cfg}, tc.args...)...)
+			if code != tc.wantCode || !strings.Contains(stderr, tc.wantErr) {
+				t.Fatalf("exit %d, stderr %q; want %d with %q", code, stderr, tc.wantCode, tc.wantErr)
+			}
+			if !strings.Contains(out, tc.wantOut) {
+				t.Fatalf("stdout %q lacks %q", out, tc.wantOut)
+			}
+			if tc.wantSub != "" {
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				for _, l := range lines {
+					var env entities.Envelope
+					if err := json.Unmarshal([]byte(l), &env); err != nil || env.Sub != tc.wantSub {
+						t.Fatalf("line %q: sub %q (%v)", l, env.Sub, err)
+					}
+				}
+			}
+			// O cursor real não anda e a cópia temporária é apagada.
+			if _, err := os.Stat(cursor); !os.IsNotExist(err) {
+				t.Fatalf("agent cursor touched: %v", err)
+			}
+			if left, _ := os.ReadDir(tmp); len(left) != 0 {
+				t.Fatalf("temporary files left: %v", left)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+	bolt "go.etcd.io/bbolt"
+)
+
+const queueUsage = `uso: aiceberg_agent queue <dump|purge> [flags]
+
+  dump  [-queue main|oslogs] [-n N] [-o file]   exporta os envelopes pendentes em JSON lines
+  purge [-queue main|oslogs]                    remove todos os envelopes pendentes
+
+O outbox é aberto direto do disco e o bbolt não permite dois processos:
+pare o agente antes.
+`
+
+// runQueue implementa o subcomando "queue" e retorna o exit code.
+func runQueue(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, queueUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("queue "+args[0], flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	queue := fs.String("queue", "main", "fila: main ou oslogs")
+	limit := fs.Int("n", 0, "máximo de envelopes (dump); 0 = todos")
+	out := fs.String("o", "", "arquivo de saída (dump)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if args[0] != "dump" && args[0] != "purge" {
+		fmt.Fprint(os.Stderr, queueUsage)
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	if cfg.OutboxBackend == "mem" {
+		fmt.Fprintln(os.Stderr, "outbox em memória (OUTBOX_BACKEND=mem): não há fila em disco")
+		return 1
+	}
+	var path string
+	switch *queue {
+	case "main":
+		path = cfg.OutboxPath
+	case "oslogs":
+		path = cfg.OSLogOutboxPath
+	default:
+		fmt.Fprintf(os.Stderr, "fila desconhecida %q (main, oslogs)\n", *queue)
+		return 2
+	}
+	if _, err := os.Stat(path); err != nil {
+		fmt.Fprintf(os.Stderr, "queue %s: %v\n", *queue, err)
+		return 1
+	}
+
+	st, err := outbox.NewBoltStore(path)
+	if errors.Is(err, bolt.ErrTimeout) {
+		fmt.Fprintf(os.Stderr, "queue %s: %s em uso; o agente está rodando? pare-o antes\n", *queue, path)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "queue %s: %v\n", *queue, err)
+		return 1
+	}
+	defer st.Close()
+
+	n, _ := st.Len()
+	if *limit > 0 && *limit < n && args[0] == "dump" {
+		n = *limit
+	}
+	envs, err := st.Peek(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "queue %s: %v\n", *queue, err)
+		return 1
+	}
+
+	if args[0] == "purge" {
+		ids := make([]string, 0, len(envs))
+		for _, e := range envs {
+			ids = append(ids, e.ID)
+		}
+		if err := st.Delete(ids); err != nil {
+			fmt.Fprintf(os.Stderr, "queue purge: %v\n", err)
+			return 1
+		}
+		fmt.Printf("purged %d envelopes from %s\n", len(ids), *queue)
+		return 0
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.OpenFile(*out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "queue dump: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	for _, e := range envs {
+		// AuthHeader não é serializado: o token não sai do host.
+		if err := enc.Encode(e); err != nil {
+			fmt.Fprintf(os.Stderr, "queue dump: %v\n", err)
+			return 1
+		}
+	}
+	if *out != "" {
+		fmt.Printf("exported %d envelopes to %s\n", len(envs), *out)
+	}
+	return 0
+}
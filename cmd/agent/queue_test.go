@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// queueConfig cria um outbox main com três envelopes (um com credencial
+// de agente atrás do hub) e devolve a config e o caminho do outbox.
+func queueConfig(t *testing.T, extra string) (cfg, path string) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, "outbox.db")
+	st, err := outbox.NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, id := range []string{"e1", "e2", "e3"} {
+		env := entities.Envelope{ID: id, Kind: "metric", Body: map[string]int{"n": i}}
+		if id == "e2" {
+			env.AuthHeader = "Token relayed-secret"
+		}
+		if err := st.Push(env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := st.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cfg = writeConfig(t, dir, "agent:\n  token: tok\nqueue:\n  path: "+path+"\n  oslogs_path: "+filepath.Join(dir, "oslogs.db")+"\n"+extra)
+	return cfg, path
+}
+
+func TestQueue(t *testing.T) {
+	cases := []struct {
+		name     string
+		args     []string
+		extra    string
+		wantCode int
+		wantIDs  []string // envelopes no stdout (dump) ou no arquivo -o
+		wantOut  string
+		wantErr  string
+		wantLeft int
+	}{
+		{name: "dump", args: []string{"dump"}, wantIDs: []string{"e1", "e2", "e3"}, wantLeft: 3},
+		{name: "dump com limite", args: []string{"dump", "-n", "2"}, wantIDs: []string{"e1", "e2"}, wantLeft: 3},
+		{name: "dump para arquivo", args: []string{"dump", "-o", "OUT"}, wantIDs: []string{"e1", "e2", "e3"}, wantOut: "exported 3 envelopes to ", wantLeft: 3},
+		{name: "purge", args: []string{"purge"}, wantOut: "purged 3 envelopes from main", wantLeft: 0},
+		{name: "fila oslogs inexistente", args: []string{"dump", "-queue", "oslogs"}, wantCode: 1, wantErr: "queue oslogs: ", wantLeft: 3},
+		{name: "fila desconhecida", args: []string{"dump", "-queue", "other"}, wantCode: 2, wantErr: `fila desconhecida "other"`, wantLeft: 3},
+		{name: "outbox em memória", args: []string{"dump"}, extra: "  backend: mem\n", wantCode: 1, wantErr: "outbox em memória", wantLeft: 3},
+		{name: "subcomando desconhecido", args: []string{"drop"}, wantCode: 2, wantErr: "uso: aiceberg_agent queue", wantLeft: 3},
+		{name: "sem subcomando", wantCode: 2, wantErr: "uso: aiceberg_agent queue", wantLeft: 3},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, path := queueConfig(t, tc.extra)
+			outFile := filepath.Join(t.TempDir(), "dump.jsonl")
+			args := append([]string(nil), tc.args...)
+			for i, a := range args {
+				if a == "OUT" {
+					args[i] = outFile
+				}
+			}
+			if len(args) > 0 {
+				args = append(args, "-config", cfg)
+			}
+			code, out, stderr := run(t, runQueue, args...)
+			if code != tc.wantCode || !strings.Contains(stderr, tc.wantErr) || !strings.Contains(out, tc.wantOut) {
+				t.Fatalf("exit %d, stdout %q, stderr %q", code, out, stderr)
+			}
+			dump := out
+			if tc.wantOut != "" && tc.wantIDs != nil {
+				raw, err := os.ReadFile(outFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				dump = string(raw)
+			}
+			if tc.wantIDs != nil {
+				lines := strings.Split(strings.TrimSpace(dump), "\n")
+				if len(lines) != len(tc.wantIDs) {
+					t.Fatalf("dump = %q, want %v", dump, tc.wantIDs)
+				}
+				for i, l := range lines {
+					var env entities.Envelope
+					if err := json.Unmarshal([]byte(l), &env); err != nil || env.ID != tc.wantIDs[i] {
+						t.Fatalf("line %d = %q (%v), want %s", i, l, err, tc.wantIDs[i])
+					}
+				}
+				if strings.Contains(dump, "relayed-secret") {
+					t.Fatal("dump leaks the relayed credential")
+				}
+			}
+			st, err := outbox.NewBoltStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+			if n, _ := st.Len(); n != tc.wantLeft {
+				t.Fatalf("envelopes left = %d, want %d", n, tc.wantLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/interfaces/control"
+	"github.com/you/aiceberg_agent/internal/interfaces/health"
+)
+
+// runStatus implementa "status": pergunta ao agente em execução, pelo socket
+// de controle, a profundidade das filas, os últimos erros e a versão da
+// config. Sai com 1 se o agente não responde ou não está pronto.
+func runStatus(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	asJSON := fs.Bool("json", false, "imprime a resposta em JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+	cl, ok := controlClient(cfg)
+	if !ok {
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var st control.Status
+	if err := cl.Call(ctx, "status", nil, &st); err != nil {
+		fmt.Fprintf(os.Stderr, "status: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(st)
+	} else {
+		printStatus(st)
+	}
+	if st.Health.Status != health.StatusOK {
+		return 1
+	}
+	return 0
+}
+
+func printStatus(st control.Status) {
+	cfgVersion := st.ConfigVersion
+	if cfgVersion == "" {
+		cfgVersion = "-"
+	}
+	fmt.Printf("agent %s pid=%d mode=%s up=%s config=%s paused=%t\n",
+		st.Version, st.PID, st.Mode, time.Since(st.StartedAt).Round(time.Second), cfgVersion, st.Paused)
+	fmt.Printf("health: %s\n", st.Health.Status)
+	names := make([]string, 0, len(st.Health.Components))
+	for name := range st.Health.Components {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if c := st.Health.Components[name]; c.Status == health.StatusFail {
+			fmt.Printf("  fail %s: %s\n", name, c.Detail)
+		}
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUEUE\tITEMS\tBYTES\tDROPPED")
+	for _, q := range st.Queues {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", q.Name, q.Items, q.Bytes, q.Dropped)
+	}
+	fmt.Fprintf(tw, "deadletter\t%d\t\t\n", st.DeadLetter)
+	_ = tw.Flush()
+
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTOR\tRUNS\tERRORS\tCONSEC_ERRORS\tLAST_START\tLAST_DURATION\tLAST_ERROR")
+	for _, c := range st.Collectors {
+		last := "-"
+		if !c.LastStart.IsZero() {
+			last = c.LastStart.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			c.Name, c.Runs, c.Errors, c.ConsecErrors, last, c.LastDuration.Round(time.Millisecond), oneLine(c.LastError, 80))
+	}
+	_ = tw.Flush()
+}
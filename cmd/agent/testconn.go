@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/httpx"
+)
+
+// probe é o resultado de uma etapa do test-connection.
+type probe struct {
+	name   string
+	ok     bool
+	detail string
+	hint   string
+	took   time.Duration
+}
+
+// runTestConnection implementa "test-connection": resolve e conecta no
+// backend (ou no hub, em relay), confere o TLS e chama os endpoints usados
+// pelo agente, explicando cada falha. Sai com 1 se alguma etapa falhar.
+func runTestConnection(args []string) int {
+	fs := flag.NewFlagSet("test-connection", flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	timeout := fs.Duration("timeout", 10*time.Second, "limite por etapa")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(*cfgPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		return 1
+	}
+
+	base := cfg.APIEndpoint("")
+	if cfg.Mode() == "relay" {
+		base = strings.TrimRight(cfg.HubURL, "/")
+	}
+	fmt.Printf("testing %s (mode=%s)\n", base, cfg.Mode())
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" {
+		fmt.Fprintf(os.Stderr, "URL inválida %q\n", base)
+		return 1
+	}
+
+	var probes []probe
+	report := func(p probe) bool {
+		probes = append(probes, p)
+		status := "ok  "
+		if !p.ok {
+			status = "FAIL"
+		}
+		fmt.Printf("%s  %-10s %s (%s)\n", status, p.name, p.detail, p.took.Round(time.Millisecond))
+		if !p.ok && p.hint != "" {
+			fmt.Printf("      → %s\n", p.hint)
+		}
+		return p.ok
+	}
+
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	if !report(probeDNS(host, *timeout)) || !report(probeTCP(host, port, *timeout)) {
+		return 1
+	}
+	if u.Scheme == "https" && !report(probeTLS(host, port, *timeout)) {
+		return 1
+	}
+
+	cl := &http.Client{Timeout: *timeout}
+	if cfg.Mode() == "relay" {
+		// O hub só expõe o ingest; ping, config e bootstrap são dele.
+		report(probeHTTP(cl, cfg, "ingest", http.MethodPost, base+"/v1/ingest", "[]", "Token "+cfg.HubToken))
+	} else {
+		report(probeHTTP(cl, cfg, "bootstrap", http.MethodGet, cfg.APIEndpoint("/v1/agent/bootstrap"), "", ""))
+		report(probeHTTP(cl, cfg, "ping", http.MethodGet, cfg.APIEndpoint("/v1/agent/ping"), "", ""))
+		report(probeHTTP(cl, cfg, "config", http.MethodGet, cfg.APIEndpoint("/v1/agent/config"), "", ""))
+		report(probeHTTP(cl, cfg, "ingest", http.MethodPost, cfg.APIEndpoint("/v1/ingest"), "[]", ""))
+	}
+	for _, p := range probes {
+		if !p.ok {
+			return 1
+		}
+	}
+	return 0
+}
+
+func probeDNS(host string, timeout time.Duration) probe {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	p := probe{name: "dns", took: time.Since(start)}
+	if err != nil {
+		p.detail = err.Error()
+		p.hint = "o nome não resolve: confira API_BASE_URL/HUB_URL e o DNS do host (/etc/resolv.conf)"
+		return p
+	}
+	p.ok, p.detail = true, host+" → "+strings.Join(addrs, ", ")
+	return p
+}
+
+func probeTCP(host, port string, timeout time.Duration) probe {
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), timeout)
+	p := probe{name: "tcp", took: time.Since(start)}
+	if err != nil {
+		p.detail = err.Error()
+		p.hint = "sem conexão na porta " + port + ": firewall, proxy obrigatório ou serviço fora do ar"
+		return p
+	}
+	_ = conn.Close()
+	p.ok, p.detail = true, conn.RemoteAddr().String()
+	return p
+}
+
+func probeTLS(host, port string, timeout time.Duration) probe {
+	start := time.Now()
+	d := &net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(d, "tcp", net.JoinHostPort(host, port), &tls.Config{ServerName: host})
+	p := probe{name: "tls", took: time.Since(start)}
+	if err != nil {
+		p.detail = err.Error()
+		p.hint = "certificado não confiável, expirado ou de outro nome; um proxy fazendo inspeção TLS também causa isso"
+		return p
+	}
+	defer conn.Close()
+	st := conn.ConnectionState()
+	cert := st.PeerCertificates[0]
+	p.ok = true
+	p.detail = fmt.Sprintf("%s, cert %s até %s", tls.VersionName(st.Version), cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02"))
+	if left := time.Until(cert.NotAfter); left < 14*24*time.Hour {
+		p.detail += fmt.Sprintf(" (expira em %d dias)", int(left.Hours()/24))
+	}
+	return p
+}
+
+// probeHTTP chama um endpoint com a autenticação do agente (ou auth, se
+// dado) e traduz o status. O bootstrap vai com GET para não registrar o
+// host: 405 já prova que a rota existe.
+func probeHTTP(cl *http.Client, cfg config.Config, name, method, u, body, auth string) probe {
+	p := probe{name: name}
+	var rd io.Reader
+	if body != "" {
+		rd = bytes.NewReader([]byte(body))
+	}
+	req, err := http.NewRequest(method, u, rd)
+	if err != nil {
+		p.detail = err.Error()
+		return p
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	} else {
+		httpx.SetAuth(req, cfg)
+	}
+	start := time.Now()
+	resp, err := cl.Do(req)
+	p.took = time.Since(start)
+	if err != nil {
+		p.detail = err.Error()
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			p.hint = "sem resposta dentro do timeout: backend lento ou proxy segurando a conexão"
+		}
+		return p
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	p.detail = method + " " + req.URL.Path + " → " + resp.Status
+	code := resp.StatusCode
+	switch {
+	case code < 400, code == http.StatusMethodNotAllowed && name == "bootstrap":
+		p.ok = true
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		p.hint = "credencial recusada: confira AGENT_TOKEN (ou API_KEY/HUB_TOKEN) e se o host já foi registrado"
+	case code == http.StatusNotFound:
+		p.hint = "rota inexistente: API_BASE_URL/HUB_URL provavelmente aponta para o serviço errado"
+	case (code == http.StatusBadRequest || code == http.StatusUnprocessableEntity) && name == "ingest":
+		// Lote vazio recusado: a rota e a autenticação estão ok.
+		p.ok = true
+		p.detail += " (lote vazio recusado, rota ok)"
+	case code == http.StatusTooManyRequests:
+		p.hint = "limite de requisições atingido; tente de novo em instantes"
+	case code >= 500:
+		p.hint = "erro no servidor; se persistir, acione o suporte com o horário do teste"
+	}
+	return p
+}
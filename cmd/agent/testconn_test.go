@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTestConnection(t *testing.T) {
+	// ok responde o que o backend responde a uma chamada válida de cada rota.
+	ok := map[string]int{
+		"/v1/agent/bootstrap": http.StatusMethodNotAllowed,
+		"/v1/agent/ping":      http.StatusOK,
+		"/v1/agent/config":    http.StatusOK,
+		"/v1/ingest":          http.StatusBadRequest,
+	}
+	cases := []struct {
+		name     string
+		mode     string
+		status   map[string]int // sobrescreve ok
+		wantCode int
+		want     []string
+	}{
+		{
+			name: "tudo ok",
+			want: []string{"ok    dns", "ok    tcp", "ok    bootstrap  GET /v1/agent/bootstrap → 405", "ok    ping", "ok    config", "(lote vazio recusado, rota ok)"},
+		},
+		{
+			name:     "credencial recusada",
+			status:   map[string]int{"/v1/agent/ping": http.StatusUnauthorized},
+			wantCode: 1,
+			want:     []string{"FAIL  ping", "credencial recusada", "ok    config"},
+		},
+		{
+			name:     "rota inexistente",
+			status:   map[string]int{"/v1/agent/config": http.StatusNotFound},
+			wantCode: 1,
+			want:     []string{"FAIL  config", "rota inexistente"},
+		},
+		{
+			name:     "limite de requisições",
+			status:   map[string]int{"/v1/ingest": http.StatusTooManyRequests},
+			wantCode: 1,
+			want:     []string{"FAIL  ingest", "limite de requisições"},
+		},
+		{
+			name:     "erro no servidor",
+			status:   map[string]int{"/v1/agent/bootstrap": http.StatusBadGateway},
+			wantCode: 1,
+			want:     []string{"FAIL  bootstrap", "erro no servidor"},
+		},
+		{
+			name: "relay só testa o ingest do hub",
+			mode: "relay",
+			want: []string{"(mode=relay)", "ok    ingest"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				paths = append(paths, r.Method+" "+r.URL.Path)
+				want := "Token tok"
+				if tc.mode == "relay" {
+					want = "Token hub-tok"
+				}
+				if r.Header.Get("Authorization") != want {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				code, found := tc.status[r.URL.Path]
+				if !found {
+					code = ok[r.URL.Path]
+				}
+				w.WriteHeader(code)
+			}))
+			defer srv.Close()
+
+			cfg := "agent:\n  token: tok\napi:\n  base_url: " + srv.URL + "\n"
+			if tc.mode == "relay" {
+				cfg = "agent:\n  token: tok\n  mode: relay\nhub:\n  url: " + srv.URL + "\n  token: hub-tok\n"
+			}
+			code, out, stderr := run(t, runTestConnection, "-config", writeConfig(t, t.TempDir(), cfg), "-timeout", "5s")
+			if code != tc.wantCode {
+				t.Fatalf("exit %d, want %d\n%s%s", code, tc.wantCode, out, stderr)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(out, w) {
+					t.Fatalf("output lacks %q:\n%s", w, out)
+				}
+			}
+			if tc.mode == "relay" && strings.Join(paths, ",") != "POST /v1/ingest" {
+				t.Fatalf("relay probed %v", paths)
+			}
+		})
+	}
+}
+
+// Sem conexão, as etapas HTTP nem rodam.
+func TestTestConnectionUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+	cfg := writeConfig(t, t.TempDir(), "agent:\n  token: tok\napi:\n  base_url: http://"+addr+"\n")
+	code, out, _ := run(t, runTestConnection, "-config", cfg, "-timeout", "2s")
+	if code != 1 || !strings.Contains(out, "FAIL  tcp") || !strings.Contains(out, "sem conexão na porta") || strings.Contains(out, "bootstrap") {
+		t.Fatalf("exit %d:\n%s", code, out)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+// runValidateConfig implementa o subcomando "validate-config": carrega arquivo
+// e env como o agente faria, lista todos os problemas e retorna 1 se houver algum.
+func runValidateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	cfgPath := fs.String("config", *configPath, "path to config file (YAML or JSON)")
+	skipToken := fs.Bool("skip-token", false, "não exige AGENT_TOKEN (útil em pipelines sem o token do host)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*cfgPath)
+	var problems []string
+	for _, e := range flatten(err) {
+		if *skipToken && errors.Is(e, config.ErrNoToken) {
+			continue
+		}
+		problems = append(problems, strings.Split(e.Error(), "\n")...)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "config inválida (%d problema(s)):\n", len(problems))
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "  - %s\n", p)
+		}
+		return 1
+	}
+	for _, w := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "aviso: %s\n", w)
+	}
+	src := *cfgPath
+	if src == "" {
+		src = "env"
+	}
+	fmt.Printf("config ok: %s (mode=%s)\n", src, cfg.Mode())
+	return 0
+}
+
+// flatten abre erros combinados com errors.Join.
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if j, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []error
+		for _, e := range j.Unwrap() {
+			out = append(out, flatten(e)...)
+		}
+		return out
+	}
+	return []error{err}
+}
@@ -2,11 +2,17 @@ module github.com/you/aiceberg_agent
 
 go 1.24.0
 
-require github.com/shirou/gopsutil/v3 v3.24.5
+require (
+	github.com/beevik/ntp v1.5.0
+	github.com/distatus/battery v0.11.0
+	github.com/klauspost/compress v1.18.0
+	github.com/shirou/gopsutil/v3 v3.24.5
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/sys v0.36.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/beevik/ntp v1.5.0 // indirect
-	github.com/distatus/battery v0.11.0 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
 	github.com/lufia/plan9stats v0.0.0-20211012122336-39d0f177ccd0 // indirect
 	github.com/power-devops/perfstat v0.0.0-20210106213030-5aafc221ea8c // indirect
@@ -15,6 +21,5 @@ require (
 	github.com/tklauser/numcpus v0.6.1 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	golang.org/x/net v0.44.0 // indirect
-	golang.org/x/sys v0.36.0 // indirect
 	howett.net/plist v1.0.0 // indirect
 )
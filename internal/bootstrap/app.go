@@ -6,17 +6,24 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/host"
 
 	"github.com/you/aiceberg_agent/internal/common/config"
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/common/scheduler"
 	"github.com/you/aiceberg_agent/internal/common/version"
 	"github.com/you/aiceberg_agent/internal/data/local/outbox"
 	"github.com/you/aiceberg_agent/internal/data/local/prefs"
@@ -24,22 +31,106 @@ import (
 	"github.com/you/aiceberg_agent/internal/data/repositories"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 	"github.com/you/aiceberg_agent/internal/domain/usecase"
+	"github.com/you/aiceberg_agent/internal/interfaces/control"
 	"github.com/you/aiceberg_agent/internal/interfaces/health"
 	"github.com/you/aiceberg_agent/internal/interfaces/hub"
+	"github.com/you/aiceberg_agent/internal/platform/collectors/journald"
 	"github.com/you/aiceberg_agent/internal/platform/collectors/oslogs"
 	"github.com/you/aiceberg_agent/internal/platform/collectors/sysmetrics"
+	"github.com/you/aiceberg_agent/internal/platform/forensics"
+	"github.com/you/aiceberg_agent/internal/platform/response"
+	"github.com/you/aiceberg_agent/internal/platform/scripts"
 )
 
-func Run(cfg config.Config, log logger.Logger) error {
-	ctx := context.Background()
+// agent reúne os componentes em execução. Stores e identidade vivem o
+// processo inteiro; collectors, transports e listeners são reconstruídos
+// quando o reload de config altera chaves que os afetam (reload.go).
+type agent struct {
+	cfg        config.Config
+	cfgPath    string
+	log        logger.Logger
+	authHeader string
 
-	// Adapters mínimos
-	store := outbox.NewMemStore()
-	outboxRepo := repositories.NewOutboxRepository(store)
-	prefStore := prefs.NewStore(cfg.PrefsPath)
-	_, _ = prefStore.Load()
+	prefStore  *prefs.Store
+	dlq        *outbox.DeadLetterStore
+	store      *outbox.QuotaStore
+	outboxRepo ports.OutboxRepo
+	osStore    *outbox.QuotaStore
+	osRepo     ports.OutboxRepo
+	closers    []func()
+	events     *usecase.EmitEvent
 
-	mode := cfg.Mode()
+	sched     *scheduler.Scheduler
+	stopSched context.CancelFunc
+	// schedDrained fecha quando as execuções dos schedulers substituídos
+	// (reload) terminam; nil sem reload.
+	schedDrained <-chan struct{}
+
+	flushUC       *usecase.FlushOutbox
+	replayUC      *usecase.ReplayDeadLetter
+	osLogFlushUC  *usecase.FlushOutbox
+	osLogReplayUC *usecase.ReplayDeadLetter
+	pingUC        *usecase.PingBackend
+	configSyncUC  *usecase.ConfigSync
+	// ingestTx é o transport do flushUC; o upload de artefatos o compartilha.
+	ingestTx ports.Transport
+
+	healthSrv *http.Server
+	hubSrv    *http.Server
+	tPing     *time.Ticker
+	tCfgSync  *time.Ticker
+
+	commands       *usecase.CommandChannel
+	scripts        *usecase.RunScript
+	actions        *usecase.ResponseActions
+	artifacts      *usecase.CollectArtifacts
+	cmdRunning     atomic.Bool
+	restartPending atomic.Bool
+	calls          chan call
+	controlSrv     *control.Server
+	tap            *control.Tap
+	restart        chan string
+
+	now func() time.Time // relógio da saúde
+	// collectors é enabledCollectors; os testes trocam por fakes.
+	collectors func(cfg config.Config) []collectorJob
+	startedAt  time.Time
+	loopBeat   atomic.Int64 // UnixNano da última iteração do loop principal
+	healthView atomic.Pointer[healthView]
+}
+
+// Run executa o agente até SIGTERM/SIGINT. cfgPath (pode ser vazio) é relido
+// no SIGHUP ou quando o arquivo muda.
+func Run(cfg config.Config, cfgPath string, log logger.Logger) error {
+	// SIGTERM/SIGINT cancelam ctx; um segundo sinal mata o processo (stop restaura o default).
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	a := &agent{cfg: cfg, cfgPath: cfgPath, log: log, calls: make(chan call), restart: make(chan string, 1), now: time.Now, startedAt: time.Now()}
+	a.collectors = a.enabledCollectors
+	a.loopBeat.Store(a.startedAt.UnixNano())
+	defer a.close()
+	for _, w := range cfg.Warnings() {
+		log.Warn("config warning", "detail", w)
+	}
+	// Tira NOTIFY_SOCKET do ambiente antes de qualquer processo filho.
+	notifySocket()
+
+	// Adapters mínimos
+	store, closeStore, err := openStore(cfg, cfg.OutboxPath)
+	if err != nil {
+		return err
+	}
+	a.closers = append(a.closers, closeStore)
+	a.store = store
+	exportDepth("main", store)
+	a.tap = control.NewTap()
+	a.outboxRepo = a.tap.Wrap(repositories.NewOutboxRepository(store))
+	a.dlq = outbox.NewDeadLetterStore(cfg.DeadLetterPath, cfg.DeadLetterMaxItems)
+	a.prefStore = prefs.NewStore(cfg.PrefsPath)
+	p, _ := a.prefStore.Load()
+	metrics.AgentInfo.Set(1, version.Version)
+	usecase.SetConfigVersion(p.Version)
 
 	if !cfg.SkipBootstrap {
 		if err := bootstrap(ctx, cfg, log); err != nil {
@@ -47,13 +138,108 @@ func Run(cfg config.Config, log logger.Logger) error {
 		}
 	}
 
-	// Use cases
-	authHeader := ""
 	if cfg.Agent.Token != "" {
-		authHeader = "Token " + cfg.Agent.Token
+		a.authHeader = "Token " + cfg.Agent.Token
 	} else if cfg.APIKey != "" {
-		authHeader = "Bearer " + cfg.APIKey
+		a.authHeader = "Bearer " + cfg.APIKey
+	}
+	a.events = usecase.NewEmitEvent(a.outboxRepo, log, a.authHeader)
+
+	if _, err := a.ensureOSLogStore(); err != nil {
+		return err
+	}
+	a.buildDelivery()
+	// Collectors rodam fora do loop principal: um collector lento não atrasa o flush.
+	a.startCollectors(ctx)
+	a.publishHealth()
+	a.startHealth()
+	a.startHub()
+	a.startTickers()
+	defer a.stopTickers()
+
+	tFlush := time.NewTicker(15 * time.Second)
+	defer tFlush.Stop()
+
+	reload := make(chan string, 1)
+	a.watchReload(ctx, reload)
+
+	a.commands = usecase.NewCommandChannel(cfg, agentID(cfg), log)
+	a.scripts = usecase.NewRunScript(scripts.New(cfg), a.events, log, cfg.ScriptsEnabled, cfg.ScriptsMaxConcurrent)
+	a.actions = usecase.NewResponseActions(cfg, response.NewFirewall(cfg.ActionsFirewall), response.NewProcessKiller(), a.events, log)
+	// Mesmo com as ações desabilitadas: bloqueios de uma execução anterior
+	// ainda precisam expirar.
+	a.actions.Restore()
+	tActions := time.NewTicker(actionsExpireInterval)
+	defer tActions.Stop()
+	a.artifacts = usecase.NewCollectArtifacts(cfg, forensics.New(cfg), transport.NewArtifactUploader(cfg, a.ingestTx), a.events, log)
+	a.artifacts.Resume(ctx)
+	a.registerCommands()
+	a.startCommands(ctx)
+
+	a.startControl()
+
+	log.Info("agent started")
+	sdNotify("READY=1")
+	a.startWatchdog(ctx)
+
+	for {
+		a.loopBeat.Store(time.Now().UnixNano())
+		select {
+		case <-ctx.Done():
+			stop()
+			a.shutdown()
+			return nil
+		case <-tFlush.C:
+			_ = a.replayUC.Execute(ctx)
+			_ = a.flushUC.Execute(ctx)
+			if a.osLogFlushUC != nil {
+				_ = a.osLogReplayUC.Execute(ctx)
+				_ = a.osLogFlushUC.Execute(ctx)
+			}
+		case <-tActions.C:
+			a.actions.Expire()
+		case <-readTick(a.tPing):
+			_ = a.pingUC.Execute(ctx)
+		case <-readTick(a.tCfgSync):
+			_ = a.configSyncUC.Execute(ctx)
+		case src := <-reload:
+			_, _ = a.reload(ctx, src)
+		case c := <-a.calls:
+			out, err := c.fn()
+			c.reply <- callResult{out: out, err: err}
+		case <-a.restart:
+			log.Info("restart requested by backend")
+			stop()
+			a.shutdown()
+			return ErrRestart
+		}
+	}
+}
+
+// ensureOSLogStore abre o outbox de logs quando a coleta (oslogs ou
+// journald) está habilitada e ele ainda não existe; retorna true se abriu
+// agora.
+func (a *agent) ensureOSLogStore() (bool, error) {
+	logs := (a.cfg.OSLogEnabled && len(a.cfg.OSLogFiles) > 0) || a.cfg.JournaldEnabled
+	if a.osStore != nil || !logs {
+		return false, nil
+	}
+	st, closeSt, err := openStore(a.cfg, a.cfg.OSLogOutboxPath)
+	if err != nil {
+		return false, err
 	}
+	a.closers = append(a.closers, closeSt)
+	a.osStore = st
+	exportDepth("oslogs", st)
+	a.osRepo = a.tap.Wrap(repositories.NewOutboxRepository(st))
+	return true, nil
+}
+
+// buildDelivery (re)cria transports e os use cases que falam com a API/hub.
+func (a *agent) buildDelivery() {
+	cfg := a.cfg
+	mode := cfg.Mode()
+	backoff := retry.Backoff{Base: cfg.RetryBaseDelay, Max: cfg.RetryMaxDelay}
 
 	var tx ports.Transport
 	if mode == "relay" {
@@ -61,89 +247,292 @@ func Run(cfg config.Config, log logger.Logger) error {
 	} else {
 		tx = transport.NewHTTPJSONClient(cfg)
 	}
+	a.flushUC = usecase.NewFlushOutbox("main", a.outboxRepo, tx, a.dlq.For("ingest"), a.log, a.authHeader, backoff)
+	a.ingestTx = tx
+	a.replayUC = usecase.NewReplayDeadLetter(a.dlq.For("ingest"), a.outboxRepo, a.log)
+	a.pingUC = usecase.NewPingBackend(cfg, a.log)
+	a.configSyncUC = usecase.NewConfigSync(cfg, a.log, a.prefStore)
 
-	collector := sysmetrics.New(outboxRepo.Len, prefStore.Get)
-	collectUC := usecase.NewCollectAndBuffer(collector, outboxRepo, log, authHeader)
-	flushUC := usecase.NewFlushOutbox(outboxRepo, tx, log, authHeader)
-	pingUC := usecase.NewPingBackend(cfg, log)
-	configSyncUC := usecase.NewConfigSync(cfg, log, prefStore)
-
-	var osLogCollectUC *usecase.CollectAndBuffer
-	var osLogFlushUC *usecase.FlushOutbox
-	if cfg.OSLogEnabled && len(cfg.OSLogFiles) > 0 {
-		osStore := outbox.NewMemStore()
-		osRepo := repositories.NewOutboxRepository(osStore)
-		osCollector := oslogs.New(cfg)
-		osLogCollectUC = usecase.NewCollectAndBuffer(osCollector, osRepo, log, authHeader)
+	if a.osRepo != nil {
 		var osTx ports.Transport
 		if mode == "relay" {
 			osTx = transport.NewHubClient(cfg)
 		} else {
 			osTx = transport.NewHTTPLogsClient(cfg)
 		}
-		osLogFlushUC = usecase.NewFlushOutbox(osRepo, osTx, log, authHeader)
+		a.osLogFlushUC = usecase.NewFlushOutbox("oslogs", a.osRepo, osTx, a.dlq.For("logs"), a.log, a.authHeader, backoff)
+		a.osLogReplayUC = usecase.NewReplayDeadLetter(a.dlq.For("logs"), a.osRepo, a.log)
 	}
+}
 
-	if cfg.HealthPort > 0 {
-		go health.Serve(cfg.HealthPort, log)
+// startCollectors cria um scheduler novo com os collectors habilitados. Após
+// um reload ele só começa quando as execuções do anterior terminam (ou após
+// collectorsDrainTimeout): duas instâncias do mesmo collector não leem os
+// mesmos arquivos e cursores ao mesmo tempo.
+func (a *agent) startCollectors(ctx context.Context) {
+	cfg := a.cfg
+	jobs := a.collectors(cfg)
+	sched := scheduler.New(a.log)
+	for _, j := range jobs {
+		addCollector(sched, cfg, j.c, usecase.NewCollectAndBuffer(j.c, j.repo, a.log, a.authHeader), a.prefStore.Get)
+	}
+	schedCtx, cancel := context.WithCancel(ctx)
+	a.sched, a.stopSched = sched, cancel
+	var ready chan struct{}
+	if drained := a.schedDrained; drained != nil {
+		ready = make(chan struct{})
+		go func() {
+			defer close(ready)
+			t := time.NewTimer(collectorsDrainTimeout)
+			defer t.Stop()
+			select {
+			case <-drained:
+			case <-t.C:
+				a.log.Error("collectors: previous runs still going, starting new scheduler anyway", "waited", collectorsDrainTimeout)
+			case <-schedCtx.Done():
+			}
+		}()
 	}
+	sched.StartAfter(schedCtx, ready)
+	// Linhas novas nos arquivos antecipam a coleta (inotify no Linux).
+	for _, j := range jobs {
+		if w, ok := j.c.(ports.Waker); ok {
+			name := j.c.Name()
+			go w.Watch(schedCtx, func() { sched.RunNow(name) })
+		}
+	}
+}
+
+// collectorJob é um collector e o outbox em que ele grava.
+type collectorJob struct {
+	c    ports.Collector
+	repo ports.OutboxRepo
+}
 
-	if mode == "hub" {
-		addr := cfg.HubListenAddr
-		if addr == "" {
-			addr = ":9090"
+// enabledCollectors cria os collectors habilitados em cfg.
+func (a *agent) enabledCollectors(cfg config.Config) []collectorJob {
+	quotas := a.quotas()
+	dropped := func() int64 {
+		var n int64
+		for _, q := range quotas {
+			n += q.Dropped()
 		}
-		go hub.ServeHub(addr, cfg, outboxRepo, log)
+		return n
+	}
+	var jobs []collectorJob
+	if cfg.SysmetricsEnabled {
+		jobs = append(jobs, collectorJob{sysmetrics.New(cfg.SysmetricsInterval, a.outboxRepo.Len, dropped, a.prefStore.Get), a.outboxRepo})
+	}
+	if cfg.OSLogEnabled && a.osRepo != nil {
+		jobs = append(jobs, collectorJob{oslogs.New(cfg), a.osRepo})
+	}
+	if cfg.JournaldEnabled && a.osRepo != nil {
+		jobs = append(jobs, collectorJob{journald.New(cfg), a.osRepo})
 	}
+	return jobs
+}
 
-	tCollect := time.NewTicker(10 * time.Second)
-	tFlush := time.NewTicker(15 * time.Second)
-	var tPing *time.Ticker
-	var tCfgSync *time.Ticker
-	var tOsCollect *time.Ticker
-	if mode != "relay" {
-		tPing = time.NewTicker(cfg.PingInterval)
-		tCfgSync = time.NewTicker(cfg.ConfigSyncInterval)
+// stopCollectors cancela o scheduler atual sem esperar as execuções em
+// andamento (roda no loop principal); schedDrained avisa quando terminarem.
+func (a *agent) stopCollectors() {
+	a.stopSched()
+	prev, prevDrained := a.sched, a.schedDrained
+	drained := make(chan struct{})
+	go func() {
+		prev.Wait()
+		if prevDrained != nil {
+			<-prevDrained
+		}
+		close(drained)
+	}()
+	a.schedDrained = drained
+}
+
+func (a *agent) startHealth() {
+	if a.cfg.HealthPort > 0 {
+		a.healthSrv = health.Serve(a.cfg.HealthPort, a, a.log)
 	}
-	if osLogCollectUC != nil {
-		tOsCollect = time.NewTicker(cfg.OSLogInterval)
+}
+
+func (a *agent) startHub() {
+	if a.cfg.Mode() != "hub" {
+		return
 	}
-	defer tCollect.Stop()
-	defer tFlush.Stop()
-	if tPing != nil {
-		defer tPing.Stop()
+	addr := a.cfg.HubListenAddr
+	if addr == "" {
+		addr = ":9090"
 	}
-	if tCfgSync != nil {
-		defer tCfgSync.Stop()
+	a.hubSrv = hub.ServeHub(addr, a.cfg, a.outboxRepo, a.log)
+}
+
+// stopServer encerra srv (se houver) respeitando SHUTDOWN_GRACE.
+func (a *agent) stopServer(srv *http.Server) {
+	if srv == nil {
+		return
 	}
-	if tOsCollect != nil {
-		defer tOsCollect.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), a.cfg.ShutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		a.log.Error("shutdown listener failed", "addr", srv.Addr, "err", err)
 	}
+}
 
-	log.Info("agent started")
+// startTickers cria os tickers de ping e config sync (não usados em relay).
+func (a *agent) startTickers() {
+	if a.cfg.Mode() == "relay" {
+		return
+	}
+	a.tPing = time.NewTicker(a.cfg.PingInterval)
+	a.tCfgSync = time.NewTicker(a.cfg.ConfigSyncInterval)
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			log.Info("shutdown")
-			return nil
-		case <-tCollect.C:
-			_ = collectUC.Execute(ctx)
-		case <-tFlush.C:
-			_ = flushUC.Execute(ctx)
-			if osLogFlushUC != nil {
-				_ = osLogFlushUC.Execute(ctx)
+func (a *agent) stopTickers() {
+	if a.tPing != nil {
+		a.tPing.Stop()
+	}
+	if a.tCfgSync != nil {
+		a.tCfgSync.Stop()
+	}
+}
+
+func (a *agent) quotas() []*outbox.QuotaStore {
+	out := []*outbox.QuotaStore{a.store}
+	if a.osStore != nil {
+		out = append(out, a.osStore)
+	}
+	return out
+}
+
+// queueMap retorna os outboxes abertos pelo nome da fila (main, oslogs).
+func (a *agent) queueMap() map[string]*outbox.QuotaStore {
+	out := map[string]*outbox.QuotaStore{"main": a.store}
+	if a.osStore != nil {
+		out["oslogs"] = a.osStore
+	}
+	return out
+}
+
+func (a *agent) close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+}
+
+// shutdown encerra listeners, espera os collectors (já cancelados via ctx) e
+// faz um flush final, tudo limitado a SHUTDOWN_GRACE. O que não sair fica no
+// outbox em disco (bbolt) para o próximo start.
+func (a *agent) shutdown() {
+	cfg, log := a.cfg, a.log
+	log.Info("shutdown", "grace", cfg.ShutdownGrace)
+	sdNotify("STOPPING=1")
+	a.stopControl()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownGrace)
+	defer cancel()
+
+	for _, srv := range []*http.Server{a.healthSrv, a.hubSrv} {
+		if srv == nil {
+			continue
+		}
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error("shutdown listener failed", "addr", srv.Addr, "err", err)
+		}
+	}
+
+	flushes := []*usecase.FlushOutbox{a.flushUC}
+	if a.osLogFlushUC != nil {
+		flushes = append(flushes, a.osLogFlushUC)
+	}
+	done := make(chan struct{})
+	go func() {
+		a.sched.Wait()
+		if a.schedDrained != nil {
+			<-a.schedDrained
+		}
+		for _, f := range flushes {
+			if err := f.Drain(ctx); err != nil {
+				log.Error("shutdown flush failed", "err", err)
 			}
-		case <-readTick(tPing):
-			_ = pingUC.Execute(ctx)
-		case <-readTick(tCfgSync):
-			_ = configSyncUC.Execute(ctx)
-		case <-readTick(tOsCollect):
-			if osLogCollectUC != nil {
-				_ = osLogCollectUC.Execute(ctx)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Error("shutdown: grace period exceeded")
+	}
+
+	pending := 0
+	for _, st := range a.quotas() {
+		n, _ := st.Len()
+		pending += n
+	}
+	if pending > 0 {
+		if cfg.OutboxBackend == "mem" {
+			log.Error("shutdown: pending envelopes discarded (OUTBOX_BACKEND=mem)", "pending", pending)
+		} else {
+			log.Info("shutdown: pending envelopes kept in the outbox", "pending", pending)
+		}
+	}
+	log.Info("shutdown complete")
+}
+
+// addCollector registra o collector no scheduler usando o próprio Interval().
+// Com as prefs em pausa a execução é pulada (o oslogs não avança o cursor).
+func addCollector(s *scheduler.Scheduler, cfg config.Config, c ports.Collector, uc *usecase.CollectAndBuffer, getPrefs func() config.CollectPrefs) {
+	s.Add(scheduler.Job{
+		Name:     c.Name(),
+		Interval: c.Interval(),
+		Timeout:  cfg.CollectTimeout,
+		Run: func(ctx context.Context) error {
+			if getPrefs().Paused {
+				return nil
 			}
+			return uc.Execute(ctx)
+		},
+	})
+}
+
+// openStore escolhe o backend do outbox conforme OUTBOX_BACKEND (bbolt|mem)
+// e aplica as quotas configuradas.
+func openStore(cfg config.Config, path string) (*outbox.QuotaStore, func(), error) {
+	if cfg.OutboxBackend == "mem" {
+		return outbox.NewQuotaStore(outbox.NewMemStore(), limits(cfg)), func() {}, nil
+	}
+	st, err := outbox.NewBoltStore(path)
+	if err != nil {
+		return nil, nil, errors.New("outbox open " + path + ": " + err.Error())
+	}
+	return outbox.NewQuotaStore(st, limits(cfg)), func() { _ = st.Close() }, nil
+}
+
+// exportDepth publica itens/bytes do outbox nas métricas a cada scrape.
+func exportDepth(queue string, st *outbox.QuotaStore) {
+	metrics.OnScrape(func() {
+		n, b := st.Len()
+		metrics.OutboxItems.Set(float64(n), queue)
+		metrics.OutboxBytes.Set(float64(b), queue)
+	})
+}
+
+func limits(cfg config.Config) outbox.Limits {
+	return outbox.Limits{
+		MaxItems:     cfg.OutboxMaxItems,
+		MaxBytes:     cfg.OutboxMaxBytes,
+		MaxAge:       cfg.OutboxMaxAge,
+		Policy:       cfg.OutboxOverflow,
+		KindPriority: cfg.OutboxKindPriority,
+	}
+}
+
+// Enroll registra o host no backend (subcomando enroll), persistindo token e
+// estado; com force refaz o registro mesmo havendo estado salvo.
+func Enroll(ctx context.Context, cfg config.Config, log logger.Logger, force bool) error {
+	if force {
+		if err := os.Remove(cfg.Agent.StatePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
 		}
 	}
+	return bootstrap(ctx, cfg, log)
 }
 
 func bootstrap(ctx context.Context, cfg config.Config, log logger.Logger) error {
@@ -154,7 +543,7 @@ func bootstrap(ctx context.Context, cfg config.Config, log logger.Logger) error
 	hostname, _ := os.Hostname()
 
 	// Se já existe estado persistido com mesmo token/host, pula bootstrap.
-	if st, err := loadBootstrapState(); err == nil {
+	if st, err := loadBootstrapState(cfg.Agent.StatePath); err == nil {
 		if st.Token == cfg.Agent.Token {
 			log.Info("bootstrap skipped (state found)")
 			return nil
@@ -191,8 +580,8 @@ func bootstrap(ctx context.Context, cfg config.Config, log logger.Logger) error
 		respBody, _ := io.ReadAll(resp.Body)
 		return errors.New("bootstrap rejected: " + resp.Status + " body=" + string(respBody))
 	}
-	_ = persistToken(cfg.Agent.Token)
-	_ = persistBootstrapState(cfg.Agent.Token, hi.HostID)
+	_ = persistToken(cfg.Agent.TokenPath, cfg.Agent.Token)
+	_ = persistBootstrapState(cfg.Agent.StatePath, cfg.Agent.Token, hi.HostID)
 	log.Info("bootstrap ok")
 	return nil
 }
@@ -231,11 +620,7 @@ func firstIP() string {
 	return ""
 }
 
-func persistToken(token string) error {
-	path := os.Getenv("AGENT_TOKEN_PATH")
-	if path == "" {
-		path = "./data/agent.token"
-	}
+func persistToken(path, token string) error {
 	_ = os.MkdirAll(filepath.Dir(path), 0o755)
 	return os.WriteFile(path, []byte(token), 0o600)
 }
@@ -245,21 +630,13 @@ type bootstrapState struct {
 	HostGUID string `json:"host_guid,omitempty"`
 }
 
-func persistBootstrapState(token, hostGUID string) error {
-	path := os.Getenv("AGENT_STATE_PATH")
-	if path == "" {
-		path = "./data/bootstrap.ok"
-	}
+func persistBootstrapState(path, token, hostGUID string) error {
 	_ = os.MkdirAll(filepath.Dir(path), 0o755)
 	payload, _ := json.Marshal(bootstrapState{Token: token, HostGUID: hostGUID})
 	return os.WriteFile(path, payload, 0o600)
 }
 
-func loadBootstrapState() (bootstrapState, error) {
-	path := os.Getenv("AGENT_STATE_PATH")
-	if path == "" {
-		path = "./data/bootstrap.ok"
-	}
+func loadBootstrapState(path string) (bootstrapState, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return bootstrapState{}, err
@@ -271,6 +648,16 @@ func loadBootstrapState() (bootstrapState, error) {
 	return st, nil
 }
 
+// agentID identifica o agente nos comandos assinados: o host_guid enviado no
+// bootstrap ou, se o host não tem GUID, o hostname (o agent_id dos envelopes).
+func agentID(cfg config.Config) string {
+	if st, err := loadBootstrapState(cfg.Agent.StatePath); err == nil && st.HostGUID != "" {
+		return st.HostGUID
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
 func readTick(t *time.Ticker) <-chan time.Time {
 	if t == nil {
 		return nil
@@ -0,0 +1,224 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+	"github.com/you/aiceberg_agent/internal/data/local/prefs"
+	"github.com/you/aiceberg_agent/internal/data/repositories"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/usecase"
+)
+
+// nopLogger descarta tudo.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)  {}
+func (nopLogger) Info(string, ...any)   {}
+func (nopLogger) Warn(string, ...any)   {}
+func (nopLogger) Error(string, ...any)  {}
+func (nopLogger) Fatal(string, ...any)  {}
+func (nopLogger) SetLevel(string) error { return nil }
+func (nopLogger) Redact(...string)      {}
+func (nopLogger) Sync()                 {}
+
+// slowCollector é um collector cuja primeira execução só termina quando
+// release fecha, mesmo com ctx cancelado, como um collector preso em E/S.
+// active conta as execuções simultâneas entre todas as instâncias.
+type slowCollector struct {
+	name    string
+	release chan struct{}
+	started chan struct{} // recebe um sinal por execução
+	active  *atomic.Int32
+	overlap *atomic.Bool
+	once    sync.Once
+}
+
+func newSlowCollector(active *atomic.Int32, overlap *atomic.Bool) *slowCollector {
+	return &slowCollector{name: "fake", release: make(chan struct{}), started: make(chan struct{}, 100), active: active, overlap: overlap}
+}
+
+func (c *slowCollector) Name() string            { return c.name }
+func (c *slowCollector) Interval() time.Duration { return 5 * time.Millisecond }
+
+func (c *slowCollector) Collect(context.Context) ([]byte, error) {
+	if c.active.Add(1) > 1 {
+		c.overlap.Store(true)
+	}
+	defer c.active.Add(-1)
+	c.started <- struct{}{}
+	first := false
+	c.once.Do(func() { first = true })
+	if first {
+		<-c.release
+	}
+	return []byte(`{"n":1}`), nil
+}
+
+// acceptAll é um transport que aceita tudo e guarda os ids enviados.
+type acceptAll struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (t *acceptAll) SendWithAuth(_ context.Context, batch []entities.Envelope, _ string) (entities.SendResult, error) {
+	var res entities.SendResult
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, e := range batch {
+		t.sent = append(t.sent, e.ID)
+		res.Accepted = append(res.Accepted, e.ID)
+	}
+	return res, nil
+}
+
+// collectorAgent monta um agente com outbox em memória, o flush main
+// ligado a tx e os collectors dados por gen (um por start).
+func collectorAgent(t *testing.T, tx *acceptAll, gen ...*slowCollector) *agent {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.CollectTimeout = time.Minute
+	cfg.ShutdownGrace = 5 * time.Second
+	a := &agent{cfg: cfg, log: nopLogger{}, prefStore: prefs.NewStore(filepath.Join(dir, "prefs.json"))}
+	a.store = outbox.NewQuotaStore(outbox.NewMemStore(), limits(cfg))
+	a.outboxRepo = repositories.NewOutboxRepository(a.store)
+	a.dlq = outbox.NewDeadLetterStore(filepath.Join(dir, "deadletter.db"), 10)
+	a.flushUC = usecase.NewFlushOutbox("main", a.outboxRepo, tx, a.dlq.For("main"), a.log, "", retry.Backoff{})
+	var starts int
+	a.collectors = func(config.Config) []collectorJob {
+		c := gen[starts]
+		starts++
+		return []collectorJob{{c, a.outboxRepo}}
+	}
+	return a
+}
+
+func waitStart(t *testing.T, c *slowCollector, what string) {
+	t.Helper()
+	select {
+	case <-c.started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s never ran", what)
+	}
+}
+
+// No reload o scheduler novo só começa quando a execução do anterior
+// termina: as duas instâncias nunca coletam ao mesmo tempo.
+func TestCollectorsReloadHandoff(t *testing.T) {
+	var active atomic.Int32
+	var overlap atomic.Bool
+	old, next := newSlowCollector(&active, &overlap), newSlowCollector(&active, &overlap)
+	close(next.release)
+	a := collectorAgent(t, &acceptAll{}, old, next)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.startCollectors(ctx)
+	first := a.sched
+	waitStart(t, old, "the first scheduler")
+
+	a.stopCollectors()
+	a.startCollectors(ctx)
+	if a.sched == first {
+		t.Fatal("reload kept the old scheduler")
+	}
+	select {
+	case <-next.started:
+		t.Fatal("new scheduler ran while the previous run was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(old.release)
+	waitStart(t, next, "the new scheduler")
+	first.Wait()
+	if n, _ := a.store.Len(); n < 2 {
+		t.Fatalf("outbox has %d envelopes, want the old run's and the new one's", n)
+	}
+	if overlap.Load() {
+		t.Fatal("old and new collectors ran at the same time")
+	}
+	cancel()
+	a.sched.Wait()
+}
+
+// O shutdown espera a execução em andamento e entrega o que ela gravou
+// no flush final.
+func TestShutdownWaitsForCollectors(t *testing.T) {
+	var active atomic.Int32
+	var overlap atomic.Bool
+	c := newSlowCollector(&active, &overlap)
+	tx := &acceptAll{}
+	a := collectorAgent(t, tx, c)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a.startCollectors(ctx)
+	waitStart(t, c, "the collector")
+	cancel() // SIGTERM
+
+	done := make(chan struct{})
+	go func() {
+		a.shutdown()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("shutdown returned with a collector run in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(c.release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not return after the run finished")
+	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if len(tx.sent) != 1 {
+		t.Fatalf("final flush sent %v, want the in-flight run's envelope", tx.sent)
+	}
+	if n, _ := a.store.Len(); n != 0 {
+		t.Fatalf("outbox left with %d envelopes", n)
+	}
+}
+
+// Depois de dois reloads seguidos o scheduler novo espera também o mais
+// antigo.
+func TestCollectorsReloadTwice(t *testing.T) {
+	var active atomic.Int32
+	var overlap atomic.Bool
+	gens := []*slowCollector{newSlowCollector(&active, &overlap), newSlowCollector(&active, &overlap), newSlowCollector(&active, &overlap)}
+	close(gens[2].release)
+	a := collectorAgent(t, &acceptAll{}, gens...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a.startCollectors(ctx)
+	waitStart(t, gens[0], "the first scheduler")
+	a.stopCollectors()
+	a.startCollectors(ctx)
+	a.stopCollectors() // o segundo nunca chegou a rodar
+	a.startCollectors(ctx)
+
+	select {
+	case <-gens[2].started:
+		t.Fatal("third scheduler ran while the first run was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(gens[0].release)
+	close(gens[1].release)
+	waitStart(t, gens[2], "the third scheduler")
+	if overlap.Load() || len(gens[1].started) != 0 {
+		t.Fatalf("overlap %v, second scheduler runs %d", overlap.Load(), len(gens[1].started))
+	}
+	cancel()
+	a.sched.Wait()
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// ErrRestart é retornado por Run quando o backend pede restart; o processo
+// sai com código não-zero para o supervisor (systemd/SCM) subir de novo.
+var ErrRestart = errors.New("restart requested")
+
+const (
+	// flushNowTimeout limita o flush-now, que roda no loop principal.
+	flushNowTimeout = 30 * time.Second
+	// collectorsDrainTimeout limita a espera, após um reload, pelas
+	// execuções do scheduler anterior antes de iniciar o novo.
+	collectorsDrainTimeout = 30 * time.Second
+	// minPollGap evita laço apertado se o backend não segurar o long-poll.
+	minPollGap = time.Second
+	// actionsExpireInterval é a frequência de checagem do TTL das ações de resposta.
+	actionsExpireInterval = 5 * time.Second
+)
+
+// call é uma função executada no loop principal, dono do estado do agente
+// (scheduler, use cases e config em uso).
+type call struct {
+	fn    func() (any, error)
+	reply chan callResult
+}
+
+type callResult struct {
+	out any
+	err error
+}
+
+// do executa fn no loop principal e espera o resultado.
+func (a *agent) do(ctx context.Context, fn func() (any, error)) (any, error) {
+	c := call{fn: fn, reply: make(chan callResult, 1)}
+	select {
+	case a.calls <- c:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case r := <-c.reply:
+		return r.out, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// registerCommands liga os tipos de comando do backend aos handlers.
+func (a *agent) registerCommands() {
+	a.commands.Register("collect-now", func(ctx context.Context, cmd entities.Command) (any, error) {
+		var p struct {
+			Collector string `json:"collector"` // vazio = todos
+		}
+		if err := decodePayload(cmd.Payload, &p); err != nil {
+			return nil, err
+		}
+		return a.collectNow(ctx, p.Collector)
+	})
+	a.commands.Register("flush-now", func(ctx context.Context, _ entities.Command) (any, error) {
+		return a.do(ctx, func() (any, error) { return a.flushNow(ctx) })
+	})
+	a.commands.Register("reload-config", func(ctx context.Context, _ entities.Command) (any, error) {
+		return a.do(ctx, func() (any, error) { return a.reload(ctx, "command") })
+	})
+	a.commands.Register("set-log-level", func(_ context.Context, cmd entities.Command) (any, error) {
+		var p struct {
+			Level string `json:"level"`
+		}
+		if err := decodePayload(cmd.Payload, &p); err != nil {
+			return nil, err
+		}
+		return a.setLogLevel(p.Level)
+	})
+	a.commands.Register("run-script", a.scripts.Handle)
+	a.commands.Register("collect-artifacts", a.artifacts.Handle)
+	a.commands.Register(entities.ActionKillProcess, a.actions.KillProcess)
+	a.commands.Register(entities.ActionBlockIP, a.actions.BlockIP)
+	a.commands.Register(entities.ActionUnblockIP, a.actions.UnblockIP)
+	a.commands.Register(entities.ActionIsolateHost, a.actions.IsolateHost)
+	a.commands.Register(entities.ActionReleaseHost, a.actions.ReleaseHost)
+	a.commands.Register("restart", func(context.Context, entities.Command) (any, error) {
+		// O restart acontece depois do ACK (ver startCommands).
+		a.restartPending.Store(true)
+		return map[string]bool{"restarting": true}, nil
+	})
+}
+
+// collectNow antecipa a próxima execução do collector (vazio = todos).
+func (a *agent) collectNow(ctx context.Context, name string) (any, error) {
+	return a.do(ctx, func() (any, error) {
+		if !a.sched.RunNow(name) {
+			return nil, errors.New("unknown collector " + name)
+		}
+		return nil, nil
+	})
+}
+
+// setLogLevel troca o nível do log até o próximo restart ou reload que
+// altere agent.log_level.
+func (a *agent) setLogLevel(level string) (any, error) {
+	if err := a.log.SetLevel(level); err != nil {
+		return nil, err
+	}
+	a.log.Info("log level changed", "level", level)
+	return map[string]string{"level": level}, nil
+}
+
+// flushNow reenvia o dead-letter marcado e esvazia os outboxes.
+func (a *agent) flushNow(ctx context.Context) (any, error) {
+	ctx, cancel := context.WithTimeout(ctx, flushNowTimeout)
+	defer cancel()
+	_ = a.replayUC.Execute(ctx)
+	err := a.flushUC.Drain(ctx)
+	if a.osLogFlushUC != nil {
+		_ = a.osLogReplayUC.Execute(ctx)
+		if e := a.osLogFlushUC.Drain(ctx); err == nil {
+			err = e
+		}
+	}
+	pending := 0
+	for _, q := range a.quotas() {
+		n, _ := q.Len()
+		pending += n
+	}
+	return map[string]int{"pending": pending}, err
+}
+
+// startCommands inicia o long-poll de comandos se habilitado e ainda não
+// rodando. O loop termina sozinho quando o canal é desabilitado (reload).
+func (a *agent) startCommands(ctx context.Context) {
+	if a.cfg.Mode() == "relay" || !a.commands.Enabled() || !a.cmdRunning.CompareAndSwap(false, true) {
+		return
+	}
+	backoff := retry.Backoff{Base: a.cfg.RetryBaseDelay, Max: a.cfg.RetryMaxDelay}
+	go func() {
+		defer a.cmdRunning.Store(false)
+		attempt := 0
+		for ctx.Err() == nil && a.commands.Enabled() {
+			start := time.Now()
+			err := a.commands.Execute(ctx)
+			// Só com o ACK enviado e o cursor gravado: senão o restart volta
+			// na reentrega e reinicia o agente de novo.
+			if err == nil && a.restartPending.Load() {
+				notify(a.restart, "command")
+				return
+			}
+			wait := minPollGap - time.Since(start)
+			if err != nil {
+				wait = max(backoff.Delay(attempt), minPollGap)
+				attempt++
+			} else {
+				attempt = 0
+			}
+			if wait <= 0 {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+	}()
+}
+
+func decodePayload(payload json.RawMessage, v any) error {
+	if len(payload) == 0 || string(payload) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return errors.New("invalid payload: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/you/aiceberg_agent/internal/common/version"
+	"github.com/you/aiceberg_agent/internal/interfaces/control"
+)
+
+// startControl abre o socket de controle usado pelo CLI.
+func (a *agent) startControl() {
+	if a.cfg.ControlSocket == "" {
+		return
+	}
+	token, err := control.LoadOrCreateToken(a.cfg.ControlTokenPath)
+	if err == nil {
+		a.controlSrv, err = control.Serve(a.cfg.ControlSocket, token, control.Handlers{
+			Calls: map[string]control.HandlerFunc{
+				"status":    a.ctlStatus,
+				"collect":   a.ctlCollect,
+				"flush":     a.ctlFlush,
+				"pause":     a.ctlPause(true),
+				"resume":    a.ctlPause(false),
+				"log-level": a.ctlLogLevel,
+			},
+			Streams: map[string]control.StreamFunc{
+				"tail": a.ctlTail,
+			},
+		}, a.log)
+	}
+	if err != nil {
+		// Sem o socket o agente segue funcionando; só o CLI perde o acesso.
+		a.log.Error("control socket failed", "err", err)
+	}
+}
+
+func (a *agent) stopControl() {
+	if a.controlSrv != nil {
+		_ = a.controlSrv.Close()
+		a.controlSrv = nil
+	}
+}
+
+// ctlStatus monta o retrato do agente no loop principal.
+func (a *agent) ctlStatus(ctx context.Context, _ json.RawMessage) (any, error) {
+	return a.do(ctx, func() (any, error) {
+		p := a.prefStore.Get()
+		st := control.Status{
+			Version:       version.Version,
+			PID:           os.Getpid(),
+			Mode:          a.cfg.Mode(),
+			StartedAt:     a.startedAt.UTC(),
+			ConfigVersion: p.Version,
+			Paused:        p.Paused,
+			Collectors:    a.sched.Status(),
+			Health:        a.Ready(),
+		}
+		queues := a.queueMap()
+		for _, name := range slices.Sorted(maps.Keys(queues)) {
+			q := queues[name]
+			n, b := q.Len()
+			st.Queues = append(st.Queues, control.QueueStatus{Name: name, Items: n, Bytes: b, Dropped: q.Dropped()})
+		}
+		st.DeadLetter, _ = a.dlq.Len()
+		return st, nil
+	})
+}
+
+func (a *agent) ctlCollect(ctx context.Context, args json.RawMessage) (any, error) {
+	var p control.CollectArgs
+	if err := decodePayload(args, &p); err != nil {
+		return nil, err
+	}
+	return a.collectNow(ctx, p.Collector)
+}
+
+func (a *agent) ctlFlush(ctx context.Context, _ json.RawMessage) (any, error) {
+	return a.do(ctx, func() (any, error) { return a.flushNow(ctx) })
+}
+
+// ctlPause liga ou desliga o Paused das prefs. A pausa local vale até a
+// próxima versão de config vinda do backend, que traz o próprio Paused.
+func (a *agent) ctlPause(paused bool) control.HandlerFunc {
+	return func(ctx context.Context, _ json.RawMessage) (any, error) {
+		return a.do(ctx, func() (any, error) {
+			p := a.prefStore.Get()
+			if p.Paused != paused {
+				p.Paused = paused
+				if err := a.prefStore.Update(p); err != nil {
+					return nil, err
+				}
+				a.log.Info("collection paused changed", "paused", paused, "source", "control")
+			}
+			return map[string]bool{"paused": paused}, nil
+		})
+	}
+}
+
+func (a *agent) ctlLogLevel(_ context.Context, args json.RawMessage) (any, error) {
+	var p control.LogLevelArgs
+	if err := decodePayload(args, &p); err != nil {
+		return nil, err
+	}
+	return a.setLogLevel(p.Level)
+}
+
+// ctlTail envia os envelopes gravados nos outboxes a partir de agora.
+func (a *agent) ctlTail(ctx context.Context, args json.RawMessage, send func(any) error) error {
+	var p control.TailArgs
+	if err := decodePayload(args, &p); err != nil {
+		return err
+	}
+	envs, cancel := a.tap.Subscribe(p.Match)
+	defer func() {
+		if dropped := cancel(); dropped > 0 {
+			a.log.Warn("control tail dropped envelopes", "dropped", dropped)
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case env := <-envs:
+			if err := send(env); err != nil {
+				return err
+			}
+		}
+	}
+}
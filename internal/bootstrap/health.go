@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/scheduler"
+	"github.com/you/aiceberg_agent/internal/interfaces/health"
+)
+
+// loopStallAfter é quanto o loop principal pode ficar sem iterar (o flush
+// dispara a cada 15s) antes de o agente ser dado como travado.
+const loopStallAfter = 5 * time.Minute
+
+// healthView é o retrato que o servidor de saúde lê fora do loop principal;
+// publicado no start e a cada reload.
+type healthView struct {
+	cfg    config.Config
+	queues map[string]queueStats
+	sched  *scheduler.Scheduler
+}
+
+// queueStats é o que a saúde lê de um outbox.
+type queueStats interface {
+	Len() (items int, bytes int64)
+}
+
+// Garante conformidade.
+var _ health.Prober = (*agent)(nil)
+
+func (a *agent) publishHealth() {
+	queues := make(map[string]queueStats)
+	for q, st := range a.queueMap() {
+		queues[q] = st
+	}
+	a.healthView.Store(&healthView{cfg: a.cfg, queues: queues, sched: a.sched})
+}
+
+// Live cobre só o que um restart resolve: o loop principal travado.
+func (a *agent) Live() health.Report {
+	return health.NewReport(map[string]health.Component{"main_loop": a.loopHealth(a.now())})
+}
+
+// Ready inclui entrega, conectividade com o backend, ocupação do outbox e
+// erros dos collectors, cada um contra o seu limite health.*.
+func (a *agent) Ready() health.Report {
+	now := a.now()
+	c := map[string]health.Component{"main_loop": a.loopHealth(now)}
+	v := a.healthView.Load()
+	if v == nil {
+		c["bootstrap"] = health.Component{Status: health.StatusFail, Detail: "starting"}
+		return health.NewReport(c)
+	}
+	cfg := v.cfg
+	c["bootstrap"] = bootstrapHealth(cfg)
+	for q, st := range v.queues {
+		c["flush."+q] = a.sinceLast(now, metrics.LastFlushSuccess, cfg.HealthMaxFlushAge, q)
+		if code, ok := metrics.FlushBlocked.Value(q); ok && code > 0 {
+			f := c["flush."+q]
+			f.Status = health.StatusFail
+			f.Detail = fmt.Sprintf("backend refused the agent (%d %s), delivery paused", int(code), http.StatusText(int(code)))
+			c["flush."+q] = f
+		}
+		c["outbox."+q] = outboxHealth(cfg, st)
+	}
+	if cfg.Mode() == "relay" {
+		// Em relay o hub faz ping e config sync pelo agente.
+		c["ping"] = health.Component{Status: health.StatusDisabled, Detail: "relay mode"}
+		c["config_sync"] = health.Component{Status: health.StatusDisabled, Detail: "relay mode"}
+	} else {
+		c["ping"] = a.sinceLast(now, metrics.PingLastAck, cfg.HealthMaxPingAge)
+		c["config_sync"] = a.sinceLast(now, metrics.ConfigSyncLastSuccess, cfg.HealthMaxConfigSyncAge)
+	}
+	if v.sched != nil {
+		for _, s := range v.sched.Status() {
+			c["collector."+s.Name] = collectorHealth(s, cfg.HealthMaxCollectorErrors)
+		}
+	}
+	return health.NewReport(c)
+}
+
+func (a *agent) loopHealth(now time.Time) health.Component {
+	last := time.Unix(0, a.loopBeat.Load())
+	age := now.Sub(last)
+	c := health.Component{Status: health.StatusOK, LastSuccess: utcPtr(last), Value: secs(age), Threshold: secs(loopStallAfter)}
+	if age > loopStallAfter {
+		c.Status, c.Detail = health.StatusFail, "main loop stalled for "+age.Round(time.Second).String()
+	}
+	return c
+}
+
+// sinceLast compara a idade do timestamp em g com max (zero desliga o
+// limite). Sem sucesso ainda, conta desde o start do agente.
+func (a *agent) sinceLast(now time.Time, g *metrics.GaugeVec, max time.Duration, labels ...string) health.Component {
+	c := health.Component{Status: health.StatusOK}
+	last := a.startedAt
+	if ts, ok := g.Value(labels...); ok {
+		last = time.Unix(0, int64(ts*1e9))
+		c.LastSuccess = utcPtr(last)
+	}
+	age := now.Sub(last)
+	c.Value = secs(age)
+	if max <= 0 {
+		return c
+	}
+	c.Threshold = secs(max)
+	if age > max {
+		c.Status = health.StatusFail
+		if c.LastSuccess == nil {
+			c.Detail = "no success since start " + age.Round(time.Second).String() + " ago"
+		} else {
+			c.Detail = "last success " + age.Round(time.Second).String() + " ago"
+		}
+	}
+	return c
+}
+
+// outboxHealth mede a ocupação pelo maior entre itens e bytes em relação
+// às quotas; sem quotas não há o que medir.
+func outboxHealth(cfg config.Config, st queueStats) health.Component {
+	c := health.Component{Status: health.StatusOK}
+	n, b := st.Len()
+	var fill float64
+	if cfg.OutboxMaxItems > 0 {
+		fill = max(fill, 100*float64(n)/float64(cfg.OutboxMaxItems))
+	}
+	if cfg.OutboxMaxBytes > 0 {
+		fill = max(fill, 100*float64(b)/float64(cfg.OutboxMaxBytes))
+	}
+	c.Detail = fmt.Sprintf("%d items, %d bytes", n, b)
+	if cfg.OutboxMaxItems <= 0 && cfg.OutboxMaxBytes <= 0 {
+		return c
+	}
+	c.Value = &fill
+	if cfg.HealthMaxOutboxFill > 0 {
+		th := float64(cfg.HealthMaxOutboxFill)
+		c.Threshold = &th
+		if fill >= th {
+			c.Status = health.StatusFail
+		}
+	}
+	return c
+}
+
+func collectorHealth(s scheduler.Status, maxErrors int) health.Component {
+	n := float64(s.ConsecErrors)
+	c := health.Component{Status: health.StatusOK, Value: &n, Detail: s.LastError}
+	if !s.LastStart.IsZero() && s.ConsecErrors == 0 {
+		c.LastSuccess = utcPtr(s.LastStart)
+	}
+	if maxErrors > 0 {
+		th := float64(maxErrors)
+		c.Threshold = &th
+		if s.ConsecErrors >= int64(maxErrors) {
+			c.Status = health.StatusFail
+		}
+	}
+	return c
+}
+
+// bootstrapHealth reflete o registro no backend; o start já falha sem ele,
+// então aqui só confirma que o estado persistido continua lá.
+func bootstrapHealth(cfg config.Config) health.Component {
+	if cfg.SkipBootstrap {
+		return health.Component{Status: health.StatusDisabled, Detail: "skip_bootstrap"}
+	}
+	if _, err := loadBootstrapState(cfg.Agent.StatePath); err != nil {
+		return health.Component{Status: health.StatusFail, Detail: "state: " + err.Error()}
+	}
+	return health.Component{Status: health.StatusOK}
+}
+
+func secs(d time.Duration) *float64 {
+	v := d.Round(time.Millisecond).Seconds()
+	return &v
+}
+
+func utcPtr(t time.Time) *time.Time {
+	t = t.UTC()
+	return &t
+}
+
+// notifySocket lê NOTIFY_SOCKET uma vez e o tira do ambiente, para que
+// processos filhos (scripts, comandos dos collectors) não falem com o systemd.
+var notifySocket = sync.OnceValue(func() string {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	_ = os.Unsetenv("NOTIFY_SOCKET")
+	return addr
+})
+
+// sdNotify envia state ao systemd (Type=notify); sem NOTIFY_SOCKET não faz
+// nada.
+func sdNotify(state string) {
+	addr := notifySocket()
+	if addr == "" {
+		return
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.Dial("unixgram", addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte(state))
+}
+
+// startWatchdog envia WATCHDOG=1 a cada metade de WATCHDOG_USEC enquanto o
+// Live estiver ok; travado, o systemd mata e reinicia o serviço.
+func (a *agent) startWatchdog(ctx context.Context) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	t := time.NewTicker(time.Duration(usec) * time.Microsecond / 2)
+	go func() {
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			if r := a.Live(); r.Status == health.StatusOK {
+				sdNotify("WATCHDOG=1")
+			} else {
+				a.log.Error("watchdog: liveness failing, not notifying systemd")
+			}
+		}
+	}()
+}
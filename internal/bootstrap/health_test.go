@@ -0,0 +1,246 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/scheduler"
+	"github.com/you/aiceberg_agent/internal/interfaces/health"
+)
+
+// healthNow é o relógio dos testes de saúde.
+var healthNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeQueue é um outbox com ocupação fixa.
+type fakeQueue struct {
+	items int
+	bytes int64
+}
+
+func (q fakeQueue) Len() (int, int64) { return q.items, q.bytes }
+
+// stamp grava em g o instante age antes de healthNow.
+func stamp(g *metrics.GaugeVec, age time.Duration, labels ...string) {
+	g.Set(float64(healthNow.Add(-age).UnixNano())/1e9, labels...)
+}
+
+func resetHealthMetrics() {
+	for _, g := range []*metrics.GaugeVec{metrics.LastFlushSuccess, metrics.FlushBlocked, metrics.PingLastAck, metrics.ConfigSyncLastSuccess} {
+		g.Reset()
+	}
+}
+
+// healthAgent monta um agente parado com relógio em healthNow, iniciado
+// há uptime, com o loop em dia e o registro no backend gravado. edit
+// (pode ser nil) altera a config padrão.
+func healthAgent(t *testing.T, edit func(cfg *config.Config), uptime time.Duration) *agent {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.Agent.StatePath = filepath.Join(t.TempDir(), "bootstrap.ok")
+	if err := os.WriteFile(cfg.Agent.StatePath, []byte(`{"agent_id":"a1"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(&cfg)
+	}
+	a := &agent{cfg: cfg, now: func() time.Time { return healthNow }, startedAt: healthNow.Add(-uptime)}
+	a.loopBeat.Store(healthNow.Add(-time.Second).UnixNano())
+	return a
+}
+
+func TestReady(t *testing.T) {
+	cases := []struct {
+		name   string
+		edit   func(cfg *config.Config)
+		uptime time.Duration
+		queues map[string]queueStats
+		// setup grava as métricas; nil = tudo em dia.
+		setup func(a *agent)
+
+		want       string
+		component  string // componente verificado
+		wantStatus string
+		wantDetail string
+	}{
+		{name: "tudo em dia", want: health.StatusOK, component: "flush.main", wantStatus: health.StatusOK},
+		{
+			name:  "loop principal travado",
+			setup: func(a *agent) { a.loopBeat.Store(healthNow.Add(-loopStallAfter - time.Second).UnixNano()) },
+			want:  health.StatusFail, component: "main_loop", wantStatus: health.StatusFail, wantDetail: "main loop stalled for 5m1s",
+		},
+		{
+			name:  "flush atrasado",
+			setup: func(*agent) { stamp(metrics.LastFlushSuccess, 11*time.Minute, "main") },
+			want:  health.StatusFail, component: "flush.main", wantStatus: health.StatusFail, wantDetail: "last success 11m0s ago",
+		},
+		{
+			name:  "flush no limite ainda ok",
+			setup: func(*agent) { stamp(metrics.LastFlushSuccess, 10*time.Minute, "main") },
+			want:  health.StatusOK, component: "flush.main", wantStatus: health.StatusOK,
+		},
+		{
+			name:   "sem flush desde o start",
+			uptime: 11 * time.Minute,
+			setup:  func(*agent) { metrics.LastFlushSuccess.Reset() },
+			want:   health.StatusFail, component: "flush.main", wantStatus: health.StatusFail, wantDetail: "no success since start 11m0s ago",
+		},
+		{
+			name:   "limite zero desliga a idade",
+			edit:   func(cfg *config.Config) { cfg.HealthMaxFlushAge = 0 },
+			uptime: 24 * time.Hour,
+			setup:  func(*agent) { metrics.LastFlushSuccess.Reset() },
+			want:   health.StatusOK, component: "flush.main", wantStatus: health.StatusOK,
+		},
+		{
+			name:  "credenciais recusadas pausam a fila",
+			setup: func(*agent) { metrics.FlushBlocked.Set(401, "oslogs") },
+			want:  health.StatusFail, component: "flush.oslogs", wantStatus: health.StatusFail, wantDetail: "backend refused the agent (401 Unauthorized), delivery paused",
+		},
+		{
+			name:  "bloqueio zerado não falha",
+			setup: func(*agent) { metrics.FlushBlocked.Set(0, "main") },
+			want:  health.StatusOK, component: "flush.main", wantStatus: health.StatusOK,
+		},
+		{
+			name:   "outbox no limite de itens",
+			queues: map[string]queueStats{"main": fakeQueue{items: 90}},
+			edit:   func(cfg *config.Config) { cfg.OutboxMaxItems, cfg.OutboxMaxBytes, cfg.HealthMaxOutboxFill = 100, 0, 90 },
+			want:   health.StatusFail, component: "outbox.main", wantStatus: health.StatusFail, wantDetail: "90 items, 0 bytes",
+		},
+		{
+			name:   "outbox abaixo do limite",
+			queues: map[string]queueStats{"main": fakeQueue{items: 89}},
+			edit:   func(cfg *config.Config) { cfg.OutboxMaxItems, cfg.OutboxMaxBytes, cfg.HealthMaxOutboxFill = 100, 0, 90 },
+			want:   health.StatusOK, component: "outbox.main", wantStatus: health.StatusOK,
+		},
+		{
+			name:   "bytes pesam mais que itens",
+			queues: map[string]queueStats{"main": fakeQueue{items: 1, bytes: 95}},
+			edit: func(cfg *config.Config) {
+				cfg.OutboxMaxItems, cfg.OutboxMaxBytes, cfg.HealthMaxOutboxFill = 100, 100, 90
+			},
+			want: health.StatusFail, component: "outbox.main", wantStatus: health.StatusFail,
+		},
+		{
+			name:   "outbox sem quotas",
+			queues: map[string]queueStats{"main": fakeQueue{items: 1 << 20}},
+			edit:   func(cfg *config.Config) { cfg.OutboxMaxItems, cfg.OutboxMaxBytes = 0, 0 },
+			want:   health.StatusOK, component: "outbox.main", wantStatus: health.StatusOK,
+		},
+		{
+			name:  "ping atrasado",
+			setup: func(*agent) { stamp(metrics.PingLastAck, 6*time.Minute) },
+			want:  health.StatusFail, component: "ping", wantStatus: health.StatusFail, wantDetail: "last success 6m0s ago",
+		},
+		{
+			name:  "config sync atrasado",
+			setup: func(*agent) { stamp(metrics.ConfigSyncLastSuccess, 16*time.Minute) },
+			want:  health.StatusFail, component: "config_sync", wantStatus: health.StatusFail, wantDetail: "last success 16m0s ago",
+		},
+		{
+			name:   "relay não faz ping",
+			edit:   func(cfg *config.Config) { cfg.AgentMode = "relay" },
+			uptime: 24 * time.Hour,
+			setup: func(*agent) {
+				metrics.PingLastAck.Reset()
+				stamp(metrics.LastFlushSuccess, time.Minute, "main")
+				stamp(metrics.LastFlushSuccess, time.Minute, "oslogs")
+			},
+			want: health.StatusOK, component: "ping", wantStatus: health.StatusDisabled, wantDetail: "relay mode",
+		},
+		{
+			name: "registro sem estado gravado",
+			edit: func(cfg *config.Config) { cfg.Agent.StatePath = filepath.Join(os.TempDir(), "missing", "bootstrap.ok") },
+			want: health.StatusFail, component: "bootstrap", wantStatus: health.StatusFail, wantDetail: "state: ",
+		},
+		{
+			name: "registro desligado",
+			edit: func(cfg *config.Config) { cfg.SkipBootstrap = true },
+			want: health.StatusOK, component: "bootstrap", wantStatus: health.StatusDisabled,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetHealthMetrics()
+			t.Cleanup(resetHealthMetrics)
+			a := healthAgent(t, tc.edit, tc.uptime)
+			for _, q := range []string{"main", "oslogs"} {
+				stamp(metrics.LastFlushSuccess, time.Minute, q)
+			}
+			stamp(metrics.PingLastAck, time.Minute)
+			stamp(metrics.ConfigSyncLastSuccess, time.Minute)
+			if tc.setup != nil {
+				tc.setup(a)
+			}
+			queues := tc.queues
+			if queues == nil {
+				queues = map[string]queueStats{"main": fakeQueue{}, "oslogs": fakeQueue{}}
+			}
+			a.healthView.Store(&healthView{cfg: a.cfg, queues: queues})
+
+			r := a.Ready()
+			if r.Status != tc.want {
+				t.Errorf("status = %s, want %s (%+v)", r.Status, tc.want, r.Components)
+			}
+			c, ok := r.Components[tc.component]
+			if !ok {
+				t.Fatalf("component %s missing: %+v", tc.component, r.Components)
+			}
+			if c.Status != tc.wantStatus || !strings.HasPrefix(c.Detail, tc.wantDetail) {
+				t.Fatalf("%s = %+v, want status %s with detail %q", tc.component, c, tc.wantStatus, tc.wantDetail)
+			}
+		})
+	}
+}
+
+// Antes do primeiro publishHealth o agente ainda não está pronto, mas o
+// Live só olha o loop.
+func TestReadyBeforeStart(t *testing.T) {
+	a := healthAgent(t, nil, 0)
+	if r := a.Ready(); r.Status != health.StatusFail || r.Components["bootstrap"].Detail != "starting" {
+		t.Fatalf("Ready = %+v", r)
+	}
+	if r := a.Live(); r.Status != health.StatusOK || len(r.Components) != 1 {
+		t.Fatalf("Live = %+v", r)
+	}
+	a.loopBeat.Store(healthNow.Add(-loopStallAfter - time.Minute).UnixNano())
+	if r := a.Live(); r.Status != health.StatusFail {
+		t.Fatalf("Live with a stalled loop = %+v", r)
+	}
+}
+
+func TestCollectorHealth(t *testing.T) {
+	last := healthNow.Add(-time.Minute)
+	cases := []struct {
+		name        string
+		st          scheduler.Status
+		maxErrors   int
+		wantStatus  string
+		wantSuccess bool
+	}{
+		{name: "nunca rodou", wantStatus: health.StatusOK, maxErrors: 3},
+		{name: "última execução ok", st: scheduler.Status{LastStart: last}, maxErrors: 3, wantStatus: health.StatusOK, wantSuccess: true},
+		{name: "abaixo do limite", st: scheduler.Status{LastStart: last, ConsecErrors: 2, LastError: "boom"}, maxErrors: 3, wantStatus: health.StatusOK},
+		{name: "no limite", st: scheduler.Status{LastStart: last, ConsecErrors: 3, LastError: "boom"}, maxErrors: 3, wantStatus: health.StatusFail},
+		{name: "limite zero desliga", st: scheduler.Status{LastStart: last, ConsecErrors: 100}, wantStatus: health.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := collectorHealth(tc.st, tc.maxErrors)
+			if c.Status != tc.wantStatus || c.Detail != tc.st.LastError {
+				t.Fatalf("component = %+v, want status %s", c, tc.wantStatus)
+			}
+			if (c.LastSuccess != nil) != tc.wantSuccess {
+				t.Fatalf("last_success = %v, want set %v", c.LastSuccess, tc.wantSuccess)
+			}
+			if *c.Value != float64(tc.st.ConsecErrors) || (c.Threshold != nil) != (tc.maxErrors > 0) {
+				t.Fatalf("value %v, threshold %v", *c.Value, c.Threshold)
+			}
+		})
+	}
+}
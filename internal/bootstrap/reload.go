@@ -0,0 +1,244 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/data/local/outbox"
+	"github.com/you/aiceberg_agent/internal/data/remote/transport"
+	"github.com/you/aiceberg_agent/internal/platform/forensics"
+	"github.com/you/aiceberg_agent/internal/platform/scripts"
+)
+
+// restartOnly são chaves que só valem com restart do processo: definem
+// stores já abertos, identidade/bootstrap ou o modo de operação.
+var restartOnly = map[string]bool{
+	"agent.token":          true,
+	"agent.token_path":     true,
+	"agent.state_path":     true,
+	"agent.mode":           true,
+	"agent.prefs_path":     true,
+	"agent.skip_bootstrap": true,
+	"queue.backend":        true,
+	"queue.path":           true,
+	"queue.oslogs_path":    true,
+	// Trocar o firewall ou o state com regras ativas deixaria regras órfãs.
+	"actions.firewall":   true,
+	"actions.state_path": true,
+	// A saída do log é aberta uma vez no start; só o nível recarrega.
+	"agent.log_format":      true,
+	"agent.log_file":        true,
+	"agent.log_max_size_mb": true,
+	"agent.log_max_backups": true,
+	"agent.log_max_age":     true,
+}
+
+// watchInterval é a frequência de checagem do arquivo de config.
+const watchInterval = 2 * time.Second
+
+// watchReload dispara reloads em out no SIGHUP e quando o arquivo de config
+// muda (mtime/tamanho). Sem arquivo, só o SIGHUP vale (relê o env).
+func (a *agent) watchReload(ctx context.Context, out chan<- string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				notify(out, "sighup")
+			}
+		}
+	}()
+	if a.cfgPath != "" {
+		go watchFile(ctx, a.cfgPath, out)
+	}
+}
+
+func watchFile(ctx context.Context, path string, out chan<- string) {
+	last, _ := os.Stat(path)
+	t := time.NewTicker(watchInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		st, err := os.Stat(path)
+		if err != nil {
+			// Arquivo sendo substituído (rename atômico de editores); tenta no próximo tick.
+			continue
+		}
+		if last == nil || !st.ModTime().Equal(last.ModTime()) || st.Size() != last.Size() {
+			last = st
+			notify(out, "file")
+		}
+	}
+}
+
+func notify(out chan<- string, src string) {
+	select {
+	case out <- src:
+	default:
+	}
+}
+
+// reload relê a config, aplica o que mudou e registra/reporta o resultado,
+// que também é retornado (comando reload-config). Em caso de erro a config
+// em uso é mantida.
+func (a *agent) reload(ctx context.Context, src string) (map[string]any, error) {
+	next, err := config.Load(a.cfgPath)
+	if err != nil {
+		msg := strings.ReplaceAll(err.Error(), "\n", "; ")
+		a.log.Error("config reload failed, keeping current config", "source", src, "err", msg)
+		return a.reportReload(src, "failed", nil, nil, msg), errors.New(msg)
+	}
+	for _, w := range next.Warnings() {
+		a.log.Warn("config warning", "detail", w)
+	}
+	changed := config.Diff(a.cfg, next)
+	if len(changed) == 0 {
+		a.log.Info("config reload: no changes", "source", src)
+		return map[string]any{"source": src, "status": "unchanged"}, nil
+	}
+	var applied, pending []string
+	for _, k := range changed {
+		if restartOnly[k] {
+			pending = append(pending, k)
+		} else {
+			applied = append(applied, k)
+		}
+	}
+	next.Keep(a.cfg, pending)
+
+	status, msg := "applied", ""
+	err = a.apply(ctx, next, applied)
+	if err != nil {
+		status, msg = "partial", err.Error()
+		a.log.Error("config reload partially applied", "source", src, "err", msg)
+	}
+	kv := []any{"source", src, "applied", strings.Join(applied, ",")}
+	if len(pending) > 0 {
+		kv = append(kv, "restart_required", strings.Join(pending, ","))
+	}
+	a.log.Info("config reload", kv...)
+	return a.reportReload(src, status, applied, pending, msg), err
+}
+
+// apply troca a config em uso por next e reinicia só os componentes afetados
+// pelas chaves alteradas.
+func (a *agent) apply(ctx context.Context, next config.Config, keys []string) error {
+	var collectors, delivery, deadletter, quotas, tickers, healthSrv, hubSrv, controlSrv bool
+	for _, k := range keys {
+		switch {
+		case k == "agent.log_level":
+			if err := a.log.SetLevel(next.Agent.LogLevel); err != nil {
+				a.log.Error("config reload: invalid log level", "err", err)
+			}
+		case strings.HasPrefix(k, "modules."):
+			collectors = true
+		case k == "api.ping_interval", k == "api.config_sync_interval":
+			tickers = true
+		case k == "health.port":
+			healthSrv = true
+		case k == "control.socket", k == "control.token_path":
+			controlSrv = true
+		case k == "api.base_url", k == "api.key", strings.HasPrefix(k, "hub."):
+			// O hub repassa /v1/agent/config para a API e autentica com HUB_TOKEN.
+			delivery, hubSrv = true, true
+		case k == "api.compression", strings.HasPrefix(k, "retry."):
+			delivery = true
+		case strings.HasPrefix(k, "queue.deadletter_"):
+			delivery, deadletter = true, true
+		case strings.HasPrefix(k, "queue."):
+			quotas = true
+		}
+	}
+	a.cfg = next
+	// Credencial nova (api.key, hub.token) passa a ser mascarada; as
+	// antigas continuam, podem estar em mensagens ainda por vir.
+	a.log.Redact(next.Secrets()...)
+	// O canal de comandos lê a config a cada poll; basta atualizar e
+	// (re)iniciar caso tenha sido habilitado agora.
+	a.commands.Update(next)
+	a.startCommands(ctx)
+	a.scripts.Update(scripts.New(next), next.ScriptsEnabled, next.ScriptsMaxConcurrent)
+	a.actions.Update(next)
+
+	var err error
+	if quotas {
+		for _, q := range a.quotas() {
+			q.SetLimits(limits(next))
+		}
+	}
+	if collectors {
+		a.stopCollectors()
+		opened, e := a.ensureOSLogStore()
+		if e != nil {
+			err = e
+		}
+		// Outbox de logs recém-aberto precisa do seu transport/flush.
+		delivery = delivery || opened
+		a.startCollectors(ctx)
+	}
+	if deadletter {
+		a.dlq = outbox.NewDeadLetterStore(next.DeadLetterPath, next.DeadLetterMaxItems)
+	}
+	if delivery {
+		a.buildDelivery()
+	}
+	// Depois do buildDelivery: o uploader usa o transport novo.
+	a.artifacts.Update(next, forensics.New(next), transport.NewArtifactUploader(next, a.ingestTx))
+	if tickers {
+		if a.tPing != nil {
+			a.tPing.Reset(next.PingInterval)
+		}
+		if a.tCfgSync != nil {
+			a.tCfgSync.Reset(next.ConfigSyncInterval)
+		}
+	}
+	if healthSrv {
+		a.stopServer(a.healthSrv)
+		a.healthSrv = nil
+		a.startHealth()
+	}
+	if controlSrv {
+		a.stopControl()
+		a.startControl()
+	}
+	if hubSrv && a.hubSrv != nil {
+		a.stopServer(a.hubSrv)
+		a.hubSrv = nil
+		a.startHub()
+	}
+	a.publishHealth()
+	return err
+}
+
+// reportReload envia o resultado do reload ao backend como evento.
+func (a *agent) reportReload(src, status string, applied, pending []string, msg string) map[string]any {
+	body := map[string]any{
+		"source": src,
+		"status": status,
+	}
+	if len(applied) > 0 {
+		body["applied"] = applied
+	}
+	if len(pending) > 0 {
+		body["restart_required"] = pending
+	}
+	if msg != "" {
+		body["error"] = msg
+	}
+	_ = a.events.Emit("config_reload", body)
+	return body
+}
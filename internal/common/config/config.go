@@ -1,23 +1,50 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
+	"runtime"
 	"strings"
 	"time"
 )
 
+// ErrNoToken indica que nem AGENT_TOKEN nem o arquivo de token foram encontrados.
+var ErrNoToken = errors.New("AGENT_TOKEN obrigatório")
+
 type AgentCfg struct {
-	LogLevel string `json:"log_level"`
-	Token    string `json:"token"`
+	LogLevel  string `json:"log_level"`
+	Token     string `json:"token"`
+	TokenPath string `json:"token_path"`
+	StatePath string `json:"state_path"`
+
+	// Saída do log: text|json, arquivo (vazio = stderr) e rotação.
+	LogFormat     string        `json:"log_format"`
+	LogFile       string        `json:"log_file"`
+	LogMaxSizeMB  int           `json:"log_max_size_mb"`
+	LogMaxBackups int           `json:"log_max_backups"`
+	LogMaxAge     time.Duration `json:"log_max_age"`
 }
 
 type Config struct {
-	Agent              AgentCfg
-	APIBaseURL         string
-	APIKey             string
-	HealthPort         int
+	Agent      AgentCfg
+	APIBaseURL string
+	APIKey     string
+	HealthPort int
+
+	// Limites do /health e /ready; zero desativa a checagem.
+	HealthMaxFlushAge        time.Duration
+	HealthMaxPingAge         time.Duration
+	HealthMaxConfigSyncAge   time.Duration
+	HealthMaxOutboxFill      int
+	HealthMaxCollectorErrors int
+
+	// ControlSocket é o socket local do CLI (status, ctl, ...); no Windows,
+	// um named pipe. Vazio desativa. ControlTokenPath guarda o token que o
+	// CLI apresenta, gerado no primeiro start.
+	ControlSocket    string
+	ControlTokenPath string
+
 	PingInterval       time.Duration
 	ConfigSyncInterval time.Duration
 	PrefsPath          string
@@ -26,12 +53,80 @@ type Config struct {
 	HubToken           string
 	HubListenAddr      string
 	SkipBootstrap      bool
+	SysmetricsEnabled  bool
 	OSLogEnabled       bool
 	OSLogFiles         []string
 	OSLogCursorPath    string
 	OSLogBatchLines    int
 	OSLogMaxBytes      int
 	OSLogInterval      time.Duration
+	OSLogWinChannels   []string
+
+	// Descoberta de OSLogFiles: entradas podem ser globs (** é recursivo) ou
+	// diretórios; OSLogExclude filtra o que elas acharem.
+	OSLogExclude        []string
+	OSLogRescanInterval time.Duration
+	OSLogMaxOpenFiles   int
+	OSLogInotify        bool
+
+	// Regras multilinha por arquivo (stack traces viram um evento só).
+	OSLogMultiline []MultilineRule
+
+	// Reconhece syslog, access log e JSON e extrai horário, host e campos.
+	OSLogParse bool
+
+	// Journal do systemd (journalctl), no mesmo formato de evento do oslogs.
+	JournaldEnabled     bool
+	JournaldUnits       []string
+	JournaldIdentifiers []string
+	JournaldPriority    string
+	JournaldCursorPath  string
+	JournaldBatchLines  int
+	JournaldInterval    time.Duration
+
+	OutboxBackend      string
+	OutboxPath         string
+	OSLogOutboxPath    string
+	DeadLetterPath     string
+	DeadLetterMaxItems int
+	OutboxMaxItems     int
+	OutboxMaxBytes     int64
+	OutboxMaxAge       time.Duration
+	OutboxOverflow     string
+	OutboxKindPriority []string
+	RetryBaseDelay     time.Duration
+	RetryMaxDelay      time.Duration
+	BreakerFailures    int
+	Compression        string
+	SysmetricsInterval time.Duration
+	CollectTimeout     time.Duration
+	ShutdownGrace      time.Duration
+	// Canal de comandos backend → agente (long-poll).
+	CommandsEnabled     bool
+	CommandsPublicKey   string
+	CommandsPollTimeout time.Duration
+	CommandsCursorPath  string
+	// Execução remota de scripts (comando run-script).
+	ScriptsEnabled       bool
+	ScriptsAllowlistPath string
+	ScriptsUser          string
+	ScriptsTimeout       time.Duration
+	ScriptsCPUTime       time.Duration
+	ScriptsMaxOutputKB   int
+	ScriptsMaxConcurrent int
+	// Ações de resposta (kill-process, block-ip, isolate-host).
+	ActionsEnabled    bool
+	ActionsFirewall   string
+	ActionsDefaultTTL time.Duration
+	ActionsMaxTTL     time.Duration
+	ActionsStatePath  string
+	// Coleta forense sob demanda (comando collect-artifacts).
+	ArtifactsEnabled      bool
+	ArtifactsDir          string
+	ArtifactsMaxFileMB    int
+	ArtifactsMaxBundleMB  int
+	ArtifactsChunkKB      int
+	ArtifactsHistoryLines int
 }
 
 type CollectPrefs struct {
@@ -55,65 +150,148 @@ type CollectPrefs struct {
 	Processes bool   `json:"processes"`
 }
 
-func Load(_ string) (Config, error) {
-	port := 0
-	if v := os.Getenv("HEALTH_PORT"); v != "" {
-		if p, err := strconv.Atoi(v); err == nil {
-			port = p
+// Load monta a configuração: defaults, depois o arquivo em path (YAML ou
+// JSON; vazio = nenhum) e por fim as variáveis de ambiente, que prevalecem.
+// Erros de leitura e de validação são reunidos num único erro.
+func Load(path string) (Config, error) {
+	cfg := Defaults()
+	var errs []error
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			errs = append(errs, err)
 		}
 	}
-	pingInterval := time.Duration(intEnv("PING_INTERVAL", 5)) * time.Second
-	cfgSyncInterval := time.Duration(intEnv("CONFIG_SYNC_INTERVAL", 30)) * time.Second
-	cfg := Config{
-		Agent:         AgentCfg{LogLevel: getenv("LOG_LEVEL", "info"), Token: loadToken()},
-		APIBaseURL:    getenv("API_BASE_URL", "https://api.aiceberg.com.br"),
-		APIKey:        getenv("API_KEY", ""),
-		HealthPort:    port,
-		PrefsPath:     getenv("PREFS_PATH", "./data/collect_prefs.json"),
-		AgentMode:     strings.ToLower(getenv("AGENT_MODE", "direct")),
-		HubURL:        getenv("HUB_URL", ""),
-		HubToken:      getenv("HUB_TOKEN", ""),
-		HubListenAddr: getenv("HUB_LISTEN_ADDR", ""),
-		SkipBootstrap: strings.ToLower(getenv("SKIP_BOOTSTRAP", "")) == "true",
-		OSLogEnabled:  strings.ToLower(getenv("OSLOG_ENABLED", "")) == "true",
-		OSLogFiles:    splitCsv(getenv("OSLOG_FILES", "")),
-		OSLogCursorPath: getenv("OSLOG_CURSOR_PATH", "./data/oslogs.cursor"),
-		OSLogBatchLines: intEnv("OSLOG_BATCH_LINES", 200),
-		OSLogMaxBytes:   intEnv("OSLOG_MAX_BYTES", 256*1024),
-		OSLogInterval:   time.Duration(intEnv("OSLOG_INTERVAL", 15)) * time.Second,
-		PingInterval: func() time.Duration {
-			if pingInterval <= 0 {
-				return 5 * time.Second
-			}
-			return pingInterval
-		}(),
-		ConfigSyncInterval: func() time.Duration {
-			if cfgSyncInterval <= 0 {
-				return 30 * time.Second
-			}
-			return cfgSyncInterval
-		}(),
+	if err := loadEnv(&cfg); err != nil {
+		errs = append(errs, err)
 	}
 	if cfg.Agent.Token == "" {
-		return cfg, fmt.Errorf("AGENT_TOKEN obrigatório")
+		cfg.Agent.Token = readToken(cfg.Agent.TokenPath)
+	}
+	normalize(&cfg)
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Agent.Token == "" {
+		errs = append(errs, ErrNoToken)
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// Defaults retorna a configuração padrão, antes de arquivo e env.
+func Defaults() Config {
+	return Config{
+		Agent: AgentCfg{
+			LogLevel:  "info",
+			TokenPath: "./data/agent.token",
+			StatePath: "./data/bootstrap.ok",
+
+			LogFormat:     "text",
+			LogMaxSizeMB:  50,
+			LogMaxBackups: 5,
+			LogMaxAge:     30 * 24 * time.Hour,
+		},
+		APIBaseURL:            "https://api.aiceberg.com.br",
+		PingInterval:          5 * time.Second,
+		ConfigSyncInterval:    30 * time.Second,
+		PrefsPath:             "./data/collect_prefs.json",
+		AgentMode:             "direct",
+		SysmetricsEnabled:     true,
+		SysmetricsInterval:    10 * time.Second,
+		OSLogCursorPath:       "./data/oslogs.cursor",
+		OSLogBatchLines:       200,
+		OSLogMaxBytes:         256 * 1024,
+		OSLogInterval:         15 * time.Second,
+		OSLogExclude:          []string{"*.gz", "*.[0-9]", "*.old", "*-[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]"},
+		OSLogRescanInterval:   time.Minute,
+		OSLogMaxOpenFiles:     256,
+		OSLogInotify:          true,
+		OSLogParse:            true,
+		JournaldCursorPath:    "./data/journald.cursor",
+		JournaldBatchLines:    200,
+		JournaldInterval:      15 * time.Second,
+		OutboxBackend:         "bbolt",
+		OutboxPath:            "./data/outbox.db",
+		OSLogOutboxPath:       "./data/outbox_oslogs.db",
+		DeadLetterPath:        "./data/deadletter.db",
+		DeadLetterMaxItems:    10000,
+		OutboxMaxBytes:        200 << 20,
+		OutboxOverflow:        "drop-oldest",
+		OutboxKindPriority:    []string{"detection", "event", "heartbeat", "metric"},
+		RetryBaseDelay:        2 * time.Second,
+		RetryMaxDelay:         300 * time.Second,
+		BreakerFailures:       5,
+		Compression:           "gzip",
+		CollectTimeout:        60 * time.Second,
+		ShutdownGrace:         10 * time.Second,
+		CommandsPollTimeout:   30 * time.Second,
+		CommandsCursorPath:    "./data/commands.cursor",
+		ScriptsAllowlistPath:  "./configs/scripts.allowlist.yml",
+		ScriptsTimeout:        5 * time.Minute,
+		ScriptsCPUTime:        time.Minute,
+		ScriptsMaxOutputKB:    1024,
+		ScriptsMaxConcurrent:  2,
+		ActionsFirewall:       "auto",
+		ActionsDefaultTTL:     time.Hour,
+		ActionsMaxTTL:         24 * time.Hour,
+		ActionsStatePath:      "./data/actions.json",
+		ArtifactsDir:          "./data/artifacts",
+		ArtifactsMaxFileMB:    100,
+		ArtifactsMaxBundleMB:  1024,
+		ArtifactsChunkKB:      1024,
+		ArtifactsHistoryLines: 1000,
+
+		HealthMaxFlushAge:        10 * time.Minute,
+		HealthMaxPingAge:         5 * time.Minute,
+		HealthMaxConfigSyncAge:   15 * time.Minute,
+		HealthMaxOutboxFill:      90,
+		HealthMaxCollectorErrors: 5,
+
+		ControlSocket:    defaultControlSocket(),
+		ControlTokenPath: "./data/control.token",
+	}
+}
+
+// loadEnv aplica sobre cfg as variáveis de ambiente definidas (não vazias).
+func loadEnv(cfg *Config) error {
+	var errs []error
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if v := os.Getenv(f.env); v != "" {
+			if err := f.setString(cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+			}
+		}
 	}
-	return cfg, nil
+	return errors.Join(errs...)
 }
 
-func getenv(k, def string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+func normalize(cfg *Config) {
+	cfg.Agent.Token = strings.TrimSpace(cfg.Agent.Token)
+	cfg.AgentMode = strings.ToLower(cfg.AgentMode)
+	cfg.OutboxBackend = strings.ToLower(cfg.OutboxBackend)
+	cfg.OutboxOverflow = strings.ToLower(cfg.OutboxOverflow)
+	cfg.Compression = strings.ToLower(cfg.Compression)
+	cfg.ActionsFirewall = strings.ToLower(cfg.ActionsFirewall)
+	// Zero volta ao default; negativos ficam para Validate apontar.
+	if cfg.PingInterval == 0 {
+		cfg.PingInterval = 5 * time.Second
+	}
+	if cfg.ConfigSyncInterval == 0 {
+		cfg.ConfigSyncInterval = 30 * time.Second
+	}
+	for i := range cfg.OSLogMultiline {
+		cfg.OSLogMultiline[i].defaults()
 	}
-	return def
 }
 
-func loadToken() string {
-	if v := os.Getenv("AGENT_TOKEN"); v != "" {
-		return v
+func readToken(path string) string {
+	if path == "" {
+		return ""
 	}
-	path := getenv("AGENT_TOKEN_PATH", "./data/agent.token")
 	if b, err := os.ReadFile(path); err == nil {
-		return string(b)
+		return strings.TrimSpace(string(b))
 	}
 	return ""
 }
@@ -129,6 +307,8 @@ func (c Config) APIEndpoint(segment string) string {
 	return base + segment
 }
 
+// Mode retorna o modo de operação; valores inválidos (rejeitados por
+// Validate) caem em direct.
 func (c Config) Mode() string {
 	switch strings.ToLower(c.AgentMode) {
 	case "hub", "relay", "direct":
@@ -138,6 +318,11 @@ func (c Config) Mode() string {
 	}
 }
 
+// Secrets lista as credenciais da config, para o logger mascarar.
+func (c Config) Secrets() []string {
+	return []string{c.Agent.Token, c.APIKey, c.HubToken}
+}
+
 func splitCsv(s string) []string {
 	if s == "" {
 		return nil
@@ -153,11 +338,11 @@ func splitCsv(s string) []string {
 	return out
 }
 
-func intEnv(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		if n, err := strconv.Atoi(v); err == nil {
-			return n
-		}
+// defaultControlSocket é o socket unix no diretório de dados ou, no
+// Windows, o named pipe do agente.
+func defaultControlSocket() string {
+	if runtime.GOOS == "windows" {
+		return `\\.\pipe\aiceberg_agent`
 	}
-	return def
+	return "./data/agent.sock"
 }
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// load roda Load com o conteúdo do arquivo (vazio = sem arquivo) e só as
+// variáveis de ambiente informadas; AGENT_TOKEN vem preenchido.
+func load(t *testing.T, name, content string, env map[string]string) (Config, error) {
+	t.Helper()
+	for _, f := range fields {
+		if f.env != "" {
+			t.Setenv(f.env, "")
+		}
+	}
+	t.Setenv("AGENT_TOKEN", "tok")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	path := ""
+	if content != "" {
+		path = writeFile(t, name, content)
+	}
+	return Load(path)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = `
+agent:
+  log_level: debug
+api:
+  base_url: https://file.example
+  ping_interval: 30
+queue:
+  max_mb: 10
+  kind_priority: [event, metric]
+retry:
+  base_delay: 500ms
+modules:
+  soc:
+    journald:
+      units: sshd.service
+`
+	cases := []struct {
+		name string
+		env  map[string]string
+		get  func(Config) any
+		want any
+	}{
+		{"default fora do arquivo", nil, func(c Config) any { return c.Compression }, "gzip"},
+		{"arquivo sobre o default", nil, func(c Config) any { return c.Agent.LogLevel }, "debug"},
+		{"env sobre o arquivo", map[string]string{"LOG_LEVEL": "warn"}, func(c Config) any { return c.Agent.LogLevel }, "warn"},
+		{"env vazio é ignorado", map[string]string{"API_BASE_URL": ""}, func(c Config) any { return c.APIBaseURL }, "https://file.example"},
+		{"duração em segundos", nil, func(c Config) any { return c.PingInterval }, 30 * time.Second},
+		{"duração Go", nil, func(c Config) any { return c.RetryBaseDelay }, 500 * time.Millisecond},
+		{"duração no env", map[string]string{"RETRY_BASE_DELAY": "2m"}, func(c Config) any { return c.RetryBaseDelay }, 2 * time.Minute},
+		{"megabytes", nil, func(c Config) any { return c.OutboxMaxBytes }, int64(10 << 20)},
+		{"megabytes no env", map[string]string{"OUTBOX_MAX_MB": "3"}, func(c Config) any { return c.OutboxMaxBytes }, int64(3 << 20)},
+		{"lista YAML", nil, func(c Config) any { return c.OutboxKindPriority }, []string{"event", "metric"}},
+		{"escalar vale como CSV", nil, func(c Config) any { return c.JournaldUnits }, []string{"sshd.service"}},
+		{"CSV no env", map[string]string{"OUTBOX_KIND_PRIORITY": " detection , event,,metric "}, func(c Config) any { return c.OutboxKindPriority }, []string{"detection", "event", "metric"}},
+		{"normaliza caixa", map[string]string{"AGENT_MODE": "Direct"}, func(c Config) any { return c.AgentMode }, "direct"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := load(t, "agent.yml", file, tc.env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tc.get(cfg); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	cfg, err := load(t, "agent.json", `{"agent": {"log_level": "error"}, "queue": {"kind_priority": ["metric"]}}`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Agent.LogLevel != "error" || !reflect.DeepEqual(cfg.OutboxKindPriority, []string{"metric"}) {
+		t.Fatalf("log_level = %q, kind_priority = %v", cfg.Agent.LogLevel, cfg.OutboxKindPriority)
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    []string
+	}{
+		{"chave desconhecida", "agent:\n  log_level: info\n  colour: red\n", nil, []string{`agent.yml:3: chave desconhecida "agent.colour"`}},
+		{"seção desconhecida", "metrics:\n  port: 9\n", nil, []string{`agent.yml:1: chave desconhecida "metrics"`}},
+		{"seção não é mapa", "agent: 5\n", nil, []string{"agent.yml:1: agent: esperado um mapa de chaves"}},
+		{"raiz não é mapa", "- a\n- b\n", nil, []string{"raiz: esperado um mapa de chaves"}},
+		{"inteiro inválido", "queue:\n  max_items: muitos\n", nil, []string{`agent.yml:2: queue.max_items: inteiro inválido "muitos"`}},
+		{"duração inválida", "api:\n  ping_interval: 5x\n", nil, []string{`api.ping_interval: duração inválida "5x"`}},
+		{"lista em campo simples", "agent:\n  log_level: [a, b]\n", nil, []string{"agent.log_level: esperado um valor simples"}},
+		{"env inválido", "", map[string]string{"OSLOG_ENABLED": "talvez"}, []string{`OSLOG_ENABLED: booleano inválido "talvez"`}},
+		{"todos os erros de uma vez", "agent:\n  colour: red\nqueue:\n  max_items: x\n", nil, []string{"chave desconhecida", "inteiro inválido"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := load(t, "agent.yml", tc.content, tc.env)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadNullSectionsAndEmptyFile(t *testing.T) {
+	for _, content := range []string{"agent:\n", "# só comentário\n", "agent:\n  log_file:\n"} {
+		cfg, err := load(t, "agent.yml", content, nil)
+		if err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		if cfg.Agent.LogLevel != "info" {
+			t.Fatalf("%q: log_level = %q, want the default", content, cfg.Agent.LogLevel)
+		}
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	tokenFile := writeFile(t, "agent.token", "  from-file\n")
+	cases := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"env", map[string]string{"AGENT_TOKEN": " from-env "}, "from-env", false},
+		{"env vence o arquivo", map[string]string{"AGENT_TOKEN": "from-env", "AGENT_TOKEN_PATH": tokenFile}, "from-env", false},
+		{"arquivo de token", map[string]string{"AGENT_TOKEN": "", "AGENT_TOKEN_PATH": tokenFile}, "from-file", false},
+		{"sem token", map[string]string{"AGENT_TOKEN": "", "AGENT_TOKEN_PATH": filepath.Join(t.TempDir(), "missing")}, "", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := load(t, "", "", tc.env)
+			if tc.wantErr != errors.Is(err, ErrNoToken) {
+				t.Fatalf("Load = %v, wantErr %v", err, tc.wantErr)
+			}
+			if cfg.Agent.Token != tc.want {
+				t.Fatalf("token = %q, want %q", cfg.Agent.Token, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import "reflect"
+
+// Diff retorna as chaves (como no arquivo, ex.: "hub.url") cujos valores
+// diferem entre a e b, na ordem da tabela de campos.
+func Diff(a, b Config) []string {
+	var out []string
+	for _, f := range fields {
+		va := reflect.ValueOf(f.ptr(&a)).Elem().Interface()
+		vb := reflect.ValueOf(f.ptr(&b)).Elem().Interface()
+		if !equal(va, vb) {
+			out = append(out, f.key)
+		}
+	}
+	return out
+}
+
+// Keep copia de src para c os valores das chaves informadas; usado no reload
+// para manter o valor em uso de chaves que só mudam com restart.
+func (c *Config) Keep(src Config, keys []string) {
+	for _, k := range keys {
+		for _, f := range fields {
+			if f.key != k {
+				continue
+			}
+			dst := reflect.ValueOf(f.ptr(c)).Elem()
+			dst.Set(reflect.ValueOf(f.ptr(&src)).Elem())
+		}
+	}
+}
+
+// equal compara valores tratando listas vazias e nil como iguais.
+func equal(a, b any) bool {
+	la, okA := a.([]string)
+	lb, okB := b.([]string)
+	if okA && okB && len(la) == 0 && len(lb) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type kind int
+
+const (
+	kString kind = iota
+	kInt
+	kBool
+	kDuration // no arquivo: "10s", "5m" ou número (segundos); no env: idem
+	kList     // no arquivo: lista YAML; no env: CSV
+	kMegabytes
+	kRules // no arquivo: lista de mapas YAML; no env: o mesmo em JSON
+)
+
+// field liga uma chave do arquivo (caminho com pontos) e sua variável de
+// ambiente a um campo de Config.
+type field struct {
+	key  string
+	env  string
+	kind kind
+	ptr  func(c *Config) any
+}
+
+var fields = []field{
+	{"agent.log_level", "LOG_LEVEL", kString, func(c *Config) any { return &c.Agent.LogLevel }},
+	{"agent.log_format", "LOG_FORMAT", kString, func(c *Config) any { return &c.Agent.LogFormat }},
+	{"agent.log_file", "LOG_FILE", kString, func(c *Config) any { return &c.Agent.LogFile }},
+	{"agent.log_max_size_mb", "LOG_MAX_SIZE_MB", kInt, func(c *Config) any { return &c.Agent.LogMaxSizeMB }},
+	{"agent.log_max_backups", "LOG_MAX_BACKUPS", kInt, func(c *Config) any { return &c.Agent.LogMaxBackups }},
+	{"agent.log_max_age", "LOG_MAX_AGE", kDuration, func(c *Config) any { return &c.Agent.LogMaxAge }},
+	{"agent.token", "AGENT_TOKEN", kString, func(c *Config) any { return &c.Agent.Token }},
+	{"agent.token_path", "AGENT_TOKEN_PATH", kString, func(c *Config) any { return &c.Agent.TokenPath }},
+	{"agent.state_path", "AGENT_STATE_PATH", kString, func(c *Config) any { return &c.Agent.StatePath }},
+	{"agent.mode", "AGENT_MODE", kString, func(c *Config) any { return &c.AgentMode }},
+	{"agent.prefs_path", "PREFS_PATH", kString, func(c *Config) any { return &c.PrefsPath }},
+	{"agent.skip_bootstrap", "SKIP_BOOTSTRAP", kBool, func(c *Config) any { return &c.SkipBootstrap }},
+	{"agent.shutdown_grace", "SHUTDOWN_GRACE", kDuration, func(c *Config) any { return &c.ShutdownGrace }},
+	{"api.base_url", "API_BASE_URL", kString, func(c *Config) any { return &c.APIBaseURL }},
+	{"api.key", "API_KEY", kString, func(c *Config) any { return &c.APIKey }},
+	{"api.ping_interval", "PING_INTERVAL", kDuration, func(c *Config) any { return &c.PingInterval }},
+	{"api.config_sync_interval", "CONFIG_SYNC_INTERVAL", kDuration, func(c *Config) any { return &c.ConfigSyncInterval }},
+	{"api.compression", "COMPRESSION", kString, func(c *Config) any { return &c.Compression }},
+	{"health.port", "HEALTH_PORT", kInt, func(c *Config) any { return &c.HealthPort }},
+	{"health.max_flush_age", "HEALTH_MAX_FLUSH_AGE", kDuration, func(c *Config) any { return &c.HealthMaxFlushAge }},
+	{"health.max_ping_age", "HEALTH_MAX_PING_AGE", kDuration, func(c *Config) any { return &c.HealthMaxPingAge }},
+	{"health.max_config_sync_age", "HEALTH_MAX_CONFIG_SYNC_AGE", kDuration, func(c *Config) any { return &c.HealthMaxConfigSyncAge }},
+	{"health.max_outbox_fill", "HEALTH_MAX_OUTBOX_FILL", kInt, func(c *Config) any { return &c.HealthMaxOutboxFill }},
+	{"health.max_collector_errors", "HEALTH_MAX_COLLECTOR_ERRORS", kInt, func(c *Config) any { return &c.HealthMaxCollectorErrors }},
+	{"control.socket", "CONTROL_SOCKET", kString, func(c *Config) any { return &c.ControlSocket }},
+	{"control.token_path", "CONTROL_TOKEN_PATH", kString, func(c *Config) any { return &c.ControlTokenPath }},
+	{"hub.url", "HUB_URL", kString, func(c *Config) any { return &c.HubURL }},
+	{"hub.token", "HUB_TOKEN", kString, func(c *Config) any { return &c.HubToken }},
+	{"hub.listen_addr", "HUB_LISTEN_ADDR", kString, func(c *Config) any { return &c.HubListenAddr }},
+	{"queue.backend", "OUTBOX_BACKEND", kString, func(c *Config) any { return &c.OutboxBackend }},
+	{"queue.path", "OUTBOX_PATH", kString, func(c *Config) any { return &c.OutboxPath }},
+	{"queue.oslogs_path", "OSLOG_OUTBOX_PATH", kString, func(c *Config) any { return &c.OSLogOutboxPath }},
+	{"queue.max_items", "OUTBOX_MAX_ITEMS", kInt, func(c *Config) any { return &c.OutboxMaxItems }},
+	{"queue.max_mb", "OUTBOX_MAX_MB", kMegabytes, func(c *Config) any { return &c.OutboxMaxBytes }},
+	{"queue.max_age", "OUTBOX_MAX_AGE", kDuration, func(c *Config) any { return &c.OutboxMaxAge }},
+	{"queue.overflow", "OUTBOX_OVERFLOW", kString, func(c *Config) any { return &c.OutboxOverflow }},
+	{"queue.kind_priority", "OUTBOX_KIND_PRIORITY", kList, func(c *Config) any { return &c.OutboxKindPriority }},
+	{"queue.deadletter_path", "DEADLETTER_PATH", kString, func(c *Config) any { return &c.DeadLetterPath }},
+	{"queue.deadletter_max_items", "DEADLETTER_MAX_ITEMS", kInt, func(c *Config) any { return &c.DeadLetterMaxItems }},
+	{"retry.base_delay", "RETRY_BASE_DELAY", kDuration, func(c *Config) any { return &c.RetryBaseDelay }},
+	{"retry.max_delay", "RETRY_MAX_DELAY", kDuration, func(c *Config) any { return &c.RetryMaxDelay }},
+	{"retry.breaker_failures", "BREAKER_FAILURES", kInt, func(c *Config) any { return &c.BreakerFailures }},
+	{"commands.enabled", "COMMANDS_ENABLED", kBool, func(c *Config) any { return &c.CommandsEnabled }},
+	{"commands.public_key", "COMMANDS_PUBLIC_KEY", kString, func(c *Config) any { return &c.CommandsPublicKey }},
+	{"commands.poll_timeout", "COMMANDS_POLL_TIMEOUT", kDuration, func(c *Config) any { return &c.CommandsPollTimeout }},
+	{"commands.cursor_path", "COMMANDS_CURSOR_PATH", kString, func(c *Config) any { return &c.CommandsCursorPath }},
+	{"scripts.enabled", "SCRIPTS_ENABLED", kBool, func(c *Config) any { return &c.ScriptsEnabled }},
+	{"scripts.allowlist_path", "SCRIPTS_ALLOWLIST_PATH", kString, func(c *Config) any { return &c.ScriptsAllowlistPath }},
+	{"scripts.user", "SCRIPTS_USER", kString, func(c *Config) any { return &c.ScriptsUser }},
+	{"scripts.timeout", "SCRIPTS_TIMEOUT", kDuration, func(c *Config) any { return &c.ScriptsTimeout }},
+	{"scripts.cpu_time", "SCRIPTS_CPU_TIME", kDuration, func(c *Config) any { return &c.ScriptsCPUTime }},
+	{"scripts.max_output_kb", "SCRIPTS_MAX_OUTPUT_KB", kInt, func(c *Config) any { return &c.ScriptsMaxOutputKB }},
+	{"scripts.max_concurrent", "SCRIPTS_MAX_CONCURRENT", kInt, func(c *Config) any { return &c.ScriptsMaxConcurrent }},
+	{"actions.enabled", "ACTIONS_ENABLED", kBool, func(c *Config) any { return &c.ActionsEnabled }},
+	{"actions.firewall", "ACTIONS_FIREWALL", kString, func(c *Config) any { return &c.ActionsFirewall }},
+	{"actions.default_ttl", "ACTIONS_DEFAULT_TTL", kDuration, func(c *Config) any { return &c.ActionsDefaultTTL }},
+	{"actions.max_ttl", "ACTIONS_MAX_TTL", kDuration, func(c *Config) any { return &c.ActionsMaxTTL }},
+	{"actions.state_path", "ACTIONS_STATE_PATH", kString, func(c *Config) any { return &c.ActionsStatePath }},
+	{"artifacts.enabled", "ARTIFACTS_ENABLED", kBool, func(c *Config) any { return &c.ArtifactsEnabled }},
+	{"artifacts.dir", "ARTIFACTS_DIR", kString, func(c *Config) any { return &c.ArtifactsDir }},
+	{"artifacts.max_file_mb", "ARTIFACTS_MAX_FILE_MB", kInt, func(c *Config) any { return &c.ArtifactsMaxFileMB }},
+	{"artifacts.max_bundle_mb", "ARTIFACTS_MAX_BUNDLE_MB", kInt, func(c *Config) any { return &c.ArtifactsMaxBundleMB }},
+	{"artifacts.chunk_kb", "ARTIFACTS_CHUNK_KB", kInt, func(c *Config) any { return &c.ArtifactsChunkKB }},
+	{"artifacts.history_lines", "ARTIFACTS_HISTORY_LINES", kInt, func(c *Config) any { return &c.ArtifactsHistoryLines }},
+	{"modules.timeout", "COLLECT_TIMEOUT", kDuration, func(c *Config) any { return &c.CollectTimeout }},
+	{"modules.noc.sysmetrics.enabled", "SYSMETRICS_ENABLED", kBool, func(c *Config) any { return &c.SysmetricsEnabled }},
+	{"modules.noc.sysmetrics.interval", "SYSMETRICS_INTERVAL", kDuration, func(c *Config) any { return &c.SysmetricsInterval }},
+	{"modules.soc.oslogs.enabled", "OSLOG_ENABLED", kBool, func(c *Config) any { return &c.OSLogEnabled }},
+	{"modules.soc.oslogs.files", "OSLOG_FILES", kList, func(c *Config) any { return &c.OSLogFiles }},
+	{"modules.soc.oslogs.cursor_path", "OSLOG_CURSOR_PATH", kString, func(c *Config) any { return &c.OSLogCursorPath }},
+	{"modules.soc.oslogs.batch_lines", "OSLOG_BATCH_LINES", kInt, func(c *Config) any { return &c.OSLogBatchLines }},
+	{"modules.soc.oslogs.max_bytes", "OSLOG_MAX_BYTES", kInt, func(c *Config) any { return &c.OSLogMaxBytes }},
+	{"modules.soc.oslogs.interval", "OSLOG_INTERVAL", kDuration, func(c *Config) any { return &c.OSLogInterval }},
+	{"modules.soc.oslogs.win_channels", "OSLOG_WIN_CHANNELS", kList, func(c *Config) any { return &c.OSLogWinChannels }},
+	{"modules.soc.oslogs.exclude", "OSLOG_EXCLUDE", kList, func(c *Config) any { return &c.OSLogExclude }},
+	{"modules.soc.oslogs.rescan_interval", "OSLOG_RESCAN_INTERVAL", kDuration, func(c *Config) any { return &c.OSLogRescanInterval }},
+	{"modules.soc.oslogs.max_open_files", "OSLOG_MAX_OPEN_FILES", kInt, func(c *Config) any { return &c.OSLogMaxOpenFiles }},
+	{"modules.soc.oslogs.inotify", "OSLOG_INOTIFY", kBool, func(c *Config) any { return &c.OSLogInotify }},
+	{"modules.soc.oslogs.multiline", "OSLOG_MULTILINE", kRules, func(c *Config) any { return &c.OSLogMultiline }},
+	{"modules.soc.oslogs.parse", "OSLOG_PARSE", kBool, func(c *Config) any { return &c.OSLogParse }},
+	{"modules.soc.journald.enabled", "JOURNALD_ENABLED", kBool, func(c *Config) any { return &c.JournaldEnabled }},
+	{"modules.soc.journald.units", "JOURNALD_UNITS", kList, func(c *Config) any { return &c.JournaldUnits }},
+	{"modules.soc.journald.identifiers", "JOURNALD_IDENTIFIERS", kList, func(c *Config) any { return &c.JournaldIdentifiers }},
+	{"modules.soc.journald.priority", "JOURNALD_PRIORITY", kString, func(c *Config) any { return &c.JournaldPriority }},
+	{"modules.soc.journald.cursor_path", "JOURNALD_CURSOR_PATH", kString, func(c *Config) any { return &c.JournaldCursorPath }},
+	{"modules.soc.journald.batch_lines", "JOURNALD_BATCH_LINES", kInt, func(c *Config) any { return &c.JournaldBatchLines }},
+	{"modules.soc.journald.interval", "JOURNALD_INTERVAL", kDuration, func(c *Config) any { return &c.JournaldInterval }},
+}
+
+// loadFile aplica o arquivo YAML (ou JSON, que é YAML válido) sobre cfg.
+// Chaves desconhecidas e valores inválidos são erros que citam a chave.
+func loadFile(cfg *Config, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil
+	}
+	byKey := make(map[string]field, len(fields))
+	sections := map[string]bool{}
+	for _, f := range fields {
+		byKey[f.key] = f
+		parts := strings.Split(f.key, ".")
+		for i := 1; i < len(parts); i++ {
+			sections[strings.Join(parts[:i], ".")] = true
+		}
+	}
+
+	var errs []error
+	var walk func(n *yaml.Node, prefix string)
+	walk = func(n *yaml.Node, prefix string) {
+		if n.Kind != yaml.MappingNode {
+			name := prefix
+			if name == "" {
+				name = "raiz"
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: %s: esperado um mapa de chaves", path, n.Line, name))
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			key := k.Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if f, ok := byKey[key]; ok {
+				if err := f.setNode(cfg, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s:%d: %s: %w", path, v.Line, key, err))
+				}
+				continue
+			}
+			if sections[key] {
+				if v.Tag == "!!null" {
+					continue
+				}
+				walk(v, key)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s:%d: chave desconhecida %q", path, k.Line, key))
+		}
+	}
+	walk(root.Content[0], "")
+	return errors.Join(errs...)
+}
+
+func (f field) setNode(cfg *Config, n *yaml.Node) error {
+	if n.Tag == "!!null" {
+		return nil
+	}
+	switch f.kind {
+	case kList:
+		out, err := nodeList(n)
+		if err != nil {
+			return err
+		}
+		*f.ptr(cfg).(*[]string) = out
+		return nil
+	case kRules:
+		rules, err := decodeRules(n)
+		if err != nil {
+			return err
+		}
+		*f.ptr(cfg).(*[]MultilineRule) = rules
+		return nil
+	}
+	if n.Kind != yaml.ScalarNode {
+		return errors.New("esperado um valor simples")
+	}
+	return f.setString(cfg, n.Value)
+}
+
+func (f field) setString(cfg *Config, v string) error {
+	v = strings.TrimSpace(v)
+	switch p := f.ptr(cfg).(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("inteiro inválido %q", v)
+		}
+		*p = n
+	case *int64:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("inteiro inválido %q", v)
+		}
+		if f.kind == kMegabytes {
+			n <<= 20
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("booleano inválido %q", v)
+		}
+		*p = b
+	case *time.Duration:
+		d, err := parseDuration(v)
+		if err != nil {
+			return err
+		}
+		*p = d
+	case *[]string:
+		*p = splitCsv(v)
+	case *[]MultilineRule:
+		// JSON é YAML válido: o env passa pelo mesmo decodificador do arquivo.
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(v), &root); err != nil {
+			return fmt.Errorf("JSON inválido: %w", err)
+		}
+		if len(root.Content) == 0 {
+			*p = nil
+			return nil
+		}
+		rules, err := decodeRules(root.Content[0])
+		if err != nil {
+			return err
+		}
+		*p = rules
+	}
+	return nil
+}
+
+// nodeList lê uma lista YAML de valores simples; um escalar vale como CSV.
+func nodeList(n *yaml.Node) ([]string, error) {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		var out []string
+		for _, it := range n.Content {
+			if it.Kind != yaml.ScalarNode {
+				return nil, errors.New("esperada lista de valores simples")
+			}
+			if s := strings.TrimSpace(it.Value); s != "" {
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	case yaml.ScalarNode:
+		return splitCsv(strings.TrimSpace(n.Value)), nil
+	}
+	return nil, errors.New("esperada uma lista")
+}
+
+// parseDuration aceita número (segundos, como nas variáveis de ambiente) ou
+// duração Go ("10s", "5m").
+func parseDuration(v string) (time.Duration, error) {
+	if n, err := strconv.Atoi(v); err == nil {
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("duração inválida %q (use segundos ou 10s, 5m)", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MultilineRule junta várias linhas de um arquivo do oslogs num só evento
+// (stack trace Java, traceback Python). Files são padrões (filepath.Match,
+// contra o caminho ou só o nome) dos arquivos em que a regra vale; vazio
+// vale para todos, e a primeira regra que casar é a usada.
+//
+// Uma linha que casa Start abre um evento novo; as outras continuam o
+// evento aberto se casam Continue (ou sempre, sem Continue). Sem Start,
+// só Continue decide. O evento fecha ao chegar em MaxLines linhas ou
+// quando o arquivo fica Timeout sem escrita.
+type MultilineRule struct {
+	Files    []string
+	Start    string
+	Continue string
+	MaxLines int
+	Timeout  time.Duration
+}
+
+func (r *MultilineRule) defaults() {
+	if r.MaxLines == 0 {
+		r.MaxLines = 500
+	}
+	if r.Timeout == 0 {
+		r.Timeout = 5 * time.Second
+	}
+}
+
+// check devolve os problemas da regra, um por item.
+func (r MultilineRule) check() []string {
+	var out []string
+	if r.Start == "" && r.Continue == "" {
+		out = append(out, "informe start e/ou continue")
+	}
+	for _, re := range [][2]string{{"start", r.Start}, {"continue", r.Continue}} {
+		if re[1] == "" {
+			continue
+		}
+		if _, err := regexp.Compile(re[1]); err != nil {
+			out = append(out, fmt.Sprintf("%s: regex inválida: %v", re[0], err))
+		}
+	}
+	for _, p := range r.Files {
+		if err := checkPattern(p); err != nil {
+			out = append(out, fmt.Sprintf("files: %s: %v", p, err))
+		}
+	}
+	if r.MaxLines < 0 {
+		out = append(out, "max_lines não pode ser negativo")
+	}
+	if r.Timeout < 0 {
+		out = append(out, "timeout não pode ser negativo")
+	}
+	return out
+}
+
+// decodeRules lê a lista de regras (chaves files, start, continue,
+// max_lines, timeout); chaves desconhecidas são erro.
+func decodeRules(n *yaml.Node) ([]MultilineRule, error) {
+	if n.Kind != yaml.SequenceNode {
+		return nil, errors.New("esperada uma lista de regras")
+	}
+	var out []MultilineRule
+	for i, it := range n.Content {
+		if it.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("regra %d: esperado um mapa de chaves", i+1)
+		}
+		var r MultilineRule
+		for j := 0; j+1 < len(it.Content); j += 2 {
+			key, v := it.Content[j].Value, it.Content[j+1]
+			if err := r.set(key, v); err != nil {
+				return nil, fmt.Errorf("regra %d: %s: %w", i+1, key, err)
+			}
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (r *MultilineRule) set(key string, v *yaml.Node) error {
+	if key == "files" {
+		files, err := nodeList(v)
+		r.Files = files
+		return err
+	}
+	if v.Kind != yaml.ScalarNode {
+		return errors.New("esperado um valor simples")
+	}
+	s := strings.TrimSpace(v.Value)
+	switch key {
+	case "start":
+		r.Start = s
+	case "continue":
+		r.Continue = s
+	case "max_lines":
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("inteiro inválido %q", s)
+		}
+		r.MaxLines = n
+	case "timeout":
+		d, err := parseDuration(s)
+		if err != nil {
+			return err
+		}
+		r.Timeout = d
+	default:
+		return errors.New("chave desconhecida (use files, start, continue, max_lines, timeout)")
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate confere a configuração já carregada e retorna todos os problemas
+// encontrados de uma vez (errors.Join), cada um citando a chave e a env.
+func (c Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", name(key), fmt.Sprintf(format, args...)))
+	}
+
+	oneOf(bad, "agent.mode", c.AgentMode, "direct", "hub", "relay")
+	oneOf(bad, "agent.log_level", strings.ToLower(c.Agent.LogLevel), "debug", "info", "warn", "error")
+	oneOf(bad, "agent.log_format", strings.ToLower(c.Agent.LogFormat), "text", "json")
+	if c.Agent.LogMaxSizeMB < 0 {
+		bad("agent.log_max_size_mb", "não pode ser negativo")
+	}
+	if c.Agent.LogMaxBackups < 0 {
+		bad("agent.log_max_backups", "não pode ser negativo")
+	}
+	if c.Agent.LogMaxAge < 0 {
+		bad("agent.log_max_age", "não pode ser negativo")
+	}
+	oneOf(bad, "queue.backend", c.OutboxBackend, "bbolt", "mem")
+	oneOf(bad, "queue.overflow", c.OutboxOverflow, "drop-oldest", "drop-newest", "drop-by-kind")
+	oneOf(bad, "api.compression", c.Compression, "gzip", "zstd", "none")
+
+	mode := strings.ToLower(c.AgentMode)
+	if c.APIBaseURL == "" {
+		if mode != "relay" {
+			bad("api.base_url", "obrigatório")
+		}
+	} else if err := checkURL(c.APIBaseURL); err != nil {
+		bad("api.base_url", "%v", err)
+	}
+	if c.HubURL == "" {
+		if mode == "relay" {
+			bad("hub.url", "obrigatório no modo relay")
+		}
+	} else if err := checkURL(c.HubURL); err != nil {
+		bad("hub.url", "%v", err)
+	}
+	if c.HubListenAddr != "" {
+		if _, _, err := net.SplitHostPort(c.HubListenAddr); err != nil {
+			bad("hub.listen_addr", "endereço inválido %q (use host:porta ou :porta)", c.HubListenAddr)
+		}
+	}
+	if c.HealthPort < 0 || c.HealthPort > 65535 {
+		bad("health.port", "porta inválida %d", c.HealthPort)
+	}
+	switch {
+	case c.ControlSocket == "":
+	case runtime.GOOS == "windows":
+		if !strings.HasPrefix(c.ControlSocket, `\\.\pipe\`) {
+			bad("control.socket", "no Windows deve ser um named pipe (\\\\.\\pipe\\nome)")
+		}
+	case len(c.ControlSocket) > 100:
+		// sun_path tem 104 bytes no macOS e 108 no Linux.
+		bad("control.socket", "caminho longo demais para um socket unix (máx. 100 caracteres)")
+	}
+	if c.ControlSocket != "" && c.ControlTokenPath == "" {
+		bad("control.token_path", "obrigatório com o socket de controle ativo")
+	}
+	if c.HealthMaxFlushAge < 0 {
+		bad("health.max_flush_age", "não pode ser negativo")
+	}
+	if c.HealthMaxPingAge < 0 {
+		bad("health.max_ping_age", "não pode ser negativo")
+	}
+	if c.HealthMaxConfigSyncAge < 0 {
+		bad("health.max_config_sync_age", "não pode ser negativo")
+	}
+	if c.HealthMaxOutboxFill < 0 || c.HealthMaxOutboxFill > 100 {
+		bad("health.max_outbox_fill", "deve estar entre 0 e 100 (%%)")
+	}
+	if c.HealthMaxCollectorErrors < 0 {
+		bad("health.max_collector_errors", "não pode ser negativo")
+	}
+
+	positive := map[string]time.Duration{
+		"api.ping_interval":               c.PingInterval,
+		"api.config_sync_interval":        c.ConfigSyncInterval,
+		"modules.noc.sysmetrics.interval": c.SysmetricsInterval,
+		"modules.soc.oslogs.interval":     c.OSLogInterval,
+		"modules.soc.journald.interval":   c.JournaldInterval,
+		"modules.timeout":                 c.CollectTimeout,
+		"agent.shutdown_grace":            c.ShutdownGrace,
+		"retry.base_delay":                c.RetryBaseDelay,
+		"retry.max_delay":                 c.RetryMaxDelay,
+	}
+	for _, f := range fields {
+		if d, ok := positive[f.key]; ok && d <= 0 {
+			bad(f.key, "deve ser maior que zero (atual %s)", d)
+		}
+	}
+	if c.RetryMaxDelay > 0 && c.RetryBaseDelay > c.RetryMaxDelay {
+		bad("retry.max_delay", "menor que retry.base_delay (%s < %s)", c.RetryMaxDelay, c.RetryBaseDelay)
+	}
+	if c.OutboxMaxAge < 0 {
+		bad("queue.max_age", "não pode ser negativo")
+	}
+	if c.OutboxMaxItems < 0 {
+		bad("queue.max_items", "não pode ser negativo")
+	}
+	if c.OutboxMaxBytes < 0 {
+		bad("queue.max_mb", "não pode ser negativo")
+	}
+	if c.DeadLetterMaxItems < 0 {
+		bad("queue.deadletter_max_items", "não pode ser negativo")
+	}
+	if c.BreakerFailures < 0 {
+		bad("retry.breaker_failures", "não pode ser negativo")
+	}
+
+	if c.CommandsEnabled {
+		if c.CommandsPublicKey == "" {
+			bad("commands.public_key", "obrigatório com o canal de comandos habilitado")
+		} else if _, err := c.CommandsKey(); err != nil {
+			bad("commands.public_key", "%v", err)
+		}
+		if c.CommandsPollTimeout <= 0 {
+			bad("commands.poll_timeout", "deve ser maior que zero")
+		}
+	}
+
+	if c.ScriptsEnabled {
+		if !c.CommandsEnabled {
+			bad("scripts.enabled", "requer commands.enabled (scripts chegam pelo canal de comandos)")
+		}
+		if err := checkReadable(c.ScriptsAllowlistPath); err != nil {
+			bad("scripts.allowlist_path", "%v", err)
+		}
+		if c.ScriptsUser != "" && runtime.GOOS == "windows" {
+			bad("scripts.user", "não suportado no Windows")
+		}
+		if c.ScriptsTimeout <= 0 {
+			bad("scripts.timeout", "deve ser maior que zero")
+		}
+		if c.ScriptsCPUTime < 0 {
+			bad("scripts.cpu_time", "não pode ser negativo")
+		}
+		if c.ScriptsMaxOutputKB <= 0 {
+			bad("scripts.max_output_kb", "deve ser maior que zero")
+		}
+		if c.ScriptsMaxConcurrent <= 0 {
+			bad("scripts.max_concurrent", "deve ser maior que zero")
+		}
+	}
+
+	if c.ActionsEnabled {
+		if !c.CommandsEnabled {
+			bad("actions.enabled", "requer commands.enabled (ações chegam pelo canal de comandos)")
+		}
+		oneOf(bad, "actions.firewall", c.ActionsFirewall, "auto", "nftables", "iptables")
+		if c.ActionsDefaultTTL <= 0 {
+			bad("actions.default_ttl", "deve ser maior que zero")
+		}
+		if c.ActionsMaxTTL < c.ActionsDefaultTTL {
+			bad("actions.max_ttl", "não pode ser menor que actions.default_ttl")
+		}
+		if c.ActionsStatePath == "" {
+			bad("actions.state_path", "obrigatório com as ações habilitadas")
+		}
+	}
+
+	if c.ArtifactsEnabled {
+		if !c.CommandsEnabled {
+			bad("artifacts.enabled", "requer commands.enabled (pedidos chegam pelo canal de comandos)")
+		}
+		if c.ArtifactsDir == "" {
+			bad("artifacts.dir", "obrigatório com a coleta forense habilitada")
+		}
+		if c.ArtifactsMaxFileMB <= 0 {
+			bad("artifacts.max_file_mb", "deve ser maior que zero")
+		}
+		if c.ArtifactsMaxBundleMB < c.ArtifactsMaxFileMB {
+			bad("artifacts.max_bundle_mb", "não pode ser menor que artifacts.max_file_mb")
+		}
+		if c.ArtifactsChunkKB <= 0 || c.ArtifactsChunkKB > 16*1024 {
+			bad("artifacts.chunk_kb", "deve estar entre 1 e 16384")
+		}
+		if c.ArtifactsHistoryLines < 0 {
+			bad("artifacts.history_lines", "não pode ser negativo")
+		}
+	}
+
+	if c.OSLogEnabled {
+		if c.OSLogBatchLines <= 0 {
+			bad("modules.soc.oslogs.batch_lines", "deve ser maior que zero")
+		}
+		if c.OSLogMaxBytes <= 0 {
+			bad("modules.soc.oslogs.max_bytes", "deve ser maior que zero")
+		}
+		// No Windows a coleta usa o Event Log (win_channels), não arquivos.
+		if runtime.GOOS != "windows" {
+			if len(c.OSLogFiles) == 0 {
+				bad("modules.soc.oslogs.files", "nenhum arquivo configurado com a coleta habilitada")
+			}
+			for _, p := range c.OSLogFiles {
+				// Globs podem não casar nada ainda: só a sintaxe é conferida.
+				if hasGlob(p) {
+					if err := checkPattern(p); err != nil {
+						bad("modules.soc.oslogs.files", "%s: %v", p, err)
+					}
+				} else if err := checkReadablePath(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					// Ausente não é erro (ver Warnings): o collector segue o
+					// caminho e lê o arquivo quando ele aparecer.
+					bad("modules.soc.oslogs.files", "%v", err)
+				}
+			}
+			for _, p := range c.OSLogExclude {
+				if err := checkPattern(p); err != nil {
+					bad("modules.soc.oslogs.exclude", "%s: %v", p, err)
+				}
+			}
+			if c.OSLogRescanInterval <= 0 {
+				bad("modules.soc.oslogs.rescan_interval", "deve ser maior que zero (atual %s)", c.OSLogRescanInterval)
+			}
+			if c.OSLogMaxOpenFiles <= 0 {
+				bad("modules.soc.oslogs.max_open_files", "deve ser maior que zero")
+			}
+			for i, r := range c.OSLogMultiline {
+				for _, msg := range r.check() {
+					bad("modules.soc.oslogs.multiline", "regra %d: %s", i+1, msg)
+				}
+			}
+		}
+	}
+
+	if c.JournaldEnabled {
+		if runtime.GOOS != "linux" {
+			bad("modules.soc.journald.enabled", "disponível só no Linux")
+		} else if _, err := exec.LookPath("journalctl"); err != nil {
+			bad("modules.soc.journald.enabled", "journalctl não encontrado no PATH")
+		}
+		if c.JournaldPriority != "" && !validPriority(c.JournaldPriority) {
+			bad("modules.soc.journald.priority", "valor inválido %q (use 0-7, emerg..debug ou um intervalo como err..warning)", c.JournaldPriority)
+		}
+		if c.JournaldBatchLines <= 0 {
+			bad("modules.soc.journald.batch_lines", "deve ser maior que zero")
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Warnings lista problemas que não impedem a carga da config: caminhos
+// literais de oslogs.files que ainda não existem.
+func (c Config) Warnings() []string {
+	var out []string
+	if !c.OSLogEnabled || runtime.GOOS == "windows" {
+		return nil
+	}
+	for _, p := range c.OSLogFiles {
+		if hasGlob(p) {
+			continue
+		}
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			out = append(out, fmt.Sprintf("%s: %s não existe; será lido quando for criado", name("modules.soc.oslogs.files"), p))
+		}
+	}
+	return out
+}
+
+// CommandsKey decodifica a chave pública Ed25519 (base64) usada para
+// verificar a assinatura dos comandos do backend.
+func (c Config) CommandsKey() (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(c.CommandsPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("chave pública inválida (esperado base64): %v", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("chave pública inválida: %d bytes (esperado %d, Ed25519)", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// name formata a chave do arquivo com a env correspondente, ex.:
+// "hub.url (HUB_URL)".
+func name(key string) string {
+	for _, f := range fields {
+		if f.key == key && f.env != "" {
+			return key + " (" + f.env + ")"
+		}
+	}
+	return key
+}
+
+func oneOf(bad func(key, format string, args ...any), key, v string, allowed ...string) {
+	for _, a := range allowed {
+		if v == a {
+			return
+		}
+	}
+	bad(key, "valor inválido %q (use %s)", v, strings.Join(allowed, ", "))
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("URL inválida %q", raw)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL inválida %q (esperado http:// ou https://)", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL inválida %q (sem host)", raw)
+	}
+	return nil
+}
+
+// hasGlob diz se p tem metacaracteres de glob.
+func hasGlob(p string) bool { return strings.ContainsAny(p, "*?[") }
+
+// checkPattern confere a sintaxe de um glob (** vale como um segmento).
+func checkPattern(p string) error {
+	_, err := filepath.Match(strings.ReplaceAll(p, "**", "*"), "")
+	return err
+}
+
+// checkReadablePath aceita um arquivo ou um diretório legível.
+func checkReadablePath(path string) error {
+	if st, err := os.Stat(path); err == nil && st.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		return f.Close()
+	}
+	return checkReadable(path)
+}
+
+func checkReadable(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.IsDir() {
+		return fmt.Errorf("%s: é um diretório", path)
+	}
+	return nil
+}
+
+// validPriority aceita o que o journalctl -p aceita: nível (número ou nome)
+// ou intervalo "de..até".
+func validPriority(p string) bool {
+	levels := []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+	for _, part := range strings.SplitN(p, "..", 2) {
+		if n, err := strconv.Atoi(part); err == nil && n >= 0 && n <= 7 {
+			continue
+		}
+		if !slices.Contains(levels, part) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key := base64.StdEncoding.EncodeToString(pub)
+	cases := []struct {
+		name string
+		edit func(c *Config)
+		// want são trechos esperados no erro; vazio = config válida.
+		want []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"modo inválido", func(c *Config) { c.AgentMode = "mesh" }, []string{`agent.mode (AGENT_MODE): valor inválido "mesh"`}},
+		{"nível inválido", func(c *Config) { c.Agent.LogLevel = "trace" }, []string{"agent.log_level (LOG_LEVEL)"}},
+		{"base_url obrigatória", func(c *Config) { c.APIBaseURL = "" }, []string{"api.base_url (API_BASE_URL): obrigatório"}},
+		{"relay dispensa base_url", func(c *Config) { c.APIBaseURL, c.AgentMode, c.HubURL = "", "relay", "http://hub:8080" }, nil},
+		{"relay exige hub.url", func(c *Config) { c.AgentMode = "relay" }, []string{"hub.url (HUB_URL): obrigatório no modo relay"}},
+		{"URL sem esquema", func(c *Config) { c.APIBaseURL = "api.example" }, []string{"esperado http:// ou https://"}},
+		{"listen_addr sem porta", func(c *Config) { c.HubListenAddr = "0.0.0.0" }, []string{"hub.listen_addr"}},
+		{"porta fora do intervalo", func(c *Config) { c.HealthPort = 70000 }, []string{"porta inválida 70000"}},
+		{"intervalo zero", func(c *Config) { c.SysmetricsInterval = 0 }, []string{"modules.noc.sysmetrics.interval (SYSMETRICS_INTERVAL): deve ser maior que zero"}},
+		{"max_delay menor que base", func(c *Config) { c.RetryBaseDelay, c.RetryMaxDelay = time.Minute, time.Second }, []string{"retry.max_delay (RETRY_MAX_DELAY): menor que retry.base_delay"}},
+		{"outbox_fill acima de 100", func(c *Config) { c.HealthMaxOutboxFill = 101 }, []string{"health.max_outbox_fill"}},
+		{"socket sem token", func(c *Config) { c.ControlTokenPath = "" }, []string{"control.token_path"}},
+		{"comandos sem chave", func(c *Config) { c.CommandsEnabled = true }, []string{"commands.public_key (COMMANDS_PUBLIC_KEY): obrigatório"}},
+		{"chave curta", func(c *Config) { c.CommandsEnabled, c.CommandsPublicKey = true, "AAAA" }, []string{"3 bytes (esperado 32, Ed25519)"}},
+		{"chave válida", func(c *Config) { c.CommandsEnabled, c.CommandsPublicKey = true, key }, nil},
+		{"scripts sem comandos", func(c *Config) {
+			c.ScriptsEnabled, c.ScriptsAllowlistPath = true, writeFile(t, "allow.yml", "scripts: []\n")
+		}, []string{"scripts.enabled (SCRIPTS_ENABLED): requer commands.enabled"}},
+		{"allowlist ausente", func(c *Config) {
+			c.CommandsEnabled, c.CommandsPublicKey, c.ScriptsEnabled = true, key, true
+			c.ScriptsAllowlistPath = filepath.Join(t.TempDir(), "missing.yml")
+		}, []string{"scripts.allowlist_path"}},
+		{"ttl máximo menor que o default", func(c *Config) {
+			c.CommandsEnabled, c.CommandsPublicKey, c.ActionsEnabled = true, key, true
+			c.ActionsMaxTTL = time.Minute
+		}, []string{"actions.max_ttl"}},
+		{"chunk grande demais", func(c *Config) {
+			c.CommandsEnabled, c.CommandsPublicKey, c.ArtifactsEnabled = true, key, true
+			c.ArtifactsChunkKB = 32 * 1024
+		}, []string{"artifacts.chunk_kb"}},
+		{"vários erros de uma vez", func(c *Config) { c.AgentMode, c.Compression = "x", "lz4" }, []string{"agent.mode", "api.compression"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Defaults()
+			tc.edit(&c)
+			err := c.Validate()
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate = nil, want an error")
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOSLogFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no Windows a coleta usa o Event Log")
+	}
+	dir := t.TempDir()
+	present := writeFile(t, "syslog", "x\n")
+	unreadable := filepath.Join(dir, "secure")
+	if err := os.WriteFile(unreadable, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.log")
+	cases := []struct {
+		name     string
+		files    []string
+		exclude  []string
+		want     string
+		warnings int
+		// perm: o caso depende de permissão de leitura (root lê tudo).
+		perm bool
+	}{
+		{"arquivo e diretório", []string{present, dir + "/"}, nil, "", 0, false},
+		{"ausente só avisa", []string{missing}, nil, "", 1, false},
+		{"glob que não casa nada", []string{dir + "/*.log"}, nil, "", 0, false},
+		{"glob inválido", []string{dir + "/[a.log"}, nil, "syntax error in pattern", 0, false},
+		{"exclude inválido", []string{present}, []string{"[x"}, "modules.soc.oslogs.exclude", 0, false},
+		{"sem arquivos", nil, nil, "nenhum arquivo configurado", 0, false},
+		{"sem permissão", []string{unreadable}, nil, "permission denied", 0, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.perm && os.Geteuid() == 0 {
+				t.Skip("root lê qualquer arquivo")
+			}
+			c := Defaults()
+			c.OSLogEnabled, c.OSLogFiles, c.OSLogExclude = true, tc.files, tc.exclude
+			err := c.Validate()
+			switch {
+			case tc.want == "" && err != nil:
+				t.Fatalf("Validate = %v, want nil", err)
+			case tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)):
+				t.Fatalf("Validate = %v, want %q", err, tc.want)
+			}
+			w := c.Warnings()
+			if len(w) != tc.warnings {
+				t.Fatalf("Warnings = %q, want %d", w, tc.warnings)
+			}
+			for _, msg := range w {
+				if !strings.Contains(msg, missing) {
+					t.Errorf("warning %q does not name the missing path", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestValidPriority(t *testing.T) {
+	cases := map[string]bool{
+		"3":            true,
+		"err":          true,
+		"err..warning": true,
+		"0..7":         true,
+		"8":            false,
+		"error":        false,
+		"err..":        false,
+	}
+	for in, want := range cases {
+		if got := validPriority(in); got != want {
+			t.Errorf("validPriority(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type field struct {
+	key   string
+	value any
+}
+
+type entry struct {
+	time   time.Time
+	level  Level
+	msg    string
+	fields []field
+}
+
+// text segue o formato do log padrão do Go, com os campos em key=value:
+//
+//	2024/05/01 12:00:00 [INFO] flush ok queue=main sent=120
+func (e entry) text() []byte {
+	var b bytes.Buffer
+	b.WriteString(e.time.Format("2006/01/02 15:04:05"))
+	b.WriteString(" [")
+	b.WriteString(strings.ToUpper(e.level.String()))
+	b.WriteString("] ")
+	b.WriteString(e.msg)
+	for _, f := range e.fields {
+		b.WriteByte(' ')
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(quote(fmt.Sprint(f.value)))
+	}
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// json gera um objeto por linha: time, level, msg e os campos na ordem em
+// que vieram (chaves repetidas ficam como estão).
+func (e entry) json() []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	writeJSON(&b, e.time.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, e.level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, e.msg)
+	for _, f := range e.fields {
+		b.WriteByte(',')
+		writeJSON(&b, f.key)
+		b.WriteByte(':')
+		writeJSON(&b, f.value)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func writeJSON(b *bytes.Buffer, v any) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(raw)
+}
+
+// quote põe entre aspas valores com espaço, aspas ou '=' para o texto
+// continuar separável.
+func quote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -1,26 +1,181 @@
+// Package logger é o log estruturado do agente: níveis, campos chave-valor,
+// saída texto ou JSON, arquivo com rotação por tamanho e mascaramento de
+// segredos.
 package logger
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Logger interface {
-	Info(msg string)
-	Error(msg string)
+	// kv são pares chave, valor: log.Info("flush ok", "queue", q, "n", 10).
+	Debug(msg string, kv ...any)
+	Info(msg string, kv ...any)
+	Warn(msg string, kv ...any)
+	Error(msg string, kv ...any)
+	// Fatal registra e encerra o processo com código 1.
 	Fatal(msg string, kv ...any)
+	// SetLevel troca o nível em execução (debug|info|warn|error).
+	SetLevel(level string) error
+	// Redact acrescenta valores (tokens, chaves) a mascarar em tudo que for
+	// registrado.
+	Redact(secrets ...string)
 	Sync()
 }
 
-type std struct{}
+// Level ordena a severidade das mensagens.
+type Level int32
 
-func New(_ string) Logger { return &std{} }
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "fatal"
+}
+
+// ParseLevel aceita debug|info|warn|error (vazio = info).
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "", "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("invalid log level %q", s)
+}
+
+// Options configura o logger. File vazio escreve em stderr.
+type Options struct {
+	Level  string
+	Format string // text | json
+	File   string
+	// Rotação do File: tamanho máximo de cada arquivo, quantos antigos
+	// manter e por quanto tempo (zero = sem limite de idade).
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAge     time.Duration
+	// Secrets são valores mascarados desde o início (ver Redact).
+	Secrets []string
+}
+
+type std struct {
+	level atomic.Int32
+	json  bool
+	red   *redactor
+	mu    sync.Mutex
+	out   io.Writer
+}
+
+// New cria o logger. Erro só na abertura do arquivo ou em opções inválidas.
+func New(o Options) (Logger, error) {
+	s := &std{red: newRedactor(o.Secrets...), out: os.Stderr}
+	if err := s.SetLevel(o.Level); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(o.Format) {
+	case "", "text":
+	case "json":
+		s.json = true
+	default:
+		return nil, fmt.Errorf("invalid log format %q", o.Format)
+	}
+	if o.File != "" {
+		f, err := openRotating(o.File, int64(o.MaxSizeMB)*1024*1024, o.MaxBackups, o.MaxAge)
+		if err != nil {
+			return nil, err
+		}
+		s.out = f
+	}
+	return s, nil
+}
+
+func (s *std) Debug(msg string, kv ...any) { s.log(LevelDebug, msg, kv) }
+func (s *std) Info(msg string, kv ...any)  { s.log(LevelInfo, msg, kv) }
+func (s *std) Warn(msg string, kv ...any)  { s.log(LevelWarn, msg, kv) }
+func (s *std) Error(msg string, kv ...any) { s.log(LevelError, msg, kv) }
 
-func (s *std) Info(msg string)  { log.Println("[INFO] " + msg) }
-func (s *std) Error(msg string) { log.Println("[ERROR] " + msg) }
 func (s *std) Fatal(msg string, kv ...any) {
-	log.Println("[FATAL] " + msg + " " + fmt.Sprint(kv...))
+	s.log(LevelFatal, msg, kv)
+	s.Sync()
 	os.Exit(1)
 }
-func (s *std) Sync() {}
+
+func (s *std) SetLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	s.level.Store(int32(l))
+	return nil
+}
+
+func (s *std) Redact(secrets ...string) { s.red.add(secrets...) }
+
+func (s *std) Sync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.out.(interface{ Sync() error }); ok {
+		_ = f.Sync()
+	}
+}
+
+func (s *std) log(l Level, msg string, kv []any) {
+	if l < Level(s.level.Load()) {
+		return
+	}
+	e := entry{time: time.Now(), level: l, msg: s.red.string(msg), fields: s.fields(kv)}
+	var line []byte
+	if s.json {
+		line = e.json()
+	} else {
+		line = e.text()
+	}
+	s.mu.Lock()
+	_, _ = s.out.Write(line)
+	s.mu.Unlock()
+}
+
+// fields pareia kv; chave sem valor vira "!BADKEY" (como no slog) para não
+// sumir com a informação.
+func (s *std) fields(kv []any) []field {
+	if len(kv) == 0 {
+		return nil
+	}
+	out := make([]field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i += 2 {
+		var k string
+		var v any
+		if i+1 < len(kv) {
+			k, v = fmt.Sprint(kv[i]), kv[i+1]
+		} else {
+			k, v = "!BADKEY", kv[i]
+		}
+		out = append(out, field{key: k, value: s.red.value(k, v)})
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type creds struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Nested   struct {
+		APIKey string `json:"api_key"`
+		Note   string `json:"note"`
+	} `json:"nested"`
+}
+
+func TestRedactValue(t *testing.T) {
+	r := newRedactor("s3cr3t-value", "short")
+	var c creds
+	c.User, c.Password = "alice", "hunter2"
+	c.Nested.APIKey, c.Nested.Note = "k", "uses s3cr3t-value"
+	cases := []struct {
+		name string
+		key  string
+		v    any
+		want any
+	}{
+		{"chave sensível", "token", "abc", redacted},
+		{"chave com prefixo", "hub_token", "abc", redacted},
+		{"chave com hífen e maiúsculas", "X-Api-Key", "abc", redacted},
+		{"chave com ponto", "db.password", "abc", redacted},
+		{"authorization", "Authorization", "Token abc", redacted},
+		{"palavra que contém o termo", "tokens_used", 12, 12},
+		{"outra palavra que contém o termo", "secretary", "bob", "bob"},
+		{"segredo registrado no texto", "url", "https://x/?k=s3cr3t-value", "https://x/?k=" + redacted},
+		{"segredo curto não é registrado", "msg", "a short note", "a short note"},
+		{"bearer no texto", "header", "Bearer abcdefghijklmnopqrstuvwxyz", "Bearer " + redacted},
+		{"par chave=valor no texto", "q", "user=a&password=p4ss&x=1", "user=a&password=" + redacted + "&x=1"},
+		{"erro", "err", errors.New("auth failed for s3cr3t-value"), "auth failed for " + redacted},
+		{"duração", "wait", 1500 * time.Millisecond, "1.5s"},
+		{"[]string", "args", []string{"--token", "s3cr3t-value"}, []string{"--token", redacted}},
+		{
+			"mapa", "body", map[string]any{"password": "p", "user": "u", "n": 1},
+			map[string]any{"password": redacted, "user": "u", "n": 1},
+		},
+		{
+			"mapa aninhado e []any", "body",
+			map[string]any{"items": []any{map[string]any{"secret": "x"}, "Token abcdefghijklmnop1234"}},
+			map[string]any{"items": []any{map[string]any{"secret": redacted}, "Token " + redacted}},
+		},
+		{
+			"map[string]string", "headers", map[string]string{"Authorization": "Bearer x", "Accept": "s3cr3t-value"},
+			map[string]string{"Authorization": redacted, "Accept": redacted},
+		},
+		{
+			"struct pelas tags json", "cfg", c,
+			map[string]any{"user": "alice", "password": redacted, "nested": map[string]any{"api_key": redacted, "note": "uses " + redacted}},
+		},
+		{"ponteiro para struct", "cfg", &struct{ Token string }{"t"}, map[string]any{"Token": redacted}},
+		{"mapa de outro tipo", "m", map[string]int{"passwd": 1, "n": 2}, map[string]any{"passwd": redacted, "n": json.Number("2")}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.value(tc.key, tc.v); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("value = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+	// O que não serializa em JSON vira o texto do fmt.Sprint.
+	if got, ok := r.value("ch", make(chan int)).(string); !ok || !strings.HasPrefix(got, "0x") {
+		t.Fatalf("value of a channel = %#v", got)
+	}
+}
+
+// newBuffered cria o logger escrevendo em buf.
+func newBuffered(t *testing.T, o Options) (*std, *bytes.Buffer) {
+	t.Helper()
+	l, err := New(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := l.(*std)
+	s.out = &buf
+	return s, &buf
+}
+
+func TestLevels(t *testing.T) {
+	s, buf := newBuffered(t, Options{Level: "warn"})
+	s.Debug("d")
+	s.Info("i")
+	s.Warn("w")
+	s.Error("e")
+	if got := buf.String(); strings.Contains(got, "[DEBUG]") || strings.Contains(got, "[INFO]") || !strings.Contains(got, "[WARN] w") || !strings.Contains(got, "[ERROR] e") {
+		t.Fatalf("output at warn:\n%s", got)
+	}
+
+	buf.Reset()
+	if err := s.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	s.Debug("d")
+	if !strings.Contains(buf.String(), "[DEBUG] d") {
+		t.Fatalf("debug not logged after SetLevel: %q", buf.String())
+	}
+
+	// Nível inválido é recusado e mantém o atual.
+	if err := s.SetLevel("verbose"); err == nil {
+		t.Fatal("SetLevel accepted an invalid level")
+	}
+	buf.Reset()
+	s.Debug("still")
+	if !strings.Contains(buf.String(), "still") {
+		t.Fatal("invalid SetLevel changed the level")
+	}
+	if _, err := New(Options{Level: "loud"}); err == nil {
+		t.Fatal("New accepted an invalid level")
+	}
+	if _, err := New(Options{Format: "xml"}); err == nil {
+		t.Fatal("New accepted an invalid format")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	s, buf := newBuffered(t, Options{Format: "json", Secrets: []string{"tok-123456"}})
+	s.Redact("added-later-secret")
+	s.Info("sent with tok-123456", "queue", "main", "n", 3, "auth", map[string]any{"token": "x"}, "extra", "added-later-secret", "dangling")
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid json line %q: %v", buf, err)
+	}
+	want := map[string]any{
+		"level": "info", "msg": "sent with " + redacted, "queue": "main", "n": 3.0,
+		"auth": map[string]any{"token": redacted}, "extra": redacted, "!BADKEY": "dangling",
+	}
+	delete(got, "time")
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("json = %v\nwant %v", got, want)
+	}
+
+	s, buf = newBuffered(t, Options{})
+	s.Info("flush ok", "queue", "main", "reason", "has space", "empty", "")
+	if line := buf.String(); !strings.HasSuffix(line, `[INFO] flush ok queue=main reason="has space" empty=""`+"\n") {
+		t.Fatalf("text = %q", line)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	cases := []struct {
+		name       string
+		maxBackups int
+		writes     int
+		want       []string // conteúdo de path, path.1, path.2...
+	}{
+		{"sem rotação", 2, 2, []string{"0123456789\n0123456789\n"}},
+		{"mantém maxBackups", 2, 5, []string{"0123456789\n", "0123456789\n0123456789\n", "0123456789\n0123456789\n"}},
+		{"sem backups", 0, 3, []string{"0123456789\n"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs", "agent.log")
+			f, err := openRotating(path, 25, tc.maxBackups, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			for i := 0; i < tc.writes; i++ {
+				if _, err := f.Write([]byte("0123456789\n")); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, want := range tc.want {
+				p := path
+				if i > 0 {
+					p = f.backup(i)
+				}
+				if b, _ := os.ReadFile(p); string(b) != want {
+					t.Errorf("%s = %q, want %q", filepath.Base(p), b, want)
+				}
+			}
+			if _, err := os.Stat(f.backup(len(tc.want))); !os.IsNotExist(err) {
+				t.Errorf("unexpected %s", f.backup(len(tc.want)))
+			}
+		})
+	}
+}
+
+// Backups mais velhos que maxAge somem na rotação; o arquivo existente é
+// continuado, não truncado.
+func TestRotationMaxAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o640); err != nil {
+		t.Fatal(err)
+	}
+	f, err := openRotating(path, 10, 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, _ = f.Write([]byte("a\n"))
+	_, _ = f.Write([]byte("bbbbbb\n")) // rotaciona: old+a vai para .1
+	if b, _ := os.ReadFile(f.backup(1)); string(b) != "old\na\n" {
+		t.Fatalf(".1 = %q", b)
+	}
+	stale := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(f.backup(1), stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte("cccccc\n")) // rotaciona: o .1 antigo vira .2 e é apagado
+	if _, err := os.Stat(f.backup(2)); !os.IsNotExist(err) {
+		t.Fatalf("stale backup kept: %v", err)
+	}
+	if b, _ := os.ReadFile(f.backup(1)); string(b) != "bbbbbb\n" {
+		t.Fatalf(".1 = %q", b)
+	}
+}
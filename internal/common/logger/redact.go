@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys são campos cujo valor nunca é registrado.
+var sensitiveKeys = []string{"token", "secret", "password", "passwd", "api_key", "apikey", "authorization"}
+
+// secretPatterns pegam credenciais que chegam dentro de mensagens (headers,
+// URLs, payloads) mesmo sem terem sido registradas via Redact.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b([Bb]earer|BEARER|Token)\s+[A-Za-z0-9._~+/=-]{16,}`),
+	regexp.MustCompile(`(?i)\b(token|secret|password|passwd|api_key|apikey)(=|"\s*:\s*")[^\s"'&,;]+`),
+}
+
+// redactor mascara segredos conhecidos e padrões de credencial.
+type redactor struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+func newRedactor(secrets ...string) *redactor {
+	r := &redactor{}
+	r.add(secrets...)
+	return r
+}
+
+func (r *redactor) add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range secrets {
+		// Valores curtos mascarariam pedaços de texto comum.
+		if len(s) < 6 {
+			continue
+		}
+		dup := false
+		for _, x := range r.secrets {
+			dup = dup || x == s
+		}
+		if !dup {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+}
+
+func (r *redactor) string(s string) string {
+	r.mu.RLock()
+	for _, x := range r.secrets {
+		s = strings.ReplaceAll(s, x, redacted)
+	}
+	r.mu.RUnlock()
+	s = secretPatterns[0].ReplaceAllString(s, "$1 "+redacted)
+	return secretPatterns[1].ReplaceAllString(s, "${1}${2}"+redacted)
+}
+
+// sensitive indica campo de credencial: a chave inteira ou um trecho dela
+// entre separadores ("hub_token", "X-Api-Key", "db.password"). Palavras
+// que só contêm o termo ("tokens_used", "secretary") não contam.
+func sensitive(key string) bool {
+	k := "_" + strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(strings.ToLower(key)) + "_"
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, "_"+s+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// value normaliza o valor de um campo (erros, durações e Stringers viram
+// texto) e mascara o que for sensível, inclusive dentro de mapas, slices e
+// structs.
+func (r *redactor) value(key string, v any) any {
+	if sensitive(key) {
+		return redacted
+	}
+	switch x := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return v
+	case string:
+		return r.string(x)
+	case error:
+		return r.string(x.Error())
+	case time.Duration:
+		return x.String()
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return r.string(x.String())
+	case []string:
+		out := make([]string, len(x))
+		for i, s := range x {
+			out[i] = r.string(s)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = r.value("", e)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = r.value(k, e)
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(x))
+		for k, e := range x {
+			if sensitive(k) {
+				out[k] = redacted
+			} else {
+				out[k] = r.string(e)
+			}
+		}
+		return out
+	}
+	// Structs, ponteiros e outros mapas/slices passam pelo JSON para que as
+	// chaves (tags json) também sejam conferidas; o que não serializa vira
+	// texto.
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return r.string(fmt.Sprint(v))
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return r.string(fmt.Sprint(v))
+	}
+	return r.value("", generic)
+}
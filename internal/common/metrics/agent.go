@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Métricas do agente. "collector" é o sub do envelope (ex.: sysmetrics,
+// oslogs) ou o kind quando não há sub; "queue" é main ou oslogs.
+var (
+	AgentInfo = NewGaugeVec("aiceberg_agent_info",
+		"Versão do agente (valor sempre 1).", "version")
+
+	OutboxItems = NewGaugeVec("aiceberg_outbox_items",
+		"Envelopes aguardando envio no outbox.", "queue")
+	OutboxBytes = NewGaugeVec("aiceberg_outbox_bytes",
+		"Bytes ocupados pelos envelopes no outbox.", "queue")
+
+	EnvelopesCollected = NewCounterVec("aiceberg_envelopes_collected_total",
+		"Envelopes gerados e gravados no outbox.", "collector")
+	EnvelopesFlushed = NewCounterVec("aiceberg_envelopes_flushed_total",
+		"Envelopes aceitos pelo backend.", "collector")
+	EnvelopesDropped = NewCounterVec("aiceberg_envelopes_dropped_total",
+		"Envelopes descartados (overflow, expired, rejected).", "collector", "reason")
+
+	TransportDuration = NewHistogramVec("aiceberg_transport_request_duration_seconds",
+		"Latência das requisições ao backend/hub.", DefBuckets, "endpoint")
+	TransportErrors = NewCounterVec("aiceberg_transport_errors_total",
+		"Falhas de requisição por status HTTP (network = sem resposta).", "endpoint", "status")
+
+	CollectorDuration = NewHistogramVec("aiceberg_collector_duration_seconds",
+		"Duração de cada execução de collector.", []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}, "collector")
+	CollectorRuns = NewCounterVec("aiceberg_collector_runs_total",
+		"Execuções de collector por resultado (ok, error, skipped).", "collector", "result")
+	OSLogFiles = NewGaugeVec("aiceberg_oslogs_files",
+		"Arquivos casados por OSLOG_FILES: followed (seguidos) ou skipped (além de OSLOG_MAX_OPEN_FILES).", "state")
+
+	ConfigSyncVersion = NewGaugeVec("aiceberg_config_sync_version_info",
+		"Versão da config remota em uso (valor sempre 1).", "version")
+	ConfigSyncLastSuccess = NewGaugeVec("aiceberg_config_sync_last_success_timestamp_seconds",
+		"Unix time do último config sync bem-sucedido.")
+
+	PingLastAck = NewGaugeVec("aiceberg_ping_last_ack_timestamp_seconds",
+		"Unix time do último ping respondido pelo backend.")
+
+	LastFlushSuccess = NewGaugeVec("aiceberg_last_flush_success_timestamp_seconds",
+		"Unix time do último flush sem erro (lote entregue ou fila vazia).", "queue")
+	FlushBlocked = NewGaugeVec("aiceberg_flush_blocked_status",
+		"Status HTTP (401, 403 ou 404) com que o backend recusa as credenciais do agente; 0 quando entrega.", "queue")
+)
+
+// Now é o timestamp Unix (segundos) usado nos gauges *_timestamp_seconds.
+func Now() float64 { return float64(time.Now().UnixNano()) / 1e9 }
+
+// ObserveRequest registra a latência de uma requisição a endpoint e, se
+// falhou, o erro por status (status 0 com err = falha de rede).
+func ObserveRequest(endpoint string, start time.Time, status int, err error) {
+	TransportDuration.Observe(time.Since(start).Seconds(), endpoint)
+	switch {
+	case err != nil && status == 0:
+		TransportErrors.Inc(endpoint, "network")
+	case status >= 400:
+		TransportErrors.Inc(endpoint, strconv.Itoa(status))
+	}
+}
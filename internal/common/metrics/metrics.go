@@ -0,0 +1,306 @@
+// Package metrics implementa o mínimo do formato texto do Prometheus
+// (counters, gauges e histogramas com labels) para a telemetria do próprio
+// agente, sem dependências externas.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets são os limites padrão (segundos) dos histogramas de latência.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	regMu    sync.Mutex
+	registry []metric
+	hooks    []func()
+)
+
+func register(m metric) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	registry = append(registry, m)
+}
+
+// OnScrape registra fn para rodar antes de cada coleta (gauges calculados
+// na hora, ex.: profundidade do outbox).
+func OnScrape(fn func()) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	hooks = append(hooks, fn)
+}
+
+// Handler serve as métricas registradas no formato texto 0.0.4.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		regMu.Lock()
+		hs := append([]func(){}, hooks...)
+		ms := append([]metric{}, registry...)
+		regMu.Unlock()
+		for _, h := range hs {
+			h()
+		}
+		bw := bufio.NewWriter(w)
+		for _, m := range ms {
+			m.write(bw)
+		}
+		_ = bw.Flush()
+	})
+}
+
+// desc é nome, ajuda e nomes de label comuns a todos os tipos.
+type desc struct {
+	name, help, typ string
+	labels          []string
+}
+
+func (d desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.typ)
+}
+
+// key junta os valores de label; \xff não aparece em texto válido.
+func key(values []string) string { return strings.Join(values, "\xff") }
+
+func (d desc) labelPairs(values []string, extra ...string) string {
+	var parts []string
+	for i, l := range d.labels {
+		parts = append(parts, l+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (d desc) check(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// series guarda valores por combinação de labels, em ordem estável.
+type series[T any] struct {
+	mu     sync.Mutex
+	values map[string]*T
+	labels map[string][]string
+	newT   func() *T
+}
+
+func (s *series[T]) get(values []string) *T {
+	k := key(values)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[k]; ok {
+		return v
+	}
+	if s.values == nil {
+		s.values, s.labels = map[string]*T{}, map[string][]string{}
+	}
+	v := s.newT()
+	s.values[k] = v
+	s.labels[k] = append([]string(nil), values...)
+	return v
+}
+
+func (s *series[T]) each(fn func(values []string, v *T)) {
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.values))
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	type entry struct {
+		labels []string
+		v      *T
+	}
+	list := make([]entry, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, entry{s.labels[k], s.values[k]})
+	}
+	s.mu.Unlock()
+	for _, e := range list {
+		fn(e.labels, e.v)
+	}
+}
+
+func (s *series[T]) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values, s.labels = nil, nil
+}
+
+// value é um float64 protegido por mutex (counters e gauges).
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// CounterVec é um contador monotônico por labels.
+type CounterVec struct {
+	desc
+	s series[value]
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, "counter", labels}, s: series[value]{newT: func() *value { return &value{} }}}
+	register(c)
+	return c
+}
+
+// Add soma d (>= 0) na série dos labels informados.
+func (c *CounterVec) Add(d float64, labels ...string) {
+	c.check(labels)
+	if d < 0 {
+		return
+	}
+	c.s.get(labels).add(d)
+}
+
+func (c *CounterVec) Inc(labels ...string) { c.Add(1, labels...) }
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w)
+	c.s.each(func(l []string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(l), formatFloat(v.get()))
+	})
+}
+
+// GaugeVec é um valor que sobe e desce, por labels.
+type GaugeVec struct {
+	desc
+	s series[value]
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{desc: desc{name, help, "gauge", labels}, s: series[value]{newT: func() *value { return &value{} }}}
+	register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(v float64, labels ...string) {
+	g.check(labels)
+	g.s.get(labels).set(v)
+}
+
+// Value lê o valor atual da série; ok=false se ela nunca foi definida.
+func (g *GaugeVec) Value(labels ...string) (v float64, ok bool) {
+	g.check(labels)
+	g.s.mu.Lock()
+	x, ok := g.s.values[key(labels)]
+	g.s.mu.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return x.get(), true
+}
+
+// Reset apaga todas as séries (ex.: métricas *_info ao trocar de versão).
+func (g *GaugeVec) Reset() { g.s.reset() }
+
+func (g *GaugeVec) write(w *bufio.Writer) {
+	g.header(w)
+	g.s.each(func(l []string, v *value) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labelPairs(l), formatFloat(v.get()))
+	})
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // por bucket, não cumulativo
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec distribui observações em buckets, por labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	s       series[histogram]
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &HistogramVec{desc: desc{name, help, "histogram", labels}, buckets: b}
+	h.s.newT = func() *histogram { return &histogram{counts: make([]uint64, len(b))} }
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	h.check(labels)
+	x := h.s.get(labels)
+	i := sort.SearchFloat64s(h.buckets, v)
+	x.mu.Lock()
+	if i < len(h.buckets) {
+		x.counts[i]++
+	}
+	x.sum += v
+	x.count++
+	x.mu.Unlock()
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w)
+	h.s.each(func(l []string, x *histogram) {
+		x.mu.Lock()
+		counts := append([]uint64(nil), x.counts...)
+		sum, count := x.sum, x.count
+		x.mu.Unlock()
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(l, "le", formatFloat(b)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(l, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(l), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(l), count)
+	})
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// scrape devolve as linhas de Handler.
+func scrape(t *testing.T) []string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	return strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+}
+
+// block devolve as linhas da métrica name, do # HELP até a próxima.
+func block(lines []string, name string) []string {
+	var out []string
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "# HELP "+name+" ") {
+			continue
+		}
+		out = append(out, l)
+		for _, next := range lines[i+1:] {
+			if strings.HasPrefix(next, "# HELP ") {
+				break
+			}
+			out = append(out, next)
+		}
+		break
+	}
+	return out
+}
+
+func TestHandler(t *testing.T) {
+	counter := NewCounterVec("test_requests_total", "Requisições de teste.", "path", "code")
+	gauge := NewGaugeVec("test_temperature", "Temperatura.")
+	hist := NewHistogramVec("test_latency_seconds", "Latência.", []float64{1, 0.1, 0.5}, "op")
+	var hooked int
+	OnScrape(func() { hooked++; gauge.Set(math.Inf(1)) })
+
+	counter.Inc("/a", "200")
+	counter.Add(2.5, "/a", "200")
+	counter.Add(-1, "/a", "200") // contador não desce
+	counter.Inc(`C:\tmp "x"`+"\n", "500")
+	counter.Inc("/b", "200")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.5, 2} {
+		hist.Observe(v, "send")
+	}
+	hist.Observe(0.7, "recv")
+
+	cases := []struct {
+		name   string
+		metric string
+		want   []string
+	}{
+		{
+			name:   "counter com labels em ordem e escape",
+			metric: "test_requests_total",
+			want: []string{
+				"# HELP test_requests_total Requisições de teste.",
+				"# TYPE test_requests_total counter",
+				`test_requests_total{path="/a",code="200"} 3.5`,
+				`test_requests_total{path="/b",code="200"} 1`,
+				`test_requests_total{path="C:\\tmp \"x\"\n",code="500"} 1`,
+			},
+		},
+		{
+			name:   "gauge sem labels atualizado pelo OnScrape",
+			metric: "test_temperature",
+			want: []string{
+				"# HELP test_temperature Temperatura.",
+				"# TYPE test_temperature gauge",
+				"test_temperature +Inf",
+			},
+		},
+		{
+			name:   "histograma com buckets ordenados e cumulativos",
+			metric: "test_latency_seconds",
+			want: []string{
+				"# HELP test_latency_seconds Latência.",
+				"# TYPE test_latency_seconds histogram",
+				`test_latency_seconds_bucket{op="recv",le="0.1"} 0`,
+				`test_latency_seconds_bucket{op="recv",le="0.5"} 0`,
+				`test_latency_seconds_bucket{op="recv",le="1"} 1`,
+				`test_latency_seconds_bucket{op="recv",le="+Inf"} 1`,
+				`test_latency_seconds_sum{op="recv"} 0.7`,
+				`test_latency_seconds_count{op="recv"} 1`,
+				`test_latency_seconds_bucket{op="send",le="0.1"} 2`,
+				`test_latency_seconds_bucket{op="send",le="0.5"} 4`,
+				`test_latency_seconds_bucket{op="send",le="1"} 4`,
+				`test_latency_seconds_bucket{op="send",le="+Inf"} 5`,
+				`test_latency_seconds_sum{op="send"} 2.95`,
+				`test_latency_seconds_count{op="send"} 5`,
+			},
+		},
+	}
+	lines := scrape(t)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := block(lines, tc.metric)
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Fatalf("exposition:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+	if hooked != 1 {
+		t.Fatalf("OnScrape ran %d times", hooked)
+	}
+}
+
+// As métricas do agente aparecem com os nomes e tipos publicados.
+func TestAgentMetrics(t *testing.T) {
+	EnvelopesCollected.Inc("sysmetrics")
+	TransportErrors.Inc("ingest", "503")
+	CollectorDuration.Observe(0.2, "sysmetrics")
+	OutboxItems.Set(7, "main")
+	lines := scrape(t)
+	cases := []struct {
+		metric, typ, sample string
+	}{
+		{"aiceberg_envelopes_collected_total", "counter", `aiceberg_envelopes_collected_total{collector="sysmetrics"} 1`},
+		{"aiceberg_transport_errors_total", "counter", `aiceberg_transport_errors_total{endpoint="ingest",status="503"} 1`},
+		{"aiceberg_outbox_items", "gauge", `aiceberg_outbox_items{queue="main"} 7`},
+		{"aiceberg_collector_duration_seconds", "histogram", `aiceberg_collector_duration_seconds_bucket{collector="sysmetrics",le="0.25"} 1`},
+		{"aiceberg_collector_duration_seconds", "histogram", `aiceberg_collector_duration_seconds_bucket{collector="sysmetrics",le="0.1"} 0`},
+		{"aiceberg_transport_request_duration_seconds", "histogram", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.metric, func(t *testing.T) {
+			got := block(lines, tc.metric)
+			if len(got) < 2 || got[1] != "# TYPE "+tc.metric+" "+tc.typ {
+				t.Fatalf("header of %s = %q", tc.metric, got)
+			}
+			if tc.sample != "" && !slices.Contains(got, tc.sample) {
+				t.Fatalf("%q not in\n%s", tc.sample, strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a missing label value")
+		}
+	}()
+	EnvelopesDropped.Inc("sysmetrics")
+}
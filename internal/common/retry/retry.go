@@ -1,3 +1,165 @@
 package retry
 
-// backoff/jitter
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrOpen é retornado quando o circuit breaker está aberto e a chamada nem é tentada.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Backoff calcula atrasos exponenciais com full jitter:
+// delay = rand[0, min(Max, Base*2^attempt)).
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (b Backoff) Delay(attempt int) time.Duration {
+	ceil := b.Ceil(attempt)
+	if ceil <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceil)))
+}
+
+// EqualDelay é o atraso com equal jitter: metade do teto fixa e metade
+// aleatória, para esperas que não podem sair perto de zero (breaker aberto).
+func (b Backoff) EqualDelay(attempt int) time.Duration {
+	ceil := b.Ceil(attempt)
+	if ceil <= 1 {
+		return ceil
+	}
+	half := ceil / 2
+	return half + time.Duration(rand.Int64N(int64(ceil-half)))
+}
+
+// Ceil retorna o teto exponencial (sem jitter) para a tentativa.
+func (b Backoff) Ceil(attempt int) time.Duration {
+	if b.Base <= 0 {
+		return 0
+	}
+	if attempt < 0 {
+		attempt = 0
+	}
+	d := b.Base
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if b.Max > 0 && d >= b.Max {
+			return b.Max
+		}
+	}
+	if b.Max > 0 && d > b.Max {
+		return b.Max
+	}
+	return d
+}
+
+// RetryAfterError é implementado por erros que carregam uma dica de espera do servidor.
+type RetryAfterError interface {
+	RetryAfter() time.Duration
+}
+
+// RetryAfter extrai a dica de espera de err, se houver.
+func RetryAfter(err error) (time.Duration, bool) {
+	var ra RetryAfterError
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return ra.RetryAfter(), true
+	}
+	return 0, false
+}
+
+// ParseRetryAfter interpreta o header Retry-After (segundos ou HTTP-date).
+func ParseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// Breaker é um circuit breaker simples: após Threshold falhas consecutivas
+// abre por um período com backoff (ou Retry-After, se maior); depois deixa
+// passar uma chamada de teste (half-open).
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	backoff   Backoff
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func NewBreaker(threshold int, b Backoff) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, backoff: b, now: time.Now}
+}
+
+// Allow informa se a chamada pode seguir. Em half-open apenas uma chamada passa.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openUntil.IsZero() {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+// Failure registra uma falha; retryAfter (se > 0) é respeitado como espera mínima.
+func (b *Breaker) Failure(retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures < b.threshold && retryAfter <= 0 {
+		return
+	}
+	wait := b.backoff.EqualDelay(b.failures - b.threshold)
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	b.openUntil = b.now().Add(wait)
+}
+
+// State retorna closed, open ou half-open.
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openUntil.IsZero():
+		return "closed"
+	case b.now().Before(b.openUntil):
+		return "open"
+	default:
+		return "half-open"
+	}
+}
@@ -0,0 +1,182 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBackoffCeil(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: 10 * time.Second}
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{-1, time.Second},
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{100, 10 * time.Second}, // sem overflow
+	}
+	for _, tc := range cases {
+		if got := b.Ceil(tc.attempt); got != tc.want {
+			t.Errorf("Ceil(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+	if got := (Backoff{}).Ceil(3); got != 0 {
+		t.Errorf("Ceil without Base = %v, want 0", got)
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+	for attempt := 0; attempt < 6; attempt++ {
+		ceil := b.Ceil(attempt)
+		for range 200 {
+			if d := b.Delay(attempt); d < 0 || d >= ceil {
+				t.Fatalf("Delay(%d) = %v, want [0, %v)", attempt, d, ceil)
+			}
+			if d := b.EqualDelay(attempt); d < ceil/2 || d >= ceil {
+				t.Fatalf("EqualDelay(%d) = %v, want [%v, %v)", attempt, d, ceil/2, ceil)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 5 ", 5 * time.Second},
+		{"-3", 0},
+		{"Sat, 18 Oct 2025 10:00:30 GMT", 30 * time.Second},
+		{"Sat, 18 Oct 2025 09:59:00 GMT", 0}, // no passado
+		{"amanhã", 0},
+	}
+	for _, tc := range cases {
+		if got := ParseRetryAfter(tc.in, now); got != tc.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+type raErr time.Duration
+
+func (e raErr) Error() string             { return "busy" }
+func (e raErr) RetryAfter() time.Duration { return time.Duration(e) }
+
+func TestRetryAfter(t *testing.T) {
+	cases := []struct {
+		err    error
+		want   time.Duration
+		wantOK bool
+	}{
+		{errors.New("x"), 0, false},
+		{raErr(0), 0, false},
+		{raErr(time.Minute), time.Minute, true},
+		{fmt.Errorf("send: %w", raErr(time.Second)), time.Second, true},
+	}
+	for _, tc := range cases {
+		got, ok := RetryAfter(tc.err)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("RetryAfter(%v) = %v, %v; want %v, %v", tc.err, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+// clock é um relógio manual para o breaker.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(threshold int) (*Breaker, *clock) {
+	c := &clock{t: time.Unix(1_700_000_000, 0)}
+	b := NewBreaker(threshold, Backoff{Base: time.Second, Max: 8 * time.Second})
+	b.now = c.now
+	return b, c
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b, c := newTestBreaker(3)
+	for i := range 2 {
+		if !b.Allow() {
+			t.Fatalf("closed breaker refused call %d", i)
+		}
+		b.Failure(0)
+	}
+	if b.State() != "closed" {
+		t.Fatalf("state after 2 failures = %s", b.State())
+	}
+	b.Failure(0)
+	if b.State() != "open" || b.Allow() {
+		t.Fatalf("state after threshold = %s", b.State())
+	}
+	// Equal jitter: aberto por ao menos metade do teto da primeira espera.
+	c.advance(499 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("breaker let a call through before half of the backoff")
+	}
+	c.advance(501 * time.Millisecond)
+	if b.State() != "half-open" {
+		t.Fatalf("state after the backoff = %s", b.State())
+	}
+	// Half-open: só uma chamada de teste passa.
+	if !b.Allow() || b.Allow() {
+		t.Fatal("half-open must allow exactly one probe")
+	}
+	b.Success()
+	if b.State() != "closed" || !b.Allow() {
+		t.Fatalf("state after a successful probe = %s", b.State())
+	}
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b, c := newTestBreaker(1)
+	b.Failure(0)
+	c.advance(time.Second)
+	if !b.Allow() {
+		t.Fatal("probe refused")
+	}
+	b.Failure(0)
+	// Segunda abertura: teto de 2s, ao menos 1s aberto.
+	c.advance(999 * time.Millisecond)
+	if b.State() != "open" {
+		t.Fatalf("state after a failed probe = %s", b.State())
+	}
+	c.advance(time.Second + time.Millisecond)
+	if b.State() != "half-open" {
+		t.Fatalf("state after the second backoff = %s", b.State())
+	}
+}
+
+func TestBreakerRetryAfter(t *testing.T) {
+	cases := []struct {
+		name       string
+		threshold  int
+		retryAfter time.Duration
+		wantOpen   time.Duration
+	}{
+		// Retry-After abre mesmo abaixo do threshold.
+		{"abaixo do threshold", 5, 30 * time.Second, 30 * time.Second},
+		// Vale o maior entre o backoff e o Retry-After.
+		{"retry-after maior", 1, 20 * time.Second, 20 * time.Second},
+		{"backoff maior", 1, time.Millisecond, 500 * time.Millisecond},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, c := newTestBreaker(tc.threshold)
+			b.Failure(tc.retryAfter)
+			c.advance(tc.wantOpen - time.Millisecond)
+			if b.State() != "open" {
+				t.Fatalf("state before %v = %s", tc.wantOpen, b.State())
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+)
+
+// Job é uma tarefa periódica (ex.: um collector).
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Timeout limita cada execução; zero usa o próprio Interval.
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// Status é o retrato da última execução de um job.
+type Status struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"interval"`
+	Running      bool          `json:"running"`
+	Runs         int64         `json:"runs"`
+	Skipped      int64         `json:"skipped"`
+	Errors       int64         `json:"errors"`
+	ConsecErrors int64         `json:"consecutive_errors"` // zera na primeira execução ok
+	LastStart    time.Time     `json:"last_start,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+}
+
+type job struct {
+	Job
+	now   chan struct{}
+	mu    sync.Mutex
+	stat  Status
+	again bool // RunNow durante uma execução: roda de novo ao terminar
+}
+
+// Scheduler executa cada job no seu próprio intervalo (com jitter), em
+// goroutines separadas, sem sobrepor execuções do mesmo job.
+type Scheduler struct {
+	log    logger.Logger
+	jitter float64
+	mu     sync.Mutex
+	jobs   []*job
+	wg     sync.WaitGroup
+}
+
+func New(log logger.Logger) *Scheduler {
+	return &Scheduler{log: log, jitter: 0.1}
+}
+
+// Add registra um job; deve ser chamado antes de Start.
+func (s *Scheduler) Add(j Job) {
+	if j.Interval <= 0 {
+		j.Interval = time.Minute
+	}
+	if j.Timeout <= 0 {
+		j.Timeout = j.Interval
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &job{Job: j, now: make(chan struct{}, 1), stat: Status{Name: j.Name, Interval: j.Interval}})
+}
+
+// Start inicia os loops dos jobs; eles param quando ctx é cancelado.
+func (s *Scheduler) Start(ctx context.Context) { s.StartAfter(ctx, nil) }
+
+// StartAfter é Start com a primeira execução só depois de ready fechar (ex.:
+// o fim das execuções de um scheduler anterior com os mesmos collectors).
+// Wait já conta com os loops desde a chamada; ready nil não espera.
+func (s *Scheduler) StartAfter(ctx context.Context, ready <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j, ready)
+	}
+}
+
+// Wait bloqueia até todos os loops e execuções em andamento terminarem.
+func (s *Scheduler) Wait() { s.wg.Wait() }
+
+// RunNow antecipa a próxima execução do job (ou de todos, se name vazio).
+func (s *Scheduler) RunNow(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, j := range s.jobs {
+		if name != "" && j.Name != name {
+			continue
+		}
+		found = true
+		select {
+		case j.now <- struct{}{}:
+		default:
+		}
+	}
+	return found
+}
+
+// Status retorna o estado de todos os jobs.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		out = append(out, j.stat)
+		j.mu.Unlock()
+	}
+	return out
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job, ready <-chan struct{}) {
+	defer s.wg.Done()
+	if ready != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ready:
+		}
+	}
+	// Primeira execução espalhada no intervalo para agentes não baterem juntos.
+	timer := time.NewTimer(time.Duration(rand.Int64N(int64(j.Interval))))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.trigger(ctx, j, false)
+		case <-j.now:
+			timer.Stop()
+			s.trigger(ctx, j, true)
+		}
+		timer.Reset(s.next(j.Interval))
+	}
+}
+
+// trigger dispara uma execução, a menos que a anterior ainda esteja rodando
+// (um collector que ignora ctx não acumula execuções). Um RunNow nesse
+// caso não é perdido: vira uma execução logo após a atual.
+func (s *Scheduler) trigger(ctx context.Context, j *job, now bool) {
+	j.mu.Lock()
+	if j.stat.Running && now {
+		j.again = true
+		j.mu.Unlock()
+		return
+	}
+	if j.stat.Running {
+		j.stat.Skipped++
+		j.mu.Unlock()
+		metrics.CollectorRuns.Inc(j.Name, "skipped")
+		s.log.Error("scheduler: job still running, skipped", "job", j.Name)
+		return
+	}
+	j.stat.Running = true
+	j.stat.LastStart = time.Now()
+	// Esta execução atende os RunNow pendentes (inclusive o reenviado pela
+	// anterior ao terminar, que chegou junto com este disparo).
+	j.again = false
+	select {
+	case <-j.now:
+	default:
+	}
+	j.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		runCtx, cancel := context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+		start := time.Now()
+		err := j.Run(runCtx)
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.stat.Running = false
+		j.stat.Runs++
+		j.stat.LastDuration = time.Since(start)
+		j.stat.LastError = ""
+		result := "ok"
+		if err != nil {
+			j.stat.Errors++
+			j.stat.ConsecErrors++
+			j.stat.LastError = err.Error()
+			result = "error"
+		} else {
+			j.stat.ConsecErrors = 0
+		}
+		metrics.CollectorDuration.Observe(j.stat.LastDuration.Seconds(), j.Name)
+		metrics.CollectorRuns.Inc(j.Name, result)
+		if j.again {
+			j.again = false
+			select {
+			case j.now <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
+
+// next aplica jitter de ±10% ao intervalo.
+func (s *Scheduler) next(d time.Duration) time.Duration {
+	delta := int64(float64(d) * s.jitter)
+	if delta <= 0 {
+		return d
+	}
+	return d - time.Duration(delta) + time.Duration(rand.Int64N(2*delta))
+}
@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)  {}
+func (nopLogger) Info(string, ...any)   {}
+func (nopLogger) Warn(string, ...any)   {}
+func (nopLogger) Error(string, ...any)  {}
+func (nopLogger) Fatal(string, ...any)  {}
+func (nopLogger) SetLevel(string) error { return nil }
+func (nopLogger) Redact(...string)      {}
+func (nopLogger) Sync()                 {}
+
+// eventually espera cond virar verdadeira (os jobs rodam em goroutines).
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func status(s *Scheduler, name string) Status {
+	for _, st := range s.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+	return Status{}
+}
+
+// start roda o scheduler até o fim do teste.
+func start(t *testing.T, s *Scheduler, ready <-chan struct{}) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartAfter(ctx, ready)
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+}
+
+func TestRunNowCountsResults(t *testing.T) {
+	results := []error{nil, errors.New("a"), errors.New("b"), nil}
+	var calls atomic.Int32
+	s := New(nopLogger{})
+	s.Add(Job{Name: "c", Interval: time.Hour, Run: func(context.Context) error {
+		return results[calls.Add(1)-1]
+	}})
+	start(t, s, nil)
+
+	want := []struct {
+		errors, consec int64
+		lastErr        string
+	}{
+		{0, 0, ""},
+		{1, 1, "a"},
+		{2, 2, "b"},
+		{2, 0, ""},
+	}
+	for i, w := range want {
+		if !s.RunNow("c") {
+			t.Fatal("RunNow did not find the job")
+		}
+		eventually(t, "run", func() bool { st := status(s, "c"); return st.Runs == int64(i+1) && !st.Running })
+		st := status(s, "c")
+		if st.Errors != w.errors || st.ConsecErrors != w.consec || st.LastError != w.lastErr {
+			t.Errorf("after run %d: %+v, want errors=%d consecutive=%d last=%q", i+1, st, w.errors, w.consec, w.lastErr)
+		}
+	}
+	if s.RunNow("other") {
+		t.Fatal("RunNow found an unknown job")
+	}
+}
+
+func TestOverlapIsSkipped(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	s := New(nopLogger{})
+	s.jitter = 0
+	s.Add(Job{Name: "slow", Interval: 5 * time.Millisecond, Timeout: time.Minute, Run: func(ctx context.Context) error {
+		if calls.Add(1) == 1 {
+			<-release
+		}
+		return nil
+	}})
+	start(t, s, nil)
+
+	eventually(t, "first run", func() bool { return status(s, "slow").Running })
+	eventually(t, "skipped ticks", func() bool { return status(s, "slow").Skipped >= 2 })
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("runs while the first was still running = %d", n)
+	}
+	close(release)
+	eventually(t, "next run", func() bool { return calls.Load() >= 2 })
+}
+
+func TestRunNowDuringRunRunsAgain(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	s := New(nopLogger{})
+	s.Add(Job{Name: "c", Interval: time.Hour, Run: func(context.Context) error {
+		if calls.Add(1) == 1 {
+			<-release
+		}
+		return nil
+	}})
+	start(t, s, nil)
+
+	s.RunNow("c")
+	eventually(t, "first run", func() bool { return status(s, "c").Running })
+	s.RunNow("c")
+	s.RunNow("c") // acumulados viram uma execução só
+	close(release)
+	eventually(t, "second run", func() bool { return status(s, "c").Runs == 2 })
+	time.Sleep(20 * time.Millisecond)
+	if st := status(s, "c"); st.Runs != 2 || st.Skipped != 0 {
+		t.Fatalf("status = %+v, want exactly one extra run", st)
+	}
+}
+
+func TestTimeoutCancelsRun(t *testing.T) {
+	s := New(nopLogger{})
+	s.Add(Job{Name: "stuck", Interval: time.Hour, Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	start(t, s, nil)
+	s.RunNow("stuck")
+	eventually(t, "timeout", func() bool { return status(s, "stuck").Runs == 1 })
+	if st := status(s, "stuck"); st.LastError != context.DeadlineExceeded.Error() {
+		t.Fatalf("LastError = %q", st.LastError)
+	}
+}
+
+func TestStartAfterWaitsForReady(t *testing.T) {
+	ready := make(chan struct{})
+	var calls atomic.Int32
+	s := New(nopLogger{})
+	s.Add(Job{Name: "c", Interval: time.Hour, Run: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+	start(t, s, ready)
+
+	s.RunNow("c")
+	time.Sleep(20 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Fatal("job ran before ready")
+	}
+	close(ready)
+	// O RunNow pedido antes continua valendo.
+	eventually(t, "run after ready", func() bool { return calls.Load() == 1 })
+}
+
+func TestStartAfterCanceledBeforeReady(t *testing.T) {
+	s := New(nopLogger{})
+	s.Add(Job{Name: "c", Interval: time.Hour, Run: func(context.Context) error { return nil }})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartAfter(ctx, make(chan struct{}))
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait blocked on a canceled scheduler that never became ready")
+	}
+}
+
+func TestNextJitter(t *testing.T) {
+	cases := []struct {
+		jitter   float64
+		interval time.Duration
+		lo, hi   time.Duration
+	}{
+		{0.1, 10 * time.Second, 9 * time.Second, 11 * time.Second},
+		{0.5, time.Second, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{0, time.Second, time.Second, time.Second},
+		{0.1, 5, 5, 5}, // delta zero
+	}
+	for _, tc := range cases {
+		s := &Scheduler{jitter: tc.jitter}
+		for range 200 {
+			if d := s.next(tc.interval); d < tc.lo || d > tc.hi {
+				t.Fatalf("next(%v) with jitter %v = %v, want [%v, %v]", tc.interval, tc.jitter, d, tc.lo, tc.hi)
+			}
+		}
+	}
+}
@@ -1 +1,313 @@
 package outbox
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/data/local"
+	"github.com/you/aiceberg_agent/internal/data/repositories"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+var (
+	bucketItems = []byte("items")
+	bucketIDs   = []byte("ids")
+)
+
+// BoltStore: fila persistente em disco (bbolt). Cada item é gravado numa
+// transação com fsync, então sobrevive a restart/crash do processo. Um ID
+// já na fila não é gravado de novo (reenvios do relay e do replay são
+// idempotentes); registros ilegíveis são descartados ao serem lidos.
+// Implementa repositories.Store e local.OutboxDataSource.
+type BoltStore struct {
+	db *bolt.DB
+
+	mu    sync.Mutex
+	items int
+	bytes int64
+}
+
+// record é o formato gravado em disco. AuthHeader e DeadLetterAttempts não
+// são serializados pelo Envelope (json:"-"), por isso ficam ao lado do payload.
+type record struct {
+	Topic    string          `json:"t,omitempty"`
+	ID       string          `json:"id,omitempty"`
+	Auth     string          `json:"a,omitempty"`
+	Attempts int             `json:"n,omitempty"`
+	Payload  json.RawMessage `json:"p"`
+}
+
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &BoltStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		items, err := tx.CreateBucketIfNotExists(bucketItems)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(bucketIDs); err != nil {
+			return err
+		}
+		return items.ForEach(func(_, v []byte) error {
+			s.items++
+			s.bytes += int64(len(v))
+			return nil
+		})
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *BoltStore) Push(e entities.Envelope) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.put(record{Topic: "outbox", ID: e.ID, Auth: e.AuthHeader, Attempts: e.DeadLetterAttempts, Payload: payload})
+}
+
+func (s *BoltStore) Peek(n int) ([]entities.Envelope, error) {
+	out := make([]entities.Envelope, 0, n)
+	var corrupt [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketItems).Cursor()
+		for k, v := c.First(); k != nil && len(out) < n; k, v = c.Next() {
+			var rec record
+			var e entities.Envelope
+			if json.Unmarshal(v, &rec) != nil || json.Unmarshal(rec.Payload, &e) != nil {
+				corrupt = append(corrupt, append([]byte(nil), k...))
+				continue
+			}
+			e.AuthHeader = rec.Auth
+			e.DeadLetterAttempts = rec.Attempts
+			out = append(out, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, s.dropCorrupt(corrupt)
+}
+
+func (s *BoltStore) Delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.update(func(tx *bolt.Tx) (int, int64, error) {
+		items, idx := tx.Bucket(bucketItems), tx.Bucket(bucketIDs)
+		var n int
+		var b int64
+		for _, id := range ids {
+			key := idx.Get([]byte(id))
+			if key == nil {
+				continue
+			}
+			if v := items.Get(key); v != nil {
+				n++
+				b += int64(len(v))
+				if err := items.Delete(key); err != nil {
+					return 0, 0, err
+				}
+			}
+			if err := idx.Delete([]byte(id)); err != nil {
+				return 0, 0, err
+			}
+		}
+		return -n, -b, nil
+	})
+}
+
+// Len retorna contagem de itens e bytes ocupados pelos registros.
+func (s *BoltStore) Len() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items, s.bytes
+}
+
+// Append grava um payload bruto (OutboxDataSource). Se o payload for um
+// Envelope serializado, o envelope_id é indexado para permitir Delete.
+func (s *BoltStore) Append(topic string, payload []byte) error {
+	var probe struct {
+		ID string `json:"envelope_id"`
+	}
+	_ = json.Unmarshal(payload, &probe)
+	return s.put(record{Topic: topic, ID: probe.ID, Payload: payload})
+}
+
+// ReadBatch lê itens em ordem de chegada até maxBytes (sempre ao menos um).
+func (s *BoltStore) ReadBatch(maxBytes int) ([][]byte, [][]byte, error) {
+	var keys, payloads, corrupt [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketItems).Cursor()
+		total := 0
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				corrupt = append(corrupt, append([]byte(nil), k...))
+				continue
+			}
+			if len(payloads) > 0 && total+len(rec.Payload) > maxBytes {
+				break
+			}
+			total += len(rec.Payload)
+			keys = append(keys, append([]byte(nil), k...))
+			payloads = append(payloads, append([]byte(nil), rec.Payload...))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, payloads, s.dropCorrupt(corrupt)
+}
+
+// Commit remove os itens lidos via ReadBatch.
+func (s *BoltStore) Commit(keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return s.update(func(tx *bolt.Tx) (int, int64, error) {
+		items, idx := tx.Bucket(bucketItems), tx.Bucket(bucketIDs)
+		var n int
+		var b int64
+		for _, k := range keys {
+			v := items.Get(k)
+			if v == nil {
+				continue
+			}
+			var rec record
+			if err := json.Unmarshal(v, &rec); err == nil && rec.ID != "" {
+				if err := idx.Delete([]byte(rec.ID)); err != nil {
+					return 0, 0, err
+				}
+			}
+			n++
+			b += int64(len(v))
+			if err := items.Delete(k); err != nil {
+				return 0, 0, err
+			}
+		}
+		return -n, -b, nil
+	})
+}
+
+func (s *BoltStore) Close() error { return s.db.Close() }
+
+func (s *BoltStore) put(rec record) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.update(func(tx *bolt.Tx) (int, int64, error) {
+		items, idx := tx.Bucket(bucketItems), tx.Bucket(bucketIDs)
+		if rec.ID != "" {
+			if key := idx.Get([]byte(rec.ID)); key != nil && items.Get(key) != nil {
+				return 0, 0, nil
+			}
+		}
+		seq, err := items.NextSequence()
+		if err != nil {
+			return 0, 0, err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		if err := items.Put(key, raw); err != nil {
+			return 0, 0, err
+		}
+		if rec.ID != "" {
+			if err := idx.Put([]byte(rec.ID), key); err != nil {
+				return 0, 0, err
+			}
+		}
+		return 1, int64(len(raw)), nil
+	})
+}
+
+// dropCorrupt remove registros que não decodificam (ex.: gravados por uma
+// versão incompatível): lidos de novo a cada Peek, eles só ocupariam a quota.
+func (s *BoltStore) dropCorrupt(keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	removed := 0
+	err := s.update(func(tx *bolt.Tx) (int, int64, error) {
+		items, idx := tx.Bucket(bucketItems), tx.Bucket(bucketIDs)
+		bad := map[string]bool{}
+		var n int
+		var b int64
+		for _, k := range keys {
+			v := items.Get(k)
+			if v == nil {
+				continue
+			}
+			bad[string(k)] = true
+			n++
+			b += int64(len(v))
+			if err := items.Delete(k); err != nil {
+				return 0, 0, err
+			}
+		}
+		// O ID do registro pode estar ilegível: procura no índice pela chave.
+		var stale [][]byte
+		_ = idx.ForEach(func(id, key []byte) error {
+			if bad[string(key)] {
+				stale = append(stale, append([]byte(nil), id...))
+			}
+			return nil
+		})
+		for _, id := range stale {
+			if err := idx.Delete(id); err != nil {
+				return 0, 0, err
+			}
+		}
+		removed = n
+		return -n, -b, nil
+	})
+	if err != nil {
+		return err
+	}
+	metrics.EnvelopesDropped.Add(float64(removed), "outbox", "corrupt")
+	return nil
+}
+
+// update executa fn numa transação e aplica o delta de contagem só após o commit.
+func (s *BoltStore) update(fn func(tx *bolt.Tx) (int, int64, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var dn int
+	var db int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		dn, db, err = fn(tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.items += dn
+	s.bytes += db
+	return nil
+}
+
+// Garante conformidade.
+var (
+	_ repositories.Store     = (*BoltStore)(nil)
+	_ local.OutboxDataSource = (*BoltStore)(nil)
+)
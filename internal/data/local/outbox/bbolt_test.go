@@ -0,0 +1,233 @@
+package outbox
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/you/aiceberg_agent/internal/data/repositories"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+func openBolt(t *testing.T, path string) *BoltStore {
+	t.Helper()
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func env(id, kind string) entities.Envelope {
+	return entities.Envelope{ID: id, Kind: kind, AgentID: "a1", SchemaVersion: 1, Body: map[string]any{"id": id}}
+}
+
+func ids(envs []entities.Envelope) []string {
+	out := make([]string, 0, len(envs))
+	for _, e := range envs {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func push(t *testing.T, s repositories.Store, envs ...entities.Envelope) {
+	t.Helper()
+	for _, e := range envs {
+		if err := s.Push(e); err != nil {
+			t.Fatalf("Push(%s): %v", e.ID, err)
+		}
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := []struct {
+		name string
+		open func(t *testing.T) repositories.Store
+	}{
+		{"bolt", func(t *testing.T) repositories.Store { return openBolt(t, filepath.Join(t.TempDir(), "outbox.db")) }},
+		{"mem", func(*testing.T) repositories.Store { return NewMemStore() }},
+	}
+	cases := []struct {
+		name   string
+		push   []string
+		delete []string
+		want   []string
+	}{
+		{"ordem de chegada", []string{"a", "b", "c"}, nil, []string{"a", "b", "c"}},
+		{"id repetido não entra de novo", []string{"a", "b", "a"}, nil, []string{"a", "b"}},
+		{"delete", []string{"a", "b", "c"}, []string{"b", "x"}, []string{"a", "c"}},
+		{"id apagado pode voltar", []string{"a", "b"}, []string{"a"}, []string{"b"}},
+	}
+	for _, st := range stores {
+		for _, tc := range cases {
+			t.Run(st.name+"/"+tc.name, func(t *testing.T) {
+				s := st.open(t)
+				for _, id := range tc.push {
+					push(t, s, env(id, "metric"))
+				}
+				if err := s.Delete(tc.delete); err != nil {
+					t.Fatal(err)
+				}
+				got, err := s.Peek(10)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(ids(got), tc.want) {
+					t.Fatalf("Peek = %v, want %v", ids(got), tc.want)
+				}
+				if n, b := s.Len(); n != len(tc.want) || (n == 0) != (b == 0) {
+					t.Fatalf("Len = %d, %d bytes; want %d items", n, b, len(tc.want))
+				}
+				// Um ID apagado é aceito de novo e vai para o fim da fila.
+				for _, id := range tc.delete {
+					if slices.Contains(tc.push, id) {
+						push(t, s, env(id, "metric"))
+						got, _ := s.Peek(10)
+						if last := got[len(got)-1].ID; last != id {
+							t.Fatalf("re-pushed %s, tail = %s", id, last)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	s := openBolt(t, path)
+	e := env("a", "metric")
+	e.AuthHeader = "Token relayed"
+	e.DeadLetterAttempts = 2
+	push(t, s, e, env("b", "event"))
+	n, b := s.Len()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openBolt(t, path)
+	if n2, b2 := s.Len(); n2 != n || b2 != b {
+		t.Fatalf("Len after reopen = %d, %d; want %d, %d", n2, b2, n, b)
+	}
+	got, err := s.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(got), []string{"a", "b"}) {
+		t.Fatalf("Peek = %v", ids(got))
+	}
+	// Campos fora do JSON do envelope voltam do registro.
+	if got[0].AuthHeader != "Token relayed" || got[0].DeadLetterAttempts != 2 {
+		t.Fatalf("restored envelope = %+v", got[0])
+	}
+	// O ID continua indexado: não entra de novo após o restart.
+	push(t, s, env("a", "metric"))
+	if n3, _ := s.Len(); n3 != 2 {
+		t.Fatalf("Len after duplicate push = %d, want 2", n3)
+	}
+}
+
+func TestBoltStoreDropsCorrupt(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  string
+	}{
+		{"registro ilegível", "not json"},
+		{"payload ilegível", `{"t":"outbox","id":"bad","p":"\"x\""}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := openBolt(t, filepath.Join(t.TempDir(), "outbox.db"))
+			push(t, s, env("a", "metric"))
+			corruptKey := insertRaw(t, s, "bad", tc.raw)
+			push(t, s, env("b", "metric"))
+
+			got, err := s.Peek(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids(got), []string{"a", "b"}) {
+				t.Fatalf("Peek = %v", ids(got))
+			}
+			if n, _ := s.Len(); n != 2 {
+				t.Fatalf("Len = %d, want the corrupt record purged", n)
+			}
+			_ = s.db.View(func(tx *bolt.Tx) error {
+				if tx.Bucket(bucketItems).Get(corruptKey) != nil {
+					t.Error("corrupt item still stored")
+				}
+				if tx.Bucket(bucketIDs).Get([]byte("bad")) != nil {
+					t.Error("index entry of the corrupt item kept")
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestBoltStoreReadBatch(t *testing.T) {
+	s := openBolt(t, filepath.Join(t.TempDir(), "outbox.db"))
+	for _, p := range []string{`{"envelope_id":"a"}`, `{"envelope_id":"b"}`, `{"envelope_id":"c"}`} {
+		if err := s.Append("outbox", []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		maxBytes int
+		want     int
+	}{
+		{1, 1}, // sempre ao menos um
+		{len(`{"envelope_id":"a"}`) * 2, 2},
+		{1 << 20, 3},
+	}
+	for _, tc := range cases {
+		keys, payloads, err := s.ReadBatch(tc.maxBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != tc.want || len(payloads) != tc.want {
+			t.Fatalf("ReadBatch(%d) = %d items, want %d", tc.maxBytes, len(keys), tc.want)
+		}
+	}
+	keys, _, _ := s.ReadBatch(1)
+	if err := s.Commit(keys); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := s.Len(); n != 2 {
+		t.Fatalf("Len after Commit = %d, want 2", n)
+	}
+	// Commit também libera o ID no índice.
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketIDs).Get([]byte("a")) != nil {
+			t.Error("committed ID still indexed")
+		}
+		return nil
+	})
+}
+
+// insertRaw grava raw direto no bucket, indexado por id, como um registro
+// de uma versão incompatível.
+func insertRaw(t *testing.T, s *BoltStore, id, raw string) []byte {
+	t.Helper()
+	var key []byte
+	err := s.update(func(tx *bolt.Tx) (int, int64, error) {
+		items := tx.Bucket(bucketItems)
+		seq, err := items.NextSequence()
+		if err != nil {
+			return 0, 0, err
+		}
+		key = binary.BigEndian.AppendUint64(nil, seq)
+		if err := items.Put(key, []byte(raw)); err != nil {
+			return 0, 0, err
+		}
+		return 1, int64(len(raw)), tx.Bucket(bucketIDs).Put([]byte(id), key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
@@ -0,0 +1,302 @@
+package outbox
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+var bucketDead = []byte("deadletter")
+
+// DeadLetterEntry é um envelope recusado definitivamente, com o histórico da recusa.
+type DeadLetterEntry struct {
+	Queue     string            `json:"queue"`
+	Envelope  entities.Envelope `json:"envelope"`
+	Auth      string            `json:"auth,omitempty"`
+	Reason    string            `json:"reason"`
+	Status    int               `json:"status"`
+	Attempts  int               `json:"attempts"`
+	FirstSeen time.Time         `json:"first_seen"`
+	LastSeen  time.Time         `json:"last_seen"`
+	Replay    bool              `json:"replay,omitempty"`
+}
+
+// DeadLetterStore guarda envelopes recusados num arquivo bbolt separado do
+// outbox. O arquivo é aberto apenas durante cada operação, para que a CLI
+// (list/export/replay) funcione com o agente rodando.
+type DeadLetterStore struct {
+	path     string
+	maxItems int
+	mu       sync.Mutex
+}
+
+func NewDeadLetterStore(path string, maxItems int) *DeadLetterStore {
+	return &DeadLetterStore{path: path, maxItems: maxItems}
+}
+
+// For retorna a visão do dead-letter para uma fila (ex.: ingest, logs).
+func (d *DeadLetterStore) For(queue string) ports.DeadLetterRepo {
+	return &deadLetterQueue{store: d, queue: queue}
+}
+
+// Put registra (ou atualiza) a recusa de env; recusas repetidas do mesmo
+// envelope incrementam Attempts e preservam FirstSeen.
+func (d *DeadLetterStore) Put(queue string, env entities.Envelope, reason string, status int) error {
+	now := time.Now().UTC()
+	return d.update(func(b *bolt.Bucket) error {
+		e := DeadLetterEntry{Queue: queue, FirstSeen: now}
+		if raw := b.Get([]byte(env.ID)); raw != nil {
+			_ = json.Unmarshal(raw, &e)
+		} else {
+			e.Attempts = env.DeadLetterAttempts
+		}
+		env.DeadLetterAttempts = 0
+		e.Envelope = env
+		e.Auth = env.AuthHeader
+		e.Reason = reason
+		e.Status = status
+		e.Attempts++
+		e.LastSeen = now
+		e.Replay = false
+		raw, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(env.ID), raw); err != nil {
+			return err
+		}
+		return d.trim(b)
+	})
+}
+
+// List retorna todas as entradas ordenadas por LastSeen.
+func (d *DeadLetterStore) List() ([]DeadLetterEntry, error) {
+	var out []DeadLetterEntry
+	err := d.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			var e DeadLetterEntry
+			if err := json.Unmarshal(v, &e); err == nil {
+				e.Envelope.AuthHeader = e.Auth
+				out = append(out, e)
+			}
+			return nil
+		})
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.Before(out[j].LastSeen) })
+	return out, err
+}
+
+// MarkReplay marca ids (ou todos, se vazio) para reenvio; o agente em
+// execução os devolve ao outbox no próximo ciclo de flush.
+func (d *DeadLetterStore) MarkReplay(ids []string) (int, error) {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	n := 0
+	err := d.update(func(b *bolt.Bucket) error {
+		type kv struct{ k, v []byte }
+		var upd []kv
+		err := b.ForEach(func(k, v []byte) error {
+			if len(want) > 0 && !want[string(k)] {
+				return nil
+			}
+			var e DeadLetterEntry
+			if err := json.Unmarshal(v, &e); err != nil || e.Replay {
+				return nil
+			}
+			e.Replay = true
+			raw, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			upd = append(upd, kv{append([]byte(nil), k...), raw})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, u := range upd {
+			if err := b.Put(u.k, u.v); err != nil {
+				return err
+			}
+		}
+		n = len(upd)
+		return nil
+	})
+	return n, err
+}
+
+// Delete remove ids (ou tudo, se vazio) do dead-letter.
+func (d *DeadLetterStore) Delete(ids []string) (int, error) {
+	n := 0
+	err := d.update(func(b *bolt.Bucket) error {
+		if len(ids) == 0 {
+			var keys [][]byte
+			_ = b.ForEach(func(k, _ []byte) error {
+				keys = append(keys, append([]byte(nil), k...))
+				return nil
+			})
+			ids = make([]string, 0, len(keys))
+			for _, k := range keys {
+				ids = append(ids, string(k))
+			}
+		}
+		for _, id := range ids {
+			if b.Get([]byte(id)) == nil {
+				continue
+			}
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// Len retorna quantidade de entradas e bytes ocupados.
+func (d *DeadLetterStore) Len() (int, int64) {
+	var items int
+	var size int64
+	_ = d.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			items++
+			size += int64(len(v))
+			return nil
+		})
+	})
+	return items, size
+}
+
+// pendingReplay retorna os envelopes da fila marcados para reenvio, com o
+// número de tentativas em DeadLetterAttempts para sobreviver ao round-trip.
+func (d *DeadLetterStore) pendingReplay(queue string) ([]entities.Envelope, error) {
+	var out []entities.Envelope
+	err := d.view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			var e DeadLetterEntry
+			if err := json.Unmarshal(v, &e); err != nil || !e.Replay || e.Queue != queue {
+				return nil
+			}
+			env := e.Envelope
+			env.AuthHeader = e.Auth
+			env.DeadLetterAttempts = e.Attempts
+			out = append(out, env)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// trim descarta as entradas mais antigas quando maxItems é excedido. Conta
+// pelo cursor: b.Stats() não vê o Put ainda não commitado.
+func (d *DeadLetterStore) trim(b *bolt.Bucket) error {
+	if d.maxItems <= 0 {
+		return nil
+	}
+	n := 0
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && n <= d.maxItems; k, _ = c.Next() {
+		n++
+	}
+	if n <= d.maxItems {
+		return nil
+	}
+	type aged struct {
+		k  []byte
+		at time.Time
+	}
+	var all []aged
+	_ = b.ForEach(func(k, v []byte) error {
+		var e DeadLetterEntry
+		_ = json.Unmarshal(v, &e)
+		all = append(all, aged{append([]byte(nil), k...), e.LastSeen})
+		return nil
+	})
+	if len(all) <= d.maxItems {
+		return nil
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].at.Before(all[j].at) })
+	for _, a := range all[:len(all)-d.maxItems] {
+		if err := b.Delete(a.k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DeadLetterStore) open() (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0o755); err != nil {
+		return nil, err
+	}
+	return bolt.Open(d.path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+}
+
+func (d *DeadLetterStore) update(fn func(b *bolt.Bucket) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketDead)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+func (d *DeadLetterStore) view(fn func(b *bolt.Bucket) error) error {
+	if _, err := os.Stat(d.path); os.IsNotExist(err) {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, err := d.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketDead)
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+// deadLetterQueue adapta o store para ports.DeadLetterRepo de uma fila.
+type deadLetterQueue struct {
+	store *DeadLetterStore
+	queue string
+}
+
+func (q *deadLetterQueue) Put(env entities.Envelope, reason string, status int) error {
+	return q.store.Put(q.queue, env, reason, status)
+}
+
+func (q *deadLetterQueue) PendingReplay() ([]entities.Envelope, error) {
+	return q.store.pendingReplay(q.queue)
+}
+
+func (q *deadLetterQueue) Remove(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := q.store.Delete(ids)
+	return err
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newDeadLetter(t *testing.T, maxItems int) *DeadLetterStore {
+	t.Helper()
+	return NewDeadLetterStore(filepath.Join(t.TempDir(), "deadletter.db"), maxItems)
+}
+
+func entryIDs(list []DeadLetterEntry) []string {
+	out := make([]string, 0, len(list))
+	for _, e := range list {
+		out = append(out, e.Envelope.ID)
+	}
+	return out
+}
+
+func TestDeadLetterPutAccumulates(t *testing.T) {
+	d := newDeadLetter(t, 0)
+	e := env("a", "metric")
+	e.AuthHeader = "Token relayed"
+	if err := d.Put("ingest", e, "Bad Request", 400); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := d.List()
+	if err := d.Put("ingest", e, "Unprocessable Entity", 422); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("entries = %d, want 1", len(list))
+	}
+	got := list[0]
+	if got.Attempts != 2 || got.Status != 422 || got.Reason != "Unprocessable Entity" {
+		t.Errorf("entry = %+v", got)
+	}
+	if !got.FirstSeen.Equal(first[0].FirstSeen) {
+		t.Errorf("FirstSeen changed: %v → %v", first[0].FirstSeen, got.FirstSeen)
+	}
+	if got.Envelope.AuthHeader != "Token relayed" {
+		t.Errorf("auth = %q", got.Envelope.AuthHeader)
+	}
+}
+
+func TestDeadLetterReplayRoundTrip(t *testing.T) {
+	d := newDeadLetter(t, 0)
+	ingest, logs := d.For("ingest"), d.For("logs")
+	for _, id := range []string{"a", "b"} {
+		if err := ingest.Put(env(id, "metric"), "Bad Request", 400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := logs.Put(env("l", "event"), "Bad Request", 400); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		mark   []string
+		wantN  int
+		ingest []string
+		logs   []string
+	}{
+		{"nada marcado", nil, -1, nil, nil},
+		{"um id", []string{"a"}, 1, []string{"a"}, nil},
+		{"já marcado não conta de novo", []string{"a"}, 0, []string{"a"}, nil},
+		{"todos", []string{}, 2, []string{"a", "b"}, []string{"l"}},
+	}
+	for _, tc := range cases {
+		if tc.wantN >= 0 {
+			n, err := d.MarkReplay(tc.mark)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tc.wantN {
+				t.Errorf("%s: MarkReplay = %d, want %d", tc.name, n, tc.wantN)
+			}
+		}
+		gotIngest, _ := ingest.PendingReplay()
+		gotLogs, _ := logs.PendingReplay()
+		slices.Sort(tc.ingest)
+		idsIngest := ids(gotIngest)
+		slices.Sort(idsIngest)
+		if !slices.Equal(idsIngest, tc.ingest) || !slices.Equal(ids(gotLogs), tc.logs) {
+			t.Errorf("%s: pending = %v / %v, want %v / %v", tc.name, idsIngest, ids(gotLogs), tc.ingest, tc.logs)
+		}
+	}
+
+	// A contagem de tentativas acompanha o envelope pelo outbox sem ir para
+	// o JSON enviado ao backend.
+	pending, _ := ingest.PendingReplay()
+	replayed := pending[0]
+	if replayed.DeadLetterAttempts != 1 {
+		t.Fatalf("DeadLetterAttempts = %d, want 1", replayed.DeadLetterAttempts)
+	}
+	raw, _ := json.Marshal(replayed)
+	if strings.Contains(string(raw), "attempts") || strings.Contains(string(raw), "dlq") {
+		t.Fatalf("replayed envelope leaks the attempt count: %s", raw)
+	}
+	// Como no replay: vai para o outbox e sai do dead-letter.
+	box := openBolt(t, filepath.Join(t.TempDir(), "outbox.db"))
+	push(t, box, replayed)
+	if err := ingest.Remove([]string{replayed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	back, _ := box.Peek(1)
+
+	// Recusado de novo: a entrada volta sem a marca de replay e soma a tentativa.
+	if err := ingest.Put(back[0], "Bad Request", 400); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := d.List()
+	i := slices.IndexFunc(list, func(e DeadLetterEntry) bool { return e.Envelope.ID == replayed.ID })
+	if i < 0 || list[i].Attempts != 2 || list[i].Replay {
+		t.Fatalf("re-rejected entry = %+v; want attempts 2 and no replay mark", list)
+	}
+}
+
+func TestDeadLetterTrim(t *testing.T) {
+	d := newDeadLetter(t, 2)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := d.Put("ingest", env(id, "metric"), "Bad Request", 400); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, _ := d.List()
+	if got := entryIDs(list); !slices.Equal(got, []string{"b", "c"}) {
+		t.Fatalf("entries = %v, want the 2 most recent", got)
+	}
+	if n, err := d.Delete(nil); err != nil || n != 2 {
+		t.Fatalf("Delete(all) = %d, %v", n, err)
+	}
+	if n, _ := d.Len(); n != 0 {
+		t.Fatalf("Len after Delete(all) = %d", n)
+	}
+}
@@ -1,6 +1,7 @@
 package outbox
 
 import (
+	"encoding/json"
 	"sync"
 
 	"github.com/you/aiceberg_agent/internal/domain/entities"
@@ -10,22 +11,34 @@ import (
 type MemStore struct {
 	mu    sync.Mutex
 	queue []entities.Envelope
+	sizes map[string]int64
+	bytes int64
 }
 
-func NewMemStore() *MemStore { return &MemStore{} }
+func NewMemStore() *MemStore { return &MemStore{sizes: map[string]int64{}} }
 
 func (m *MemStore) Push(e entities.Envelope) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	// Como no BoltStore, um ID já na fila não entra de novo.
+	if _, dup := m.sizes[e.ID]; dup && e.ID != "" {
+		return nil
+	}
 	m.queue = append(m.queue, e)
+	m.sizes[e.ID] += int64(len(raw))
+	m.bytes += int64(len(raw))
 	return nil
 }
 
-// Len retorna contagem aproximada de itens e bytes (bytes não calculados aqui).
+// Len retorna contagem de itens e bytes (tamanho JSON dos envelopes).
 func (m *MemStore) Len() (int, int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	return len(m.queue), 0
+	return len(m.queue), m.bytes
 }
 
 func (m *MemStore) Peek(n int) ([]entities.Envelope, error) {
@@ -53,5 +66,9 @@ outer:
 		keep = append(keep, e)
 	}
 	m.queue = keep
+	for _, id := range ids {
+		m.bytes -= m.sizes[id]
+		delete(m.sizes, id)
+	}
 	return nil
 }
@@ -0,0 +1,314 @@
+package outbox
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/data/repositories"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// Políticas de overflow quando o outbox atinge um limite.
+const (
+	DropOldest = "drop-oldest"
+	DropNewest = "drop-newest"
+	DropByKind = "drop-by-kind"
+)
+
+// Limits define as quotas do outbox. Zero desativa o limite correspondente.
+type Limits struct {
+	MaxItems int
+	MaxBytes int64
+	MaxAge   time.Duration
+	Policy   string
+	// KindPriority lista os kinds do mais importante para o menos importante
+	// (usado por drop-by-kind). Kinds ausentes têm a menor prioridade.
+	KindPriority []string
+}
+
+// QuotaStore envolve um Store aplicando limites de itens, bytes e idade.
+// Quando o envelope entrante é o descartado, Push retorna ports.ErrDropped.
+type QuotaStore struct {
+	inner   repositories.Store
+	limits  Limits
+	mu      sync.Mutex
+	dropped atomic.Int64
+	now     func() time.Time
+	kinds   *kindIndex // montado no primeiro overflow com drop-by-kind
+}
+
+func NewQuotaStore(inner repositories.Store, l Limits) *QuotaStore {
+	if l.Policy == "" {
+		l.Policy = DropOldest
+	}
+	return &QuotaStore{inner: inner, limits: l, now: time.Now}
+}
+
+// SetLimits troca as quotas em tempo de execução (reload de config); os
+// novos limites valem a partir do próximo Push/Peek.
+func (q *QuotaStore) SetLimits(l Limits) {
+	if l.Policy == "" {
+		l.Policy = DropOldest
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.limits = l
+}
+
+// Dropped retorna o total de envelopes descartados desde o start.
+func (q *QuotaStore) Dropped() int64 { return q.dropped.Load() }
+
+func (q *QuotaStore) Push(e entities.Envelope) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.expire(); err != nil {
+		return err
+	}
+	size := envSize(e)
+	for q.over(1, size) {
+		var victim entities.Envelope
+		switch q.limits.Policy {
+		case DropNewest:
+			return q.dropIncoming(e)
+		case DropByKind:
+			v, ok, err := q.lowestPriority(e.Kind)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return q.dropIncoming(e)
+			}
+			victim = v
+		default:
+			head, err := q.inner.Peek(1)
+			if err != nil {
+				return err
+			}
+			if len(head) == 0 {
+				// Envelope sozinho excede a quota.
+				return q.dropIncoming(e)
+			}
+			victim = head[0]
+		}
+		removed, err := q.drop([]entities.Envelope{victim}, "overflow")
+		if err != nil {
+			return err
+		}
+		if removed == 0 {
+			// Sem progresso (ex.: envelope sem ID); descarta o entrante.
+			return q.dropIncoming(e)
+		}
+	}
+	if err := q.inner.Push(e); err != nil {
+		return err
+	}
+	if q.kinds != nil {
+		q.kinds.add(e)
+	}
+	return nil
+}
+
+func (q *QuotaStore) Peek(n int) ([]entities.Envelope, error) {
+	q.mu.Lock()
+	err := q.expire()
+	q.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return q.inner.Peek(n)
+}
+
+func (q *QuotaStore) Delete(ids []string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.inner.Delete(ids); err != nil {
+		return err
+	}
+	if q.kinds != nil {
+		q.kinds.remove(ids)
+	}
+	return nil
+}
+
+func (q *QuotaStore) Len() (int, int64) { return q.inner.Len() }
+
+func (q *QuotaStore) over(addItems int, addBytes int64) bool {
+	items, bytes := q.inner.Len()
+	if q.limits.MaxItems > 0 && items+addItems > q.limits.MaxItems {
+		return true
+	}
+	if q.limits.MaxBytes > 0 && bytes+addBytes > q.limits.MaxBytes {
+		return true
+	}
+	return false
+}
+
+// expire remove do início da fila os envelopes mais velhos que MaxAge.
+func (q *QuotaStore) expire() error {
+	if q.limits.MaxAge <= 0 {
+		return nil
+	}
+	cutoff := q.now().Add(-q.limits.MaxAge).UnixMilli()
+	for {
+		head, err := q.inner.Peek(50)
+		if err != nil {
+			return err
+		}
+		var old []entities.Envelope
+		for _, e := range head {
+			if e.TSUnixMs >= cutoff {
+				break
+			}
+			old = append(old, e)
+		}
+		if len(old) == 0 {
+			return nil
+		}
+		removed, err := q.drop(old, "expired")
+		if err != nil {
+			return err
+		}
+		if removed == 0 || len(old) < len(head) {
+			return nil
+		}
+	}
+}
+
+// lowestPriority escolhe o envelope mais antigo do kind de menor prioridade
+// que não seja mais importante que o kind entrante. Usa o índice por kind;
+// só a primeira chamada lê a fila inteira para montá-lo.
+func (q *QuotaStore) lowestPriority(incoming string) (entities.Envelope, bool, error) {
+	if q.kinds == nil {
+		items, _ := q.inner.Len()
+		all, err := q.inner.Peek(items)
+		if err != nil {
+			return entities.Envelope{}, false, err
+		}
+		q.kinds = newKindIndex()
+		for _, e := range all {
+			q.kinds.add(e)
+		}
+	}
+	limit := q.rank(incoming)
+	var best indexed
+	bestRank := -1
+	for kind, queue := range q.kinds.queues {
+		r := q.rank(kind)
+		if r < limit || r < bestRank || len(queue) == 0 {
+			continue
+		}
+		if r > bestRank || queue[0].seq < best.seq {
+			best, bestRank = queue[0], r
+		}
+	}
+	return entities.Envelope{ID: best.id, Kind: best.kind, Sub: best.sub}, best.id != "", nil
+}
+
+// rank: quanto maior, menos importante.
+func (q *QuotaStore) rank(kind string) int {
+	for i, k := range q.limits.KindPriority {
+		if k == kind {
+			return i
+		}
+	}
+	return len(q.limits.KindPriority)
+}
+
+// drop remove envs do store e contabiliza quantos saíram de fato.
+func (q *QuotaStore) drop(envs []entities.Envelope, reason string) (int, error) {
+	ids := make([]string, 0, len(envs))
+	for _, e := range envs {
+		ids = append(ids, e.ID)
+	}
+	before, _ := q.inner.Len()
+	if err := q.inner.Delete(ids); err != nil {
+		return 0, err
+	}
+	if q.kinds != nil {
+		q.kinds.remove(ids)
+	}
+	after, _ := q.inner.Len()
+	removed := before - after
+	if removed > 0 {
+		q.dropped.Add(int64(removed))
+		for _, e := range envs[:min(removed, len(envs))] {
+			metrics.EnvelopesDropped.Inc(e.Source(), reason)
+		}
+	}
+	return removed, nil
+}
+
+// dropIncoming descarta o envelope entrante (não chegou ao store).
+func (q *QuotaStore) dropIncoming(e entities.Envelope) error {
+	q.dropped.Add(1)
+	metrics.EnvelopesDropped.Inc(e.Source(), "overflow")
+	return ports.ErrDropped
+}
+
+// kindIndex guarda, por kind, os IDs na fila em ordem de chegada, para o
+// drop-by-kind achar a vítima sem decodificar a fila inteira.
+type kindIndex struct {
+	seq    uint64
+	queues map[string][]indexed
+	kindOf map[string]string // id → kind
+}
+
+type indexed struct {
+	id, kind, sub string
+	seq           uint64
+}
+
+func newKindIndex() *kindIndex {
+	return &kindIndex{queues: map[string][]indexed{}, kindOf: map[string]string{}}
+}
+
+func (x *kindIndex) add(e entities.Envelope) {
+	if e.ID == "" {
+		return
+	}
+	x.seq++
+	x.queues[e.Kind] = append(x.queues[e.Kind], indexed{id: e.ID, kind: e.Kind, sub: e.Sub, seq: x.seq})
+	x.kindOf[e.ID] = e.Kind
+}
+
+// remove esquece ids; as filas andam até o primeiro ID ainda presente (a
+// fila do outbox é consumida pelo início, então isso basta).
+func (x *kindIndex) remove(ids []string) {
+	touched := map[string]bool{}
+	for _, id := range ids {
+		if kind, ok := x.kindOf[id]; ok {
+			delete(x.kindOf, id)
+			touched[kind] = true
+		}
+	}
+	for kind := range touched {
+		queue := x.queues[kind]
+		for len(queue) > 0 {
+			if _, ok := x.kindOf[queue[0].id]; ok {
+				break
+			}
+			queue = queue[1:]
+		}
+		if len(queue) == 0 {
+			delete(x.queues, kind)
+		} else {
+			x.queues[kind] = queue
+		}
+	}
+}
+
+func envSize(e entities.Envelope) int64 {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return 0
+	}
+	return int64(len(raw))
+}
+
+// Garante conformidade.
+var _ repositories.Store = (*QuotaStore)(nil)
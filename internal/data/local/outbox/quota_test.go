@@ -0,0 +1,214 @@
+package outbox
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// item é "kind:id"; sem ":" o kind é metric.
+func item(s string) entities.Envelope {
+	kind, id, ok := strings.Cut(s, ":")
+	if !ok {
+		kind, id = "metric", s
+	}
+	return env(id, kind)
+}
+
+func TestQuotaPolicies(t *testing.T) {
+	cases := []struct {
+		name        string
+		limits      Limits
+		push        []string
+		want        []string
+		wantDropped int64
+		// rejected são os IDs cujo Push retorna ErrDropped.
+		rejected []string
+	}{
+		{
+			name:        "sem limite",
+			limits:      Limits{},
+			push:        []string{"a", "b", "c"},
+			want:        []string{"a", "b", "c"},
+			wantDropped: 0,
+		},
+		{
+			name:        "drop-oldest",
+			limits:      Limits{MaxItems: 2},
+			push:        []string{"a", "b", "c", "d"},
+			want:        []string{"c", "d"},
+			wantDropped: 2,
+		},
+		{
+			name:        "drop-newest",
+			limits:      Limits{MaxItems: 2, Policy: DropNewest},
+			push:        []string{"a", "b", "c", "d"},
+			want:        []string{"a", "b"},
+			wantDropped: 2,
+			rejected:    []string{"c", "d"},
+		},
+		{
+			name:        "drop-by-kind descarta o menos importante",
+			limits:      Limits{MaxItems: 2, Policy: DropByKind, KindPriority: []string{"event", "metric"}},
+			push:        []string{"event:e1", "metric:m1", "event:e2"},
+			want:        []string{"e1", "e2"},
+			wantDropped: 1,
+		},
+		{
+			name:        "drop-by-kind: kind fora da lista vale menos",
+			limits:      Limits{MaxItems: 2, Policy: DropByKind, KindPriority: []string{"event", "metric"}},
+			push:        []string{"metric:m1", "heartbeat:h1", "metric:m2"},
+			want:        []string{"m1", "m2"},
+			wantDropped: 1,
+		},
+		{
+			name:        "drop-by-kind: mesmo kind sai o mais antigo",
+			limits:      Limits{MaxItems: 2, Policy: DropByKind, KindPriority: []string{"event", "metric"}},
+			push:        []string{"metric:m1", "metric:m2", "metric:m3"},
+			want:        []string{"m2", "m3"},
+			wantDropped: 1,
+		},
+		{
+			name:        "drop-by-kind não descarta mais importante",
+			limits:      Limits{MaxItems: 2, Policy: DropByKind, KindPriority: []string{"event", "metric"}},
+			push:        []string{"event:e1", "event:e2", "metric:m1"},
+			want:        []string{"e1", "e2"},
+			wantDropped: 1,
+			rejected:    []string{"m1"},
+		},
+		{
+			name:        "envelope maior que a quota",
+			limits:      Limits{MaxBytes: 10},
+			push:        []string{"a"},
+			want:        nil,
+			wantDropped: 1,
+			rejected:    []string{"a"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewQuotaStore(NewMemStore(), tc.limits)
+			var rejected []string
+			for _, s := range tc.push {
+				e := item(s)
+				err := q.Push(e)
+				switch {
+				case errors.Is(err, ports.ErrDropped):
+					rejected = append(rejected, e.ID)
+				case err != nil:
+					t.Fatalf("Push(%s): %v", s, err)
+				}
+			}
+			got, err := q.Peek(10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(ids(got), tc.want) {
+				t.Errorf("queue = %v, want %v", ids(got), tc.want)
+			}
+			if !slices.Equal(rejected, tc.rejected) {
+				t.Errorf("ErrDropped for %v, want %v", rejected, tc.rejected)
+			}
+			if d := q.Dropped(); d != tc.wantDropped {
+				t.Errorf("Dropped = %d, want %d", d, tc.wantDropped)
+			}
+		})
+	}
+}
+
+// O índice por kind é montado no primeiro overflow e precisa acompanhar os
+// Deletes (acks) feitos depois dele.
+func TestQuotaKindIndexFollowsDeletes(t *testing.T) {
+	q := NewQuotaStore(NewMemStore(), Limits{MaxItems: 3, Policy: DropByKind, KindPriority: []string{"event", "metric"}})
+	steps := []struct {
+		push   string
+		delete []string
+		want   []string
+	}{
+		{push: "metric:m1", want: []string{"m1"}},
+		{push: "metric:m2", want: []string{"m1", "m2"}},
+		{push: "event:e1", want: []string{"m1", "m2", "e1"}},
+		{push: "event:e2", want: []string{"m2", "e1", "e2"}}, // monta o índice
+		{delete: []string{"m2"}, want: []string{"e1", "e2"}},
+		{push: "metric:m3", want: []string{"e1", "e2", "m3"}},
+		{push: "event:e3", want: []string{"e1", "e2", "e3"}},
+		{delete: []string{"e1"}, want: []string{"e2", "e3"}},
+		{push: "metric:m4", want: []string{"e2", "e3", "m4"}},
+		{push: "event:e4", want: []string{"e2", "e3", "e4"}},
+	}
+	for i, st := range steps {
+		if st.push != "" {
+			if err := q.Push(item(st.push)); err != nil {
+				t.Fatalf("step %d: Push(%s): %v", i, st.push, err)
+			}
+		}
+		if err := q.Delete(st.delete); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := q.Peek(10)
+		if !slices.Equal(ids(got), st.want) {
+			t.Fatalf("step %d: queue = %v, want %v", i, ids(got), st.want)
+		}
+	}
+	// Cada overflow descartou um metric: m1, m3 e m4.
+	if d := q.Dropped(); d != 3 {
+		t.Fatalf("Dropped = %d, want 3", d)
+	}
+}
+
+func TestQuotaMaxAge(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	q := NewQuotaStore(NewMemStore(), Limits{MaxAge: time.Minute})
+	q.now = func() time.Time { return now }
+	for _, tc := range []struct {
+		id  string
+		age time.Duration
+	}{
+		{"old1", 2 * time.Minute},
+		{"old2", 61 * time.Second},
+		{"fresh", 30 * time.Second},
+	} {
+		e := env(tc.id, "metric")
+		e.TSUnixMs = now.Add(-tc.age).UnixMilli()
+		if err := q.inner.Push(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := q.Peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(got), []string{"fresh"}) {
+		t.Fatalf("queue = %v, want only the fresh envelope", ids(got))
+	}
+	if q.Dropped() != 2 {
+		t.Fatalf("Dropped = %d, want 2", q.Dropped())
+	}
+}
+
+func TestQuotaSetLimits(t *testing.T) {
+	q := NewQuotaStore(NewMemStore(), Limits{MaxItems: 10})
+	for _, s := range []string{"a", "b", "c"} {
+		if err := q.Push(item(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.SetLimits(Limits{MaxItems: 2, Policy: DropNewest})
+	if err := q.Push(item("d")); !errors.Is(err, ports.ErrDropped) {
+		t.Fatalf("Push over the new limit = %v, want ErrDropped", err)
+	}
+	q.SetLimits(Limits{MaxItems: 2})
+	if err := q.Push(item("e")); err != nil {
+		t.Fatal(err)
+	}
+	// drop-oldest volta ao limite descartando o início.
+	got, _ := q.Peek(10)
+	if !slices.Equal(ids(got), []string{"c", "e"}) {
+		t.Fatalf("queue = %v", ids(got))
+	}
+}
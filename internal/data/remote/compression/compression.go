@@ -0,0 +1,59 @@
+package compression
+
+import (
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+	None = "none"
+)
+
+// ErrUnsupported indica um Content-Encoding desconhecido.
+var ErrUnsupported = errors.New("unsupported content-encoding")
+
+// New retorna o compressor para name; "none" (ou vazio) retorna nil.
+func New(name string) (ports.Compressor, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case Gzip:
+		return NewGzip(), nil
+	case Zstd:
+		return NewZstd(), nil
+	case "", None, "identity":
+		return nil, nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// Chain retorna os compressores a tentar, do preferido ao mais simples.
+// Usado para negociar com servidores que respondem 415 ao encoding.
+func Chain(preferred string) []ports.Compressor {
+	var out []ports.Compressor
+	switch strings.ToLower(strings.TrimSpace(preferred)) {
+	case Zstd:
+		out = append(out, NewZstd(), NewGzip())
+	case Gzip:
+		out = append(out, NewGzip())
+	}
+	return append(out, nil)
+}
+
+// NewReader devolve um leitor descomprimido conforme o Content-Encoding.
+func NewReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(r), nil
+	case Gzip:
+		return newGzipReader(r)
+	case Zstd:
+		return newZstdReader(r)
+	default:
+		return nil, ErrUnsupported
+	}
+}
@@ -0,0 +1,106 @@
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	in := []byte(strings.Repeat(`{"kind":"metric","body":{"cpu":0.42}}`, 200))
+	for _, name := range []string{Gzip, Zstd, " ZSTD "} {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := c.Compress(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) >= len(in) {
+				t.Fatalf("compressed %d bytes into %d", len(in), len(out))
+			}
+			// O compressor é reaproveitado entre envios.
+			again, _ := c.Compress(in)
+			rd, err := NewReader(c.Encoding(), bytes.NewReader(again))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rd.Close()
+			got, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, in) {
+				t.Fatalf("round trip changed the payload (%d bytes)", len(got))
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	cases := []struct {
+		encoding string
+		wantErr  error
+	}{
+		{"", nil},
+		{"identity", nil},
+		{"br", ErrUnsupported},
+		{"deflate", ErrUnsupported},
+		{"gzip, zstd", ErrUnsupported},
+	}
+	for _, tc := range cases {
+		rd, err := NewReader(tc.encoding, strings.NewReader("plain"))
+		if !errors.Is(err, tc.wantErr) {
+			t.Errorf("NewReader(%q) err = %v, want %v", tc.encoding, err, tc.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if b, _ := io.ReadAll(rd); string(b) != "plain" {
+			t.Errorf("NewReader(%q) = %q", tc.encoding, b)
+		}
+	}
+	// Corpo que não é do formato declarado falha na leitura, não no pânico.
+	if rd, err := NewReader(Gzip, strings.NewReader("plain")); err == nil {
+		if _, err := io.ReadAll(rd); err == nil {
+			t.Error("gzip reader accepted a plain body")
+		}
+	}
+}
+
+func TestNewAndChain(t *testing.T) {
+	for _, name := range []string{"", "none", "identity"} {
+		if c, err := New(name); c != nil || err != nil {
+			t.Errorf("New(%q) = %v, %v; want no compressor", name, c, err)
+		}
+	}
+	if _, err := New("lz4"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("New(lz4) err = %v", err)
+	}
+	cases := []struct {
+		preferred string
+		want      []string
+	}{
+		{"zstd", []string{Zstd, Gzip, "identity"}},
+		{"gzip", []string{Gzip, "identity"}},
+		{"none", []string{"identity"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, c := range Chain(tc.preferred) {
+			if c == nil {
+				got = append(got, "identity")
+			} else {
+				got = append(got, c.Encoding())
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("Chain(%q) = %v, want %v", tc.preferred, got, tc.want)
+		}
+	}
+}
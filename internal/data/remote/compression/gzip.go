@@ -0,0 +1,42 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+type gzipCompressor struct {
+	pool sync.Pool
+}
+
+func NewGzip() ports.Compressor {
+	return &gzipCompressor{pool: sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}}}
+}
+
+func (g *gzipCompressor) Encoding() string { return Gzip }
+
+func (g *gzipCompressor) Compress(in []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := g.pool.Get().(*gzip.Writer)
+	defer g.pool.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(in); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func newGzipReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
@@ -0,0 +1,33 @@
+package compression
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+type zstdCompressor struct {
+	enc *zstd.Encoder
+}
+
+func NewZstd() ports.Compressor {
+	// Encoder sem writer é seguro para EncodeAll concorrente.
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	return &zstdCompressor{enc: enc}
+}
+
+func (z *zstdCompressor) Encoding() string { return Zstd }
+
+func (z *zstdCompressor) Compress(in []byte) ([]byte, error) {
+	return z.enc.EncodeAll(in, make([]byte, 0, len(in)/4)), nil
+}
+
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/httpx"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// artifactClient implementa o upload retomável de bundles:
+//
+//	POST /v1/agent/artifacts                 registra o bundle → {"offset": n}
+//	PUT  /v1/agent/artifacts/{id}?offset=n   envia um pedaço   → {"offset": próximo}
+//	POST /v1/agent/artifacts/{id}/complete   fecha e confere o SHA-256
+//
+// Em 409 (offset divergente) o backend responde o offset que tem e o envio
+// continua dali. Usa o cliente e o circuit breaker do transport de ingest:
+// backend fora do ar ou com 429 pausa os dois juntos. Em modo relay vai
+// pelo hub, que repassa para a API.
+type artifactClient struct {
+	cl      *http.Client
+	br      *retry.Breaker
+	timeout time.Duration
+	base    string
+	cfg     config.Config
+}
+
+// NewArtifactUploader cria o uploader sobre tx (o transport de ingest).
+func NewArtifactUploader(cfg config.Config, tx ports.Transport) ports.ArtifactUploader {
+	c := &artifactClient{cfg: cfg, base: cfg.APIEndpoint("")}
+	if cfg.Mode() == "relay" {
+		c.base = strings.TrimRight(cfg.HubURL, "/")
+	}
+	var cl *http.Client
+	if l, ok := tx.(link); ok {
+		cl, c.br = l.link()
+	} else {
+		cl, c.br = &http.Client{Timeout: 10 * time.Second}, newBreaker(cfg)
+	}
+	// Mesma conexão, sem o timeout fixo: o prazo vai por requisição (ver deadline).
+	shared := *cl
+	shared.Timeout = 0
+	c.cl, c.timeout = &shared, cl.Timeout
+	return c
+}
+
+func (c *artifactClient) Begin(ctx context.Context, b entities.ArtifactBundle) (int64, error) {
+	raw, err := json.Marshal(map[string]any{
+		"bundle_id":  b.ID,
+		"cmd_id":     b.CmdID,
+		"size":       b.Size,
+		"sha256":     b.SHA256,
+		"format":     "tar+gzip",
+		"created_at": b.CreatedAt,
+	})
+	if err != nil {
+		return 0, err
+	}
+	off, _, err := c.do(ctx, http.MethodPost, c.base+"/v1/agent/artifacts", "application/json", raw)
+	return off, err
+}
+
+func (c *artifactClient) Chunk(ctx context.Context, b entities.ArtifactBundle, offset int64, chunk []byte) (int64, error) {
+	u := c.base + "/v1/agent/artifacts/" + url.PathEscape(b.ID) + "?offset=" + strconv.FormatInt(offset, 10)
+	off, ok, err := c.do(ctx, http.MethodPut, u, "application/octet-stream", chunk)
+	if err == nil && !ok {
+		off = offset + int64(len(chunk))
+	}
+	return off, err
+}
+
+func (c *artifactClient) Complete(ctx context.Context, b entities.ArtifactBundle) error {
+	_, _, err := c.do(ctx, http.MethodPost, c.base+"/v1/agent/artifacts/"+url.PathEscape(b.ID)+"/complete", "application/json", []byte("{}"))
+	return err
+}
+
+// do envia a requisição e lê o offset da resposta (ok=false se ausente).
+// 409 não é erro: o offset devolvido é onde continuar. Como no ingest,
+// falhas de rede e 429/5xx contam no breaker e trazem o Retry-After.
+func (c *artifactClient) do(ctx context.Context, method, u, ctype string, body []byte) (int64, bool, error) {
+	if !c.br.Allow() {
+		return 0, false, retry.ErrOpen
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.deadline(len(body)))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", ctype)
+	httpx.SetAuth(req, c.cfg)
+	start := time.Now()
+	resp, err := c.cl.Do(req)
+	if err != nil {
+		metrics.ObserveRequest("/v1/agent/artifacts", start, 0, err)
+		c.br.Failure(0)
+		return 0, false, err
+	}
+	// Sem o bundle_id no label: o endpoint é um só para as métricas.
+	metrics.ObserveRequest("/v1/agent/artifacts", start, resp.StatusCode, nil)
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := record(c.br, resp); err != nil {
+		return 0, false, err
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusConflict {
+		return 0, false, &httpStatusErr{code: resp.StatusCode}
+	}
+	var or struct {
+		Offset *int64 `json:"offset"`
+	}
+	if json.Unmarshal(raw, &or) != nil || or.Offset == nil {
+		if resp.StatusCode == http.StatusConflict {
+			return 0, false, &httpStatusErr{code: resp.StatusCode}
+		}
+		return 0, false, nil
+	}
+	return *or.Offset, true, nil
+}
+
+// deadline é o prazo de uma requisição: o do transport mais o tempo de
+// mandar n bytes a 64KB/s, para pedaços grandes em links lentos.
+func (c *artifactClient) deadline(n int) time.Duration {
+	return c.timeout + time.Duration(n/(64*1024))*time.Second
+}
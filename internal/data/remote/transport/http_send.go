@@ -0,0 +1,233 @@
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/data/remote/compression"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+func newBreaker(cfg config.Config) *retry.Breaker {
+	return retry.NewBreaker(cfg.BreakerFailures, retry.Backoff{Base: cfg.RetryBaseDelay, Max: cfg.RetryMaxDelay})
+}
+
+// minCompressBytes: payloads menores vão sem compressão.
+const minCompressBytes = 1024
+
+// codec negocia o Content-Encoding: começa pelo configurado e, se o servidor
+// responder 415, passa para o próximo da cadeia (zstd → gzip → identity).
+type codec struct {
+	mu    sync.Mutex
+	chain []ports.Compressor
+	idx   int
+}
+
+func newCodec(cfg config.Config) *codec {
+	return &codec{chain: compression.Chain(cfg.Compression)}
+}
+
+func (c *codec) current() ports.Compressor {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.chain[c.idx]
+}
+
+func (c *codec) downgrade(from ports.Compressor) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.chain[c.idx] != from {
+		return true // outro envio já rebaixou
+	}
+	if c.idx+1 >= len(c.chain) {
+		return false
+	}
+	c.idx++
+	return true
+}
+
+// encode comprime raw com o codec atual; retorna o corpo e o Content-Encoding.
+func (c *codec) encode(raw []byte) ([]byte, ports.Compressor, error) {
+	comp := c.current()
+	if comp == nil || len(raw) < minCompressBytes {
+		return raw, nil, nil
+	}
+	out, err := comp.Compress(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, comp, nil
+}
+
+// send envia o payload passando pelo circuit breaker e devolve o resultado por envelope.
+// newReq monta a requisição para o corpo (já comprimido, se for o caso).
+// 429/5xx e falhas de rede contam como falha do breaker; Retry-After é respeitado.
+// Recusas definitivas (4xx não retentáveis) voltam em SendResult.Rejected sem erro.
+func send(cl *http.Client, br *retry.Breaker, cd *codec, newReq func(body []byte) (*http.Request, error), raw []byte, batch []entities.Envelope) (entities.SendResult, error) {
+	if !br.Allow() {
+		return entities.SendResult{}, retry.ErrOpen
+	}
+	var resp *http.Response
+	for {
+		body, comp, err := cd.encode(raw)
+		if err != nil {
+			return entities.SendResult{}, err
+		}
+		req, err := newReq(body)
+		if err != nil {
+			return entities.SendResult{}, err
+		}
+		if comp != nil {
+			req.Header.Set("Content-Encoding", comp.Encoding())
+		}
+		start := time.Now()
+		resp, err = cl.Do(req)
+		if err != nil {
+			metrics.ObserveRequest(req.URL.Path, start, 0, err)
+			br.Failure(0)
+			return entities.SendResult{}, err
+		}
+		metrics.ObserveRequest(req.URL.Path, start, resp.StatusCode, nil)
+		if resp.StatusCode != http.StatusUnsupportedMediaType || comp == nil || !cd.downgrade(comp) {
+			break
+		}
+		resp.Body.Close()
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := record(br, resp); err != nil {
+		return entities.SendResult{}, err
+	}
+	if resp.StatusCode < 300 {
+		return parseResult(batch, body), nil
+	}
+	if resp.StatusCode == http.StatusRequestEntityTooLarge && len(batch) > 1 {
+		return split(cl, br, cd, newReq, batch)
+	}
+	if !permanentStatus(resp.StatusCode) {
+		return entities.SendResult{}, &httpStatusErr{code: resp.StatusCode}
+	}
+	reason := http.StatusText(resp.StatusCode)
+	if len(body) > 0 {
+		reason += ": " + truncate(string(body), 512)
+	}
+	res := entities.SendResult{Rejected: make([]entities.Rejection, 0, len(batch))}
+	for _, e := range batch {
+		res.Rejected = append(res.Rejected, entities.Rejection{ID: e.ID, Reason: reason, Status: resp.StatusCode})
+	}
+	return res, nil
+}
+
+// split reenvia um lote recusado com 413 em duas metades (e assim por
+// diante); só um envelope sozinho acima do limite é recusado de vez. Se a
+// segunda metade falhar, a primeira ainda conta: os IDs que faltam no
+// resultado voltam no próximo flush.
+func split(cl *http.Client, br *retry.Breaker, cd *codec, newReq func(body []byte) (*http.Request, error), batch []entities.Envelope) (entities.SendResult, error) {
+	var res entities.SendResult
+	half := len(batch) / 2
+	for i, part := range [][]entities.Envelope{batch[:half], batch[half:]} {
+		raw, err := json.Marshal(part)
+		if err != nil {
+			return entities.SendResult{}, err
+		}
+		r, err := send(cl, br, cd, newReq, raw, part)
+		if err != nil {
+			if i == 0 {
+				return entities.SendResult{}, err
+			}
+			return res, nil
+		}
+		res.Accepted = append(res.Accepted, r.Accepted...)
+		res.Rejected = append(res.Rejected, r.Rejected...)
+	}
+	return res, nil
+}
+
+// record registra a resposta no breaker: 429/5xx contam como falha e voltam
+// como erro, com o Retry-After do servidor; o resto conta como sucesso.
+func record(br *retry.Breaker, resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		br.Success()
+		return nil
+	}
+	serr := &httpStatusErr{code: resp.StatusCode}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		serr.retryAfter = retry.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	br.Failure(serr.retryAfter)
+	return serr
+}
+
+// link expõe o cliente HTTP e o breaker de um transport, para que outros
+// envios ao mesmo destino (ex.: upload de artefatos) os compartilhem.
+type link interface {
+	link() (*http.Client, *retry.Breaker)
+}
+
+// permanentStatus indica recusas em que reenviar o mesmo payload não adianta.
+// 401/403/404/408 ficam de fora: costumam ser configuração/infra e se
+// resolvem; voltam como erro com StatusCode() e o FlushOutbox decide
+// (pausa as credenciais do agente, dead-letter as de agentes do hub).
+func permanentStatus(code int) bool {
+	switch code {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusGone, http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// ingestResponse é a resposta opcional do backend listando aceitos/recusados.
+type ingestResponse struct {
+	Accepted []string `json:"accepted"`
+	Rejected []struct {
+		ID     string `json:"envelope_id"`
+		Reason string `json:"reason"`
+		Status int    `json:"status"`
+	} `json:"rejected"`
+}
+
+// parseResult interpreta a resposta 2xx; sem listas explícitas, todo o batch foi aceito.
+func parseResult(batch []entities.Envelope, body []byte) entities.SendResult {
+	var ir ingestResponse
+	if len(body) > 0 && json.Unmarshal(body, &ir) == nil && (ir.Accepted != nil || ir.Rejected != nil) {
+		res := entities.SendResult{Accepted: ir.Accepted}
+		for _, r := range ir.Rejected {
+			if r.Status == 0 {
+				r.Status = http.StatusUnprocessableEntity
+			}
+			res.Rejected = append(res.Rejected, entities.Rejection{ID: r.ID, Reason: r.Reason, Status: r.Status})
+		}
+		return res
+	}
+	res := entities.SendResult{Accepted: make([]string, 0, len(batch))}
+	for _, e := range batch {
+		res.Accepted = append(res.Accepted, e.ID)
+	}
+	return res
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+type httpStatusErr struct {
+	code       int
+	retryAfter time.Duration
+}
+
+func (e *httpStatusErr) Error() string { return http.StatusText(e.code) }
+
+func (e *httpStatusErr) StatusCode() int { return e.code }
+
+func (e *httpStatusErr) RetryAfter() time.Duration { return e.retryAfter }
@@ -0,0 +1,219 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/data/remote/compression"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// batch cria envelopes com corpo de size bytes (acima de minCompressBytes
+// o envio sai comprimido).
+func batch(size int, ids ...string) []entities.Envelope {
+	out := make([]entities.Envelope, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, entities.Envelope{ID: id, Kind: "metric", Body: strings.Repeat("x", size)})
+	}
+	return out
+}
+
+// ingestServer decodifica cada envio e chama reply com o Content-Encoding e
+// os envelopes recebidos; reply devolve o status.
+func ingestServer(t *testing.T, reply func(encoding string, got []entities.Envelope) int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := r.Header.Get("Content-Encoding")
+		rd, err := compression.NewReader(enc, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		defer rd.Close()
+		raw, _ := io.ReadAll(rd)
+		var got []entities.Envelope
+		if err := json.Unmarshal(raw, &got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(reply(enc, got))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSendDowngradesOn415(t *testing.T) {
+	cases := []struct {
+		name      string
+		preferred string
+		supported []string // encodings aceitos pelo servidor ("" = identity)
+		wantSeen  []string // Content-Encoding de cada requisição do primeiro envio
+		wantNext  string   // encoding do envio seguinte
+		wantOK    bool
+	}{
+		{"zstd aceito", "zstd", []string{"zstd", "gzip", ""}, []string{"zstd"}, "zstd", true},
+		{"zstd cai para gzip", "zstd", []string{"gzip", ""}, []string{"zstd", "gzip"}, "gzip", true},
+		{"zstd cai até identity", "zstd", []string{""}, []string{"zstd", "gzip", ""}, "", true},
+		{"gzip cai para identity", "gzip", []string{""}, []string{"gzip", ""}, "", true},
+		{"nenhum aceito", "zstd", nil, []string{"zstd", "gzip", ""}, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var seen []string
+			srv := ingestServer(t, func(enc string, _ []entities.Envelope) int {
+				mu.Lock()
+				defer mu.Unlock()
+				seen = append(seen, enc)
+				if !slices.Contains(tc.supported, enc) {
+					return http.StatusUnsupportedMediaType
+				}
+				return http.StatusAccepted
+			})
+			cfg := config.Defaults()
+			cfg.APIBaseURL = srv.URL
+			cfg.Compression = tc.preferred
+			tx := NewHTTPJSONClient(cfg)
+
+			res, err := tx.SendWithAuth(context.Background(), batch(2048, "a", "b"), "Token t")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok := slices.Equal(res.Accepted, []string{"a", "b"}); ok != tc.wantOK {
+				t.Fatalf("result = %+v, want accepted %v", res, tc.wantOK)
+			}
+			if !tc.wantOK && (len(res.Rejected) != 2 || res.Rejected[0].Status != http.StatusUnsupportedMediaType) {
+				t.Fatalf("rejected = %+v, want 415 for the batch", res.Rejected)
+			}
+			if !slices.Equal(seen, tc.wantSeen) {
+				t.Fatalf("encodings = %q, want %q", seen, tc.wantSeen)
+			}
+
+			// O rebaixamento vale para os próximos envios.
+			seen = nil
+			if _, err := tx.SendWithAuth(context.Background(), batch(2048, "c"), "Token t"); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(seen, []string{tc.wantNext}) {
+				t.Fatalf("next send encodings = %q, want %q", seen, tc.wantNext)
+			}
+		})
+	}
+}
+
+// Payload abaixo de minCompressBytes sai sem compressão e não rebaixa o codec.
+func TestSendSmallPayloadUncompressed(t *testing.T) {
+	var seen []string
+	srv := ingestServer(t, func(enc string, _ []entities.Envelope) int {
+		seen = append(seen, enc)
+		return http.StatusAccepted
+	})
+	cfg := config.Defaults()
+	cfg.APIBaseURL = srv.URL
+	cfg.Compression = "zstd"
+	tx := NewHTTPJSONClient(cfg)
+	for _, size := range []int{10, 2048} {
+		if _, err := tx.SendWithAuth(context.Background(), batch(size, "a"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(seen, []string{"", "zstd"}) {
+		t.Fatalf("encodings = %q", seen)
+	}
+}
+
+func TestSendSplitsOn413(t *testing.T) {
+	cases := []struct {
+		name string
+		// limit é o máximo de envelopes por requisição; big, um ID acima do
+		// limite sozinho.
+		limit        int
+		big          string
+		ids          []string
+		wantSizes    []int
+		wantAccepted []string
+		wantRejected []string
+	}{
+		{
+			name: "cabe", limit: 10, ids: []string{"a", "b", "c"},
+			wantSizes: []int{3}, wantAccepted: []string{"a", "b", "c"},
+		},
+		{
+			name: "divide ao meio até caber", limit: 2, ids: []string{"a", "b", "c", "d", "e"},
+			wantSizes: []int{5, 2, 3, 1, 2}, wantAccepted: []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "envelope sozinho acima do limite é recusado", limit: 10, big: "b", ids: []string{"a", "b", "c"},
+			wantSizes: []int{3, 1, 2, 1, 1}, wantAccepted: []string{"a", "c"}, wantRejected: []string{"b"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sizes []int
+			srv := ingestServer(t, func(_ string, got []entities.Envelope) int {
+				sizes = append(sizes, len(got))
+				if len(got) > tc.limit || slices.ContainsFunc(got, func(e entities.Envelope) bool { return e.ID == tc.big }) {
+					return http.StatusRequestEntityTooLarge
+				}
+				return http.StatusAccepted
+			})
+			cfg := config.Defaults()
+			cfg.APIBaseURL = srv.URL
+			cfg.Compression = "none"
+			res, err := NewHTTPJSONClient(cfg).SendWithAuth(context.Background(), batch(10, tc.ids...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(sizes, tc.wantSizes) {
+				t.Errorf("request sizes = %v, want %v", sizes, tc.wantSizes)
+			}
+			if !slices.Equal(res.Accepted, tc.wantAccepted) {
+				t.Errorf("accepted = %v, want %v", res.Accepted, tc.wantAccepted)
+			}
+			var rejected []string
+			for _, r := range res.Rejected {
+				if r.Status != http.StatusRequestEntityTooLarge {
+					t.Errorf("rejection %+v, want 413", r)
+				}
+				rejected = append(rejected, r.ID)
+			}
+			if !slices.Equal(rejected, tc.wantRejected) {
+				t.Errorf("rejected = %v, want %v", rejected, tc.wantRejected)
+			}
+		})
+	}
+}
+
+// Se a segunda metade falha, a primeira continua entregue e o resto volta
+// no próximo flush (fora do resultado).
+func TestSendSplitKeepsFirstHalf(t *testing.T) {
+	calls := 0
+	srv := ingestServer(t, func(_ string, got []entities.Envelope) int {
+		calls++
+		switch {
+		case len(got) > 2:
+			return http.StatusRequestEntityTooLarge
+		case got[0].ID == "c":
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusAccepted
+	})
+	cfg := config.Defaults()
+	cfg.APIBaseURL = srv.URL
+	cfg.Compression = "none"
+	res, err := NewHTTPJSONClient(cfg).SendWithAuth(context.Background(), batch(10, "a", "b", "c", "d"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res.Accepted, []string{"a", "b"}) || len(res.Rejected) != 0 || calls != 3 {
+		t.Fatalf("result = %+v after %d calls", res, calls)
+	}
+}
@@ -2,11 +2,13 @@ package transport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/retry"
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 )
@@ -14,40 +16,41 @@ import (
 // HTTP client específico para logs brutos.
 type logsClient struct {
 	cl  *http.Client
+	br  *retry.Breaker
+	cd  *codec
 	cfg config.Config
 }
 
 func NewHTTPLogsClient(cfg config.Config) ports.Transport {
 	return &logsClient{
 		cl:  &http.Client{Timeout: 10 * time.Second},
+		br:  newBreaker(cfg),
+		cd:  newCodec(cfg),
 		cfg: cfg,
 	}
 }
 
-func (h *logsClient) SendWithAuth(batch []entities.Envelope, authHeader string) error {
+func (h *logsClient) link() (*http.Client, *retry.Breaker) { return h.cl, h.br }
+
+func (h *logsClient) SendWithAuth(ctx context.Context, batch []entities.Envelope, authHeader string) (entities.SendResult, error) {
 	b, err := json.Marshal(batch)
 	if err != nil {
-		return err
-	}
-	req, err := http.NewRequest(http.MethodPost, h.cfg.APIEndpoint("/v1/logs/raw"), bytes.NewReader(b))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if authHeader != "" {
-		req.Header.Set("Authorization", authHeader)
-	} else if h.cfg.Agent.Token != "" {
-		req.Header.Set("Authorization", "Token "+h.cfg.Agent.Token)
-	} else if h.cfg.APIKey != "" {
-		req.Header.Set("Authorization", "Bearer "+h.cfg.APIKey)
-	}
-	resp, err := h.cl.Do(req)
-	if err != nil {
-		return err
+		return entities.SendResult{}, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return &httpStatusErr{code: resp.StatusCode}
+	newReq := func(body []byte) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.cfg.APIEndpoint("/v1/logs/raw"), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		} else if h.cfg.Agent.Token != "" {
+			req.Header.Set("Authorization", "Token "+h.cfg.Agent.Token)
+		} else if h.cfg.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+h.cfg.APIKey)
+		}
+		return req, nil
 	}
-	return nil
+	return send(h.cl, h.br, h.cd, newReq, b, batch)
 }
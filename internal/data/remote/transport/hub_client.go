@@ -2,11 +2,13 @@ package transport
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/retry"
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 )
@@ -14,39 +16,40 @@ import (
 // HubClient envia lotes para um hub (relay).
 type hubClient struct {
 	cl  *http.Client
+	br  *retry.Breaker
+	cd  *codec
 	cfg config.Config
 }
 
 func NewHubClient(cfg config.Config) ports.Transport {
 	return &hubClient{
 		cl:  &http.Client{Timeout: 10 * time.Second},
+		br:  newBreaker(cfg),
+		cd:  newCodec(cfg),
 		cfg: cfg,
 	}
 }
 
-func (h *hubClient) SendWithAuth(batch []entities.Envelope, authHeader string) error {
+func (h *hubClient) link() (*http.Client, *retry.Breaker) { return h.cl, h.br }
+
+func (h *hubClient) SendWithAuth(ctx context.Context, batch []entities.Envelope, authHeader string) (entities.SendResult, error) {
 	b, err := json.Marshal(batch)
 	if err != nil {
-		return err
+		return entities.SendResult{}, err
 	}
 	url := h.cfg.HubURL + "/v1/ingest"
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(b))
-	if err != nil {
-		return err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if authHeader != "" {
-		req.Header.Set("Authorization", authHeader)
-	} else if h.cfg.HubToken != "" {
-		req.Header.Set("Authorization", "Token "+h.cfg.HubToken)
-	}
-	resp, err := h.cl.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		return &httpStatusErr{code: resp.StatusCode}
+	newReq := func(body []byte) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		} else if h.cfg.HubToken != "" {
+			req.Header.Set("Authorization", "Token "+h.cfg.HubToken)
+		}
+		return req, nil
 	}
-	return nil
+	return send(h.cl, h.br, h.cd, newReq, b, batch)
 }
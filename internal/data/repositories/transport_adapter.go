@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"strconv"
 
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
@@ -17,23 +20,37 @@ func NewTransportAdapter(repo TelemetryRepository) *TransportAdapter {
 }
 
 // Implementa ports.Transport
-func (a *TransportAdapter) SendWithAuth(batch []entities.Envelope, authHeader string) error {
+func (a *TransportAdapter) SendWithAuth(ctx context.Context, batch []entities.Envelope, authHeader string) (entities.SendResult, error) {
+	// O IngestClient não aceita ctx; ao menos não começa um envio cancelado.
+	if err := ctx.Err(); err != nil {
+		return entities.SendResult{}, err
+	}
 	impl, ok := a.repo.(*telemetryRepoImpl)
 	if !ok {
-		return nil
+		return entities.SendResult{}, nil
 	}
 
 	payload, err := json.Marshal(batch)
 	if err != nil {
-		return err
+		return entities.SendResult{}, err
 	}
 
 	headers := map[string]string{"Content-Type": "application/json"}
 	if authHeader != "" {
 		headers["Authorization"] = authHeader
 	}
-	_, _, err = impl.ingest.SendBatch("/v1/ingest", payload, headers)
-	return err
+	status, _, err := impl.ingest.SendBatch("/v1/ingest", payload, headers)
+	if err != nil {
+		return entities.SendResult{}, err
+	}
+	if status < 200 || status >= 300 {
+		return entities.SendResult{}, errors.New("ingest status " + strconv.Itoa(status))
+	}
+	res := entities.SendResult{Accepted: make([]string, 0, len(batch))}
+	for _, e := range batch {
+		res.Accepted = append(res.Accepted, e.ID)
+	}
+	return res, nil
 }
 
 // Garante conformidade
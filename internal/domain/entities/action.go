@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// Ações de resposta (contenção) disparadas pelo backend.
+const (
+	ActionKillProcess = "kill-process"
+	ActionBlockIP     = "block-ip"
+	ActionIsolateHost = "isolate-host"
+	// Desfazem block-ip/isolate-host antes do TTL.
+	ActionUnblockIP   = "unblock-ip"
+	ActionReleaseHost = "release-host"
+)
+
+// ResponseAction é uma ação de contenção ativa, desfeita ao expirar.
+type ResponseAction struct {
+	ID        string    `json:"cmd_id"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Allow     []string  `json:"allow,omitempty"` // isolate-host: IPs liberados
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ProcessInfo identifica o processo alvo de kill-process.
+type ProcessInfo struct {
+	PID     int32  `json:"pid"`
+	Name    string `json:"name,omitempty"`
+	Exe     string `json:"exe,omitempty"`
+	Cmdline string `json:"cmdline,omitempty"`
+	User    string `json:"user,omitempty"`
+}
@@ -0,0 +1,38 @@
+package entities
+
+import "time"
+
+// ArtifactRequest é o conjunto de artefatos pedido no comando collect-artifacts.
+type ArtifactRequest struct {
+	// Paths aceita arquivos, diretórios (recursivo) e globs.
+	Paths        []string `json:"paths,omitempty"`
+	Processes    bool     `json:"processes,omitempty"`     // lista com linhas de comando
+	Connections  bool     `json:"connections,omitempty"`   // conexões abertas
+	ExeHashes    bool     `json:"exe_hashes,omitempty"`    // SHA-256 dos executáveis em execução
+	ShellHistory bool     `json:"shell_history,omitempty"` // histórico recente de shells
+}
+
+// ArtifactEntry descreve um item do bundle (manifest.json).
+type ArtifactEntry struct {
+	Name   string `json:"name,omitempty"`   // caminho dentro do bundle
+	Source string `json:"source,omitempty"` // caminho no host
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Skipped explica itens não incluídos (limite, permissão, ...).
+	Skipped string `json:"skipped,omitempty"`
+	// Truncated indica arquivo que encolheu durante a cópia: o item tem Size
+	// bytes, e os que faltaram no fim são zeros.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ArtifactBundle é um bundle tar.gz gerado e pendente de upload.
+type ArtifactBundle struct {
+	ID        string    `json:"bundle_id"`
+	CmdID     string    `json:"cmd_id"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Entries   int       `json:"entries"`
+	Skipped   int       `json:"skipped"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package entities
+
+import "encoding/json"
+
+// Command é uma ordem do backend para o agente, recebida pelo long-poll.
+// Signature é Ed25519 (base64) sobre SignedBytes(); AgentID amarra a
+// assinatura ao agente de destino.
+type Command struct {
+	ID        string          `json:"cmd_id"`
+	AgentID   string          `json:"agent_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	IssuedAt  string          `json:"issued_at"`            // RFC3339
+	ExpiresAt string          `json:"expires_at,omitempty"` // RFC3339
+	Signature string          `json:"signature"`
+}
+
+// SignedBytes é a mensagem assinada pelo backend: os campos exatamente como
+// enviados, separados por "\n" (cmd_id, agent_id, type, issued_at,
+// expires_at, payload).
+func (c Command) SignedBytes() []byte {
+	out := make([]byte, 0, len(c.ID)+len(c.AgentID)+len(c.Type)+len(c.IssuedAt)+len(c.ExpiresAt)+len(c.Payload)+5)
+	out = append(out, c.ID...)
+	out = append(out, '\n')
+	out = append(out, c.AgentID...)
+	out = append(out, '\n')
+	out = append(out, c.Type...)
+	out = append(out, '\n')
+	out = append(out, c.IssuedAt...)
+	out = append(out, '\n')
+	out = append(out, c.ExpiresAt...)
+	out = append(out, '\n')
+	return append(out, c.Payload...)
+}
+
+// Status de execução de um comando.
+const (
+	CommandOK       = "ok"
+	CommandError    = "error"
+	CommandRejected = "rejected" // assinatura inválida, expirado ou tipo desconhecido
+)
+
+// CommandResult é o ACK de um comando, correlacionado por cmd_id.
+type CommandResult struct {
+	ID         string `json:"cmd_id"`
+	Type       string `json:"type"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Output     any    `json:"output,omitempty"`
+	StartedAt  int64  `json:"started_at_ms"`
+	FinishedAt int64  `json:"finished_at_ms"`
+}
@@ -0,0 +1,15 @@
+package entities
+
+// Rejection descreve um envelope recusado de forma definitiva pelo backend.
+type Rejection struct {
+	ID     string `json:"envelope_id"`
+	Reason string `json:"reason,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+// SendResult é o resultado por envelope de um envio. IDs que não aparecem
+// em Accepted nem em Rejected não foram entregues e devem ser reenviados.
+type SendResult struct {
+	Accepted []string
+	Rejected []Rejection
+}
@@ -11,4 +11,15 @@ type Envelope struct {
 	Meta          map[string]string `json:"meta,omitempty"`
 	Body          any               `json:"body"`
 	AuthHeader    string            `json:"-"`
+	// DeadLetterAttempts são as recusas anteriores de um envelope devolvido
+	// pelo replay do dead-letter; como AuthHeader, não sai do agente.
+	DeadLetterAttempts int `json:"-"`
+}
+
+// Source identifica a origem do envelope nas métricas: o sub ou, sem ele, o kind.
+func (e Envelope) Source() string {
+	if e.Sub != "" {
+		return e.Sub
+	}
+	return e.Kind
 }
@@ -0,0 +1,20 @@
+package entities
+
+// Script é um script enviado pelo backend no comando run-script.
+type Script struct {
+	Name        string   `json:"name,omitempty"`
+	Interpreter string   `json:"interpreter"` // bash|sh|powershell|pwsh
+	Body        string   `json:"script"`
+	Args        []string `json:"args,omitempty"`
+	// TimeoutSec pede um limite menor que o configurado (nunca maior).
+	TimeoutSec int `json:"timeout_s,omitempty"`
+}
+
+// ScriptResult resume uma execução de script.
+type ScriptResult struct {
+	ExitCode    int   `json:"exit_code"`
+	DurationMs  int64 `json:"duration_ms"`
+	OutputBytes int64 `json:"output_bytes"`
+	TimedOut    bool  `json:"timed_out,omitempty"`
+	Truncated   bool  `json:"truncated,omitempty"`
+}
@@ -0,0 +1,23 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// ArtifactCollector reúne artefatos forenses num bundle comprimido em disco.
+type ArtifactCollector interface {
+	Build(ctx context.Context, id string, req entities.ArtifactRequest) (entities.ArtifactBundle, error)
+}
+
+// ArtifactUploader envia bundles em pedaços; o backend guarda o offset
+// recebido, então um upload interrompido continua de onde parou.
+type ArtifactUploader interface {
+	// Begin registra o bundle (ou o reencontra) e devolve o offset já recebido.
+	Begin(ctx context.Context, b entities.ArtifactBundle) (offset int64, err error)
+	// Chunk envia chunk a partir de offset e devolve o próximo offset esperado.
+	Chunk(ctx context.Context, b entities.ArtifactBundle, offset int64, chunk []byte) (next int64, err error)
+	// Complete fecha o upload; o backend confere tamanho e SHA-256.
+	Complete(ctx context.Context, b entities.ArtifactBundle) error
+}
@@ -10,3 +10,18 @@ type Collector interface {
 	Interval() time.Duration
 	Collect(ctx context.Context) ([]byte, error)
 }
+
+// Checkpointer é implementado por collectors com cursor (oslogs): Commit
+// persiste a posição alcançada pelo último Collect e só é chamado depois
+// que o lote foi gravado no outbox. Sem Commit, o Collect seguinte relê a
+// partir do cursor anterior.
+type Checkpointer interface {
+	Commit() error
+}
+
+// Waker é implementado por collectors que sabem quando há dado novo
+// (inotify no oslogs): Watch roda até ctx acabar, chamando wake a cada
+// mudança para o scheduler antecipar a coleta.
+type Waker interface {
+	Watch(ctx context.Context, wake func())
+}
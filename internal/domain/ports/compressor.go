@@ -2,4 +2,6 @@ package ports
 
 type Compressor interface {
 	Compress(in []byte) ([]byte, error)
+	// Encoding é o valor usado no header Content-Encoding (ex.: gzip, zstd).
+	Encoding() string
 }
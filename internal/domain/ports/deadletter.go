@@ -0,0 +1,11 @@
+package ports
+
+import "github.com/you/aiceberg_agent/internal/domain/entities"
+
+// DeadLetterRepo guarda envelopes recusados definitivamente pelo backend.
+type DeadLetterRepo interface {
+	Put(env entities.Envelope, reason string, status int) error
+	// PendingReplay retorna envelopes marcados (via CLI) para reenvio.
+	PendingReplay() ([]entities.Envelope, error)
+	Remove(ids []string) error
+}
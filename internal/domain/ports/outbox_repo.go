@@ -1,6 +1,14 @@
 package ports
 
-import "github.com/you/aiceberg_agent/internal/domain/entities"
+import (
+	"errors"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// ErrDropped é o erro do Append quando o outbox está cheio e a política de
+// overflow descartou o próprio envelope: ele não foi gravado.
+var ErrDropped = errors.New("outbox full: envelope dropped")
 
 type OutboxRepo interface {
 	Append(env entities.Envelope) error
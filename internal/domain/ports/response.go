@@ -0,0 +1,20 @@
+package ports
+
+import "github.com/you/aiceberg_agent/internal/domain/entities"
+
+// Firewall aplica regras de contenção de rede no host. As operações são
+// idempotentes: bloquear duas vezes ou liberar o que não existe não é erro.
+type Firewall interface {
+	Backend() string
+	BlockIP(ip string) error
+	UnblockIP(ip string) error
+	// Isolate bloqueia todo tráfego exceto loopback e os IPs em allow.
+	Isolate(allow []string) error
+	Release() error
+}
+
+// ProcessKiller identifica e termina processos.
+type ProcessKiller interface {
+	Lookup(pid int32) (entities.ProcessInfo, error)
+	Kill(pid int32, force bool) error
+}
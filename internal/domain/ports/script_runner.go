@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// ScriptRunner executa scripts remotos sob a política local (allowlist) e
+// os limites configurados.
+type ScriptRunner interface {
+	// Check valida s contra a política sem executar.
+	Check(s entities.Script) error
+	// Run executa s repassando stdout/stderr em pedaços para out, na ordem.
+	Run(ctx context.Context, s entities.Script, out func(stream string, chunk []byte)) (entities.ScriptResult, error)
+}
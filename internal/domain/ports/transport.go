@@ -1,8 +1,14 @@
 package ports
 
-import "github.com/you/aiceberg_agent/internal/domain/entities"
+import (
+	"context"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
 
 type Transport interface {
 	// SendWithAuth envia um batch aplicando o header Authorization fornecido (se não vazio).
-	SendWithAuth(batch []entities.Envelope, authHeader string) error
+	// Erros indicam falha retentável (rede, 429, 5xx); recusas definitivas vêm em SendResult.Rejected.
+	// Cancelar ctx interrompe a requisição em andamento.
+	SendWithAuth(ctx context.Context, batch []entities.Envelope, authHeader string) (entities.SendResult, error)
 }
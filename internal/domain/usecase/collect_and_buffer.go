@@ -3,10 +3,12 @@ package usecase
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 )
@@ -25,13 +27,13 @@ func NewCollectAndBuffer(c ports.Collector, o ports.OutboxRepo, l logger.Logger,
 func (uc *CollectAndBuffer) Execute(ctx context.Context) error {
 	data, err := uc.collector.Collect(ctx) // []byte
 	if err != nil {
-		uc.log.Error("collect: " + err.Error())
+		uc.log.Error("collect failed", "collector", uc.collector.Name(), "err", err)
 		return err
 	}
 
 	hostname, _ := os.Hostname()
 	if data == nil {
-		return nil
+		return uc.commit()
 	}
 	env := entities.Envelope{
 		ID:            genID(),
@@ -45,10 +47,30 @@ func (uc *CollectAndBuffer) Execute(ctx context.Context) error {
 	}
 
 	if err := uc.outbox.Append(env); err != nil {
-		uc.log.Error("outbox append: " + err.Error())
+		// Sem commit: o collector com cursor relê o lote na próxima coleta.
+		if errors.Is(err, ports.ErrDropped) {
+			uc.log.Warn("outbox full, batch dropped", "collector", uc.collector.Name())
+		} else {
+			uc.log.Error("outbox append failed", "collector", uc.collector.Name(), "err", err)
+		}
+		return err
+	}
+	metrics.EnvelopesCollected.Inc(env.Source())
+	uc.log.Debug("buffered", "collector", uc.collector.Name(), "id", env.ID)
+	return uc.commit()
+}
+
+// commit avança o cursor do collector, se ele tiver um, depois que o lote
+// está no outbox. Se falhar, o próximo Collect repete parte do lote.
+func (uc *CollectAndBuffer) commit() error {
+	cp, ok := uc.collector.(ports.Checkpointer)
+	if !ok {
+		return nil
+	}
+	if err := cp.Commit(); err != nil {
+		uc.log.Error("collector commit failed", "collector", uc.collector.Name(), "err", err)
 		return err
 	}
-	uc.log.Info("buffered: " + env.ID)
 	return nil
 }
 
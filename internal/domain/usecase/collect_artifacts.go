@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// maxUploadAttempts limita as tentativas seguidas de um upload; depois o
+// bundle fica em disco e é retomado no próximo start ou pedido.
+const maxUploadAttempts = 5
+
+// CollectArtifacts trata o comando collect-artifacts: responde o ACK com o
+// bundle_id, gera o bundle em segundo plano e o envia em pedaços. O
+// progresso vai como eventos artifact_bundle (collected, uploaded, failed).
+type CollectArtifacts struct {
+	events *EmitEvent
+	log    logger.Logger
+
+	mu        sync.Mutex
+	cfg       config.Config
+	collector ports.ArtifactCollector
+	uploader  ports.ArtifactUploader
+	busy      bool
+}
+
+func NewCollectArtifacts(cfg config.Config, c ports.ArtifactCollector, u ports.ArtifactUploader, events *EmitEvent, l logger.Logger) *CollectArtifacts {
+	return &CollectArtifacts{cfg: cfg, collector: c, uploader: u, events: events, log: l}
+}
+
+// Update troca config e adapters (reload); um bundle em andamento segue
+// com os antigos.
+func (uc *CollectArtifacts) Update(cfg config.Config, c ports.ArtifactCollector, u ports.ArtifactUploader) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.cfg, uc.collector, uc.uploader = cfg, c, u
+}
+
+// Handle é o CommandHandler do collect-artifacts. ctx deve durar o processo.
+func (uc *CollectArtifacts) Handle(ctx context.Context, cmd entities.Command) (any, error) {
+	var req entities.ArtifactRequest
+	if err := decodeArtifactRequest(cmd.Payload, &req); err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	if !uc.cfg.ArtifactsEnabled {
+		uc.mu.Unlock()
+		return nil, errors.New("artifact collection disabled")
+	}
+	if uc.busy {
+		uc.mu.Unlock()
+		return nil, errors.New("artifact collection already running")
+	}
+	uc.busy = true
+	cfg, collector, uploader := uc.cfg, uc.collector, uc.uploader
+	uc.mu.Unlock()
+
+	id := "art-" + genID()
+	go func() {
+		defer uc.done()
+		// Pendentes de execuções anteriores vão primeiro.
+		uc.resume(ctx, cfg, uploader)
+		b, err := collector.Build(ctx, id, req)
+		b.ID, b.CmdID = id, cmd.ID
+		if err != nil {
+			uc.report(b, "failed", err, false)
+			return
+		}
+		if err := writeCursor(metaPath(b.Path), mustJSON(b)); err != nil {
+			uc.log.Error("artifacts: persist bundle meta failed", "bundle_id", b.ID, "err", err)
+		}
+		uc.report(b, "collected", nil, false)
+		uc.upload(ctx, cfg, uploader, b)
+	}()
+	return map[string]string{"bundle_id": id, "status": "started"}, nil
+}
+
+// Resume retoma em segundo plano os bundles que ficaram pendentes.
+func (uc *CollectArtifacts) Resume(ctx context.Context) {
+	uc.mu.Lock()
+	if !uc.cfg.ArtifactsEnabled || uc.busy {
+		uc.mu.Unlock()
+		return
+	}
+	uc.busy = true
+	cfg, uploader := uc.cfg, uc.uploader
+	uc.mu.Unlock()
+	go func() {
+		defer uc.done()
+		uc.resume(ctx, cfg, uploader)
+	}()
+}
+
+func (uc *CollectArtifacts) done() {
+	uc.mu.Lock()
+	uc.busy = false
+	uc.mu.Unlock()
+}
+
+func (uc *CollectArtifacts) resume(ctx context.Context, cfg config.Config, uploader ports.ArtifactUploader) {
+	metas, _ := filepath.Glob(filepath.Join(cfg.ArtifactsDir, "*.tar.gz.json"))
+	for _, m := range metas {
+		if ctx.Err() != nil {
+			return
+		}
+		raw, err := os.ReadFile(m)
+		var b entities.ArtifactBundle
+		if err == nil {
+			err = json.Unmarshal(raw, &b)
+		}
+		if err != nil {
+			uc.log.Error("artifacts: invalid bundle meta", "path", m, "err", err)
+			continue
+		}
+		uc.log.Info("artifacts: resuming upload", "bundle_id", b.ID)
+		uc.upload(ctx, cfg, uploader, b)
+	}
+}
+
+// upload envia o bundle a partir do offset que o backend já tem, com
+// backoff (ou o Retry-After do servidor) entre tentativas. Concluído (ou
+// recusado de vez), o bundle é apagado; falhas transitórias o mantêm para
+// retomar depois. Com o breaker do transport aberto nada foi enviado, e a
+// tentativa não conta.
+func (uc *CollectArtifacts) upload(ctx context.Context, cfg config.Config, uploader ports.ArtifactUploader, b entities.ArtifactBundle) {
+	backoff := retry.Backoff{Base: cfg.RetryBaseDelay, Max: cfg.RetryMaxDelay}
+	var err error
+	for attempt := 0; attempt < maxUploadAttempts; {
+		if err != nil {
+			// Equal jitter: com o breaker aberto a tentativa não conta, e a
+			// espera não pode sair perto de zero.
+			wait := backoff.EqualDelay(max(attempt-1, 0))
+			if ra, ok := retry.RetryAfter(err); ok && ra > wait {
+				wait = ra
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+		if err = uploadOnce(ctx, cfg, uploader, b); err == nil {
+			uc.remove(b)
+			uc.report(b, "uploaded", nil, false)
+			return
+		}
+		if permanentUploadErr(err) {
+			uc.remove(b)
+			uc.report(b, "failed", err, false)
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !errors.Is(err, retry.ErrOpen) {
+			attempt++
+		}
+	}
+	uc.report(b, "failed", err, true)
+}
+
+func uploadOnce(ctx context.Context, cfg config.Config, uploader ports.ArtifactUploader, b entities.ArtifactBundle) error {
+	f, err := os.Open(b.Path)
+	if err != nil {
+		return errBundleMissing{err}
+	}
+	defer f.Close()
+	off, err := uploader.Begin(ctx, b)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, cfg.ArtifactsChunkKB*1024)
+	for off < b.Size {
+		if off < 0 {
+			return fmt.Errorf("invalid offset %d from backend", off)
+		}
+		n, err := f.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		next, err := uploader.Chunk(ctx, b, off, buf[:n])
+		if err != nil {
+			return err
+		}
+		// O offset só anda para frente: um backend que volta ou não avança
+		// faria o loop girar sem fim. Retroceder de verdade (409) é tratado
+		// na próxima tentativa, que recomeça do offset devolvido por Begin.
+		if next > b.Size || next <= off {
+			return fmt.Errorf("invalid offset %d from backend (sent %d)", next, off)
+		}
+		off = next
+	}
+	return uploader.Complete(ctx, b)
+}
+
+// errBundleMissing indica bundle apagado do disco: não há o que retomar.
+type errBundleMissing struct{ err error }
+
+func (e errBundleMissing) Error() string { return "bundle missing: " + e.err.Error() }
+
+// permanentUploadErr indica recusas em que tentar de novo não adianta.
+func permanentUploadErr(err error) bool {
+	var missing errBundleMissing
+	if errors.As(err, &missing) {
+		return true
+	}
+	var se interface{ StatusCode() int }
+	if errors.As(err, &se) {
+		switch se.StatusCode() {
+		case http.StatusBadRequest, http.StatusGone, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+			return true
+		}
+	}
+	return false
+}
+
+func (uc *CollectArtifacts) remove(b entities.ArtifactBundle) {
+	_ = os.Remove(b.Path)
+	_ = os.Remove(metaPath(b.Path))
+}
+
+func (uc *CollectArtifacts) report(b entities.ArtifactBundle, status string, err error, willRetry bool) {
+	body := map[string]any{
+		"cmd_id":    b.CmdID,
+		"bundle_id": b.ID,
+		"status":    status,
+	}
+	if b.SHA256 != "" {
+		body["size"] = b.Size
+		body["sha256"] = b.SHA256
+		body["entries"] = b.Entries
+		body["skipped"] = b.Skipped
+	}
+	kv := []any{"bundle_id", b.ID, "cmd_id", b.CmdID, "status", status}
+	if err != nil {
+		body["error"] = err.Error()
+		if willRetry {
+			body["will_retry"] = true
+		}
+		uc.log.Error("artifacts", append(kv, "err", err)...)
+	} else {
+		uc.log.Info("artifacts", kv...)
+	}
+	_ = uc.events.Emit("artifact_bundle", body)
+}
+
+func decodeArtifactRequest(payload json.RawMessage, req *entities.ArtifactRequest) error {
+	if len(payload) == 0 {
+		return errors.New("missing payload")
+	}
+	if err := json.Unmarshal(payload, req); err != nil {
+		return errors.New("invalid payload: " + err.Error())
+	}
+	if len(req.Paths) == 0 && !req.Processes && !req.Connections && !req.ExeHashes && !req.ShellHistory {
+		return errors.New("empty artifact request")
+	}
+	for _, p := range req.Paths {
+		if strings.TrimSpace(p) == "" || !filepath.IsAbs(p) {
+			return errors.New("paths must be absolute: " + p)
+		}
+	}
+	return nil
+}
+
+func metaPath(bundlePath string) string { return bundlePath + ".json" }
+
+func mustJSON(v any) string {
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/data/remote/transport"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// artifactBackend imita o upload retomável da API: guarda os bytes
+// recebidos por bundle, responde 409 com o offset que tem quando o pedaço
+// não continua de onde parou e confere o SHA-256 no complete.
+type artifactBackend struct {
+	mu       sync.Mutex
+	maxBytes int64 // tamanho máximo aceito no begin (0 = sem limite)
+	data     map[string][]byte
+	sha      map[string]string
+	puts     []int64 // offset de cada PUT
+	// onPut, se definido, responde o PUT no lugar do backend.
+	onPut     func(w http.ResponseWriter, id string, off int64) bool
+	completed bool
+}
+
+func (a *artifactBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.Header.Get("Authorization") != "Token tok" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	reply := func(status int, off int) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]int{"offset": off})
+	}
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/agent/artifacts"), "/complete")
+	id = strings.TrimPrefix(id, "/")
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		var meta struct {
+			ID     string `json:"bundle_id"`
+			Size   int64  `json:"size"`
+			SHA256 string `json:"sha256"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&meta); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if a.maxBytes > 0 && meta.Size > a.maxBytes {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		a.sha[meta.ID] = meta.SHA256
+		reply(http.StatusOK, len(a.data[meta.ID]))
+	case r.Method == http.MethodPut:
+		off, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+		a.puts = append(a.puts, off)
+		if a.onPut != nil && a.onPut(w, id, off) {
+			return
+		}
+		if off != int64(len(a.data[id])) {
+			reply(http.StatusConflict, len(a.data[id]))
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		a.data[id] = append(a.data[id], chunk...)
+		reply(http.StatusOK, len(a.data[id]))
+	case r.Method == http.MethodPost && rest == "":
+		sum := sha256.Sum256(a.data[id])
+		if hex.EncodeToString(sum[:]) != a.sha[id] {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		a.completed = true
+		w.WriteHeader(http.StatusOK)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newBundle grava size bytes aleatórios como bundle em dir.
+func newBundle(t *testing.T, dir string, size int) (entities.ArtifactBundle, []byte) {
+	t.Helper()
+	raw := make([]byte, size)
+	_, _ = rand.Read(raw)
+	b := entities.ArtifactBundle{ID: "art-1", CmdID: "c1", Path: filepath.Join(dir, "art-1.tar.gz"), Size: int64(size)}
+	sum := sha256.Sum256(raw)
+	b.SHA256 = hex.EncodeToString(sum[:])
+	if err := os.WriteFile(b.Path, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCursor(metaPath(b.Path), mustJSON(b)); err != nil {
+		t.Fatal(err)
+	}
+	return b, raw
+}
+
+func TestArtifactUpload(t *testing.T) {
+	const size = 2500 // 3 pedaços de 1 KB
+	cases := []struct {
+		name string
+		// held são os bytes que o backend já tem antes do upload.
+		held      int
+		maxBytes  int64
+		onPut     func(a *artifactBackend, raw []byte) func(w http.ResponseWriter, id string, off int64) bool
+		wantPuts  []int64
+		wantEvent string
+		wantRetry bool
+		wantKept  bool // bundle continua em disco para retomar
+	}{
+		{name: "do zero em pedaços", wantPuts: []int64{0, 1024, 2048}, wantEvent: "uploaded"},
+		{name: "retoma do offset que o backend tem", held: 1024, wantPuts: []int64{1024, 2048}, wantEvent: "uploaded"},
+		{name: "bundle todo já enviado só fecha", held: size, wantEvent: "uploaded"},
+		{
+			// Um pedaço de uma tentativa anterior chegou atrasado: o 409
+			// devolve o offset do backend e o envio segue dali.
+			name: "409 continua do offset do backend",
+			onPut: func(a *artifactBackend, raw []byte) func(http.ResponseWriter, string, int64) bool {
+				return func(_ http.ResponseWriter, id string, off int64) bool {
+					if off == 1024 && len(a.data[id]) == 1024 {
+						a.data[id] = append(a.data[id], raw[1024:2048]...)
+					}
+					return false
+				}
+			},
+			wantPuts: []int64{0, 1024, 2048}, wantEvent: "uploaded",
+		},
+		{name: "acima do limite do backend", maxBytes: 2048, wantEvent: "failed"},
+		{
+			name: "backend que não avança",
+			onPut: func(*artifactBackend, []byte) func(http.ResponseWriter, string, int64) bool {
+				return func(w http.ResponseWriter, _ string, off int64) bool {
+					_ = json.NewEncoder(w).Encode(map[string]int64{"offset": off})
+					return true
+				}
+			},
+			wantPuts:  slices.Repeat([]int64{0}, maxUploadAttempts),
+			wantEvent: "failed", wantRetry: true, wantKept: true,
+		},
+		{
+			name: "backend que volta",
+			held: 1024,
+			onPut: func(*artifactBackend, []byte) func(http.ResponseWriter, string, int64) bool {
+				return func(w http.ResponseWriter, _ string, off int64) bool {
+					_ = json.NewEncoder(w).Encode(map[string]int64{"offset": off - 1})
+					return true
+				}
+			},
+			wantPuts:  slices.Repeat([]int64{1024}, maxUploadAttempts),
+			wantEvent: "failed", wantRetry: true, wantKept: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			b, raw := newBundle(t, dir, size)
+			be := &artifactBackend{maxBytes: tc.maxBytes, data: map[string][]byte{}, sha: map[string]string{}}
+			if tc.held > 0 {
+				be.data[b.ID] = slices.Clone(raw[:tc.held])
+			}
+			if tc.onPut != nil {
+				be.onPut = tc.onPut(be, raw)
+			}
+			srv := httptest.NewServer(be)
+			defer srv.Close()
+
+			cfg := config.Defaults()
+			cfg.APIBaseURL = srv.URL
+			cfg.Agent.Token = "tok"
+			cfg.ArtifactsDir = dir
+			cfg.ArtifactsChunkKB = 1
+			cfg.RetryBaseDelay, cfg.RetryMaxDelay = time.Millisecond, time.Millisecond
+			box := &fakeOutbox{}
+			up := transport.NewArtifactUploader(cfg, transport.NewHTTPJSONClient(cfg))
+			uc := NewCollectArtifacts(cfg, nil, up, NewEmitEvent(box, nopLogger{}, ""), nopLogger{})
+			uc.upload(context.Background(), cfg, up, b)
+
+			if !slices.Equal(be.puts, tc.wantPuts) {
+				t.Errorf("PUT offsets = %v, want %v", be.puts, tc.wantPuts)
+			}
+			if len(box.bodies) != 1 {
+				t.Fatalf("events = %v", box.bodies)
+			}
+			ev := box.bodies[0].(map[string]any)
+			if ev["status"] != tc.wantEvent || (ev["will_retry"] == true) != tc.wantRetry {
+				t.Errorf("event = %v, want status %s (will_retry %v)", ev, tc.wantEvent, tc.wantRetry)
+			}
+			if tc.wantEvent == "uploaded" && (!be.completed || !bytes.Equal(be.data[b.ID], raw)) {
+				t.Errorf("backend has %d bytes, completed %v", len(be.data[b.ID]), be.completed)
+			}
+			_, err := os.Stat(b.Path)
+			_, merr := os.Stat(metaPath(b.Path))
+			if kept := err == nil && merr == nil; kept != tc.wantKept {
+				t.Errorf("bundle kept = %v, want %v (%v, %v)", kept, tc.wantKept, err, merr)
+			}
+		})
+	}
+}
+
+// Bundle pendente de uma execução anterior é retomado a partir do meta em
+// disco.
+func TestArtifactResume(t *testing.T) {
+	dir := t.TempDir()
+	b, raw := newBundle(t, dir, 1500)
+	be := &artifactBackend{data: map[string][]byte{b.ID: slices.Clone(raw[:1024])}, sha: map[string]string{}}
+	srv := httptest.NewServer(be)
+	defer srv.Close()
+
+	cfg := config.Defaults()
+	cfg.APIBaseURL = srv.URL
+	cfg.Agent.Token = "tok"
+	cfg.ArtifactsEnabled = true
+	cfg.ArtifactsDir = dir
+	cfg.ArtifactsChunkKB = 1
+	up := transport.NewArtifactUploader(cfg, transport.NewHTTPJSONClient(cfg))
+	uc := NewCollectArtifacts(cfg, nil, up, NewEmitEvent(&fakeOutbox{}, nopLogger{}, ""), nopLogger{})
+	uc.resume(context.Background(), cfg, up)
+	if !be.completed || !bytes.Equal(be.data[b.ID], raw) || !slices.Equal(be.puts, []int64{1024}) {
+		t.Fatalf("resume: completed %v, puts %v", be.completed, be.puts)
+	}
+	if metas, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(metas) != 0 {
+		t.Fatalf("meta left behind: %v", metas)
+	}
+}
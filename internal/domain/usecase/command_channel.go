@@ -0,0 +1,370 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/httpx"
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// CommandHandler executa um comando; output (se não nil) volta no ACK.
+type CommandHandler func(ctx context.Context, cmd entities.Command) (output any, err error)
+
+const (
+	// maxCommandAge é a validade máxima de um comando: recusa os emitidos há
+	// mais tempo que isso e os com expires_at além de issued_at+maxCommandAge.
+	maxCommandAge = 10 * time.Minute
+	// clockSkew tolera relógios adiantados/atrasados em relação ao backend.
+	clockSkew = 2 * time.Minute
+	// seenTTL é por quanto tempo cmd_ids executados são lembrados (também em
+	// disco, ao lado do cursor, para valer depois de um restart): reentregas
+	// (ex.: ACK perdido) recebem o resultado anterior sem reexecutar. Cobre
+	// toda a validade aceita (issued_at pode estar clockSkew no futuro).
+	seenTTL = maxCommandAge + 2*clockSkew
+)
+
+// seenCommand é um comando já executado, gravado ao lado do cursor para
+// que a reentrega depois de um restart não o execute de novo.
+type seenCommand struct {
+	At     time.Time              `json:"at"`
+	Result entities.CommandResult `json:"result"`
+}
+
+// CommandChannel faz long-poll de comandos assinados no backend, executa-os
+// pelos handlers registrados e devolve os resultados (ACK) por cmd_id.
+type CommandChannel struct {
+	log      logger.Logger
+	agentID  string
+	mu       sync.Mutex
+	cfg      config.Config
+	key      ed25519.PublicKey
+	cl       *http.Client
+	handlers map[string]CommandHandler
+	cursor   string
+	seen     map[string]seenCommand
+	now      func() time.Time
+}
+
+// NewCommandChannel cria o canal; agentID é a identificação do agente no
+// backend, que precisa constar (assinada) em cada comando.
+func NewCommandChannel(cfg config.Config, agentID string, log logger.Logger) *CommandChannel {
+	uc := &CommandChannel{
+		log:      log,
+		agentID:  agentID,
+		handlers: map[string]CommandHandler{},
+		seen:     map[string]seenCommand{},
+		now:      time.Now,
+	}
+	uc.Update(cfg)
+	uc.cursor = readCursor(cfg.CommandsCursorPath)
+	uc.seen = readSeen(seenPath(cfg.CommandsCursorPath), uc.now())
+	return uc
+}
+
+// Register associa um tipo de comando (ex.: flush-now) ao seu handler.
+func (uc *CommandChannel) Register(typ string, h CommandHandler) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.handlers[typ] = h
+}
+
+// Update troca a config usada nas próximas chamadas (reload).
+func (uc *CommandChannel) Update(cfg config.Config) {
+	key, _ := cfg.CommandsKey()
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.cfg = cfg
+	uc.key = key
+	uc.cl = &http.Client{Timeout: cfg.CommandsPollTimeout + 15*time.Second}
+}
+
+// Enabled informa se o canal está habilitado e com chave válida.
+func (uc *CommandChannel) Enabled() bool {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.cfg.CommandsEnabled && uc.key != nil
+}
+
+// Execute faz um long-poll; os comandos recebidos são executados em ordem e
+// os ACKs enviados antes de avançar o cursor persistido.
+func (uc *CommandChannel) Execute(ctx context.Context) error {
+	cursor, cmds, err := uc.poll(ctx)
+	if err != nil {
+		uc.log.Error("commands poll failed", "err", err)
+		return err
+	}
+	if len(cmds) > 0 {
+		results := make([]entities.CommandResult, 0, len(cmds))
+		for _, c := range cmds {
+			results = append(results, uc.run(ctx, c))
+		}
+		if err := uc.ack(ctx, results); err != nil {
+			uc.log.Error("commands ack failed", "err", err)
+			return err
+		}
+	}
+	uc.mu.Lock()
+	changed := cursor != "" && cursor != uc.cursor
+	if changed {
+		uc.cursor = cursor
+	}
+	path := uc.cfg.CommandsCursorPath
+	uc.mu.Unlock()
+	if changed {
+		if err := writeCursor(path, cursor); err != nil {
+			uc.log.Error("commands cursor persist failed", "err", err)
+		}
+	}
+	return nil
+}
+
+func (uc *CommandChannel) poll(ctx context.Context) (string, []entities.Command, error) {
+	uc.mu.Lock()
+	cfg, cl, cursor := uc.cfg, uc.cl, uc.cursor
+	uc.mu.Unlock()
+
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	q.Set("timeout", strconv.Itoa(int(cfg.CommandsPollTimeout/time.Second)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.APIEndpoint("/v1/agent/commands")+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	httpx.SetAuth(req, cfg)
+
+	start := time.Now()
+	resp, err := cl.Do(req)
+	if err != nil {
+		metrics.ObserveRequest("/v1/agent/commands", start, 0, err)
+		return "", nil, err
+	}
+	metrics.ObserveRequest("/v1/agent/commands", start, resp.StatusCode, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNoContent {
+		return "", nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return "", nil, &httpStatusErr{code: resp.StatusCode}
+	}
+	var payload struct {
+		Cursor   string             `json:"cursor"`
+		Commands []entities.Command `json:"commands"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", nil, err
+	}
+	return payload.Cursor, payload.Commands, nil
+}
+
+// run valida e executa um comando, sempre produzindo um resultado.
+func (uc *CommandChannel) run(ctx context.Context, c entities.Command) entities.CommandResult {
+	res := entities.CommandResult{ID: c.ID, Type: c.Type, StartedAt: uc.now().UnixMilli()}
+	finish := func(status string, output any, err error) entities.CommandResult {
+		res.Status = status
+		res.Output = output
+		if err != nil {
+			res.Error = err.Error()
+		}
+		res.FinishedAt = uc.now().UnixMilli()
+		if err != nil {
+			uc.log.Error("command", "type", c.Type, "cmd_id", c.ID, "status", status, "err", err)
+		} else {
+			uc.log.Info("command", "type", c.Type, "cmd_id", c.ID, "status", status)
+		}
+		return res
+	}
+
+	if err := uc.verify(c); err != nil {
+		return finish(entities.CommandRejected, nil, err)
+	}
+	uc.mu.Lock()
+	prev, done := uc.seen[c.ID]
+	h, ok := uc.handlers[c.Type]
+	uc.mu.Unlock()
+	if done {
+		uc.log.Info("command already executed, resending result", "type", c.Type, "cmd_id", c.ID)
+		return prev.Result
+	}
+	if !ok {
+		res = finish(entities.CommandRejected, nil, errors.New("unknown command type"))
+	} else if out, err := h(ctx, c); err != nil {
+		res = finish(entities.CommandError, out, err)
+	} else {
+		res = finish(entities.CommandOK, out, nil)
+	}
+	uc.mu.Lock()
+	uc.seen[c.ID] = seenCommand{At: uc.now(), Result: res}
+	raw, err := json.Marshal(uc.seen)
+	path := seenPath(uc.cfg.CommandsCursorPath)
+	uc.mu.Unlock()
+	// Gravado antes do ACK: se o agente cair aqui, a reentrega recebe o
+	// resultado em vez de executar de novo.
+	if err == nil {
+		err = writeAtomic(path, raw)
+	}
+	if err != nil {
+		uc.log.Error("commands seen persist failed", "err", err)
+	}
+	return res
+}
+
+// verify confere assinatura e validade do comando.
+func (uc *CommandChannel) verify(c entities.Command) error {
+	uc.mu.Lock()
+	key := uc.key
+	uc.mu.Unlock()
+	if c.ID == "" || c.Type == "" {
+		return errors.New("missing cmd_id or type")
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil || key == nil || !ed25519.Verify(key, c.SignedBytes(), sig) {
+		return errors.New("invalid signature")
+	}
+	if uc.agentID == "" || c.AgentID != uc.agentID {
+		return errors.New("command addressed to another agent")
+	}
+
+	now := uc.now()
+	issued, err := time.Parse(time.RFC3339, c.IssuedAt)
+	if err != nil {
+		return errors.New("invalid issued_at")
+	}
+	if issued.After(now.Add(clockSkew)) {
+		return errors.New("issued_at in the future")
+	}
+	if c.ExpiresAt != "" {
+		exp, err := time.Parse(time.RFC3339, c.ExpiresAt)
+		if err != nil {
+			return errors.New("invalid expires_at")
+		}
+		if exp.Sub(issued) > maxCommandAge {
+			return errors.New("expires_at too far from issued_at")
+		}
+		if now.After(exp.Add(clockSkew)) {
+			return errors.New("command expired")
+		}
+	} else if now.Sub(issued) > maxCommandAge {
+		return errors.New("command expired")
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	for id, s := range uc.seen {
+		if now.Sub(s.At) > seenTTL {
+			delete(uc.seen, id)
+		}
+	}
+	return nil
+}
+
+func (uc *CommandChannel) ack(ctx context.Context, results []entities.CommandResult) error {
+	uc.mu.Lock()
+	cfg := uc.cfg
+	uc.mu.Unlock()
+
+	raw, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	// Comandos já executados precisam de ACK mesmo se ctx foi cancelado
+	// (SIGTERM no meio do lote); o envio tem prazo próprio.
+	actx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(actx, http.MethodPost, cfg.APIEndpoint("/v1/agent/commands/acks"), bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	httpx.SetAuth(req, cfg)
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		metrics.ObserveRequest("/v1/agent/commands/acks", start, 0, err)
+		return err
+	}
+	metrics.ObserveRequest("/v1/agent/commands/acks", start, resp.StatusCode, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return &httpStatusErr{code: resp.StatusCode}
+	}
+	return nil
+}
+
+func readCursor(path string) string {
+	if path == "" {
+		return ""
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func writeCursor(path, cursor string) error {
+	return writeAtomic(path, []byte(cursor))
+}
+
+// seenPath é o arquivo dos cmd_ids executados, ao lado do cursor.
+func seenPath(cursorPath string) string {
+	if cursorPath == "" {
+		return ""
+	}
+	return cursorPath + ".seen"
+}
+
+// readSeen carrega os comandos executados ainda dentro de seenTTL.
+func readSeen(path string, now time.Time) map[string]seenCommand {
+	out := map[string]seenCommand{}
+	if path == "" {
+		return out
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return out
+	}
+	var saved map[string]seenCommand
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return out
+	}
+	for id, s := range saved {
+		if now.Sub(s.At) <= seenTTL {
+			out[id] = s
+		}
+	}
+	return out
+}
+
+// writeAtomic grava de forma atômica (arquivo temporário + rename).
+func writeAtomic(path string, data []byte) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// cmdNow é o relógio dos testes; perto do real porque o estado gravado é
+// recarregado com time.Now.
+var cmdNow = time.Now().UTC().Truncate(time.Second)
+
+// newChannel cria um canal para o agente "agent-1" com relógio manual em
+// cmdNow e devolve a chave privada que assina os comandos.
+func newChannel(t *testing.T, baseURL string) (*CommandChannel, ed25519.PrivateKey, *time.Time) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Defaults()
+	cfg.APIBaseURL = baseURL
+	cfg.Agent.Token = "tok"
+	cfg.CommandsEnabled = true
+	cfg.CommandsPublicKey = base64.StdEncoding.EncodeToString(pub)
+	cfg.CommandsCursorPath = filepath.Join(t.TempDir(), "commands.cursor")
+	uc := NewCommandChannel(cfg, "agent-1", nopLogger{})
+	now := cmdNow
+	uc.now = func() time.Time { return now }
+	return uc, priv, &now
+}
+
+func command(id, typ string, issued time.Time, ttl time.Duration) entities.Command {
+	c := entities.Command{
+		ID:       id,
+		AgentID:  "agent-1",
+		Type:     typ,
+		Payload:  json.RawMessage(`{"n":1}`),
+		IssuedAt: issued.Format(time.RFC3339),
+	}
+	if ttl > 0 {
+		c.ExpiresAt = issued.Add(ttl).Format(time.RFC3339)
+	}
+	return c
+}
+
+func sign(priv ed25519.PrivateKey, c entities.Command) entities.Command {
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, c.SignedBytes()))
+	return c
+}
+
+func TestCommandVerify(t *testing.T) {
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	cases := []struct {
+		name string
+		// edit altera o comando antes de assinar; tamper, depois.
+		edit, tamper func(c *entities.Command)
+		otherKey     bool
+		wantStatus   string
+		wantErr      string
+	}{
+		{name: "válido", wantStatus: entities.CommandOK},
+		{name: "payload alterado", tamper: func(c *entities.Command) { c.Payload = json.RawMessage(`{"n":2}`) }, wantStatus: entities.CommandRejected, wantErr: "invalid signature"},
+		{name: "agent_id trocado depois de assinar", tamper: func(c *entities.Command) { c.AgentID = "agent-2" }, wantStatus: entities.CommandRejected, wantErr: "invalid signature"},
+		{name: "expires_at estendido", tamper: func(c *entities.Command) { c.ExpiresAt = cmdNow.Add(time.Hour).Format(time.RFC3339) }, wantStatus: entities.CommandRejected, wantErr: "invalid signature"},
+		{name: "assinatura não é base64", tamper: func(c *entities.Command) { c.Signature = "%%%" }, wantStatus: entities.CommandRejected, wantErr: "invalid signature"},
+		{name: "outra chave", otherKey: true, wantStatus: entities.CommandRejected, wantErr: "invalid signature"},
+		{name: "outro agente", edit: func(c *entities.Command) { c.AgentID = "agent-2" }, wantStatus: entities.CommandRejected, wantErr: "another agent"},
+		{name: "sem cmd_id", edit: func(c *entities.Command) { c.ID = "" }, wantStatus: entities.CommandRejected, wantErr: "missing cmd_id"},
+		{name: "issued_at inválido", edit: func(c *entities.Command) { c.IssuedAt = "ontem" }, wantStatus: entities.CommandRejected, wantErr: "invalid issued_at"},
+		{name: "emitido há mais que a validade", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow.Add(-maxCommandAge-time.Second), 0) }, wantStatus: entities.CommandRejected, wantErr: "command expired"},
+		{name: "emitido no futuro além da tolerância", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow.Add(clockSkew+time.Minute), 0) }, wantStatus: entities.CommandRejected, wantErr: "issued_at in the future"},
+		{name: "emitido no futuro dentro da tolerância", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow.Add(time.Minute), 0) }, wantStatus: entities.CommandOK},
+		{name: "expirado", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow.Add(-6*time.Minute), 3*time.Minute) }, wantStatus: entities.CommandRejected, wantErr: "command expired"},
+		{name: "expirado dentro da tolerância", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow.Add(-4*time.Minute), 3*time.Minute) }, wantStatus: entities.CommandOK},
+		{name: "expires_at longe demais de issued_at", edit: func(c *entities.Command) { *c = command(c.ID, c.Type, cmdNow, maxCommandAge+time.Second) }, wantStatus: entities.CommandRejected, wantErr: "expires_at too far"},
+		{name: "tipo desconhecido", edit: func(c *entities.Command) { c.Type = "format-disk" }, wantStatus: entities.CommandRejected, wantErr: "unknown command type"},
+		{name: "handler falha", edit: func(c *entities.Command) { c.Type = "fail" }, wantStatus: entities.CommandError, wantErr: "boom"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc, priv, _ := newChannel(t, "http://backend.invalid")
+			var calls int
+			uc.Register("flush-now", func(context.Context, entities.Command) (any, error) { calls++; return "done", nil })
+			uc.Register("fail", func(context.Context, entities.Command) (any, error) { calls++; return nil, errors.New("boom") })
+
+			c := command("c1", "flush-now", cmdNow.Add(-time.Minute), 5*time.Minute)
+			if tc.edit != nil {
+				tc.edit(&c)
+			}
+			if tc.otherKey {
+				priv = otherKey
+			}
+			c = sign(priv, c)
+			if tc.tamper != nil {
+				tc.tamper(&c)
+			}
+			res := uc.run(context.Background(), c)
+			if res.Status != tc.wantStatus || !strings.Contains(res.Error, tc.wantErr) {
+				t.Fatalf("result = %+v, want status %q with error %q", res, tc.wantStatus, tc.wantErr)
+			}
+			if ran := calls > 0; ran != (tc.wantStatus != entities.CommandRejected) {
+				t.Fatalf("handler calls = %d for status %s", calls, res.Status)
+			}
+		})
+	}
+}
+
+func TestCommandReplayReturnsPreviousResult(t *testing.T) {
+	uc, priv, now := newChannel(t, "http://backend.invalid")
+	var calls int
+	uc.Register("flush-now", func(context.Context, entities.Command) (any, error) {
+		calls++
+		return calls, nil
+	})
+	c := sign(priv, command("c1", "flush-now", cmdNow, 5*time.Minute))
+
+	// Uma cópia adulterada é recusada sem marcar o cmd_id como executado.
+	forged := c
+	forged.Payload = json.RawMessage(`{"n":9}`)
+	if res := uc.run(context.Background(), forged); res.Status != entities.CommandRejected {
+		t.Fatalf("forged copy = %+v", res)
+	}
+
+	first := uc.run(context.Background(), c)
+	*now = now.Add(time.Minute)
+	again := uc.run(context.Background(), c)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times for a redelivered command", calls)
+	}
+	if again.Status != entities.CommandOK || again.Output != first.Output || again.StartedAt != first.StartedAt {
+		t.Fatalf("redelivery = %+v, want the first result %+v", again, first)
+	}
+
+	// Passada a validade, a reentrega é recusada como expirada e o cmd_id
+	// esquecido depois de seenTTL.
+	*now = cmdNow.Add(seenTTL + time.Second)
+	if res := uc.run(context.Background(), c); res.Status != entities.CommandRejected || res.Error != "command expired" {
+		t.Fatalf("late redelivery = %+v", res)
+	}
+	uc.run(context.Background(), sign(priv, command("c2", "flush-now", *now, time.Minute)))
+	if _, ok := uc.seen["c1"]; ok {
+		t.Fatal("c1 still remembered after seenTTL")
+	}
+}
+
+// Os cmd_ids executados sobrevivem ao restart: a reentrega dentro da
+// validade devolve o resultado gravado em vez de executar de novo.
+func TestCommandReplayAfterRestart(t *testing.T) {
+	uc, priv, _ := newChannel(t, "http://backend.invalid")
+	var calls int
+	kill := func(context.Context, entities.Command) (any, error) {
+		calls++
+		return map[string]int{"pid": 42}, nil
+	}
+	uc.Register("kill-process", kill)
+	c := sign(priv, command("c1", "kill-process", cmdNow, 5*time.Minute))
+	first := uc.run(context.Background(), c)
+
+	restarted := NewCommandChannel(uc.cfg, "agent-1", nopLogger{})
+	restarted.now = uc.now
+	restarted.Register("kill-process", kill)
+	again := restarted.run(context.Background(), c)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times across a restart", calls)
+	}
+	if again.Status != entities.CommandOK || again.StartedAt != first.StartedAt {
+		t.Fatalf("redelivery after restart = %+v, want the first result %+v", again, first)
+	}
+
+	// Entradas além de seenTTL não são recarregadas.
+	if seen := readSeen(seenPath(uc.cfg.CommandsCursorPath), cmdNow.Add(seenTTL+time.Second)); len(seen) != 0 {
+		t.Fatalf("expired entries reloaded: %v", seen)
+	}
+}
+
+func TestCommandExecuteAcksAndPersistsCursor(t *testing.T) {
+	var acked []entities.CommandResult
+	var polls atomic.Int32
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/agent/commands":
+			if polls.Add(1) > 1 {
+				if r.URL.Query().Get("cursor") != "c-2" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Write(body)
+		case "/v1/agent/commands/acks":
+			if err := json.NewDecoder(r.Body).Decode(&acked); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	uc, priv, _ := newChannel(t, srv.URL)
+	uc.Register("flush-now", func(context.Context, entities.Command) (any, error) { return nil, nil })
+	cmds := []entities.Command{
+		sign(priv, command("c1", "flush-now", cmdNow, time.Minute)),
+		sign(priv, command("c2", "flush-now", cmdNow.Add(-time.Hour), 0)),
+	}
+	body, _ = json.Marshal(map[string]any{"cursor": "c-2", "commands": cmds})
+
+	if err := uc.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(acked) != 2 || acked[0].Status != entities.CommandOK || acked[1].Status != entities.CommandRejected {
+		t.Fatalf("acks = %+v", acked)
+	}
+	if b, _ := os.ReadFile(uc.cfg.CommandsCursorPath); string(b) != "c-2" {
+		t.Fatalf("persisted cursor = %q", b)
+	}
+	// O próximo poll continua do cursor salvo.
+	if err := uc.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
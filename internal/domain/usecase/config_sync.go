@@ -9,6 +9,7 @@ import (
 	"github.com/you/aiceberg_agent/internal/common/config"
 	"github.com/you/aiceberg_agent/internal/common/httpx"
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
 	"github.com/you/aiceberg_agent/internal/data/local/prefs"
 )
 
@@ -36,14 +37,18 @@ func (uc *ConfigSync) Execute(ctx context.Context) error {
 	}
 	httpx.SetAuth(req, uc.cfg)
 
+	start := time.Now()
 	resp, err := uc.cl.Do(req)
 	if err != nil {
-		uc.log.Error("config sync: " + err.Error())
+		metrics.ObserveRequest("/v1/agent/config", start, 0, err)
+		uc.log.Error("config sync failed", "err", err)
 		return err
 	}
+	metrics.ObserveRequest("/v1/agent/config", start, resp.StatusCode, nil)
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNoContent {
+		metrics.ConfigSyncLastSuccess.Set(metrics.Now())
 		return nil
 	}
 	if resp.StatusCode >= 300 {
@@ -63,13 +68,23 @@ func (uc *ConfigSync) Execute(ctx context.Context) error {
 
 	cur := uc.store.Get()
 	if cur.Version == payload.Collect.Version && payload.Collect.Version != "" {
+		metrics.ConfigSyncLastSuccess.Set(metrics.Now())
 		return nil
 	}
 
 	if err := uc.store.Update(payload.Collect); err != nil {
-		uc.log.Error("config persist: " + err.Error())
+		uc.log.Error("config persist failed", "err", err)
 		return err
 	}
-	uc.log.Info("config sync ok version=" + payload.Collect.Version)
+	uc.log.Info("config sync ok", "version", payload.Collect.Version)
+	SetConfigVersion(payload.Collect.Version)
+	metrics.ConfigSyncLastSuccess.Set(metrics.Now())
 	return nil
 }
+
+// SetConfigVersion publica a versão da config remota em uso (também
+// chamada no start, com a versão das prefs salvas).
+func SetConfigVersion(v string) {
+	metrics.ConfigSyncVersion.Reset()
+	metrics.ConfigSyncVersion.Set(1, v)
+}
@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"os"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// EmitEvent enfileira eventos do próprio agente (kind=event) no outbox, para
+// seguirem ao backend pelo mesmo caminho da telemetria.
+type EmitEvent struct {
+	outbox     ports.OutboxRepo
+	log        logger.Logger
+	authHeader string
+}
+
+func NewEmitEvent(o ports.OutboxRepo, l logger.Logger, authHeader string) *EmitEvent {
+	return &EmitEvent{outbox: o, log: l, authHeader: authHeader}
+}
+
+// Emit enfileira body como evento com o sub informado (ex.: config_reload).
+func (uc *EmitEvent) Emit(sub string, body any) error {
+	hostname, _ := os.Hostname()
+	env := entities.Envelope{
+		ID:            genID(),
+		SchemaVersion: 1,
+		Kind:          "event",
+		Sub:           sub,
+		AgentID:       hostname,
+		TSUnixMs:      time.Now().UnixMilli(),
+		Body:          body,
+		AuthHeader:    uc.authHeader,
+	}
+	if err := uc.outbox.Append(env); err != nil {
+		uc.log.Error("event outbox append failed", "sub", sub, "err", err)
+		return err
+	}
+	metrics.EnvelopesCollected.Inc(env.Source())
+	return nil
+}
@@ -2,54 +2,182 @@ package usecase
 
 import (
 	"context"
-	"strconv"
+	"errors"
+	"net/http"
+	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/retry"
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 )
 
 type FlushOutbox struct {
+	queue       string // main|oslogs (métricas)
 	outbox      ports.OutboxRepo
 	tx          ports.Transport
 	log         logger.Logger
 	defaultAuth string
+	dlq         ports.DeadLetterRepo
+	backoff     retry.Backoff
+	failures    int
+	nextAttempt time.Time
 }
 
-func NewFlushOutbox(o ports.OutboxRepo, t ports.Transport, l logger.Logger, defaultAuth string) *FlushOutbox {
-	return &FlushOutbox{o, t, l, defaultAuth}
+func NewFlushOutbox(queue string, o ports.OutboxRepo, t ports.Transport, dlq ports.DeadLetterRepo, l logger.Logger, defaultAuth string, b retry.Backoff) *FlushOutbox {
+	return &FlushOutbox{queue: queue, outbox: o, tx: t, dlq: dlq, log: l, defaultAuth: defaultAuth, backoff: b}
 }
 
 func (uc *FlushOutbox) Execute(ctx context.Context) error {
+	// Após falha, espera o backoff (com jitter) antes de tentar de novo.
+	if time.Now().Before(uc.nextAttempt) {
+		return nil
+	}
+	_, err := uc.flush(ctx)
+	return err
+}
+
+// Drain envia lotes em sequência até esvaziar o outbox, falhar ou ctx expirar,
+// inclusive no meio de um envio (usado no shutdown; ignora a espera de
+// backoff, mas não o circuit breaker).
+func (uc *FlushOutbox) Drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		n, err := uc.flush(ctx)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// flush envia um lote e retorna quantos envelopes saíram do outbox.
+func (uc *FlushOutbox) flush(ctx context.Context) (int, error) {
 	batch, err := uc.outbox.ReadBatch(50)
-	if err != nil || len(batch) == 0 {
-		return err
+	if err != nil {
+		return 0, err
+	}
+	if len(batch) == 0 {
+		metrics.LastFlushSuccess.Set(metrics.Now(), uc.queue)
+		return 0, nil
 	}
 
+	// Grupos na ordem em que aparecem no lote: o mais antigo sai primeiro.
 	grouped := make(map[string][]entities.Envelope)
+	var order []string
 	for _, e := range batch {
 		h := e.AuthHeader
 		if h == "" {
 			h = uc.defaultAuth
 		}
+		if _, ok := grouped[h]; !ok {
+			order = append(order, h)
+		}
 		grouped[h] = append(grouped[h], e)
 	}
 
-	for auth, list := range grouped {
-		if err := uc.tx.SendWithAuth(list, auth); err != nil {
-			uc.log.Error("transport: " + err.Error())
-			return err
+	// Ack apenas do que foi entregue (ou movido para dead-letter); o resto fica
+	// no outbox para a próxima tentativa, mesmo se um grupo posterior falhar.
+	var done []string
+	var rejected int
+	var sendErr error
+	for _, auth := range order {
+		list := grouped[auth]
+		byID := make(map[string]entities.Envelope, len(list))
+		for _, e := range list {
+			byID[e.ID] = e
+		}
+		res, err := uc.tx.SendWithAuth(ctx, list, auth)
+		if code := blockedStatus(err); code != 0 {
+			if auth == uc.defaultAuth {
+				// Credenciais do próprio agente: pausa (com backoff) até
+				// serem aceitas de novo; o health mostra o motivo.
+				metrics.FlushBlocked.Set(float64(code), uc.queue)
+				sendErr = err
+				break
+			}
+			if code != http.StatusNotFound {
+				// Credenciais de um agente atrás do hub: o lote travaria a
+				// fila para sempre, então vai para o dead-letter. (404 é o
+				// endpoint, comum a todos: segue como falha.)
+				res, err = entities.SendResult{}, nil
+				for _, e := range list {
+					res.Rejected = append(res.Rejected, entities.Rejection{ID: e.ID, Reason: "credentials rejected: " + http.StatusText(code), Status: code})
+				}
+			}
+		}
+		if err != nil {
+			sendErr = err
+			break
+		}
+		if auth == uc.defaultAuth {
+			metrics.FlushBlocked.Set(0, uc.queue)
+		}
+		for _, id := range res.Accepted {
+			if e, ok := byID[id]; ok {
+				done = append(done, id)
+				metrics.EnvelopesFlushed.Inc(e.Source())
+			}
+		}
+		for _, r := range res.Rejected {
+			env, ok := byID[r.ID]
+			if !ok {
+				continue
+			}
+			if uc.dlq != nil {
+				if err := uc.dlq.Put(env, r.Reason, r.Status); err != nil {
+					uc.log.Error("dead-letter put failed", "queue", uc.queue, "id", r.ID, "err", err)
+					continue
+				}
+			}
+			uc.log.Error("rejected", "queue", uc.queue, "id", r.ID, "status", r.Status, "reason", r.Reason)
+			done = append(done, r.ID)
+			rejected++
+			metrics.EnvelopesDropped.Inc(env.Source(), "rejected")
 		}
 	}
 
-	ids := make([]string, 0, len(batch))
-	for _, e := range batch {
-		ids = append(ids, e.ID)
+	if len(done) > 0 {
+		if err := uc.outbox.Ack(done); err != nil {
+			uc.log.Error("ack failed", "queue", uc.queue, "err", err)
+			return 0, err
+		}
+		uc.log.Info("flushed", "queue", uc.queue, "ack", len(done), "rejected", rejected)
+	}
+	if sendErr != nil {
+		uc.fail(sendErr)
+		return len(done), sendErr
+	}
+	uc.failures = 0
+	uc.nextAttempt = time.Time{}
+	metrics.LastFlushSuccess.Set(metrics.Now(), uc.queue)
+	return len(done), nil
+}
+
+// blockedStatus devolve o status de err se o backend recusou as credenciais
+// ou não conhece o agente (401, 403, 404); senão zero.
+func blockedStatus(err error) int {
+	var sc interface{ StatusCode() int }
+	if !errors.As(err, &sc) {
+		return 0
+	}
+	switch code := sc.StatusCode(); code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return code
+	}
+	return 0
+}
+
+// fail agenda a próxima tentativa conforme backoff ou Retry-After do servidor.
+func (uc *FlushOutbox) fail(err error) {
+	if errors.Is(err, retry.ErrOpen) {
+		return
 	}
-	if err := uc.outbox.Ack(ids); err != nil {
-		uc.log.Error("ack: " + err.Error())
-		return err
+	uc.failures++
+	wait := uc.backoff.Delay(uc.failures - 1)
+	if ra, ok := retry.RetryAfter(err); ok && ra > wait {
+		wait = ra
 	}
-	uc.log.Info("flushed: ack=" + strconv.Itoa(len(ids)))
-	return nil
+	uc.nextAttempt = time.Now().Add(wait)
+	uc.log.Error("transport failed", "queue", uc.queue, "err", err, "retry_in", wait.Round(time.Second))
 }
@@ -0,0 +1,258 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/common/metrics"
+	"github.com/you/aiceberg_agent/internal/common/retry"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+const ownAuth = "Token own"
+
+// queueOutbox é um outbox em memória: ReadBatch devolve os mais antigos e
+// Ack os remove.
+type queueOutbox struct {
+	queued []entities.Envelope
+	acked  []string
+}
+
+func (o *queueOutbox) Append(e entities.Envelope) error {
+	o.queued = append(o.queued, e)
+	return nil
+}
+
+func (o *queueOutbox) ReadBatch(n int) ([]entities.Envelope, error) {
+	return slices.Clone(o.queued[:min(n, len(o.queued))]), nil
+}
+
+func (o *queueOutbox) Ack(ids []string) error {
+	o.acked = append(o.acked, ids...)
+	o.queued = slices.DeleteFunc(o.queued, func(e entities.Envelope) bool { return slices.Contains(ids, e.ID) })
+	return nil
+}
+
+func (o *queueOutbox) Len() (int, int64) { return len(o.queued), 0 }
+
+// statusErr imita o erro HTTP do transport.
+type statusErr int
+
+func (e statusErr) Error() string   { return fmt.Sprintf("status %d", int(e)) }
+func (e statusErr) StatusCode() int { return int(e) }
+
+type sendReply struct {
+	res entities.SendResult
+	err error
+}
+
+// fakeSender responde por credencial e registra cada envio como
+// "credencial: ids". Sem resposta cadastrada, aceita o lote inteiro.
+type fakeSender struct {
+	replies map[string]sendReply
+	calls   []string
+}
+
+func (s *fakeSender) SendWithAuth(_ context.Context, batch []entities.Envelope, auth string) (entities.SendResult, error) {
+	s.calls = append(s.calls, auth+": "+strings.Join(ids(batch), ","))
+	if r, ok := s.replies[auth]; ok {
+		return r.res, r.err
+	}
+	return entities.SendResult{Accepted: ids(batch)}, nil
+}
+
+// env cria um envelope com a credencial dada ("" = a do agente).
+func env(id, auth string) entities.Envelope {
+	return entities.Envelope{ID: id, Kind: "metric", AuthHeader: auth}
+}
+
+func TestFlushOutbox(t *testing.T) {
+	relayed := []entities.Envelope{env("a", ""), env("b", "Token r1"), env("c", ""), env("d", "Token r2")}
+	cases := []struct {
+		name    string
+		batch   []entities.Envelope
+		replies map[string]sendReply
+		putErr  error
+
+		wantCalls   []string
+		wantAcked   []string
+		wantDead    []string
+		wantQueued  []string
+		wantErr     bool
+		wantBlocked float64
+	}{
+		{
+			name:      "tudo aceito",
+			batch:     []entities.Envelope{env("a", ""), env("b", "")},
+			wantCalls: []string{ownAuth + ": a,b"},
+			wantAcked: []string{"a", "b"},
+		},
+		{
+			name:      "agrupa por credencial na ordem do lote",
+			batch:     relayed,
+			wantCalls: []string{ownAuth + ": a,c", "Token r1: b", "Token r2: d"},
+			wantAcked: []string{"a", "c", "b", "d"},
+		},
+		{
+			name:  "aceite e rejeição parciais",
+			batch: []entities.Envelope{env("a", ""), env("b", ""), env("c", "")},
+			replies: map[string]sendReply{ownAuth: {res: entities.SendResult{
+				Accepted: []string{"a", "x"},
+				Rejected: []entities.Rejection{{ID: "b", Reason: "schema", Status: 422}, {ID: "y", Status: 422}},
+			}}},
+			wantCalls:  []string{ownAuth + ": a,b,c"},
+			wantAcked:  []string{"a", "b"},
+			wantDead:   []string{"b"},
+			wantQueued: []string{"c"},
+		},
+		{
+			name:  "dead-letter falhando mantém o rejeitado na fila",
+			batch: []entities.Envelope{env("a", ""), env("b", "")},
+			replies: map[string]sendReply{ownAuth: {res: entities.SendResult{
+				Accepted: []string{"a"},
+				Rejected: []entities.Rejection{{ID: "b", Status: 422}},
+			}}},
+			putErr:     errors.New("disk full"),
+			wantCalls:  []string{ownAuth + ": a,b"},
+			wantAcked:  []string{"a"},
+			wantQueued: []string{"b"},
+		},
+		{
+			name:      "401 de agente atrás do hub vai para o dead-letter",
+			batch:     relayed,
+			replies:   map[string]sendReply{"Token r1": {err: statusErr(http.StatusUnauthorized)}},
+			wantCalls: []string{ownAuth + ": a,c", "Token r1: b", "Token r2: d"},
+			wantAcked: []string{"a", "c", "b", "d"},
+			wantDead:  []string{"b"},
+		},
+		{
+			name:      "403 de agente atrás do hub vai para o dead-letter",
+			batch:     []entities.Envelope{env("b", "Token r1"), env("e", "Token r1")},
+			replies:   map[string]sendReply{"Token r1": {err: statusErr(http.StatusForbidden)}},
+			wantCalls: []string{"Token r1: b,e"},
+			wantAcked: []string{"b", "e"},
+			wantDead:  []string{"b", "e"},
+		},
+		{
+			name:        "401 próprio pausa o envio",
+			batch:       relayed,
+			replies:     map[string]sendReply{ownAuth: {err: statusErr(http.StatusUnauthorized)}},
+			wantCalls:   []string{ownAuth + ": a,c"},
+			wantQueued:  []string{"a", "b", "c", "d"},
+			wantErr:     true,
+			wantBlocked: http.StatusUnauthorized,
+		},
+		{
+			name:        "403 próprio depois de um grupo entregue",
+			batch:       []entities.Envelope{env("b", "Token r1"), env("a", "")},
+			replies:     map[string]sendReply{ownAuth: {err: statusErr(http.StatusForbidden)}},
+			wantCalls:   []string{"Token r1: b", ownAuth + ": a"},
+			wantAcked:   []string{"b"},
+			wantQueued:  []string{"a"},
+			wantErr:     true,
+			wantBlocked: http.StatusForbidden,
+		},
+		{
+			name:        "404 próprio pausa o envio",
+			batch:       []entities.Envelope{env("a", "")},
+			replies:     map[string]sendReply{ownAuth: {err: statusErr(http.StatusNotFound)}},
+			wantCalls:   []string{ownAuth + ": a"},
+			wantQueued:  []string{"a"},
+			wantErr:     true,
+			wantBlocked: http.StatusNotFound,
+		},
+		{
+			name:       "404 de agente atrás do hub é falha, não dead-letter",
+			batch:      relayed,
+			replies:    map[string]sendReply{"Token r1": {err: statusErr(http.StatusNotFound)}},
+			wantCalls:  []string{ownAuth + ": a,c", "Token r1: b"},
+			wantAcked:  []string{"a", "c"},
+			wantQueued: []string{"b", "d"},
+			wantErr:    true,
+		},
+		{
+			name:       "erro retentável mantém o grupo na fila",
+			batch:      relayed,
+			replies:    map[string]sendReply{"Token r1": {err: statusErr(http.StatusServiceUnavailable)}},
+			wantCalls:  []string{ownAuth + ": a,c", "Token r1: b"},
+			wantAcked:  []string{"a", "c"},
+			wantQueued: []string{"b", "d"},
+			wantErr:    true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := &queueOutbox{queued: slices.Clone(tc.batch)}
+			tx := &fakeSender{replies: tc.replies}
+			dlq := &fakeDLQ{putErr: tc.putErr}
+			uc := NewFlushOutbox(tc.name, box, tx, dlq, nopLogger{}, ownAuth, retry.Backoff{})
+			err := uc.Execute(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Execute = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !slices.Equal(tx.calls, tc.wantCalls) {
+				t.Errorf("calls = %q, want %q", tx.calls, tc.wantCalls)
+			}
+			if !slices.Equal(box.acked, tc.wantAcked) {
+				t.Errorf("acked = %v, want %v", box.acked, tc.wantAcked)
+			}
+			var dead []string
+			for _, r := range dlq.put {
+				dead = append(dead, r.ID)
+			}
+			if !slices.Equal(dead, tc.wantDead) {
+				t.Errorf("dead-lettered = %v, want %v", dead, tc.wantDead)
+			}
+			if got := ids(box.queued); !slices.Equal(got, tc.wantQueued) {
+				t.Errorf("queued = %v, want %v", got, tc.wantQueued)
+			}
+			if got, _ := metrics.FlushBlocked.Value(tc.name); got != tc.wantBlocked {
+				t.Errorf("flush blocked = %v, want %v", got, tc.wantBlocked)
+			}
+			if failed := uc.failures > 0; failed != tc.wantErr {
+				t.Errorf("failures = %d after err %v", uc.failures, err)
+			}
+		})
+	}
+}
+
+// O dead-letter de credencial recusada guarda o status e o motivo.
+func TestFlushOutboxRelayedRejectionReason(t *testing.T) {
+	box := &queueOutbox{queued: []entities.Envelope{env("b", "Token r1")}}
+	tx := &fakeSender{replies: map[string]sendReply{"Token r1": {err: statusErr(http.StatusForbidden)}}}
+	dlq := &fakeDLQ{}
+	uc := NewFlushOutbox("main", box, tx, dlq, nopLogger{}, ownAuth, retry.Backoff{})
+	if err := uc.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []entities.Rejection{{ID: "b", Reason: "credentials rejected: Forbidden", Status: http.StatusForbidden}}
+	if !slices.Equal(dlq.put, want) {
+		t.Fatalf("dead-letter = %+v, want %+v", dlq.put, want)
+	}
+}
+
+// Depois de pausar, o envio volta a liberar o status quando a credencial
+// própria é aceita.
+func TestFlushOutboxUnblocks(t *testing.T) {
+	box := &queueOutbox{queued: []entities.Envelope{env("a", "")}}
+	tx := &fakeSender{replies: map[string]sendReply{ownAuth: {err: statusErr(http.StatusUnauthorized)}}}
+	uc := NewFlushOutbox("unblock", box, tx, &fakeDLQ{}, nopLogger{}, ownAuth, retry.Backoff{})
+	if err := uc.Execute(context.Background()); err == nil {
+		t.Fatal("expected the 401")
+	}
+	delete(tx.replies, ownAuth)
+	if err := uc.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := metrics.FlushBlocked.Value("unblock"); got != 0 {
+		t.Fatalf("flush blocked = %v after a successful send", got)
+	}
+	if len(box.queued) != 0 || uc.failures != 0 {
+		t.Fatalf("queued = %v, failures = %d", ids(box.queued), uc.failures)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/you/aiceberg_agent/internal/common/config"
 	"github.com/you/aiceberg_agent/internal/common/httpx"
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
 	"github.com/you/aiceberg_agent/internal/common/version"
 )
 
@@ -33,10 +34,14 @@ func NewPingBackend(cfg config.Config, log logger.Logger) *PingBackend {
 
 func (uc *PingBackend) Execute(ctx context.Context) error {
 	challenge, err := uc.fetchChallenge(ctx)
-	if err != nil || challenge == "" {
-		return err
+	if err == nil && challenge != "" {
+		err = uc.sendAck(ctx, challenge)
+	}
+	if err == nil {
+		// 204 (sem challenge) também conta: o backend respondeu.
+		metrics.PingLastAck.Set(metrics.Now())
 	}
-	return uc.sendAck(ctx, challenge)
+	return err
 }
 
 func (uc *PingBackend) fetchChallenge(ctx context.Context) (string, error) {
@@ -46,10 +51,13 @@ func (uc *PingBackend) fetchChallenge(ctx context.Context) (string, error) {
 	}
 	httpx.SetAuth(req, uc.cfg)
 
+	start := time.Now()
 	resp, err := uc.cl.Do(req)
 	if err != nil {
+		metrics.ObserveRequest("/v1/agent/ping", start, 0, err)
 		return "", err
 	}
+	metrics.ObserveRequest("/v1/agent/ping", start, resp.StatusCode, nil)
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusNoContent {
@@ -84,15 +92,18 @@ func (uc *PingBackend) sendAck(ctx context.Context, challenge string) error {
 	httpx.SetAuth(req, uc.cfg)
 	req.Header.Set("Content-Type", "application/json")
 
+	start := time.Now()
 	resp, err := uc.cl.Do(req)
 	if err != nil {
+		metrics.ObserveRequest("/v1/agent/ping", start, 0, err)
 		return err
 	}
+	metrics.ObserveRequest("/v1/agent/ping", start, resp.StatusCode, nil)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		return &httpStatusErr{code: resp.StatusCode}
 	}
-	uc.log.Info("ping ack sent challenge=" + challenge)
+	uc.log.Debug("ping ack sent", "challenge", challenge)
 	return nil
 }
 
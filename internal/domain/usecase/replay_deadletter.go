@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// ReplayDeadLetter devolve ao outbox os envelopes do dead-letter marcados para reenvio.
+type ReplayDeadLetter struct {
+	dlq    ports.DeadLetterRepo
+	outbox ports.OutboxRepo
+	log    logger.Logger
+	// appended são IDs já devolvidos ao outbox cuja remoção do dead-letter
+	// falhou: na próxima execução só a remoção é refeita, sem reenfileirar
+	// (o envelope pode já ter sido entregue).
+	appended map[string]bool
+}
+
+func NewReplayDeadLetter(d ports.DeadLetterRepo, o ports.OutboxRepo, l logger.Logger) *ReplayDeadLetter {
+	return &ReplayDeadLetter{dlq: d, outbox: o, log: l, appended: map[string]bool{}}
+}
+
+func (uc *ReplayDeadLetter) Execute(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	envs, err := uc.dlq.PendingReplay()
+	if err != nil || len(envs) == 0 {
+		return err
+	}
+	ids := make([]string, 0, len(envs))
+	for _, e := range envs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !uc.appended[e.ID] {
+			if err := uc.outbox.Append(e); err != nil {
+				uc.log.Error("replay append failed", "err", err)
+				break
+			}
+			uc.appended[e.ID] = true
+		}
+		ids = append(ids, e.ID)
+	}
+	if err := uc.dlq.Remove(ids); err != nil {
+		uc.log.Error("replay remove failed", "err", err)
+		return err
+	}
+	for _, id := range ids {
+		delete(uc.appended, id)
+	}
+	uc.log.Info("dead-letter replayed", "n", len(ids))
+	return ctx.Err()
+}
@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// nopLogger descarta tudo.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)  {}
+func (nopLogger) Info(string, ...any)   {}
+func (nopLogger) Warn(string, ...any)   {}
+func (nopLogger) Error(string, ...any)  {}
+func (nopLogger) Fatal(string, ...any)  {}
+func (nopLogger) SetLevel(string) error { return nil }
+func (nopLogger) Redact(...string)      {}
+func (nopLogger) Sync()                 {}
+
+type fakeDLQ struct {
+	pending   []entities.Envelope
+	put       []entities.Rejection
+	putErr    error
+	removeErr error
+}
+
+func (d *fakeDLQ) Put(e entities.Envelope, reason string, status int) error {
+	if d.putErr != nil {
+		return d.putErr
+	}
+	d.put = append(d.put, entities.Rejection{ID: e.ID, Reason: reason, Status: status})
+	return nil
+}
+
+func (d *fakeDLQ) PendingReplay() ([]entities.Envelope, error) { return d.pending, nil }
+
+func (d *fakeDLQ) Remove(ids []string) error {
+	if d.removeErr != nil {
+		return d.removeErr
+	}
+	d.pending = slices.DeleteFunc(d.pending, func(e entities.Envelope) bool { return slices.Contains(ids, e.ID) })
+	return nil
+}
+
+type fakeOutbox struct {
+	appended  []string
+	bodies    []any
+	appendErr map[string]error
+}
+
+func (o *fakeOutbox) Append(e entities.Envelope) error {
+	if err := o.appendErr[e.ID]; err != nil {
+		return err
+	}
+	o.appended = append(o.appended, e.ID)
+	o.bodies = append(o.bodies, e.Body)
+	return nil
+}
+
+func (o *fakeOutbox) ReadBatch(int) ([]entities.Envelope, error) { return nil, nil }
+func (o *fakeOutbox) Ack([]string) error                         { return nil }
+func (o *fakeOutbox) Len() (int, int64)                          { return len(o.appended), 0 }
+
+func envelopes(ids ...string) []entities.Envelope {
+	out := make([]entities.Envelope, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, entities.Envelope{ID: id, Kind: "metric"})
+	}
+	return out
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	errDisk := errors.New("disk full")
+	cases := []struct {
+		name         string
+		pending      []string
+		appendErr    map[string]error
+		removeErr    error
+		wantAppended []string
+		wantPending  []string
+		wantErr      bool
+	}{
+		{"vazio", nil, nil, nil, nil, nil, false},
+		{"devolve e remove", []string{"a", "b"}, nil, nil, []string{"a", "b"}, nil, false},
+		{"append falha no meio", []string{"a", "b", "c"}, map[string]error{"b": errDisk}, nil, []string{"a"}, []string{"b", "c"}, false},
+		{"remove falha", []string{"a", "b"}, nil, errDisk, []string{"a", "b"}, []string{"a", "b"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dlq := &fakeDLQ{pending: envelopes(tc.pending...), removeErr: tc.removeErr}
+			box := &fakeOutbox{appendErr: tc.appendErr}
+			uc := NewReplayDeadLetter(dlq, box, nopLogger{})
+			err := uc.Execute(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Execute = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !slices.Equal(box.appended, tc.wantAppended) {
+				t.Errorf("appended = %v, want %v", box.appended, tc.wantAppended)
+			}
+			if got := ids(dlq.pending); !slices.Equal(got, tc.wantPending) {
+				t.Errorf("pending = %v, want %v", got, tc.wantPending)
+			}
+		})
+	}
+}
+
+// Remove que falhou é refeito na próxima execução sem reenfileirar: o
+// envelope pode já ter sido entregue.
+func TestReplayDeadLetterRetriesRemoveOnly(t *testing.T) {
+	dlq := &fakeDLQ{pending: envelopes("a", "b"), removeErr: errors.New("locked")}
+	box := &fakeOutbox{}
+	uc := NewReplayDeadLetter(dlq, box, nopLogger{})
+	if err := uc.Execute(context.Background()); err == nil {
+		t.Fatal("expected the Remove error")
+	}
+	dlq.removeErr = nil
+	if err := uc.Execute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(box.appended, []string{"a", "b"}) {
+		t.Fatalf("appended = %v, want each envelope once", box.appended)
+	}
+	if len(dlq.pending) != 0 {
+		t.Fatalf("pending = %v", ids(dlq.pending))
+	}
+}
+
+func TestReplayDeadLetterCanceled(t *testing.T) {
+	dlq := &fakeDLQ{pending: envelopes("a")}
+	box := &fakeOutbox{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := NewReplayDeadLetter(dlq, box, nopLogger{}).Execute(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Execute = %v, want context.Canceled", err)
+	}
+	if len(box.appended) != 0 {
+		t.Fatalf("appended = %v after cancel", box.appended)
+	}
+}
+
+func ids(envs []entities.Envelope) []string {
+	var out []string
+	for _, e := range envs {
+		out = append(out, e.ID)
+	}
+	return out
+}
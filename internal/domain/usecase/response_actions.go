@@ -0,0 +1,400 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// ResponseActions executa ações de contenção vindas do canal de comandos.
+// block-ip e isolate-host ficam registradas (e persistidas) até expirar o
+// TTL ou chegar unblock-ip/release-host; toda ação gera um evento
+// response_action para auditoria.
+type ResponseActions struct {
+	fw     ports.Firewall
+	procs  ports.ProcessKiller
+	events *EmitEvent
+	log    logger.Logger
+	now    func() time.Time
+
+	mu     sync.Mutex
+	cfg    config.Config
+	active map[string]entities.ResponseAction // chave: ação + alvo
+}
+
+func NewResponseActions(cfg config.Config, fw ports.Firewall, procs ports.ProcessKiller, events *EmitEvent, l logger.Logger) *ResponseActions {
+	return &ResponseActions{
+		fw:     fw,
+		procs:  procs,
+		events: events,
+		log:    l,
+		now:    time.Now,
+		cfg:    cfg,
+		active: map[string]entities.ResponseAction{},
+	}
+}
+
+// Update troca a config (reload). Ações ativas mantêm o TTL original.
+func (uc *ResponseActions) Update(cfg config.Config) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.cfg = cfg
+}
+
+// Restore relê as ações persistidas no start: reaplica as vigentes (regras
+// somem no reboot) e desfaz as que expiraram com o agente parado.
+func (uc *ResponseActions) Restore() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	b, err := os.ReadFile(uc.cfg.ActionsStatePath)
+	if err != nil {
+		return
+	}
+	var saved []entities.ResponseAction
+	if err := json.Unmarshal(b, &saved); err != nil {
+		uc.log.Error("response actions: invalid state file", "path", uc.cfg.ActionsStatePath, "err", err)
+		return
+	}
+	now := uc.now()
+	for _, a := range saved {
+		if now.After(a.ExpiresAt) {
+			uc.rollback(a, "expired")
+			continue
+		}
+		var err error
+		switch a.Action {
+		case entities.ActionBlockIP:
+			err = uc.fw.BlockIP(a.Target)
+		case entities.ActionIsolateHost:
+			err = uc.fw.Isolate(a.Allow)
+		}
+		if err != nil {
+			uc.log.Error("response actions: restore failed", "action", a.Action, "target", a.Target, "err", err)
+		}
+		uc.active[actionKey(a.Action, a.Target)] = a
+	}
+	uc.persist()
+}
+
+// Expire desfaz as ações cujo TTL venceu.
+func (uc *ResponseActions) Expire() {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	now := uc.now()
+	changed := false
+	for k, a := range uc.active {
+		if now.After(a.ExpiresAt) {
+			if uc.rollback(a, "expired") {
+				delete(uc.active, k)
+				changed = true
+			}
+		}
+	}
+	if changed {
+		uc.persist()
+	}
+}
+
+// KillProcess trata kill-process: {"pid": 1234, "name": "opcional", "force": false}.
+// Se name vier, precisa bater com o processo (evita matar PID reciclado).
+func (uc *ResponseActions) KillProcess(_ context.Context, cmd entities.Command) (any, error) {
+	var p struct {
+		PID   int32  `json:"pid"`
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+	if err := uc.decode(cmd, &p); err != nil {
+		return nil, err
+	}
+	target := strconv.Itoa(int(p.PID))
+	audit := map[string]any{}
+	err := func() error {
+		if p.PID <= 1 || int(p.PID) == os.Getpid() {
+			return errors.New("refusing to kill pid " + target)
+		}
+		info, err := uc.procs.Lookup(p.PID)
+		if err != nil {
+			return errors.New("process not found: " + err.Error())
+		}
+		audit["process"] = info
+		if p.Name != "" && p.Name != info.Name {
+			return errors.New("process name mismatch: " + info.Name)
+		}
+		return uc.procs.Kill(p.PID, p.Force)
+	}()
+	audit["force"] = p.Force
+	uc.audit(cmd.ID, entities.ActionKillProcess, target, "command", err, audit)
+	if err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// BlockIP trata block-ip: {"ip": "203.0.113.7" ou CIDR, "ttl_s": 3600}.
+// Repetir o bloqueio renova o TTL.
+func (uc *ResponseActions) BlockIP(_ context.Context, cmd entities.Command) (any, error) {
+	var p struct {
+		IP   string `json:"ip"`
+		TTLs int64  `json:"ttl_s"`
+	}
+	if err := uc.decode(cmd, &p); err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	a := uc.newAction(cmd.ID, entities.ActionBlockIP, p.IP, p.TTLs)
+	err := uc.checkBlockable(p.IP)
+	if err == nil {
+		err = uc.fw.BlockIP(p.IP)
+	}
+	return uc.applied(a, err)
+}
+
+// UnblockIP trata unblock-ip: {"ip": "..."}.
+func (uc *ResponseActions) UnblockIP(_ context.Context, cmd entities.Command) (any, error) {
+	var p struct {
+		IP string `json:"ip"`
+	}
+	if err := uc.decode(cmd, &p); err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.release(cmd.ID, entities.ActionBlockIP, p.IP)
+}
+
+// IsolateHost trata isolate-host: {"ttl_s": 3600, "allow": ["ip", ...]}.
+// O backend (APIBaseURL) fica sempre liberado.
+func (uc *ResponseActions) IsolateHost(_ context.Context, cmd entities.Command) (any, error) {
+	var p struct {
+		TTLs  int64    `json:"ttl_s"`
+		Allow []string `json:"allow"`
+	}
+	if err := uc.decode(cmd, &p); err != nil {
+		return nil, err
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	a := uc.newAction(cmd.ID, entities.ActionIsolateHost, "", p.TTLs)
+	api, err := uc.apiAddrs()
+	if err == nil {
+		for _, ip := range p.Allow {
+			if !validTarget(ip) {
+				err = errors.New("invalid allow entry " + ip)
+				break
+			}
+		}
+	}
+	if err == nil {
+		a.Allow = append(api, p.Allow...)
+		err = uc.fw.Isolate(a.Allow)
+	}
+	return uc.applied(a, err)
+}
+
+// ReleaseHost trata release-host: desfaz o isolamento antes do TTL.
+func (uc *ResponseActions) ReleaseHost(_ context.Context, cmd entities.Command) (any, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.release(cmd.ID, entities.ActionIsolateHost, "")
+}
+
+func (uc *ResponseActions) decode(cmd entities.Command, v any) error {
+	uc.mu.Lock()
+	enabled := uc.cfg.ActionsEnabled
+	uc.mu.Unlock()
+	if !enabled {
+		return errors.New("response actions disabled")
+	}
+	if len(cmd.Payload) == 0 {
+		return errors.New("missing payload")
+	}
+	if err := json.Unmarshal(cmd.Payload, v); err != nil {
+		return errors.New("invalid payload: " + err.Error())
+	}
+	return nil
+}
+
+// newAction monta a ação com TTL: zero usa o default, acima do máximo é limitado.
+func (uc *ResponseActions) newAction(id, action, target string, ttlSeconds int64) entities.ResponseAction {
+	ttl := time.Duration(ttlSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = uc.cfg.ActionsDefaultTTL
+	}
+	if ttl > uc.cfg.ActionsMaxTTL {
+		ttl = uc.cfg.ActionsMaxTTL
+	}
+	now := uc.now()
+	return entities.ResponseAction{ID: id, Action: action, Target: target, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+}
+
+// applied registra a ação aplicada (ou a falha) e devolve a saída do ACK.
+func (uc *ResponseActions) applied(a entities.ResponseAction, err error) (any, error) {
+	if err != nil {
+		uc.audit(a.ID, a.Action, a.Target, "command", err, nil)
+		return nil, err
+	}
+	extra := map[string]any{"expires_at": a.ExpiresAt.UTC().Format(time.RFC3339)}
+	if len(a.Allow) > 0 {
+		extra["allow"] = a.Allow
+	}
+	uc.audit(a.ID, a.Action, a.Target, "command", nil, extra)
+	uc.active[actionKey(a.Action, a.Target)] = a
+	uc.persist()
+	return extra, nil
+}
+
+func (uc *ResponseActions) release(id, action, target string) (any, error) {
+	a, ok := uc.active[actionKey(action, target)]
+	if !ok {
+		// Sem registro (ex.: state perdido): desfaz mesmo assim, é idempotente.
+		a = entities.ResponseAction{Action: action, Target: target}
+	}
+	a.ID = id
+	if !uc.rollback(a, "command") {
+		return nil, errors.New("rollback failed")
+	}
+	delete(uc.active, actionKey(action, target))
+	uc.persist()
+	return map[string]string{"status": "rolled_back"}, nil
+}
+
+// rollback desfaz a ação no firewall e audita; false mantém a ação ativa
+// para nova tentativa no próximo Expire.
+func (uc *ResponseActions) rollback(a entities.ResponseAction, trigger string) bool {
+	var err error
+	switch a.Action {
+	case entities.ActionBlockIP:
+		err = uc.fw.UnblockIP(a.Target)
+	case entities.ActionIsolateHost:
+		err = uc.fw.Release()
+	}
+	uc.auditRollback(a, trigger, err)
+	return err == nil
+}
+
+// checkBlockable recusa alvos inválidos e os que cortariam o próprio backend.
+func (uc *ResponseActions) checkBlockable(target string) error {
+	if !validTarget(target) {
+		return errors.New("invalid ip " + target)
+	}
+	t := net.ParseIP(target)
+	if t != nil && (t.IsLoopback() || t.IsUnspecified()) {
+		return errors.New("refusing to block " + target)
+	}
+	api, err := uc.apiAddrs()
+	if err != nil {
+		return err
+	}
+	for _, s := range api {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		if t != nil && t.Equal(ip) {
+			return errors.New("refusing to block backend address " + target)
+		}
+		if _, n, err := net.ParseCIDR(target); err == nil && n.Contains(ip) {
+			return errors.New("refusing to block range containing backend address " + s)
+		}
+	}
+	return nil
+}
+
+// apiAddrs resolve o host de APIBaseURL (literal IP é usado como está).
+func (uc *ResponseActions) apiAddrs() ([]string, error) {
+	u, err := url.Parse(uc.cfg.APIBaseURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, errors.New("invalid api base url")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return nil, errors.New("resolve " + u.Hostname() + ": " + err.Error())
+	}
+	out := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		out = append(out, ip.String())
+	}
+	return out, nil
+}
+
+func (uc *ResponseActions) persist() {
+	list := make([]entities.ResponseAction, 0, len(uc.active))
+	for _, a := range uc.active {
+		list = append(list, a)
+	}
+	raw, _ := json.MarshalIndent(list, "", "  ")
+	if err := writeCursor(uc.cfg.ActionsStatePath, string(raw)); err != nil {
+		uc.log.Error("response actions: persist state failed", "err", err)
+	}
+}
+
+func (uc *ResponseActions) audit(id, action, target, trigger string, err error, extra map[string]any) {
+	status := "applied"
+	if err != nil {
+		status = "failed"
+	}
+	uc.emit(id, action, target, trigger, status, err, extra)
+}
+
+func (uc *ResponseActions) auditRollback(a entities.ResponseAction, trigger string, err error) {
+	status := "rolled_back"
+	if err != nil {
+		status = "rollback_failed"
+	}
+	uc.emit(a.ID, a.Action, a.Target, trigger, status, err, nil)
+}
+
+func (uc *ResponseActions) emit(id, action, target, trigger, status string, err error, extra map[string]any) {
+	body := map[string]any{
+		"cmd_id":  id,
+		"action":  action,
+		"status":  status,
+		"trigger": trigger,
+	}
+	if target != "" {
+		body["target"] = target
+	}
+	if action != entities.ActionKillProcess {
+		body["backend"] = uc.fw.Backend()
+	}
+	for k, v := range extra {
+		body[k] = v
+	}
+	kv := []any{"action", action, "cmd_id", id}
+	if target != "" {
+		kv = append(kv, "target", target)
+	}
+	kv = append(kv, "status", status, "trigger", trigger)
+	if err != nil {
+		body["error"] = err.Error()
+		uc.log.Error("response action", append(kv, "err", err)...)
+	} else {
+		uc.log.Info("response action", kv...)
+	}
+	_ = uc.events.Emit("response_action", body)
+}
+
+func actionKey(action, target string) string { return action + ":" + target }
+
+func validTarget(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(s)
+	return err == nil
+}
@@ -0,0 +1,353 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// fakeFirewall registra as chamadas ("block 1.2.3.4", "isolate a,b",
+// "release"...) e falha as operações listadas em fail.
+type fakeFirewall struct {
+	calls []string
+	fail  map[string]error // chave: block|unblock|isolate|release
+}
+
+func (f *fakeFirewall) Backend() string { return "fake" }
+
+func (f *fakeFirewall) do(op, arg string) error {
+	f.calls = append(f.calls, strings.TrimSpace(op+" "+arg))
+	return f.fail[op]
+}
+
+func (f *fakeFirewall) BlockIP(ip string) error   { return f.do("block", ip) }
+func (f *fakeFirewall) UnblockIP(ip string) error { return f.do("unblock", ip) }
+func (f *fakeFirewall) Isolate(allow []string) error {
+	return f.do("isolate", strings.Join(allow, ","))
+}
+func (f *fakeFirewall) Release() error { return f.do("release", "") }
+
+type fakeProcs struct {
+	procs  map[int32]string // pid -> nome
+	killed []int32
+}
+
+func (p *fakeProcs) Lookup(pid int32) (entities.ProcessInfo, error) {
+	name, ok := p.procs[pid]
+	if !ok {
+		return entities.ProcessInfo{}, errors.New("no such process")
+	}
+	return entities.ProcessInfo{PID: pid, Name: name}, nil
+}
+
+func (p *fakeProcs) Kill(pid int32, _ bool) error {
+	p.killed = append(p.killed, pid)
+	return nil
+}
+
+var actNow = time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// newActions cria o use case com backend em 192.0.2.10 (sem DNS), estado
+// num diretório temporário e relógio manual em actNow.
+func newActions(t *testing.T) (*ResponseActions, *fakeFirewall, *fakeOutbox, *time.Time) {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.APIBaseURL = "https://192.0.2.10:8443"
+	cfg.ActionsEnabled = true
+	cfg.ActionsStatePath = filepath.Join(t.TempDir(), "actions.json")
+	fw := &fakeFirewall{}
+	box := &fakeOutbox{}
+	uc := NewResponseActions(cfg, fw, &fakeProcs{procs: map[int32]string{4242: "miner"}}, NewEmitEvent(box, nopLogger{}, ""), nopLogger{})
+	now := actNow
+	uc.now = func() time.Time { return now }
+	return uc, fw, box, &now
+}
+
+func actionCmd(id string, payload string) entities.Command {
+	return entities.Command{ID: id, Payload: json.RawMessage(payload)}
+}
+
+// statuses devolve o status de cada evento response_action, na ordem.
+func statuses(box *fakeOutbox) []string {
+	out := make([]string, 0, len(box.bodies))
+	for _, b := range box.bodies {
+		out = append(out, b.(map[string]any)["status"].(string))
+	}
+	return out
+}
+
+// saved lê o estado persistido como chaves ação:alvo ordenadas.
+func saved(t *testing.T, uc *ResponseActions) []string {
+	t.Helper()
+	b, err := os.ReadFile(uc.cfg.ActionsStatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []entities.ResponseAction
+	if err := json.Unmarshal(b, &list); err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, 0, len(list))
+	for _, a := range list {
+		out = append(out, actionKey(a.Action, a.Target))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestResponseActionsApplyAndExpire(t *testing.T) {
+	uc, fw, box, now := newActions(t)
+	ctx := context.Background()
+	if _, err := uc.BlockIP(ctx, actionCmd("c1", `{"ip":"203.0.113.7","ttl_s":60}`)); err != nil {
+		t.Fatal(err)
+	}
+	out, err := uc.IsolateHost(ctx, actionCmd("c2", `{"ttl_s":120,"allow":["198.51.100.1"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.(map[string]any)["allow"]; !slices.Equal(got.([]string), []string{"192.0.2.10", "198.51.100.1"}) {
+		t.Fatalf("allow = %v, want the backend first", got)
+	}
+	if got := saved(t, uc); !slices.Equal(got, []string{"block-ip:203.0.113.7", "isolate-host:"}) {
+		t.Fatalf("saved = %v", got)
+	}
+
+	// Antes do TTL nada muda.
+	fw.calls = nil
+	*now = actNow.Add(time.Minute)
+	uc.Expire()
+	if len(fw.calls) != 0 {
+		t.Fatalf("rolled back before the TTL: %v", fw.calls)
+	}
+
+	*now = actNow.Add(90 * time.Second)
+	uc.Expire()
+	if !slices.Equal(fw.calls, []string{"unblock 203.0.113.7"}) {
+		t.Fatalf("calls = %v", fw.calls)
+	}
+	if got := saved(t, uc); !slices.Equal(got, []string{"isolate-host:"}) {
+		t.Fatalf("saved = %v", got)
+	}
+
+	*now = actNow.Add(3 * time.Minute)
+	uc.Expire()
+	if !slices.Equal(fw.calls, []string{"unblock 203.0.113.7", "release"}) {
+		t.Fatalf("calls = %v", fw.calls)
+	}
+	if got := saved(t, uc); len(got) != 0 {
+		t.Fatalf("saved = %v", got)
+	}
+	if got := statuses(box); !slices.Equal(got, []string{"applied", "applied", "rolled_back", "rolled_back"}) {
+		t.Fatalf("audit = %v", got)
+	}
+}
+
+// Rollback que falha mantém a ação ativa e é refeito no próximo Expire.
+func TestResponseActionsExpireRetriesFailedRollback(t *testing.T) {
+	uc, fw, box, now := newActions(t)
+	if _, err := uc.BlockIP(context.Background(), actionCmd("c1", `{"ip":"203.0.113.7","ttl_s":60}`)); err != nil {
+		t.Fatal(err)
+	}
+	fw.fail = map[string]error{"unblock": errors.New("iptables locked")}
+	*now = actNow.Add(2 * time.Minute)
+	uc.Expire()
+	if got := saved(t, uc); !slices.Equal(got, []string{"block-ip:203.0.113.7"}) {
+		t.Fatalf("saved after a failed rollback = %v", got)
+	}
+	fw.fail = nil
+	uc.Expire()
+	if got := saved(t, uc); len(got) != 0 {
+		t.Fatalf("saved = %v", got)
+	}
+	if got := statuses(box); !slices.Equal(got, []string{"applied", "rollback_failed", "rolled_back"}) {
+		t.Fatalf("audit = %v", got)
+	}
+}
+
+func TestResponseActionsReleaseHost(t *testing.T) {
+	uc, fw, box, _ := newActions(t)
+	ctx := context.Background()
+	if _, err := uc.IsolateHost(ctx, actionCmd("c1", `{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.ReleaseHost(ctx, actionCmd("c2", "")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(fw.calls, []string{"isolate 192.0.2.10", "release"}) {
+		t.Fatalf("calls = %v", fw.calls)
+	}
+	if got := saved(t, uc); len(got) != 0 {
+		t.Fatalf("saved = %v", got)
+	}
+	// Sem registro (state perdido) o release ainda chega ao firewall.
+	if _, err := uc.ReleaseHost(ctx, actionCmd("c3", "")); err != nil {
+		t.Fatal(err)
+	}
+	// Release que falha devolve erro e mantém a ação registrada.
+	if _, err := uc.IsolateHost(ctx, actionCmd("c4", `{}`)); err != nil {
+		t.Fatal(err)
+	}
+	fw.fail = map[string]error{"release": errors.New("nft busy")}
+	if _, err := uc.ReleaseHost(ctx, actionCmd("c5", "")); err == nil {
+		t.Fatal("expected the rollback error")
+	}
+	if got := saved(t, uc); !slices.Equal(got, []string{"isolate-host:"}) {
+		t.Fatalf("saved = %v", got)
+	}
+	want := []string{"applied", "rolled_back", "rolled_back", "applied", "rollback_failed"}
+	if got := statuses(box); !slices.Equal(got, want) {
+		t.Fatalf("audit = %v, want %v", got, want)
+	}
+}
+
+func TestResponseActionsFailedApply(t *testing.T) {
+	cases := []struct {
+		name    string
+		run     func(uc *ResponseActions) (any, error)
+		fail    string
+		wantErr string
+		// wantCalls são as chamadas esperadas ao firewall.
+		wantCalls []string
+	}{
+		{
+			name: "firewall recusa o bloqueio",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.BlockIP(context.Background(), actionCmd("c1", `{"ip":"203.0.113.7"}`))
+			},
+			fail:      "block",
+			wantErr:   "boom",
+			wantCalls: []string{"block 203.0.113.7"},
+		},
+		{
+			name: "firewall recusa o isolamento",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.IsolateHost(context.Background(), actionCmd("c1", `{}`))
+			},
+			fail:      "isolate",
+			wantErr:   "boom",
+			wantCalls: []string{"isolate 192.0.2.10"},
+		},
+		{
+			name: "bloquear o backend",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.BlockIP(context.Background(), actionCmd("c1", `{"ip":"192.0.2.10"}`))
+			},
+			wantErr: "backend address",
+		},
+		{
+			name: "faixa com o backend",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.BlockIP(context.Background(), actionCmd("c1", `{"ip":"192.0.2.0/24"}`))
+			},
+			wantErr: "range containing backend",
+		},
+		{
+			name: "loopback",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.BlockIP(context.Background(), actionCmd("c1", `{"ip":"127.0.0.1"}`))
+			},
+			wantErr: "refusing to block",
+		},
+		{
+			name: "allow inválido",
+			run: func(uc *ResponseActions) (any, error) {
+				return uc.IsolateHost(context.Background(), actionCmd("c1", `{"allow":["x"]}`))
+			},
+			wantErr: "invalid allow entry",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc, fw, box, _ := newActions(t)
+			if tc.fail != "" {
+				fw.fail = map[string]error{tc.fail: errors.New("boom")}
+			}
+			if _, err := tc.run(uc); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+			if !slices.Equal(fw.calls, tc.wantCalls) {
+				t.Errorf("calls = %v, want %v", fw.calls, tc.wantCalls)
+			}
+			if len(uc.active) != 0 {
+				t.Errorf("failed action registered: %v", uc.active)
+			}
+			if _, err := os.Stat(uc.cfg.ActionsStatePath); !os.IsNotExist(err) {
+				t.Errorf("state persisted after a failed apply: %v", err)
+			}
+			if got := statuses(box); !slices.Equal(got, []string{"failed"}) {
+				t.Errorf("audit = %v", got)
+			}
+		})
+	}
+}
+
+// Restore reaplica as ações vigentes e desfaz as que venceram com o agente
+// parado.
+func TestResponseActionsRestore(t *testing.T) {
+	uc, fw, _, now := newActions(t)
+	state := []entities.ResponseAction{
+		{ID: "c1", Action: entities.ActionBlockIP, Target: "203.0.113.7", ExpiresAt: actNow.Add(time.Hour)},
+		{ID: "c2", Action: entities.ActionBlockIP, Target: "203.0.113.8", ExpiresAt: actNow.Add(-time.Minute)},
+		{ID: "c3", Action: entities.ActionIsolateHost, Allow: []string{"192.0.2.10", "198.51.100.1"}, ExpiresAt: actNow.Add(time.Hour)},
+	}
+	raw, _ := json.Marshal(state)
+	if err := os.WriteFile(uc.cfg.ActionsStatePath, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	uc.Restore()
+	want := []string{"block 203.0.113.7", "unblock 203.0.113.8", "isolate 192.0.2.10,198.51.100.1"}
+	if !slices.Equal(fw.calls, want) {
+		t.Fatalf("calls = %v, want %v", fw.calls, want)
+	}
+	if got := saved(t, uc); !slices.Equal(got, []string{"block-ip:203.0.113.7", "isolate-host:"}) {
+		t.Fatalf("saved = %v", got)
+	}
+	// O TTL original vale depois do restore.
+	fw.calls = nil
+	*now = actNow.Add(2 * time.Hour)
+	uc.Expire()
+	slices.Sort(fw.calls)
+	if !slices.Equal(fw.calls, []string{"release", "unblock 203.0.113.7"}) {
+		t.Fatalf("calls after the TTL = %v", fw.calls)
+	}
+}
+
+func TestResponseActionsKillProcess(t *testing.T) {
+	cases := []struct {
+		name       string
+		payload    string
+		wantErr    string
+		wantKilled bool
+	}{
+		{"mata", `{"pid":4242}`, "", true},
+		{"nome confere", `{"pid":4242,"name":"miner"}`, "", true},
+		{"nome diferente", `{"pid":4242,"name":"sshd"}`, "name mismatch", false},
+		{"inexistente", `{"pid":99}`, "process not found", false},
+		{"init", `{"pid":1}`, "refusing to kill", false},
+		{"o próprio agente", `{"pid":` + strconv.Itoa(os.Getpid()) + `}`, "refusing to kill", false},
+		{"payload inválido", `{"pid":"x"}`, "invalid payload", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc, _, _, _ := newActions(t)
+			procs := uc.procs.(*fakeProcs)
+			_, err := uc.KillProcess(context.Background(), actionCmd("c1", tc.payload))
+			if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+			if killed := len(procs.killed) > 0; killed != tc.wantKilled {
+				t.Fatalf("killed = %v", procs.killed)
+			}
+		})
+	}
+}
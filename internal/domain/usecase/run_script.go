@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// RunScript trata o comando run-script: valida contra a allowlist, responde
+// o ACK na hora e executa em segundo plano, enviando stdout/stderr como
+// eventos script_output e o resumo como script_result (correlação: cmd_id).
+type RunScript struct {
+	events *EmitEvent
+	log    logger.Logger
+
+	mu      sync.Mutex
+	runner  ports.ScriptRunner
+	enabled bool
+	max     int
+	running int
+}
+
+func NewRunScript(r ports.ScriptRunner, events *EmitEvent, l logger.Logger, enabled bool, maxConcurrent int) *RunScript {
+	return &RunScript{runner: r, events: events, log: l, enabled: enabled, max: maxConcurrent}
+}
+
+// Update troca runner e limites (reload); execuções em andamento seguem
+// com o runner antigo.
+func (uc *RunScript) Update(r ports.ScriptRunner, enabled bool, maxConcurrent int) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	uc.runner, uc.enabled, uc.max = r, enabled, maxConcurrent
+}
+
+// Handle é o CommandHandler do run-script. ctx deve durar o processo: a
+// execução continua depois do ACK.
+func (uc *RunScript) Handle(ctx context.Context, cmd entities.Command) (any, error) {
+	var s entities.Script
+	if err := json.Unmarshal(cmd.Payload, &s); err != nil {
+		return nil, errors.New("invalid payload: " + err.Error())
+	}
+
+	uc.mu.Lock()
+	runner, enabled := uc.runner, uc.enabled
+	if !enabled {
+		uc.mu.Unlock()
+		return nil, errors.New("remote scripts disabled")
+	}
+	if err := runner.Check(s); err != nil {
+		uc.mu.Unlock()
+		return nil, err
+	}
+	if uc.running >= uc.max {
+		uc.mu.Unlock()
+		return nil, errors.New("too many scripts running (max " + strconv.Itoa(uc.max) + ")")
+	}
+	uc.running++
+	uc.mu.Unlock()
+
+	go func() {
+		defer func() {
+			uc.mu.Lock()
+			uc.running--
+			uc.mu.Unlock()
+		}()
+		uc.run(ctx, runner, cmd.ID, s)
+	}()
+	return map[string]string{"status": "started"}, nil
+}
+
+func (uc *RunScript) run(ctx context.Context, runner ports.ScriptRunner, cmdID string, s entities.Script) {
+	// stdout e stderr chegam de goroutines diferentes; seq ordena os pedaços.
+	var seqMu sync.Mutex
+	seq := 0
+	res, err := runner.Run(ctx, s, func(stream string, chunk []byte) {
+		seqMu.Lock()
+		defer seqMu.Unlock()
+		seq++
+		_ = uc.events.Emit("script_output", map[string]any{
+			"cmd_id": cmdID,
+			"stream": stream,
+			"seq":    seq,
+			"data":   string(chunk),
+		})
+	})
+	body := map[string]any{
+		"cmd_id":      cmdID,
+		"name":        s.Name,
+		"interpreter": s.Interpreter,
+		"result":      res,
+		"chunks":      seq,
+	}
+	kv := []any{"cmd_id", cmdID, "exit", res.ExitCode, "duration_ms", res.DurationMs}
+	if res.TimedOut {
+		kv = append(kv, "timed_out", true)
+	}
+	if res.Truncated {
+		kv = append(kv, "truncated", true)
+	}
+	if err != nil {
+		body["error"] = err.Error()
+		uc.log.Error("script finished", append(kv, "err", err)...)
+	} else {
+		uc.log.Info("script finished", kv...)
+	}
+	_ = uc.events.Emit("script_result", body)
+}
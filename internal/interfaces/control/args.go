@@ -0,0 +1,24 @@
+package control
+
+import "github.com/you/aiceberg_agent/internal/domain/entities"
+
+// CollectArgs são os argumentos de "collect".
+type CollectArgs struct {
+	Collector string `json:"collector,omitempty"` // vazio = todos
+}
+
+// LogLevelArgs são os argumentos de "log-level".
+type LogLevelArgs struct {
+	Level string `json:"level"`
+}
+
+// TailArgs filtram o stream "tail"; campos vazios aceitam qualquer valor.
+type TailArgs struct {
+	Kind string `json:"kind,omitempty"`
+	Sub  string `json:"sub,omitempty"`
+}
+
+// Match diz se env passa pelo filtro.
+func (t TailArgs) Match(env entities.Envelope) bool {
+	return (t.Kind == "" || env.Kind == t.Kind) && (t.Sub == "" || env.Sub == t.Sub)
+}
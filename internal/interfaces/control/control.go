@@ -0,0 +1,326 @@
+// Package control é o socket local de operação: o CLI (aiceberg_agent
+// status, ctl, ...) conversa com o agente em execução por ele. Cada conexão
+// leva um pedido JSON (uma linha) e recebe respostas JSON (uma por linha):
+// uma só nas chamadas, várias até o cliente desconectar nos streams.
+package control
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/logger"
+)
+
+// Request é o pedido do cliente: o token, a operação e seus argumentos.
+type Request struct {
+	Token string          `json:"token"`
+	Op    string          `json:"op"`
+	Args  json.RawMessage `json:"args,omitempty"`
+}
+
+// Response é a resposta do agente; Error preenchido indica falha.
+type Response struct {
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// HandlerFunc trata uma operação; o retorno vai serializado em Data.
+type HandlerFunc func(ctx context.Context, args json.RawMessage) (any, error)
+
+// StreamFunc trata uma operação contínua: chama send para cada item até
+// ctx ser cancelado (cliente desconectou ou servidor fechando).
+type StreamFunc func(ctx context.Context, args json.RawMessage, send func(any) error) error
+
+// Handlers são as operações atendidas pelo servidor.
+type Handlers struct {
+	Calls   map[string]HandlerFunc
+	Streams map[string]StreamFunc
+}
+
+// callTimeout limita cada pedido (leitura, execução e resposta) e cada
+// item enviado num stream.
+const callTimeout = 30 * time.Second
+
+// streamKeepalive é o intervalo da linha vazia que mantém o stream vivo e
+// revela clientes que foram embora.
+const streamKeepalive = 15 * time.Second
+
+// ErrUnauthorized é devolvido quando o token do pedido não confere.
+var ErrUnauthorized = errors.New("unauthorized: wrong or missing control token")
+
+// Server atende o socket de controle.
+type Server struct {
+	ln       net.Listener
+	log      logger.Logger
+	token    string
+	handlers Handlers
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// Serve abre o endpoint em addr (socket unix ou named pipe, conforme o
+// sistema; só o dono do processo acessa) e atende em background os pedidos
+// que trazem token; encerre com Close.
+func Serve(addr, token string, h Handlers, log logger.Logger) (*Server, error) {
+	if token == "" {
+		return nil, errors.New("control: empty token")
+	}
+	ln, err := DefaultTransport.Listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{ln: ln, log: log, token: token, handlers: h, ctx: ctx, cancel: cancel}
+	s.wg.Add(1)
+	go s.accept()
+	log.Info("control socket listening", "addr", addr)
+	return s, nil
+}
+
+// Close para de aceitar conexões, encerra os streams e espera as conexões
+// em andamento.
+func (s *Server) Close() error {
+	s.cancel()
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if s.ctx.Err() == nil {
+				s.log.Error("control accept failed", "err", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(conn)
+		}()
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(callTimeout))
+	var req Request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		writeResponse(conn, nil, errors.New("invalid request"))
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(s.token)) != 1 {
+		s.log.Warn("control request rejected", "op", req.Op, "reason", "bad token")
+		writeResponse(conn, nil, ErrUnauthorized)
+		return
+	}
+	if f, ok := s.handlers.Streams[req.Op]; ok {
+		s.stream(conn, f, req)
+		return
+	}
+	h, ok := s.handlers.Calls[req.Op]
+	if !ok {
+		writeResponse(conn, nil, errors.New("unknown op "+req.Op))
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, callTimeout)
+	defer cancel()
+	out, err := h(ctx, req.Args)
+	writeResponse(conn, out, err)
+}
+
+// stream roda f até o servidor fechar ou uma escrita falhar (cliente
+// desconectou). O keepalive detecta a desconexão mesmo sem itens; a
+// conexão não é lida em paralelo porque a E/S do named pipe é síncrona.
+// Um erro de f vira a última linha.
+func (s *Server) stream(conn net.Conn, f StreamFunc, req Request) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	_ = conn.SetDeadline(time.Time{})
+	var mu sync.Mutex
+	enc := json.NewEncoder(conn)
+	write := func(resp Response) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = conn.SetWriteDeadline(time.Now().Add(callTimeout))
+		err := enc.Encode(resp)
+		if err != nil {
+			cancel()
+		}
+		return err
+	}
+	go func() {
+		t := time.NewTicker(streamKeepalive)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				_ = write(Response{OK: true})
+			}
+		}
+	}()
+	send := func(v any) error {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return write(Response{OK: true, Data: raw})
+	}
+	s.log.Debug("control stream started", "op", req.Op)
+	if err := f(ctx, req.Args, send); err != nil && ctx.Err() == nil {
+		_ = write(Response{Error: err.Error()})
+	}
+	s.log.Debug("control stream ended", "op", req.Op)
+}
+
+func writeResponse(conn net.Conn, out any, err error) {
+	resp := Response{OK: err == nil}
+	if err != nil {
+		resp.Error = err.Error()
+	} else if out != nil {
+		raw, mErr := json.Marshal(out)
+		if mErr != nil {
+			resp = Response{Error: mErr.Error()}
+		} else {
+			resp.Data = raw
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// LoadOrCreateToken lê o token do arquivo em path ou, se não existir, gera
+// um aleatório e o grava com permissão só para o dono.
+func LoadOrCreateToken(path string) (string, error) {
+	if tok, err := ReadToken(path); err == nil {
+		return tok, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	tok := hex.EncodeToString(b[:])
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(tok+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return tok, nil
+}
+
+// ReadToken lê o token gravado pelo agente.
+func ReadToken(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	tok := strings.TrimSpace(string(raw))
+	if tok == "" {
+		return "", errors.New("control token file " + path + " is empty")
+	}
+	return tok, nil
+}
+
+// Client fala com o agente em Addr usando Token.
+type Client struct {
+	Addr  string
+	Token string
+}
+
+func (c Client) dial(ctx context.Context, op string, args any) (net.Conn, error) {
+	conn, err := DefaultTransport.Dial(ctx, c.Addr)
+	if err != nil {
+		return nil, errors.New("agent not reachable on " + c.Addr + " (is it running?): " + err.Error())
+	}
+	req := Request{Token: c.Token, Op: op}
+	if args != nil {
+		if req.Args, err = json.Marshal(args); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Call envia op ao agente e decodifica Data em out (pode ser nil).
+func (c Client) Call(ctx context.Context, op string, args, out any) error {
+	conn, err := c.dial(ctx, op, args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return err
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+	if out != nil && len(resp.Data) > 0 {
+		return json.Unmarshal(resp.Data, out)
+	}
+	return nil
+}
+
+// Stream envia op ao agente e chama fn para cada item recebido, até ctx
+// ser cancelado, o agente encerrar o stream ou fn retornar erro.
+func (c Client) Stream(ctx context.Context, op string, args any, fn func(json.RawMessage) error) error {
+	conn, err := c.dial(ctx, op, args)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	dec := json.NewDecoder(conn)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !resp.OK {
+			return errors.New(resp.Error)
+		}
+		if len(resp.Data) == 0 {
+			continue // keepalive
+		}
+		if err := fn(resp.Data); err != nil {
+			return err
+		}
+	}
+}
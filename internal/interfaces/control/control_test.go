@@ -0,0 +1,223 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// nopLogger descarta tudo.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)  {}
+func (nopLogger) Info(string, ...any)   {}
+func (nopLogger) Warn(string, ...any)   {}
+func (nopLogger) Error(string, ...any)  {}
+func (nopLogger) Fatal(string, ...any)  {}
+func (nopLogger) SetLevel(string) error { return nil }
+func (nopLogger) Redact(...string)      {}
+func (nopLogger) Sync()                 {}
+
+// memRepo é o outbox mínimo para o Tap embrulhar.
+type memRepo struct{ n int }
+
+func (r *memRepo) Append(entities.Envelope) error             { r.n++; return nil }
+func (r *memRepo) ReadBatch(int) ([]entities.Envelope, error) { return nil, nil }
+func (r *memRepo) Ack([]string) error                         { return nil }
+func (r *memRepo) Len() (int, int64)                          { return r.n, 0 }
+
+const testToken = "tok-0123456789"
+
+// serve sobe o servidor em testAddr com as operações de teste: "echo"
+// devolve os args, "fail" falha e "tail" é o stream do Tap, como no agente.
+func serve(t *testing.T, tap *Tap) (*Server, string) {
+	t.Helper()
+	addr := testAddr(t)
+	h := Handlers{
+		Calls: map[string]HandlerFunc{
+			"echo": func(_ context.Context, args json.RawMessage) (any, error) { return args, nil },
+			"fail": func(context.Context, json.RawMessage) (any, error) { return nil, errors.New("boom") },
+		},
+		Streams: map[string]StreamFunc{
+			"tail": func(ctx context.Context, raw json.RawMessage, send func(any) error) error {
+				var p TailArgs
+				if len(raw) > 0 {
+					if err := json.Unmarshal(raw, &p); err != nil {
+						return err
+					}
+				}
+				envs, cancel := tap.Subscribe(p.Match)
+				defer cancel()
+				for {
+					select {
+					case <-ctx.Done():
+						return nil
+					case env := <-envs:
+						if err := send(env); err != nil {
+							return err
+						}
+					}
+				}
+			},
+			"broken": func(context.Context, json.RawMessage, func(any) error) error { return errors.New("stream failed") },
+		},
+	}
+	srv, err := Serve(addr, testToken, h, nopLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+	return srv, addr
+}
+
+func TestCalls(t *testing.T) {
+	_, addr := serve(t, NewTap())
+	cases := []struct {
+		name    string
+		token   string
+		op      string
+		args    any
+		want    string
+		wantErr string
+	}{
+		{name: "chamada", token: testToken, op: "echo", args: LogLevelArgs{Level: "debug"}, want: `{"level":"debug"}`},
+		{name: "token errado", token: "other", op: "echo", wantErr: ErrUnauthorized.Error()},
+		{name: "sem token", op: "echo", wantErr: ErrUnauthorized.Error()},
+		{name: "operação desconhecida", token: testToken, op: "format-disk", wantErr: "unknown op format-disk"},
+		{name: "handler falha", token: testToken, op: "fail", wantErr: "boom"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			var out json.RawMessage
+			err := Client{Addr: addr, Token: tc.token}.Call(ctx, tc.op, tc.args, &out)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.want {
+				t.Fatalf("data = %s, want %s", out, tc.want)
+			}
+		})
+	}
+}
+
+// Linha que não é JSON recebe erro e a conexão é fechada.
+func TestInvalidRequest(t *testing.T) {
+	_, addr := serve(t, NewTap())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DefaultTransport.Dial(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("not json\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(line), &resp); err != nil || resp.OK || resp.Error != "invalid request" {
+		t.Fatalf("response = %q (%v)", line, err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	tap := NewTap()
+	repo := tap.Wrap(&memRepo{})
+	srv, addr := serve(t, tap)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan entities.Envelope, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- Client{Addr: addr, Token: testToken}.Stream(ctx, "tail", TailArgs{Kind: "log"}, func(raw json.RawMessage) error {
+			var env entities.Envelope
+			if err := json.Unmarshal(raw, &env); err != nil {
+				return err
+			}
+			got <- env
+			return nil
+		})
+	}()
+	// Espera a assinatura antes de gravar.
+	for tap.n.Load() == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("tail never subscribed")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	for _, e := range []entities.Envelope{{ID: "m1", Kind: "metric"}, {ID: "l1", Kind: "log"}, {ID: "l2", Kind: "log", Sub: "oslogs"}} {
+		if err := repo.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, want := range []string{"l1", "l2"} {
+		select {
+		case env := <-got:
+			if env.ID != want {
+				t.Fatalf("tail got %s, want %s", env.ID, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("tail timed out waiting for %s", want)
+		}
+	}
+
+	// Fechar o servidor encerra o stream sem erro e libera a assinatura.
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Stream = %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("stream did not end with the server")
+	}
+	if n := tap.n.Load(); n != 0 {
+		t.Fatalf("subscribers after close = %d", n)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	_, addr := serve(t, NewTap())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	noop := func(json.RawMessage) error { return nil }
+	cases := []struct {
+		name, token, op string
+		args            any
+		wantErr         string
+	}{
+		{"erro do stream vira a última linha", testToken, "broken", nil, "stream failed"},
+		{"token errado", "x", "tail", nil, ErrUnauthorized.Error()},
+		{"args inválidos", testToken, "tail", []int{1}, "json: cannot unmarshal"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Client{Addr: addr, Token: tc.token}.Stream(ctx, tc.op, tc.args, noop)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
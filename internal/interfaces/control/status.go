@@ -0,0 +1,30 @@
+package control
+
+import (
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/scheduler"
+	"github.com/you/aiceberg_agent/internal/interfaces/health"
+)
+
+// Status é a resposta da operação "status".
+type Status struct {
+	Version       string             `json:"version"`
+	PID           int                `json:"pid"`
+	Mode          string             `json:"mode"`
+	StartedAt     time.Time          `json:"started_at"`
+	ConfigVersion string             `json:"config_version,omitempty"`
+	Paused        bool               `json:"paused"`
+	Queues        []QueueStatus      `json:"queues"`
+	DeadLetter    int                `json:"deadletter"`
+	Collectors    []scheduler.Status `json:"collectors"`
+	Health        health.Report      `json:"health"`
+}
+
+// QueueStatus é a profundidade de um outbox.
+type QueueStatus struct {
+	Name    string `json:"name"`
+	Items   int    `json:"items"`
+	Bytes   int64  `json:"bytes"`
+	Dropped int64  `json:"dropped"`
+}
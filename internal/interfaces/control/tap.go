@@ -0,0 +1,90 @@
+package control
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// tapBuffer é quantos envelopes cada assinante acumula antes de perder os
+// seguintes; o tail é para depuração e nunca segura a coleta.
+const tapBuffer = 256
+
+// Tap repassa os envelopes gravados no outbox aos assinantes do stream
+// "tail". Sem assinantes o custo por Append é um load atômico.
+type Tap struct {
+	mu   sync.Mutex
+	subs map[*tapSub]struct{}
+	n    atomic.Int32
+}
+
+type tapSub struct {
+	ch      chan entities.Envelope
+	match   func(entities.Envelope) bool
+	dropped atomic.Int64
+}
+
+// NewTap cria um Tap sem assinantes.
+func NewTap() *Tap {
+	return &Tap{subs: make(map[*tapSub]struct{})}
+}
+
+// Wrap devolve repo com os Appends bem-sucedidos espelhados no Tap.
+func (t *Tap) Wrap(repo ports.OutboxRepo) ports.OutboxRepo {
+	return &tappedRepo{OutboxRepo: repo, tap: t}
+}
+
+// Subscribe assina os envelopes aceitos por match (nil aceita todos).
+// cancel encerra a assinatura e devolve quantos foram perdidos por buffer
+// cheio.
+func (t *Tap) Subscribe(match func(entities.Envelope) bool) (envs <-chan entities.Envelope, cancel func() int64) {
+	s := &tapSub{ch: make(chan entities.Envelope, tapBuffer), match: match}
+	t.mu.Lock()
+	t.subs[s] = struct{}{}
+	t.n.Store(int32(len(t.subs)))
+	t.mu.Unlock()
+	return s.ch, func() int64 {
+		t.mu.Lock()
+		delete(t.subs, s)
+		t.n.Store(int32(len(t.subs)))
+		t.mu.Unlock()
+		return s.dropped.Load()
+	}
+}
+
+func (t *Tap) publish(env entities.Envelope) {
+	if t.n.Load() == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for s := range t.subs {
+		if s.match != nil && !s.match(env) {
+			continue
+		}
+		select {
+		case s.ch <- env:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// tappedRepo é o OutboxRepo embrulhado por Tap.Wrap.
+type tappedRepo struct {
+	ports.OutboxRepo
+	tap *Tap
+}
+
+// Garante conformidade.
+var _ ports.OutboxRepo = (*tappedRepo)(nil)
+
+func (r *tappedRepo) Append(env entities.Envelope) error {
+	if err := r.OutboxRepo.Append(env); err != nil {
+		return err
+	}
+	r.tap.publish(env)
+	return nil
+}
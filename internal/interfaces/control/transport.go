@@ -0,0 +1,17 @@
+package control
+
+import (
+	"context"
+	"net"
+)
+
+// Transport é o canal local entre CLI e agente: socket unix no Linux e
+// macOS, named pipe no Windows. Os dois restringem o acesso ao dono do
+// processo (e, no Windows, aos administradores); o token vem por cima.
+type Transport interface {
+	Listen(addr string) (net.Listener, error)
+	Dial(ctx context.Context, addr string) (net.Conn, error)
+}
+
+// DefaultTransport é o transporte da plataforma.
+var DefaultTransport Transport = platformTransport{}
@@ -0,0 +1,52 @@
+//go:build !windows
+// +build !windows
+
+package control
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// platformTransport usa um socket unix no caminho dado.
+type platformTransport struct{}
+
+// Garante conformidade.
+var _ Transport = platformTransport{}
+
+func (platformTransport) Listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	// Socket de uma execução anterior que não fechou direito.
+	if _, err := os.Stat(path); err == nil {
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = c.Close()
+			return nil, errors.New("control socket " + path + " in use by another process")
+		}
+		_ = os.Remove(path)
+	}
+	// Com umask 077 o socket já nasce só do dono: sem a janela entre o
+	// bind e o chmod em que outro usuário poderia conectar.
+	old := syscall.Umask(0o077)
+	ln, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func (platformTransport) Dial(ctx context.Context, path string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", path)
+}
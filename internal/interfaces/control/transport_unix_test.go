@@ -0,0 +1,63 @@
+//go:build !windows
+// +build !windows
+
+package control
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func testAddr(t *testing.T) string {
+	// Caminhos de socket unix são curtos (~100 bytes); o TempDir do teste
+	// pode passar disso.
+	dir, err := os.MkdirTemp("", "ctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "agent.sock")
+}
+
+func TestListenPermissions(t *testing.T) {
+	addr := testAddr(t)
+	prev := syscall.Umask(0o022)
+	defer syscall.Umask(prev)
+	ln, err := DefaultTransport.Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A umask do processo volta ao que era depois do Listen.
+	if m := syscall.Umask(0o022); m != 0o022 {
+		t.Fatalf("umask after Listen = %o, want 22", m)
+	}
+	st, err := os.Stat(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := st.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode = %o, want 600", perm)
+	}
+	// Socket em uso por outro servidor é recusado.
+	if _, err := DefaultTransport.Listen(addr); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("second Listen = %v", err)
+	}
+	_ = ln.Close()
+
+	// Socket velho de uma execução que não fechou é substituído.
+	stale, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+	ln, err = DefaultTransport.Listen(addr)
+	if err != nil {
+		t.Fatalf("Listen over a stale socket = %v", err)
+	}
+	_ = ln.Close()
+}
@@ -0,0 +1,263 @@
+//go:build windows
+// +build windows
+
+package control
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// pipePrefix é o namespace local de named pipes.
+const pipePrefix = `\\.\pipe\`
+
+// pipeSDDL dá acesso total só a LocalSystem e aos administradores.
+const pipeSDDL = "D:P(A;;GA;;;SY)(A;;GA;;;BA)"
+
+// platformTransport usa um named pipe (\\.\pipe\nome). A E/S é síncrona:
+// deadlines e Close cancelam a operação pendente com CancelIoEx.
+type platformTransport struct{}
+
+// Garante conformidade.
+var _ Transport = platformTransport{}
+
+func (platformTransport) Listen(name string) (net.Listener, error) {
+	if !strings.HasPrefix(name, pipePrefix) {
+		return nil, errors.New("control: named pipe must start with " + pipePrefix)
+	}
+	sd, err := windows.SecurityDescriptorFromString(pipeSDDL)
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{name: name, sd: sd}
+	// A primeira instância garante que nenhum outro processo é dono do nome.
+	if l.next, err = l.create(true); err != nil {
+		if errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, errors.New("control pipe " + name + " in use by another process")
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+func (platformTransport) Dial(ctx context.Context, name string) (net.Conn, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		h, err := windows.CreateFile(p, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+		if err == nil {
+			return newPipeConn(h, name, false), nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return nil, err
+		}
+		// Todas as instâncias ocupadas: o servidor cria outra a cada Accept.
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+type pipeListener struct {
+	name string
+	sd   *windows.SECURITY_DESCRIPTOR
+
+	mu     sync.Mutex
+	next   windows.Handle // instância criada e ainda não usada
+	closed bool
+}
+
+func (l *pipeListener) create(first bool) (windows.Handle, error) {
+	p, err := windows.UTF16PtrFromString(l.name)
+	if err != nil {
+		return 0, err
+	}
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	sa := &windows.SecurityAttributes{SecurityDescriptor: l.sd}
+	sa.Length = uint32(unsafe.Sizeof(*sa))
+	mode := uint32(windows.PIPE_TYPE_BYTE | windows.PIPE_READMODE_BYTE | windows.PIPE_WAIT | windows.PIPE_REJECT_REMOTE_CLIENTS)
+	return windows.CreateNamedPipe(p, flags, mode, windows.PIPE_UNLIMITED_INSTANCES, 64*1024, 64*1024, 0, sa)
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	h := l.next
+	l.next = 0
+	l.mu.Unlock()
+
+	var err error
+	if h == 0 {
+		if h, err = l.create(false); err != nil {
+			return nil, err
+		}
+	}
+	err = windows.ConnectNamedPipe(h, nil)
+	if err != nil && !errors.Is(err, windows.ERROR_PIPE_CONNECTED) {
+		_ = windows.CloseHandle(h)
+		return nil, err
+	}
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		// Acordado pelo Close (ver abaixo).
+		_ = windows.DisconnectNamedPipe(h)
+		_ = windows.CloseHandle(h)
+		return nil, net.ErrClosed
+	}
+	return newPipeConn(h, l.name, true), nil
+}
+
+// Close marca o listener como fechado e acorda o ConnectNamedPipe pendente
+// conectando nele mesmo.
+func (l *pipeListener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	h := l.next
+	l.next = 0
+	l.mu.Unlock()
+	if h != 0 {
+		return windows.CloseHandle(h)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if c, err := (platformTransport{}).Dial(ctx, l.name); err == nil {
+		_ = c.Close()
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return pipeAddr(l.name) }
+
+// pipeConn é uma ponta do named pipe. Os deadlines compartilham um timer
+// que cancela a E/S pendente quando vence.
+type pipeConn struct {
+	h      windows.Handle
+	name   string
+	server bool
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	expired bool
+	closed  bool
+}
+
+// Garante conformidade.
+var _ net.Conn = (*pipeConn)(nil)
+
+func newPipeConn(h windows.Handle, name string, server bool) *pipeConn {
+	return &pipeConn{h: h, name: name, server: server}
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	if c.isExpired() {
+		return 0, errDeadline
+	}
+	var n uint32
+	err := windows.ReadFile(c.h, b, &n, nil)
+	return int(n), c.mapErr(err)
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	if c.isExpired() {
+		return 0, errDeadline
+	}
+	var n uint32
+	err := windows.WriteFile(c.h, b, &n, nil)
+	return int(n), c.mapErr(err)
+}
+
+var errDeadline = errors.New("pipe: i/o timeout")
+
+func (c *pipeConn) mapErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, windows.ERROR_BROKEN_PIPE), errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED):
+		return io.EOF
+	case errors.Is(err, windows.ERROR_OPERATION_ABORTED):
+		if c.isExpired() {
+			return errDeadline
+		}
+		return net.ErrClosed
+	}
+	return err
+}
+
+func (c *pipeConn) isExpired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.expired
+}
+
+func (c *pipeConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.mu.Unlock()
+	_ = windows.CancelIoEx(c.h, nil)
+	if c.server {
+		_ = windows.DisconnectNamedPipe(c.h)
+	}
+	return windows.CloseHandle(c.h)
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return pipeAddr(c.name) }
+func (c *pipeConn) RemoteAddr() net.Addr { return pipeAddr(c.name) }
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.expired = false
+	if t.IsZero() || c.closed {
+		return nil
+	}
+	c.timer = time.AfterFunc(time.Until(t), func() {
+		c.mu.Lock()
+		c.expired = true
+		c.mu.Unlock()
+		_ = windows.CancelIoEx(c.h, nil)
+	})
+	return nil
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return c.SetDeadline(t) }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
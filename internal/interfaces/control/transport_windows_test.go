@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package control
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testAddr(t *testing.T) string {
+	return pipePrefix + "aiceberg-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
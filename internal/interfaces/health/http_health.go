@@ -1,18 +1,91 @@
 package health
 
 import (
-	"fmt"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
 )
 
-func Serve(port int, log logger.Logger) {
+// Estados de um componente e do relatório.
+const (
+	StatusOK       = "ok"
+	StatusFail     = "fail"
+	StatusDisabled = "disabled"
+)
+
+// Component é o estado de uma parte do agente. Value e Threshold usam a
+// mesma unidade (segundos para idades, % para ocupação, contagem para erros).
+type Component struct {
+	Status      string     `json:"status"`
+	Detail      string     `json:"detail,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	Value       *float64   `json:"value,omitempty"`
+	Threshold   *float64   `json:"threshold,omitempty"`
+}
+
+// Report é a resposta de /health e /ready; Status é fail se algum
+// componente falhou.
+type Report struct {
+	Status     string               `json:"status"`
+	CheckedAt  time.Time            `json:"checked_at"`
+	Components map[string]Component `json:"components"`
+}
+
+// NewReport monta o relatório e calcula o status geral.
+func NewReport(components map[string]Component) Report {
+	r := Report{Status: StatusOK, CheckedAt: time.Now().UTC(), Components: components}
+	for _, c := range components {
+		if c.Status == StatusFail {
+			r.Status = StatusFail
+		}
+	}
+	return r
+}
+
+// Prober fornece os relatórios: Live diz se o processo está funcionando
+// (watchdog), Ready se está entregando dados (load balancer do hub).
+type Prober interface {
+	Live() Report
+	Ready() Report
+}
+
+// Serve inicia os endpoints de saúde (/health e /ready, JSON; 503 quando
+// algum componente falha) e de métricas Prometheus (/metrics) em
+// background; encerre com Shutdown no servidor retornado.
+func Serve(port int, p Prober, log logger.Logger) *http.Server {
 	addr := ":" + strconv.Itoa(port)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "ok")
+	srv := &http.Server{Addr: addr, Handler: newMux(p)}
+	log.Info("health listener started", "addr", addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("health listener failed", "err", err)
+		}
+	}()
+	return srv
+}
+
+func newMux(p Prober) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, p.Live())
 	})
-	log.Info("health on " + addr)
-	_ = http.ListenAndServe(addr, nil)
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, p.Ready())
+	})
+	mux.Handle("/metrics", metrics.Handler())
+	return mux
+}
+
+func writeReport(w http.ResponseWriter, r Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(r)
 }
@@ -0,0 +1,85 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	cases := []struct {
+		name       string
+		components map[string]Component
+		want       string
+	}{
+		{name: "sem componentes", want: StatusOK},
+		{name: "todos ok", components: map[string]Component{"a": {Status: StatusOK}, "b": {Status: StatusOK}}, want: StatusOK},
+		{name: "desligado não falha", components: map[string]Component{"a": {Status: StatusOK}, "ping": {Status: StatusDisabled}}, want: StatusOK},
+		{name: "um falho derruba o relatório", components: map[string]Component{"a": {Status: StatusOK}, "b": {Status: StatusFail}, "c": {Status: StatusDisabled}}, want: StatusFail},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewReport(tc.components)
+			if r.Status != tc.want {
+				t.Fatalf("status = %s, want %s", r.Status, tc.want)
+			}
+			if r.CheckedAt.IsZero() || r.CheckedAt.Location().String() != "UTC" {
+				t.Fatalf("checked_at = %v", r.CheckedAt)
+			}
+		})
+	}
+}
+
+// fakeProber devolve relatórios fixos.
+type fakeProber struct{ live, ready Report }
+
+func (p fakeProber) Live() Report  { return p.live }
+func (p fakeProber) Ready() Report { return p.ready }
+
+func TestEndpoints(t *testing.T) {
+	ok := NewReport(map[string]Component{"main_loop": {Status: StatusOK}})
+	fail := NewReport(map[string]Component{"main_loop": {Status: StatusOK}, "flush.main": {Status: StatusFail, Detail: "last success 11m0s ago"}})
+	cases := []struct {
+		name     string
+		prober   fakeProber
+		path     string
+		wantCode int
+		want     Report
+	}{
+		{name: "health ok", prober: fakeProber{live: ok, ready: fail}, path: "/health", wantCode: http.StatusOK, want: ok},
+		{name: "health falho", prober: fakeProber{live: fail, ready: ok}, path: "/health", wantCode: http.StatusServiceUnavailable, want: fail},
+		{name: "ready ok", prober: fakeProber{live: fail, ready: ok}, path: "/ready", wantCode: http.StatusOK, want: ok},
+		{name: "ready falho", prober: fakeProber{live: ok, ready: fail}, path: "/ready", wantCode: http.StatusServiceUnavailable, want: fail},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			newMux(tc.prober).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.wantCode {
+				t.Fatalf("code = %d, want %d", rec.Code, tc.wantCode)
+			}
+			if ct, cc := rec.Header().Get("Content-Type"), rec.Header().Get("Cache-Control"); ct != "application/json" || cc != "no-store" {
+				t.Fatalf("headers: Content-Type %q, Cache-Control %q", ct, cc)
+			}
+			var got Report
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tc.want.Status || len(got.Components) != len(tc.want.Components) {
+				t.Fatalf("report = %+v, want %+v", got, tc.want)
+			}
+			for name, c := range tc.want.Components {
+				if got.Components[name] != c {
+					t.Fatalf("component %s = %+v, want %+v", name, got.Components[name], c)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	newMux(fakeProber{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics code = %d", rec.Code)
+	}
+}
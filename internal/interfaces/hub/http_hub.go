@@ -2,19 +2,40 @@ package hub
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/config"
 	"github.com/you/aiceberg_agent/internal/common/logger"
+	"github.com/you/aiceberg_agent/internal/data/remote/compression"
 	"github.com/you/aiceberg_agent/internal/domain/entities"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
 )
 
-// ServeHub inicia o listener HTTP para receber ingest de agentes em modo hub.
-func ServeHub(addr string, cfg config.Config, outbox ports.OutboxRepo, log logger.Logger) {
+const (
+	// maxIngestBytes limita o corpo de /v1/ingest já descomprimido.
+	maxIngestBytes = 10 << 20
+	// upstreamHeaderTimeout é a espera pelos headers da API no repasse de
+	// artefatos; o corpo segue em stream, sem prazo total.
+	upstreamHeaderTimeout = 30 * time.Second
+)
+
+// ServeHub inicia em background o listener HTTP para receber ingest de agentes
+// em modo hub; encerre com Shutdown no servidor retornado.
+func ServeHub(addr string, cfg config.Config, outbox ports.OutboxRepo, log logger.Logger) *http.Server {
+	srv := &http.Server{Addr: addr, Handler: newMux(cfg, outbox, log)}
+	log.Info("hub listener started", "addr", addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("hub listener failed", "err", err)
+		}
+	}()
+	return srv
+}
+
+func newMux(cfg config.Config, outbox ports.OutboxRepo, log logger.Logger) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/v1/ingest", func(w http.ResponseWriter, r *http.Request) {
@@ -28,28 +49,56 @@ func ServeHub(addr string, cfg config.Config, outbox ports.OutboxRepo, log logge
 			return
 		}
 
-		body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20)) // 10MB
+		defer r.Body.Close()
+		// Aceita corpo gzip/zstd; o limite vale para o conteúdo já descomprimido.
+		rd, err := compression.NewReader(r.Header.Get("Content-Encoding"), r.Body)
+		if err != nil {
+			http.Error(w, "unsupported content-encoding", http.StatusUnsupportedMediaType)
+			return
+		}
+		defer rd.Close()
+		body, err := io.ReadAll(io.LimitReader(rd, maxIngestBytes+1))
 		if err != nil {
 			http.Error(w, "read error", http.StatusBadRequest)
 			return
 		}
-		defer r.Body.Close()
+		if len(body) > maxIngestBytes {
+			// O relay divide o lote e reenvia as metades.
+			http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 
 		var batch []entities.Envelope
 		if err := json.Unmarshal(body, &batch); err != nil {
 			http.Error(w, "invalid payload", http.StatusBadRequest)
 			return
 		}
+		// Responde com os IDs efetivamente bufferizados; o relay reenvia o restante.
+		accepted := make([]string, 0, len(batch))
+		dropped := 0
 		for i := range batch {
 			if batch[i].Meta == nil {
 				batch[i].Meta = map[string]string{}
 			}
 			batch[i].Meta["via"] = "hub"
 			batch[i].AuthHeader = auth
-			_ = outbox.Append(batch[i])
+			if err := outbox.Append(batch[i]); err != nil {
+				if errors.Is(err, ports.ErrDropped) {
+					dropped++
+				} else {
+					log.Error("hub append failed", "err", err)
+				}
+				continue
+			}
+			accepted = append(accepted, batch[i].ID)
 		}
-		log.Info("hub ingest buffered n=" + strconv.Itoa(len(batch)))
+		if dropped > 0 {
+			log.Warn("hub outbox full, envelopes not accepted", "dropped", dropped)
+		}
+		log.Info("hub ingest buffered", "n", len(accepted))
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]any{"accepted": accepted})
 	})
 
 	mux.HandleFunc("/v1/agent/config", func(w http.ResponseWriter, r *http.Request) {
@@ -79,6 +128,44 @@ func ServeHub(addr string, cfg config.Config, outbox ports.OutboxRepo, log logge
 		_, _ = io.Copy(w, resp.Body)
 	})
 
-	log.Info("hub listener on " + addr)
-	_ = http.ListenAndServe(addr, mux)
+	// Upload de artefatos dos agentes em relay: repassa método, query e
+	// corpo (pedaços de até ARTIFACTS_CHUNK_KB) como vieram, em stream.
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = upstreamHeaderTimeout
+	upstream := &http.Client{Transport: tr}
+	artifacts := func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if auth == "" {
+			http.Error(w, "missing Authorization", http.StatusUnauthorized)
+			return
+		}
+		u := cfg.APIEndpoint(r.URL.EscapedPath())
+		if r.URL.RawQuery != "" {
+			u += "?" + r.URL.RawQuery
+		}
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, u, r.Body)
+		if err != nil {
+			http.Error(w, "upstream build error", http.StatusInternalServerError)
+			return
+		}
+		req.ContentLength = r.ContentLength
+		req.Header.Set("Authorization", auth)
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		resp, err := upstream.Do(req)
+		if err != nil {
+			http.Error(w, "upstream error", http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for _, h := range []string{"Content-Type", "Retry-After"} {
+			if v := resp.Header.Get(h); v != "" {
+				w.Header().Set(h, v)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}
+	mux.HandleFunc("/v1/agent/artifacts", artifacts)
+	mux.HandleFunc("/v1/agent/artifacts/", artifacts)
+	return mux
 }
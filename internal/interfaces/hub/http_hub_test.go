@@ -0,0 +1,204 @@
+package hub
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/data/remote/compression"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// nopLogger descarta tudo.
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any)  {}
+func (nopLogger) Info(string, ...any)   {}
+func (nopLogger) Warn(string, ...any)   {}
+func (nopLogger) Error(string, ...any)  {}
+func (nopLogger) Fatal(string, ...any)  {}
+func (nopLogger) SetLevel(string) error { return nil }
+func (nopLogger) Redact(...string)      {}
+func (nopLogger) Sync()                 {}
+
+// memOutbox guarda o que o hub bufferizou; full recusa com ErrDropped.
+type memOutbox struct {
+	envs []entities.Envelope
+	full map[string]bool
+}
+
+func (o *memOutbox) Append(e entities.Envelope) error {
+	if o.full[e.ID] {
+		return ports.ErrDropped
+	}
+	o.envs = append(o.envs, e)
+	return nil
+}
+
+func (o *memOutbox) ReadBatch(int) ([]entities.Envelope, error) { return nil, nil }
+func (o *memOutbox) Ack([]string) error                         { return nil }
+func (o *memOutbox) Len() (int, int64)                          { return len(o.envs), 0 }
+
+func compress(t *testing.T, encoding string, raw []byte) []byte {
+	t.Helper()
+	c, err := compression.New(encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c == nil {
+		return raw
+	}
+	out, err := c.Compress(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestIngest(t *testing.T) {
+	valid, _ := json.Marshal([]entities.Envelope{{ID: "a", Kind: "metric"}, {ID: "b", Kind: "metric"}})
+	huge := []byte("[" + strings.Repeat(" ", maxIngestBytes) + "]")
+	cases := []struct {
+		name         string
+		method       string
+		auth         string
+		encoding     string
+		body         []byte
+		full         map[string]bool
+		wantStatus   int
+		wantAccepted []string
+	}{
+		{name: "aceita", auth: "Token r1", body: valid, wantStatus: http.StatusAccepted, wantAccepted: []string{"a", "b"}},
+		{name: "gzip", auth: "Token r1", encoding: "gzip", body: valid, wantStatus: http.StatusAccepted, wantAccepted: []string{"a", "b"}},
+		{name: "zstd", auth: "Token r1", encoding: "zstd", body: valid, wantStatus: http.StatusAccepted, wantAccepted: []string{"a", "b"}},
+		{name: "outbox cheio aceita só o que coube", auth: "Token r1", body: valid, full: map[string]bool{"a": true}, wantStatus: http.StatusAccepted, wantAccepted: []string{"b"}},
+		{name: "sem credencial", body: valid, wantStatus: http.StatusUnauthorized},
+		{name: "método errado", method: http.MethodGet, auth: "Token r1", wantStatus: http.StatusMethodNotAllowed},
+		{name: "encoding desconhecido", auth: "Token r1", encoding: "br", body: valid, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "json inválido", auth: "Token r1", body: []byte("{"), wantStatus: http.StatusBadRequest},
+		{name: "corpo no limite", auth: "Token r1", body: huge[:maxIngestBytes-1], wantStatus: http.StatusBadRequest},
+		{name: "corpo acima do limite", auth: "Token r1", body: huge, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "limite vale descomprimido", auth: "Token r1", encoding: "gzip", body: huge, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			box := &memOutbox{full: tc.full}
+			mux := newMux(config.Defaults(), box, nopLogger{})
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			body := tc.body
+			if tc.encoding != "" && tc.encoding != "br" {
+				body = compress(t, tc.encoding, body)
+			}
+			req := httptest.NewRequest(method, "/v1/ingest", bytes.NewReader(body))
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tc.wantStatus, rec.Body)
+			}
+			if tc.wantStatus != http.StatusAccepted {
+				if len(box.envs) != 0 {
+					t.Fatalf("buffered %d envelopes on status %d", len(box.envs), rec.Code)
+				}
+				return
+			}
+			var resp struct {
+				Accepted []string `json:"accepted"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(resp.Accepted, tc.wantAccepted) {
+				t.Fatalf("accepted = %v, want %v", resp.Accepted, tc.wantAccepted)
+			}
+			for _, e := range box.envs {
+				if e.AuthHeader != tc.auth || e.Meta["via"] != "hub" {
+					t.Fatalf("buffered %+v, want auth %q and via=hub", e, tc.auth)
+				}
+			}
+		})
+	}
+}
+
+// O repasse de artefatos leva método, caminho, query, corpo e credencial do
+// agente, e devolve status, Content-Type e Retry-After da API.
+func TestArtifactsProxy(t *testing.T) {
+	var got []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization")+" "+string(body))
+		if r.URL.Query().Get("offset") == "9" {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = io.WriteString(w, `{"offset":4}`)
+	}))
+	defer api.Close()
+	cfg := config.Defaults()
+	cfg.APIBaseURL = api.URL
+	mux := newMux(cfg, &memOutbox{}, nopLogger{})
+
+	cases := []struct {
+		name, method, target, auth, body string
+		wantStatus                       int
+		wantHeader, wantValue            string
+		wantUpstream                     string
+	}{
+		{"begin", http.MethodPost, "/v1/agent/artifacts", "Token r1", `{"bundle_id":"art-1"}`, http.StatusConflict, "Content-Type", "application/json", `POST /v1/agent/artifacts Token r1 {"bundle_id":"art-1"}`},
+		{"pedaço com query", http.MethodPut, "/v1/agent/artifacts/art%2F1?offset=0", "Token r2", "abcd", http.StatusConflict, "Content-Type", "application/json", "PUT /v1/agent/artifacts/art%2F1?offset=0 Token r2 abcd"},
+		{"retry-after", http.MethodPut, "/v1/agent/artifacts/art-1?offset=9", "Token r1", "x", http.StatusTooManyRequests, "Retry-After", "7", "PUT /v1/agent/artifacts/art-1?offset=9 Token r1 x"},
+		{"sem credencial", http.MethodPut, "/v1/agent/artifacts/art-1?offset=0", "", "x", http.StatusUnauthorized, "", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got = nil
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.auth != "" {
+				req.Header.Set("Authorization", tc.auth)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if tc.wantHeader != "" && rec.Header().Get(tc.wantHeader) != tc.wantValue {
+				t.Errorf("%s = %q, want %q", tc.wantHeader, rec.Header().Get(tc.wantHeader), tc.wantValue)
+			}
+			var want []string
+			if tc.wantUpstream != "" {
+				want = []string{tc.wantUpstream}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("upstream saw %q, want %q", got, want)
+			}
+		})
+	}
+
+	// API fora do ar vira 502.
+	api.Close()
+	req := httptest.NewRequest(http.MethodPost, "/v1/agent/artifacts", strings.NewReader("{}"))
+	req.Header.Set("Authorization", "Token r1")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status with the API down = %d", rec.Code)
+	}
+}
@@ -0,0 +1,147 @@
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// FixtureRunner é um Runner que lê um export do journalctl -o json (uma
+// entrada por linha) em vez do journal do sistema, aplicando os filtros que
+// o collector usa (matches CAMPO=valor com "+", -p, --after-cursor). O --since da primeira coleta
+// é ignorado: o export inteiro conta como novo. Serve para reproduzir a
+// coleta sem systemd (once -journal-file).
+func FixtureRunner(path string) Runner {
+	return func(_ context.Context, args []string) (io.ReadCloser, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		q, err := parseArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		after := q.afterCursor == ""
+		for sc.Scan() {
+			line := sc.Bytes()
+			var raw map[string]json.RawMessage
+			if json.Unmarshal(line, &raw) != nil {
+				continue
+			}
+			if !after {
+				after = fieldValue(raw["__CURSOR"]) == q.afterCursor
+				continue
+			}
+			if q.match(raw) {
+				out.Write(line)
+				out.WriteByte('\n')
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		if !after {
+			return nil, fmt.Errorf("cursor %q não encontrado em %s", q.afterCursor, path)
+		}
+		return io.NopCloser(&out), nil
+	}
+}
+
+// query são os filtros dos args do journalctl. groups são os matches: casa
+// a entrada que satisfaz algum grupo; num grupo, campos diferentes se somam
+// (E) e valores do mesmo campo são alternativas (OU).
+type query struct {
+	groups      []map[string][]string
+	minPrio     int
+	maxPrio     int
+	afterCursor string
+}
+
+func parseArgs(args []string) (query, error) {
+	q := query{minPrio: 0, maxPrio: 7}
+	for i := 0; i < len(args); i++ {
+		val := func() string {
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch arg := args[i]; {
+		case arg == "+":
+			q.groups = append(q.groups, nil)
+		case arg == "-p":
+			lo, hi, ok := priorityRange(val())
+			if !ok {
+				return q, fmt.Errorf("prioridade inválida %q", args[i])
+			}
+			q.minPrio, q.maxPrio = lo, hi
+		case arg == "--after-cursor":
+			q.afterCursor = val()
+		case arg == "-o" || arg == "--since":
+			val()
+		case !strings.HasPrefix(arg, "-") && strings.Contains(arg, "="):
+			field, value, _ := strings.Cut(arg, "=")
+			if len(q.groups) == 0 {
+				q.groups = append(q.groups, nil)
+			}
+			g := q.groups[len(q.groups)-1]
+			if g == nil {
+				g = map[string][]string{}
+				q.groups[len(q.groups)-1] = g
+			}
+			g[field] = append(g[field], value)
+		}
+	}
+	return q, nil
+}
+
+func (q query) match(raw map[string]json.RawMessage) bool {
+	get := func(k string) string { return fieldValue(raw[k]) }
+	if len(q.groups) > 0 && !slices.ContainsFunc(q.groups, func(g map[string][]string) bool {
+		for field, values := range g {
+			if !slices.Contains(values, get(field)) {
+				return false
+			}
+		}
+		return true
+	}) {
+		return false
+	}
+	if p, err := strconv.Atoi(get("PRIORITY")); err == nil && (p < q.minPrio || p > q.maxPrio) {
+		return false
+	}
+	return true
+}
+
+// priorityRange interpreta -p como o journalctl: um nível vale "até ele"
+// (0..n); "a..b" é o intervalo.
+func priorityRange(p string) (lo, hi int, ok bool) {
+	from, to, isRange := strings.Cut(p, "..")
+	if !isRange {
+		n, ok := priorityLevel(p)
+		return 0, n, ok
+	}
+	a, okA := priorityLevel(from)
+	b, okB := priorityLevel(to)
+	return min(a, b), max(a, b), okA && okB
+}
+
+func priorityLevel(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 7 {
+		return n, true
+	}
+	i := slices.Index([]string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}, s)
+	return i, i >= 0
+}
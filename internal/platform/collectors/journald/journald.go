@@ -0,0 +1,312 @@
+// Package journald coleta o journal do systemd via journalctl, para distros
+// que não gravam /var/log/syslog (RHEL, Fedora, Arch). Os eventos têm o
+// mesmo formato dos do oslogs e vão para o mesmo outbox de logs.
+package journald
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+	"github.com/you/aiceberg_agent/internal/platform/logparse"
+)
+
+type collector struct {
+	run        Runner
+	filters    []string // -p, fixo
+	matches    []string // units e identifiers, fixos (ver NewWithRunner)
+	cursorPath string
+	cursor     string // confirmado (gravado no outbox)
+	pending    string // alcançado pelo último Collect
+	since      time.Time
+	batchLines int
+	maxBytes   int
+	interval   time.Duration
+}
+
+// Garante conformidade.
+var _ ports.Checkpointer = (*collector)(nil)
+
+func New(cfg config.Config) ports.Collector {
+	return NewWithRunner(cfg, ExecRunner)
+}
+
+// NewWithRunner é New com outro executor do journalctl (ex.: FixtureRunner).
+func NewWithRunner(cfg config.Config, run Runner) ports.Collector {
+	var filters []string
+	if cfg.JournaldPriority != "" {
+		filters = append(filters, "-p", cfg.JournaldPriority)
+	}
+	maxBytes := cfg.OSLogMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 256 * 1024
+	}
+	return &collector{
+		run:        run,
+		filters:    filters,
+		matches:    matches(cfg.JournaldUnits, cfg.JournaldIdentifiers),
+		cursorPath: cfg.JournaldCursorPath,
+		cursor:     loadCursor(cfg.JournaldCursorPath),
+		since:      time.Now(),
+		batchLines: cfg.JournaldBatchLines,
+		maxBytes:   maxBytes,
+		interval:   cfg.JournaldInterval,
+	}
+}
+
+// matches monta os filtros de units e identifiers como matches do
+// journalctl. Com -u e -t o journalctl exige os dois (E); aqui basta casar
+// um deles: cada grupo é separado por "+" (OU), e o -p continua valendo
+// para todos. Como no -u, a unit inclui as mensagens do systemd sobre ela
+// (_PID=1 UNIT=...).
+func matches(units, idents []string) []string {
+	var out []string
+	if len(units) > 0 {
+		for _, u := range units {
+			out = append(out, "_SYSTEMD_UNIT="+unitName(u))
+		}
+		out = append(out, "+", "_PID=1")
+		for _, u := range units {
+			out = append(out, "UNIT="+unitName(u))
+		}
+	}
+	if len(idents) > 0 {
+		if len(out) > 0 {
+			out = append(out, "+")
+		}
+		for _, t := range idents {
+			out = append(out, "SYSLOG_IDENTIFIER="+t)
+		}
+	}
+	return out
+}
+
+// unitName completa o nome sem tipo com ".service", como o journalctl -u.
+func unitName(u string) string {
+	if strings.Contains(u, ".") {
+		return u
+	}
+	return u + ".service"
+}
+
+func (c *collector) Name() string { return "journald" }
+
+func (c *collector) Interval() time.Duration { return c.interval }
+
+// logEvent tem os campos do evento do oslogs; file fica vazio e a unit do
+// systemd vai em fields.
+type logEvent struct {
+	Timestamp   string         `json:"timestamp"`
+	CollectedAt string         `json:"collected_at"`
+	Source      string         `json:"source,omitempty"`
+	Format      string         `json:"format"`
+	Host        string         `json:"host,omitempty"`
+	Program     string         `json:"program,omitempty"`
+	PID         int            `json:"pid,omitempty"`
+	Severity    string         `json:"severity,omitempty"`
+	Facility    string         `json:"facility,omitempty"`
+	Content     string         `json:"content,omitempty"`
+	Fields      map[string]any `json:"fields,omitempty"`
+	Message     string         `json:"message"`
+}
+
+type payload struct {
+	Events []logEvent `json:"events"`
+}
+
+// Collect lê a partir do cursor confirmado; sem cursor (primeira execução)
+// começa no instante em que o collector foi criado, sem o histórico.
+func (c *collector) Collect(ctx context.Context) ([]byte, error) {
+	args := append([]string{"-o", "json", "--no-pager"}, c.filters...)
+	if c.cursor != "" {
+		args = append(args, "--after-cursor", c.cursor)
+	} else {
+		args = append(args, "--since", "@"+strconv.FormatInt(c.since.Unix(), 10))
+	}
+	args = append(args, c.matches...)
+	out, err := c.run(ctx, args)
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	var events []logEvent
+	next := c.cursor
+	br := bufio.NewReader(out)
+	for len(events) < c.batchLines {
+		line, rerr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if ev, cur, ok := c.parse(line, hostname); ok {
+				events = append(events, ev)
+				next = cur
+			}
+		}
+		if rerr != nil {
+			break
+		}
+	}
+	// Lote cheio: o resto fica para a próxima coleta (o Close encerra o processo).
+	if err := out.Close(); err != nil {
+		if c.cursor != "" && strings.Contains(err.Error(), "cursor") {
+			// Cursor ilegível (journal recriado): recomeça de agora.
+			c.cursor, c.since = "", time.Now()
+			_ = saveCursor(c.cursorPath, "")
+		}
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	c.pending = next
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(payload{Events: events})
+}
+
+// Commit grava o cursor do último Collect (ver ports.Checkpointer).
+func (c *collector) Commit() error {
+	if c.pending == "" || c.pending == c.cursor {
+		return nil
+	}
+	c.cursor, c.pending = c.pending, ""
+	return saveCursor(c.cursorPath, c.cursor)
+}
+
+// parse converte uma entrada do journalctl -o json no evento e devolve o
+// cursor dela.
+func (c *collector) parse(line []byte, hostname string) (logEvent, string, bool) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return logEvent{}, "", false
+	}
+	get := func(k string) string { return fieldValue(raw[k]) }
+	cursor := get("__CURSOR")
+	if cursor == "" {
+		return logEvent{}, "", false
+	}
+	now := time.Now()
+	ts := now
+	if us, err := strconv.ParseInt(get("__REALTIME_TIMESTAMP"), 10, 64); err == nil {
+		ts = time.UnixMicro(us)
+	}
+	r := logparse.Record{
+		Host:    get("_HOSTNAME"),
+		Program: get("SYSLOG_IDENTIFIER"),
+		Content: get("MESSAGE"),
+	}
+	if r.Program == "" {
+		r.Program = get("_COMM")
+	}
+	r.PID, _ = strconv.Atoi(firstOf(get("_PID"), get("SYSLOG_PID")))
+	if n, err := strconv.Atoi(get("PRIORITY")); err == nil {
+		r.Severity = logparse.Severity(n)
+	}
+	if n, err := strconv.Atoi(get("SYSLOG_FACILITY")); err == nil {
+		r.Facility = logparse.Facility(n)
+	}
+	if len(r.Content) > c.maxBytes {
+		r.Content = r.Content[:c.maxBytes]
+	}
+	logparse.Enrich(&r)
+	for key, name := range journalFields {
+		if v := get(key); v != "" {
+			if r.Fields == nil {
+				r.Fields = map[string]any{}
+			}
+			r.Fields[name] = v
+		}
+	}
+
+	// message no formato do syslog (journalctl -o short), como nos arquivos.
+	msg := ts.Local().Format(time.Stamp) + " " + r.Host + " " + r.Program
+	if r.PID != 0 {
+		msg += "[" + strconv.Itoa(r.PID) + "]"
+	}
+	msg += ": " + r.Content
+	return logEvent{
+		Timestamp:   ts.UTC().Format(time.RFC3339Nano),
+		CollectedAt: now.UTC().Format(time.RFC3339Nano),
+		Source:      hostname,
+		Format:      "journald",
+		Host:        r.Host,
+		Program:     r.Program,
+		PID:         r.PID,
+		Severity:    r.Severity,
+		Facility:    r.Facility,
+		Content:     r.Content,
+		Fields:      r.Fields,
+		Message:     msg,
+	}, cursor, true
+}
+
+// journalFields são os campos do journal copiados para fields.
+var journalFields = map[string]string{
+	"_SYSTEMD_UNIT":      "unit",
+	"_SYSTEMD_USER_UNIT": "user_unit",
+	"_UID":               "uid",
+	"_COMM":              "comm",
+	"_EXE":               "exe",
+	"_TRANSPORT":         "transport",
+	"_BOOT_ID":           "boot_id",
+}
+
+// fieldValue lê um campo do journalctl -o json: string, array de bytes
+// (valor não UTF-8 ou binário) ou array de valores (campo repetido, fica o
+// primeiro). null (valor grande demais) volta vazio.
+func fieldValue(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var nums []int
+	if err := json.Unmarshal(raw, &nums); err == nil {
+		b := make([]byte, len(nums))
+		for i, n := range nums {
+			b[i] = byte(n)
+		}
+		return string(b)
+	}
+	var many []json.RawMessage
+	if err := json.Unmarshal(raw, &many); err == nil && len(many) > 0 {
+		return fieldValue(many[0])
+	}
+	return ""
+}
+
+func firstOf(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func loadCursor(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// saveCursor grava o cursor de forma atômica (arquivo temporário + rename).
+func saveCursor(path, cursor string) error {
+	if path == "" {
+		return nil
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(cursor), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
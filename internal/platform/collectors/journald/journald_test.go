@@ -0,0 +1,268 @@
+package journald
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+const fixture = "testdata/journal.json"
+
+func newTestCollector(t *testing.T, mod func(*config.Config)) *collector {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.JournaldCursorPath = filepath.Join(t.TempDir(), "journald.cursor")
+	cfg.JournaldBatchLines = 100
+	if mod != nil {
+		mod(&cfg)
+	}
+	return NewWithRunner(cfg, FixtureRunner(fixture)).(*collector)
+}
+
+func collect(t *testing.T, c *collector) []logEvent {
+	t.Helper()
+	raw, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if raw == nil {
+		return nil
+	}
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	return p.Events
+}
+
+func programs(evs []logEvent) []string {
+	out := make([]string, 0, len(evs))
+	for _, e := range evs {
+		out = append(out, e.Program)
+	}
+	return out
+}
+
+func TestFilters(t *testing.T) {
+	cases := []struct {
+		name     string
+		units    []string
+		idents   []string
+		priority string
+		want     []string
+	}{
+		{"sem filtros", nil, nil, "", []string{"systemd", "sshd", "sshd", "sudo", "nginx", "kernel", "app", "app"}},
+		{"unit sem tipo", []string{"sshd"}, nil, "", []string{"sshd", "sshd"}},
+		{"units", []string{"nginx.service", "app.service"}, nil, "", []string{"nginx", "app", "app"}},
+		{"identifier", nil, []string{"sudo"}, "", []string{"sudo"}},
+		{"unit ou identifier", []string{"sshd"}, []string{"sudo", "kernel"}, "", []string{"sshd", "sshd", "sudo", "kernel"}},
+		{"prioridade", nil, nil, "warning", []string{"nginx", "kernel"}},
+		{"intervalo", nil, nil, "notice..info", []string{"systemd", "sshd", "sshd", "sudo", "app"}},
+		{"prioridade vale para todos os grupos", []string{"sshd"}, []string{"sudo"}, "info", []string{"sshd", "sshd", "sudo"}},
+		{"prioridade restringe os grupos", []string{"app"}, []string{"nginx"}, "err", []string{"nginx"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCollector(t, func(cfg *config.Config) {
+				cfg.JournaldUnits = tc.units
+				cfg.JournaldIdentifiers = tc.idents
+				cfg.JournaldPriority = tc.priority
+			})
+			if got := programs(collect(t, c)); !slices.Equal(got, tc.want) {
+				t.Fatalf("programs = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCursorAcrossCollectAndCommit(t *testing.T) {
+	c := newTestCollector(t, func(cfg *config.Config) { cfg.JournaldBatchLines = 3 })
+
+	first := collect(t, c)
+	if got := programs(first); !slices.Equal(got, []string{"systemd", "sshd", "sshd"}) {
+		t.Fatalf("first batch = %v", got)
+	}
+	// Sem Commit o lote é relido.
+	if got := programs(collect(t, c)); !slices.Equal(got, programs(first)) {
+		t.Fatalf("batch without commit = %v, want %v", got, programs(first))
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(c.cursorPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), ";i=1a03;") {
+		t.Fatalf("saved cursor = %q, want the third entry", saved)
+	}
+
+	// Um collector novo retoma do cursor gravado.
+	cfg := config.Defaults()
+	cfg.JournaldCursorPath = c.cursorPath
+	cfg.JournaldBatchLines = 3
+	next := NewWithRunner(cfg, FixtureRunner(fixture)).(*collector)
+	if got := programs(collect(t, next)); !slices.Equal(got, []string{"sudo", "nginx", "kernel"}) {
+		t.Fatalf("batch after restart = %v", got)
+	}
+	if err := next.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := programs(collect(t, next)); !slices.Equal(got, []string{"app", "app"}) {
+		t.Fatalf("last batch = %v", got)
+	}
+	if err := next.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if evs := collect(t, next); evs != nil {
+		t.Fatalf("expected nothing new, got %v", programs(evs))
+	}
+}
+
+func TestCommitWithoutProgressKeepsCursor(t *testing.T) {
+	c := newTestCollector(t, nil)
+	if err := c.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.cursorPath); !os.IsNotExist(err) {
+		t.Fatalf("cursor file written without a Collect: %v", err)
+	}
+}
+
+func TestInvalidCursorResets(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "journald.cursor")
+	if err := os.WriteFile(path, []byte("s=deadbeef;i=1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Defaults()
+	cfg.JournaldCursorPath = path
+	cfg.JournaldBatchLines = 100
+	// O FixtureRunner falha na abertura; o reset depende do erro no Close,
+	// como o do journalctl ao sair.
+	c := NewWithRunner(cfg, closeErrRunner("Failed to seek to cursor: Invalid argument")).(*collector)
+
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Fatal("expected error from journalctl")
+	}
+	if c.cursor != "" {
+		t.Fatalf("cursor = %q, want reset", c.cursor)
+	}
+	if saved, _ := os.ReadFile(path); len(saved) != 0 {
+		t.Fatalf("saved cursor = %q, want empty", saved)
+	}
+	c.run = FixtureRunner(fixture)
+	if evs := collect(t, c); len(evs) != 8 {
+		t.Fatalf("events after reset = %d, want 8", len(evs))
+	}
+}
+
+func TestOtherErrorsKeepCursor(t *testing.T) {
+	c := newTestCollector(t, nil)
+	c.cursor = "s=0f3e1b2a9c8d4e7f;i=1a02"
+	c.run = closeErrRunner("Failed to open journal: Permission denied")
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Fatal("expected error from journalctl")
+	}
+	if c.cursor == "" {
+		t.Fatal("cursor reset on an unrelated error")
+	}
+}
+
+func TestUnknownCursorInFixture(t *testing.T) {
+	c := newTestCollector(t, nil)
+	c.cursor = "s=unknown"
+	if _, err := c.Collect(context.Background()); err == nil {
+		t.Fatal("expected error for a cursor missing from the export")
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	c := newTestCollector(t, nil)
+	evs := collect(t, c)
+	if len(evs) != 8 {
+		t.Fatalf("events = %d, want 8", len(evs))
+	}
+
+	// MESSAGE veio como array de bytes; o byte inválido vira U+FFFD no JSON.
+	bin := evs[6]
+	if bin.Content != "binary\ufffd payload" {
+		t.Errorf("byte-array MESSAGE = %q", bin.Content)
+	}
+	if !strings.HasSuffix(bin.Message, "app[3100]: binary\ufffd payload") {
+		t.Errorf("message = %q", bin.Message)
+	}
+
+	ssh := evs[1]
+	if ssh.Format != "journald" || ssh.Host != "fedora-web01" || ssh.PID != 2210 || ssh.Severity != "info" {
+		t.Errorf("sshd event = %+v", ssh)
+	}
+	if ssh.Timestamp != "2025-10-18T10:00:02Z" {
+		t.Errorf("timestamp = %q", ssh.Timestamp)
+	}
+	for k, v := range map[string]any{"unit": "sshd.service", "user": "alice", "src_ip": "10.0.0.5", "action": "accepted"} {
+		if ssh.Fields[k] != v {
+			t.Errorf("fields[%s] = %v, want %v", k, ssh.Fields[k], v)
+		}
+	}
+	if sudo := evs[3]; sudo.Fields["target_user"] != "root" || sudo.Fields["command"] != "/usr/bin/systemctl restart nginx" {
+		t.Errorf("sudo fields = %v", sudo.Fields)
+	}
+	if kern := evs[5]; kern.PID != 0 || !strings.Contains(kern.Message, " kernel: [UFW BLOCK]") {
+		t.Errorf("kernel event = %+v", kern)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{`"texto"`, "texto"},
+		{`[104,105]`, "hi"},
+		{`["a.c","b.c"]`, "a.c"},
+		{`null`, ""},
+		{``, ""},
+	}
+	for _, tc := range cases {
+		if got := fieldValue(json.RawMessage(tc.raw)); got != tc.want {
+			t.Errorf("fieldValue(%s) = %q, want %q", tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	got := matches([]string{"sshd", "nginx.service"}, []string{"sudo"})
+	want := []string{
+		"_SYSTEMD_UNIT=sshd.service", "_SYSTEMD_UNIT=nginx.service",
+		"+", "_PID=1", "UNIT=sshd.service", "UNIT=nginx.service",
+		"+", "SYSLOG_IDENTIFIER=sudo",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("matches = %q, want %q", got, want)
+	}
+	if got := matches(nil, nil); got != nil {
+		t.Fatalf("matches without filters = %q", got)
+	}
+}
+
+// closeErrRunner simula um journalctl que não escreve nada e sai com erro.
+func closeErrRunner(stderr string) Runner {
+	return func(context.Context, []string) (io.ReadCloser, error) {
+		return errCloser{msg: stderr}, nil
+	}
+}
+
+type errCloser struct{ msg string }
+
+func (errCloser) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (e errCloser) Close() error { return errors.New("exit status 1: " + e.msg) }
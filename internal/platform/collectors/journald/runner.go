@@ -0,0 +1,58 @@
+package journald
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Runner executa o journalctl com args e devolve a saída (JSON por linha).
+// O Close encerra o processo se a leitura parou antes do fim, e retorna o
+// erro do journalctl se ele falhou.
+type Runner func(ctx context.Context, args []string) (io.ReadCloser, error)
+
+// ExecRunner roda o journalctl do sistema.
+func ExecRunner(ctx context.Context, args []string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &process{cmd: cmd, out: out}
+	cmd.Stderr = &p.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	out    io.ReadCloser
+	stderr bytes.Buffer
+	eof    bool
+}
+
+func (p *process) Read(b []byte) (int, error) {
+	n, err := p.out.Read(b)
+	if err == io.EOF {
+		p.eof = true
+	}
+	return n, err
+}
+
+func (p *process) Close() error {
+	if !p.eof {
+		// Lote cheio: o journalctl ainda teria o que escrever.
+		_ = p.cmd.Process.Kill()
+		_ = p.cmd.Wait()
+		return nil
+	}
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(p.stderr.String()))
+	}
+	return nil
+}
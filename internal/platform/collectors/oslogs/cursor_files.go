@@ -0,0 +1,101 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// headSize é quanto do início do arquivo entra no fingerprint.
+const headSize = 1024
+
+// fileID identifica o arquivo independente do nome.
+type fileID struct {
+	Dev uint64 `json:"dev"`
+	Ino uint64 `json:"ino"`
+}
+
+// fileCursor é a posição lida de um arquivo: identidade (device+inode),
+// fingerprint dos primeiros HeadLen bytes e o offset. O inode detecta o
+// rename do logrotate; o fingerprint detecta o copytruncate (mesmo inode,
+// conteúdo novo) e acha a cópia ou o .gz com o resto a ler.
+type fileCursor struct {
+	fileID
+	Offset  int64  `json:"offset"`
+	Head    string `json:"head,omitempty"`
+	HeadLen int    `json:"head_len,omitempty"`
+}
+
+// known diz se o cursor tem identidade (falta no formato antigo, só offset).
+func (c fileCursor) known() bool { return c.Ino != 0 }
+
+// headMatches confere se r começa com os mesmos HeadLen bytes.
+func (c fileCursor) headMatches(r io.Reader) bool {
+	if c.HeadLen == 0 {
+		return true
+	}
+	sum, n := fingerprint(r, c.HeadLen)
+	return n == c.HeadLen && sum == c.Head
+}
+
+// withHead recalcula o fingerprint se o arquivo cresceu além de HeadLen.
+func (c fileCursor) withHead(f *os.File, size int64) fileCursor {
+	if c.HeadLen >= headSize || size <= int64(c.HeadLen) {
+		return c
+	}
+	c.Head, c.HeadLen = fingerprint(io.NewSectionReader(f, 0, headSize), headSize)
+	return c
+}
+
+// fingerprint é o sha256 dos primeiros n bytes de r (ou menos, se r acabar).
+func fingerprint(r io.Reader, n int) (string, int) {
+	var buf bytes.Buffer
+	read, _ := io.CopyN(&buf, r, int64(n))
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), int(read)
+}
+
+// loadCursor lê o cursor salvo; entradas do formato antigo (só o offset)
+// viram cursores sem identidade, adotada na próxima leitura.
+func loadCursor(path string) map[string]fileCursor {
+	out := map[string]fileCursor{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return out
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return out
+	}
+	for file, v := range raw {
+		var cur fileCursor
+		if err := json.Unmarshal(v, &cur); err != nil {
+			if err := json.Unmarshal(v, &cur.Offset); err != nil {
+				continue
+			}
+		}
+		out[file] = cur
+	}
+	return out
+}
+
+// saveCursor grava o cursor de forma atômica (arquivo temporário + rename).
+func saveCursor(path string, cur map[string]fileCursor) error {
+	if path == "" {
+		return nil
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	raw, _ := json.Marshal(cur)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,208 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// discovery expande as entradas de OSLOG_FILES em arquivos: caminhos
+// literais valem sempre (mesmo antes de existirem); globs (com ** para
+// qualquer profundidade) e diretórios (recursivos) passam por exclude e
+// pelo limite de arquivos, ficando os modificados mais recentemente.
+type discovery struct {
+	include []string
+	exclude []string
+	maxOpen int
+}
+
+// scan devolve os arquivos a seguir, os diretórios que os contêm (para o
+// inotify) e quantos arquivos casaram mas ficaram de fora pelo limite.
+func (d discovery) scan() (files, dirs []string, skipped int) {
+	seen := map[string]bool{}
+	type match struct {
+		path  string
+		mtime int64
+	}
+	var found []match
+	dirSet := map[string]bool{}
+	add := func(p string, fi fs.FileInfo) {
+		found = append(found, match{p, fi.ModTime().UnixNano()})
+	}
+	for _, inc := range d.include {
+		switch {
+		case hasGlob(inc):
+			root, rest, recursive := strings.Cut(inc, "**")
+			if !recursive {
+				m, _ := filepath.Glob(inc)
+				for _, p := range m {
+					if fi, ok := d.accept(p); ok {
+						add(p, fi)
+						dirSet[filepath.Dir(p)] = true
+					}
+				}
+				if dir := filepath.Dir(inc); !hasGlob(dir) {
+					dirSet[dir] = true
+				}
+				continue
+			}
+			rest = strings.TrimPrefix(rest, string(filepath.Separator))
+			roots := []string{filepath.Clean(root)}
+			if hasGlob(root) {
+				roots, _ = filepath.Glob(filepath.Clean(root))
+			}
+			for _, r := range roots {
+				if isDir(r) {
+					d.walk(r, rest, dirSet, add)
+				}
+			}
+		case isDir(inc):
+			d.walk(filepath.Clean(inc), "", dirSet, add)
+		default:
+			// Literal: seguido mesmo se ausente (aparece depois).
+			if !seen[inc] {
+				seen[inc] = true
+				files = append(files, inc)
+				dirSet[filepath.Dir(inc)] = true
+			}
+		}
+	}
+
+	slices.SortFunc(found, func(a, b match) int {
+		switch {
+		case a.mtime > b.mtime:
+			return -1
+		case a.mtime < b.mtime:
+			return 1
+		}
+		return strings.Compare(a.path, b.path)
+	})
+	for _, m := range found {
+		if seen[m.path] {
+			continue
+		}
+		seen[m.path] = true
+		if len(files) >= d.maxOpen {
+			skipped++
+			continue
+		}
+		files = append(files, m.path)
+	}
+	for dir := range dirSet {
+		dirs = append(dirs, dir)
+	}
+	slices.Sort(dirs)
+	return files, dirs, skipped
+}
+
+// wants diz se um arquivo que apareceu em p entraria no próximo scan
+// (ignorando o limite de arquivos).
+func (d discovery) wants(p string) bool {
+	sep := string(filepath.Separator)
+	for _, inc := range d.include {
+		if !hasGlob(inc) {
+			if p == inc || (strings.HasPrefix(p, filepath.Clean(inc)+sep) && !d.excluded(p)) {
+				return true
+			}
+			continue
+		}
+		root, rest, recursive := strings.Cut(inc, "**")
+		if !recursive {
+			if ok, _ := filepath.Match(inc, p); ok && !d.excluded(p) {
+				return true
+			}
+			continue
+		}
+		root = filepath.Clean(root)
+		parts := strings.Split(p, sep)
+		n := strings.Count(root, sep) + 1
+		if len(parts) <= n {
+			continue
+		}
+		if ok, _ := filepath.Match(root, strings.Join(parts[:n], sep)); !ok {
+			continue
+		}
+		rest = strings.TrimPrefix(rest, sep)
+		if (rest == "" || matchTail(rest, p, strings.Count(rest, sep)+1)) && !d.excluded(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk percorre root recursivamente; com pattern, só os arquivos cujos
+// últimos segmentos do caminho casam com ele.
+func (d discovery) walk(root, pattern string, dirSet map[string]bool, fn func(string, fs.FileInfo)) {
+	segs := 0
+	if pattern != "" {
+		segs = strings.Count(pattern, string(filepath.Separator)) + 1
+	}
+	_ = filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // diretório ilegível: segue com o resto
+		}
+		if e.IsDir() {
+			if p != root && d.excluded(p) {
+				return filepath.SkipDir
+			}
+			dirSet[p] = true
+			return nil
+		}
+		if pattern != "" && !matchTail(pattern, p, segs) {
+			return nil
+		}
+		if fi, ok := d.accept(p); ok {
+			fn(p, fi)
+		}
+		return nil
+	})
+}
+
+// accept diz se p é um arquivo regular (links simbólicos seguidos, como os
+// de /var/log/containers) fora do exclude.
+func (d discovery) accept(p string) (fs.FileInfo, bool) {
+	if d.excluded(p) {
+		return nil, false
+	}
+	fi, err := os.Stat(p)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil, false
+	}
+	return fi, true
+}
+
+// excluded compara os padrões com o nome e com o caminho completo.
+func (d discovery) excluded(p string) bool {
+	base := filepath.Base(p)
+	for _, ex := range d.exclude {
+		if ok, _ := filepath.Match(ex, base); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(ex, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTail casa pattern com os últimos segs segmentos de p.
+func matchTail(pattern, p string, segs int) bool {
+	parts := strings.Split(p, string(filepath.Separator))
+	if len(parts) < segs {
+		return false
+	}
+	ok, _ := filepath.Match(pattern, strings.Join(parts[len(parts)-segs:], string(filepath.Separator)))
+	return ok
+}
+
+func hasGlob(p string) bool { return strings.ContainsAny(p, "*?[") }
+
+func isDir(p string) bool {
+	fi, err := os.Stat(p)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,154 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+// tree cria os arquivos (caminhos relativos a root) com mtimes crescentes
+// na ordem dada: o último é o mais recente.
+func tree(t *testing.T, root string, files ...string) {
+	t.Helper()
+	base := time.Now().Add(-time.Hour)
+	for i, f := range files {
+		p := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		write(t, p, "x\n")
+		mt := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func rel(root string, paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		r, _ := filepath.Rel(root, p)
+		out = append(out, r)
+	}
+	return out
+}
+
+func abs(root string, paths []string) []string {
+	out := make([]string, 0, len(paths))
+	for _, p := range paths {
+		out = append(out, filepath.Join(root, p))
+	}
+	return out
+}
+
+func TestDiscoveryScan(t *testing.T) {
+	root := t.TempDir()
+	tree(t, root,
+		"syslog",
+		"auth.log", "auth.log.1", "auth.log.2.gz", "auth.log-20251018",
+		"nginx/access.log", "nginx/error.log", "nginx/old/access.log", "nginx/error.log.old",
+		"containers/a/app.log", "containers/b/app.log", "containers/b/sidecar.log",
+	)
+	excludes := config.Defaults().OSLogExclude
+	cases := []struct {
+		name        string
+		include     []string
+		exclude     []string
+		maxOpen     int
+		want        []string // ordem do scan: literais, depois os mais recentes
+		wantSkipped int
+	}{
+		{"literal ausente é seguido", []string{"missing.log"}, excludes, 10, []string{"missing.log"}, 0},
+		{"glob com excludes padrão", []string{"auth.log*"}, excludes, 10, []string{"auth.log"}, 0},
+		{"glob sem excludes", []string{"auth.log*"}, nil, 10, []string{"auth.log-20251018", "auth.log.2.gz", "auth.log.1", "auth.log"}, 0},
+		{"diretório recursivo", []string{"nginx"}, excludes, 10, []string{"nginx/old/access.log", "nginx/error.log", "nginx/access.log"}, 0},
+		{"diretório excluído", []string{"nginx"}, []string{"old", "*.old"}, 10, []string{"nginx/error.log", "nginx/access.log"}, 0},
+		{"exclude pelo caminho completo", []string{"nginx/*.log"}, []string{filepath.Join(root, "nginx/error.*")}, 10, []string{"nginx/access.log"}, 0},
+		{"** em qualquer profundidade", []string{"containers/**/app.log"}, excludes, 10, []string{"containers/b/app.log", "containers/a/app.log"}, 0},
+		{"** sem sufixo", []string{"containers/**"}, excludes, 10, []string{"containers/b/sidecar.log", "containers/b/app.log", "containers/a/app.log"}, 0},
+		{"literal e glob sem duplicar", []string{"nginx/access.log", "nginx/*.log"}, excludes, 10, []string{"nginx/access.log", "nginx/error.log"}, 0},
+		{"limite fica com os mais recentes", []string{"containers"}, excludes, 2, []string{"containers/b/sidecar.log", "containers/b/app.log"}, 1},
+		{"literais contam no limite", []string{"syslog", "containers"}, excludes, 2, []string{"syslog", "containers/b/sidecar.log"}, 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := discovery{include: abs(root, tc.include), exclude: tc.exclude, maxOpen: tc.maxOpen}
+			files, _, skipped := d.scan()
+			if got := rel(root, files); !slices.Equal(got, tc.want) {
+				t.Errorf("files = %v, want %v", got, tc.want)
+			}
+			if skipped != tc.wantSkipped {
+				t.Errorf("skipped = %d, want %d", skipped, tc.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestDiscoveryWatchDirs(t *testing.T) {
+	root := t.TempDir()
+	tree(t, root, "containers/a/app.log", "containers/b/app.log")
+	d := discovery{include: abs(root, []string{"containers/**/app.log", "pending/*.log", "var/missing.log"}), maxOpen: 10}
+	_, dirs, _ := d.scan()
+	want := []string{"containers", "containers/a", "containers/b", "pending", "var"}
+	if got := rel(root, dirs); !slices.Equal(got, want) {
+		t.Fatalf("dirs = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoveryWants(t *testing.T) {
+	root := t.TempDir()
+	tree(t, root, "nginx/access.log", "containers/a/app.log")
+	d := discovery{
+		include: abs(root, []string{"syslog", "nginx", "apps/*.log", "containers/**/app.log"}),
+		exclude: []string{"*.gz"},
+		maxOpen: 10,
+	}
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"syslog", true},
+		{"syslog.1", false},
+		{"nginx/new.log", true},
+		{"nginx/deep/new.log", true},
+		{"nginx/new.log.gz", false},
+		{"apps/api.log", true},
+		{"apps/api.log.gz", false},
+		{"apps/sub/api.log", false},
+		{"containers/c/app.log", true},
+		{"containers/c/d/app.log", true},
+		{"containers/c/other.log", false},
+		{"elsewhere/app.log", false},
+	}
+	for _, tc := range cases {
+		if got := d.wants(filepath.Join(root, tc.path)); got != tc.want {
+			t.Errorf("wants(%s) = %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+// Arquivo novo que casa o glob entra na coleta sem esperar o rescan
+// periódico quando o inotify o anuncia.
+func TestCollectPicksUpNewFiles(t *testing.T) {
+	root := t.TempDir()
+	tree(t, root, "apps/a.log")
+	c := newTestCollector(t, []string{filepath.Join(root, "apps/*.log")}, nil)
+	if got := collect(t, c); !slices.Equal(got, []string{"x\n"}) {
+		t.Fatalf("first collect = %q", got)
+	}
+	write(t, filepath.Join(root, "apps/b.log"), "new\n")
+	if got := collect(t, c); got != nil {
+		t.Fatalf("collected %q before the rescan", got)
+	}
+	c.rescanNow.Store(true)
+	if got := collect(t, c); !slices.Equal(got, []string{"new\n"}) {
+		t.Fatalf("after rescan = %q", got)
+	}
+}
@@ -0,0 +1,102 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+// partialTimeout é quanto uma linha sem '\n' no fim espera, em arquivo sem
+// regra multilinha, até ser enviada assim mesmo (escritor parado).
+const partialTimeout = 5 * time.Second
+
+// multiline é uma config.MultilineRule compilada. O valor zero (sem start
+// nem continue) é o de arquivos sem regra: cada linha é um evento.
+type multiline struct {
+	files    []string
+	start    *regexp.Regexp
+	cont     *regexp.Regexp
+	maxLines int
+	timeout  time.Duration
+}
+
+// compileRules compila as regras; as inválidas (já barradas por Validate)
+// são ignoradas.
+func compileRules(rules []config.MultilineRule) []multiline {
+	var out []multiline
+	for _, r := range rules {
+		m := multiline{files: r.Files, maxLines: r.MaxLines, timeout: r.Timeout}
+		var err error
+		if r.Start != "" {
+			if m.start, err = regexp.Compile(r.Start); err != nil {
+				continue
+			}
+		}
+		if r.Continue != "" {
+			if m.cont, err = regexp.Compile(r.Continue); err != nil {
+				continue
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// ruleFor devolve a primeira regra cujos padrões casam com path (caminho
+// completo ou nome).
+func (c *collector) ruleFor(path string) multiline {
+	base := filepath.Base(path)
+	for _, r := range c.rules {
+		if len(r.files) == 0 {
+			return r
+		}
+		for _, p := range r.files {
+			if ok, _ := filepath.Match(p, path); ok {
+				return r
+			}
+			if ok, _ := filepath.Match(p, base); ok {
+				return r
+			}
+		}
+	}
+	return multiline{maxLines: 1, timeout: partialTimeout}
+}
+
+// continues diz se line pertence ao evento aberto.
+func (m multiline) continues(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	switch {
+	case m.start != nil && m.start.MatchString(line):
+		return false
+	case m.cont != nil:
+		return m.cont.MatchString(line)
+	}
+	return m.start != nil
+}
+
+// event acumula as linhas de um evento; bytes conta o que foi consumido do
+// arquivo, mesmo o que o limite de tamanho cortou da mensagem.
+type event struct {
+	msg   strings.Builder
+	lines int
+	bytes int64
+}
+
+func (e *event) add(line string, maxBytes int) {
+	e.lines++
+	e.bytes += int64(len(line))
+	if room := maxBytes - e.msg.Len(); room > 0 {
+		e.msg.WriteString(line[:min(len(line), room)])
+	}
+}
+
+func (e *event) reset() {
+	e.msg.Reset()
+	e.lines, e.bytes = 0, 0
+}
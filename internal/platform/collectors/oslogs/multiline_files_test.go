@@ -0,0 +1,183 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+func rule(start, cont string, maxLines int) multiline {
+	rules := compileRules([]config.MultilineRule{{Start: start, Continue: cont, MaxLines: maxLines, Timeout: time.Minute}})
+	return rules[0]
+}
+
+func TestReadLines(t *testing.T) {
+	plain := multiline{maxLines: 1, timeout: partialTimeout}
+	java := rule(`^\d{4}-\d{2}-\d{2} `, "", 500)
+	python := rule("", `^(\s|Traceback)`, 500)
+	cases := []struct {
+		name     string
+		rule     multiline
+		in       string
+		budget   int
+		flush    bool
+		maxBytes int
+		want     []string
+		wantN    int
+		wantEOF  bool
+	}{
+		{
+			name: "linha a linha", rule: plain, in: "a\nb\n", budget: 10,
+			want: []string{"a\n", "b\n"}, wantN: 4, wantEOF: true,
+		},
+		{
+			name: "linha parcial fica para depois", rule: plain, in: "a\npart", budget: 10,
+			want: []string{"a\n"}, wantN: 2, wantEOF: true,
+		},
+		{
+			name: "linha parcial sai com flush", rule: plain, in: "a\npart", budget: 10, flush: true,
+			want: []string{"a\n", "part"}, wantN: 6, wantEOF: true,
+		},
+		{
+			name: "stack trace por start", rule: java, budget: 10,
+			in:   "2025-10-18 ERROR boom\n\tat A.run\n\tat B.main\n2025-10-18 INFO ok\n",
+			want: []string{"2025-10-18 ERROR boom\n\tat A.run\n\tat B.main\n"}, wantN: 43, wantEOF: true,
+		},
+		{
+			name: "último evento sai com flush", rule: java, budget: 10, flush: true,
+			in:   "2025-10-18 ERROR boom\n\tat A.run\n2025-10-18 INFO ok\n",
+			want: []string{"2025-10-18 ERROR boom\n\tat A.run\n", "2025-10-18 INFO ok\n"}, wantN: 51, wantEOF: true,
+		},
+		{
+			name: "linhas antes do primeiro start", rule: java, budget: 10,
+			in:   "orphan\n2025-10-18 INFO ok\n",
+			want: []string{"orphan\n"}, wantN: 7, wantEOF: true,
+		},
+		{
+			name: "traceback por continue", rule: python, budget: 10,
+			in:   "Traceback (most recent call last):\n  File \"a.py\", line 1\n    raise X\nValueError: boom\nnext\n",
+			want: []string{"Traceback (most recent call last):\n  File \"a.py\", line 1\n    raise X\n", "ValueError: boom\n"}, wantN: 86, wantEOF: true,
+		},
+		{
+			name: "max_lines fecha o evento", rule: rule("^S", "", 2), budget: 10,
+			in:   "S1\nc\nc\nc\n",
+			want: []string{"S1\nc\n", "c\nc\n"}, wantN: 9, wantEOF: true,
+		},
+		{
+			name: "max_bytes corta a mensagem mas não o offset", rule: plain, budget: 10, maxBytes: 5,
+			in:   "abcdefgh\nij\n",
+			want: []string{"abcde", "ij\n"}, wantN: 12, wantEOF: true,
+		},
+		{
+			name: "budget", rule: plain, in: "a\nb\nc\n", budget: 2,
+			want: []string{"a\n", "b\n"}, wantN: 4, wantEOF: false,
+		},
+		{
+			name: "budget ao fechar por start", rule: java, budget: 1, flush: true,
+			in:   "2025-10-18 A\nx\n2025-10-18 B\n",
+			want: []string{"2025-10-18 A\nx\n"}, wantN: 15, wantEOF: false,
+		},
+		{
+			// O último evento não coube: não é fim do arquivo.
+			name: "budget com flush no fim", rule: java, budget: 1, flush: true,
+			in:   "2025-10-18 A\n2025-10-18 B",
+			want: []string{"2025-10-18 A\n"}, wantN: 13, wantEOF: false,
+		},
+		{
+			name: "flush com espaço no budget", rule: java, budget: 2, flush: true,
+			in:   "2025-10-18 A\n2025-10-18 B",
+			want: []string{"2025-10-18 A\n", "2025-10-18 B"}, wantN: 25, wantEOF: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &collector{maxBytes: 1 << 20}
+			if tc.maxBytes > 0 {
+				c.maxBytes = tc.maxBytes
+			}
+			evs, n, eof := c.readLines(strings.NewReader(tc.in), "app.log", "host", tc.budget, tc.rule, tc.flush)
+			got := make([]string, 0, len(evs))
+			for _, e := range evs {
+				got = append(got, e.Message)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("events = %q, want %q", got, tc.want)
+			}
+			if n != int64(tc.wantN) || eof != tc.wantEOF {
+				t.Errorf("n, eof = %d, %v; want %d, %v", n, eof, tc.wantN, tc.wantEOF)
+			}
+		})
+	}
+}
+
+func TestRuleFor(t *testing.T) {
+	c := &collector{rules: compileRules([]config.MultilineRule{
+		{Files: []string{"/opt/app/*.log"}, Start: "^A", MaxLines: 10},
+		{Files: []string{"catalina.out"}, Start: "^B", MaxLines: 20},
+		{Start: "^C", MaxLines: 30},
+	})}
+	cases := []struct {
+		path string
+		want int
+	}{
+		{"/opt/app/api.log", 10},
+		{"/var/log/tomcat/catalina.out", 20},
+		{"/var/log/syslog", 30},
+	}
+	for _, tc := range cases {
+		if got := c.ruleFor(tc.path).maxLines; got != tc.want {
+			t.Errorf("ruleFor(%s).maxLines = %d, want %d", tc.path, got, tc.want)
+		}
+	}
+	if r := (&collector{}).ruleFor("/var/log/syslog"); r.maxLines != 1 || r.start != nil || r.timeout != partialTimeout {
+		t.Errorf("default rule = %+v", r)
+	}
+	// Regra com regex inválida é descartada.
+	if rules := compileRules([]config.MultilineRule{{Start: "("}}); len(rules) != 0 {
+		t.Errorf("compileRules kept an invalid rule: %+v", rules)
+	}
+}
+
+// O evento aberto sai quando o escritor para (timeout) ou quando o arquivo
+// é rotacionado; até lá é relido a cada coleta.
+func TestCollectHoldsOpenEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	c := newTestCollector(t, []string{path}, func(cfg *config.Config) {
+		cfg.OSLogMultiline = []config.MultilineRule{{Start: "^S", MaxLines: 100, Timeout: time.Hour}}
+	})
+	write(t, path, "S1\nx\nS2\ny\n")
+	if got := collect(t, c); !slices.Equal(got, []string{"S1\nx\n"}) {
+		t.Fatalf("first collect = %q", got)
+	}
+	appendTo(t, path, "z\n")
+	if got := collect(t, c); got != nil {
+		t.Fatalf("open event emitted early: %q", got)
+	}
+
+	// Escritor parado além do timeout.
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, c); !slices.Equal(got, []string{"S2\ny\nz\n"}) {
+		t.Fatalf("idle collect = %q", got)
+	}
+
+	// Rotacionado: o fim do arquivo antigo fecha o evento.
+	appendTo(t, path, "S3\nw\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	write(t, path, "S4\n")
+	if got := collect(t, c); !slices.Equal(got, []string{"S3\nw\n"}) {
+		t.Fatalf("after rotation = %q", got)
+	}
+}
@@ -7,121 +7,309 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/common/metrics"
 	"github.com/you/aiceberg_agent/internal/domain/ports"
+	"github.com/you/aiceberg_agent/internal/platform/logparse"
 )
 
 type collector struct {
-	files      []string
+	disc       discovery
 	cursorPath string
 	batchLines int
 	maxBytes   int
-	cursor     map[string]int64
+	rules      []multiline
+	parse      bool
+	cursor     map[string]fileCursor // confirmado (gravado no outbox)
+	pending    map[string]fileCursor // alcançado pelo último Collect
 	interval   time.Duration
+	rescan     time.Duration
+	inotify    bool
+
+	files   []string // resultado do último scan
+	scanned time.Time
+	start   int // rodízio: nenhum arquivo monopoliza o lote
+
+	mu        sync.Mutex
+	watchDirs []string        // diretórios para o inotify
+	watched   map[string]bool // arquivos seguidos e os alvos dos links
+	rescanNow atomic.Bool     // arquivo novo visto pelo inotify
 }
 
+// Garante conformidade.
+var _ ports.Checkpointer = (*collector)(nil)
+
 func New(cfg config.Config) ports.Collector {
-	return &collector{
-		files:      cfg.OSLogFiles,
+	c := &collector{
+		disc:       discovery{include: cfg.OSLogFiles, exclude: cfg.OSLogExclude, maxOpen: cfg.OSLogMaxOpenFiles},
 		cursorPath: cfg.OSLogCursorPath,
 		batchLines: cfg.OSLogBatchLines,
 		maxBytes:   cfg.OSLogMaxBytes,
+		rules:      compileRules(cfg.OSLogMultiline),
+		parse:      cfg.OSLogParse,
 		cursor:     loadCursor(cfg.OSLogCursorPath),
 		interval:   cfg.OSLogInterval,
+		rescan:     cfg.OSLogRescanInterval,
+		inotify:    cfg.OSLogInotify,
 	}
+	// Scan já na criação: o Watch precisa dos diretórios antes da 1ª coleta.
+	c.discover(c.cursor)
+	return c
 }
 
 func (c *collector) Name() string { return "oslogs" }
 
 func (c *collector) Interval() time.Duration { return c.interval }
 
+// logEvent é uma linha (ou evento multilinha) do arquivo. Timestamp é o
+// horário do próprio evento quando o parser o reconhece, senão o da coleta;
+// Message é sempre o texto cru.
 type logEvent struct {
-	Timestamp string `json:"timestamp"`
-	Source    string `json:"source,omitempty"`
-	File      string `json:"file"`
-	Message   string `json:"message"`
+	Timestamp   string         `json:"timestamp"`
+	CollectedAt string         `json:"collected_at"`
+	Source      string         `json:"source,omitempty"`
+	File        string         `json:"file"`
+	Format      string         `json:"format,omitempty"`
+	Host        string         `json:"host,omitempty"`
+	Program     string         `json:"program,omitempty"`
+	PID         int            `json:"pid,omitempty"`
+	Severity    string         `json:"severity,omitempty"`
+	Facility    string         `json:"facility,omitempty"`
+	Content     string         `json:"content,omitempty"`
+	Fields      map[string]any `json:"fields,omitempty"`
+	Message     string         `json:"message"`
 }
 
 type payload struct {
 	Events []logEvent `json:"events"`
 }
 
+// Collect lê a partir do cursor confirmado; a posição alcançada só é
+// gravada no Commit, depois que o lote está no outbox.
 func (c *collector) Collect(ctx context.Context) ([]byte, error) {
+	if len(c.disc.include) == 0 {
+		return nil, nil
+	}
+	next := maps.Clone(c.cursor)
+	if time.Since(c.scanned) >= c.rescan || c.rescanNow.Swap(false) {
+		c.discover(next)
+	}
 	if len(c.files) == 0 {
+		c.pending = next
 		return nil, nil
 	}
 	hostname, _ := os.Hostname()
 	var events []logEvent
-	for _, path := range c.files {
-		evs := c.readFile(path, hostname)
+	c.start = (c.start + 1) % len(c.files)
+	for i := range c.files {
+		path := c.files[(c.start+i)%len(c.files)]
+		evs, cur, ok := c.readFile(path, hostname, next[path], c.batchLines-len(events))
+		if ok {
+			next[path] = cur
+		}
 		events = append(events, evs...)
 		if len(events) >= c.batchLines {
 			break
 		}
 	}
+	c.pending = next
 	if len(events) == 0 {
 		return nil, nil
 	}
-	_ = saveCursor(c.cursorPath, c.cursor)
 	return json.Marshal(payload{Events: events})
 }
 
-func (c *collector) readFile(path, hostname string) []logEvent {
-	var out []logEvent
+// discover refaz a lista de arquivos e esquece o cursor dos que sumiram
+// de vez (fora do scan e inexistentes).
+func (c *collector) discover(cursor map[string]fileCursor) {
+	files, dirs, skipped := c.disc.scan()
+	metrics.OSLogFiles.Set(float64(len(files)), "followed")
+	metrics.OSLogFiles.Set(float64(skipped), "skipped")
+	watched := make(map[string]bool, len(files))
+	dirSet := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		dirSet[d] = true
+	}
+	for _, f := range files {
+		watched[filepath.Clean(f)] = true
+		// Escritas em link simbólico (/var/log/containers) acontecem no alvo.
+		if target, err := filepath.EvalSymlinks(f); err == nil && target != f {
+			watched[target] = true
+			dirSet[filepath.Dir(target)] = true
+		}
+	}
+	for path, cur := range cursor {
+		if watched[filepath.Clean(path)] {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		// Rotacionado e ainda não recriado: o resto será lido quando voltar.
+		if r := openRotated(path, cur); r != nil {
+			_ = r.Close()
+			continue
+		}
+		delete(cursor, path)
+	}
+	c.files, c.scanned = files, time.Now()
+	c.mu.Lock()
+	c.watchDirs = slices.Sorted(maps.Keys(dirSet))
+	c.watched = watched
+	c.mu.Unlock()
+}
+
+// Commit grava o cursor do último Collect (ver ports.Checkpointer).
+func (c *collector) Commit() error {
+	if c.pending == nil {
+		return nil
+	}
+	c.cursor, c.pending = c.pending, nil
+	return saveCursor(c.cursorPath, c.cursor)
+}
+
+// readFile lê até budget eventos de path a partir de cur. Se o conteúdo de
+// cur não está mais em path (rotação ou truncamento), termina antes o
+// arquivo rotacionado e só então passa para o novo, do início. ok=false
+// quando path não existe e não há nada a atualizar.
+func (c *collector) readFile(path, hostname string, cur fileCursor, budget int) (out []logEvent, next fileCursor, ok bool) {
 	f, err := os.Open(path)
 	if err != nil {
-		return out
+		// Renomeado e ainda não recriado: o resto está no rotacionado.
+		if !cur.known() {
+			return nil, cur, false
+		}
+		out, cur, _ = c.readRotated(path, hostname, cur, budget, c.ruleFor(path))
+		return out, cur, true
 	}
 	defer f.Close()
-	offset := c.cursor[path]
-	if offset > 0 {
-		_, _ = f.Seek(offset, 0)
-	}
-	r := bufio.NewReader(f)
-	for len(out) < c.batchLines {
-		line, err := r.ReadString('\n')
-		if line != "" {
-			if len(line) > c.maxBytes {
-				line = line[:c.maxBytes]
-			}
-			out = append(out, logEvent{
-				Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
-				Source:    hostname,
-				File:      path,
-				Message:   line,
-			})
+	id, fi, err := identify(f)
+	if err != nil {
+		return nil, cur, false
+	}
+	size := fi.Size()
+	rule := c.ruleFor(path)
+
+	switch {
+	case !cur.known():
+		// Primeira leitura ou cursor antigo (só offset): adota o arquivo atual.
+		if size < cur.Offset {
+			cur.Offset = 0
 		}
-		if err != nil {
-			break
+		cur = fileCursor{fileID: id, Offset: cur.Offset}
+	case id != cur.fileID || size < cur.Offset || !cur.headMatches(io.NewSectionReader(f, 0, int64(cur.HeadLen))):
+		var done bool
+		out, cur, done = c.readRotated(path, hostname, cur, budget, rule)
+		if !done {
+			return out, cur, true
 		}
+		budget -= len(out)
+		cur = fileCursor{fileID: id}
 	}
-	if pos, err := f.Seek(0, 1); err == nil {
-		c.cursor[path] = pos
+
+	if _, err := f.Seek(cur.Offset, io.SeekStart); err != nil {
+		return out, cur, true
 	}
-	return out
+	// Escritor parado há mais que o timeout: o que está pela metade não
+	// vai mais crescer e sai assim mesmo.
+	idle := time.Since(fi.ModTime()) >= rule.timeout
+	evs, n, _ := c.readLines(f, path, hostname, budget, rule, idle)
+	cur.Offset += n
+	return append(out, evs...), cur.withHead(f, size), true
 }
 
-func loadCursor(path string) map[string]int64 {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return map[string]int64{}
+// readRotated lê o resto do arquivo rotacionado descrito por cur. done indica
+// que ele acabou (ou não foi achado) e o cursor pode seguir para o novo.
+func (c *collector) readRotated(path, hostname string, cur fileCursor, budget int, rule multiline) ([]logEvent, fileCursor, bool) {
+	r := openRotated(path, cur)
+	if r == nil {
+		return nil, cur, true
 	}
-	var cur map[string]int64
-	if err := json.Unmarshal(b, &cur); err != nil {
-		return map[string]int64{}
+	defer r.Close()
+	// Rotacionado não cresce mais: o fim do arquivo fecha o último evento.
+	out, n, eof := c.readLines(r, path, hostname, budget, rule, true)
+	cur.Offset += n
+	return out, cur, eof
+}
+
+// readLines lê até budget eventos de r, juntando as linhas conforme rule;
+// n é quantos bytes foram consumidos (o bufio lê adiante, então a posição
+// do arquivo não serve de cursor). O evento ainda aberto e a linha sem '\n'
+// no fim não entram em n, e são relidos na próxima coleta, a menos que
+// flush diga que o arquivo não vai mais crescer.
+func (c *collector) readLines(r io.Reader, path, hostname string, budget int, rule multiline, flush bool) (out []logEvent, n int64, eof bool) {
+	br := bufio.NewReader(r)
+	var ev event
+	emit := func() {
+		out = append(out, c.newEvent(ev.msg.String(), path, hostname))
+		n += ev.bytes
+		ev.reset()
 	}
-	return cur
+	for len(out) < budget {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if flush {
+				if line != "" {
+					if ev.lines > 0 && !rule.continues(line) {
+						emit()
+					}
+					ev.add(line, c.maxBytes)
+				}
+				if ev.lines > 0 {
+					if len(out) >= budget {
+						// Sem espaço para o último evento: ele fica para a
+						// próxima coleta, e o arquivo ainda não acabou.
+						return out, n, false
+					}
+					emit()
+				}
+			}
+			return out, n, true
+		}
+		if ev.lines > 0 && !rule.continues(line) {
+			emit()
+			if len(out) >= budget {
+				break
+			}
+		}
+		ev.add(line, c.maxBytes)
+		if ev.lines >= rule.maxLines {
+			emit()
+		}
+	}
+	return out, n, false
 }
 
-func saveCursor(path string, cur map[string]int64) error {
-	if path == "" {
-		return nil
+// newEvent monta o evento de msg, com os campos que o parser reconhecer.
+func (c *collector) newEvent(msg, path, hostname string) logEvent {
+	now := time.Now()
+	ev := logEvent{
+		Timestamp:   now.UTC().Format(time.RFC3339Nano),
+		CollectedAt: now.UTC().Format(time.RFC3339Nano),
+		Source:      hostname,
+		File:        path,
+		Message:     msg,
+	}
+	if !c.parse {
+		return ev
+	}
+	r, ok := logparse.Parse(msg, now)
+	if !ok {
+		return ev
+	}
+	if !r.Time.IsZero() {
+		ev.Timestamp = r.Time.UTC().Format(time.RFC3339Nano)
 	}
-	_ = os.MkdirAll(filepath.Dir(path), 0o755)
-	raw, _ := json.Marshal(cur)
-	return os.WriteFile(path, raw, 0o600)
+	ev.Format, ev.Host, ev.Program, ev.PID = r.Format, r.Host, r.Program, r.PID
+	ev.Severity, ev.Facility, ev.Content, ev.Fields = r.Severity, r.Facility, r.Content, r.Fields
+	return ev
 }
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -22,7 +23,8 @@ import (
 type winCollector struct {
 	channels   []string
 	cursorPath string
-	cursor     map[string]uint64
+	cursor     map[string]uint64 // confirmado (gravado no outbox)
+	pending    map[string]uint64 // alcançado pelo último Collect
 	batchLines int
 	maxBytes   int
 	interval   time.Duration
@@ -43,6 +45,9 @@ type payload struct {
 	Events []logEvent `json:"events"`
 }
 
+// Garante conformidade.
+var _ ports.Checkpointer = (*winCollector)(nil)
+
 func New(cfg config.Config) ports.Collector {
 	ch := cfg.OSLogWinChannels
 	if len(ch) == 0 {
@@ -65,12 +70,13 @@ func (c *winCollector) Interval() time.Duration { return c.interval }
 func (c *winCollector) Collect(ctx context.Context) ([]byte, error) {
 	hostname, _ := os.Hostname()
 	var out []logEvent
+	next := maps.Clone(c.cursor)
 
 	for _, ch := range c.channels {
 		if len(out) >= c.batchLines {
 			break
 		}
-		last := c.cursor[ch]
+		last := next[ch]
 		events := c.fetchChannel(ctx, ch, last, c.batchLines-len(out), hostname)
 		if len(events) > 0 {
 			out = append(out, events...)
@@ -80,17 +86,26 @@ func (c *winCollector) Collect(ctx context.Context) ([]byte, error) {
 					maxRec = ev.RecordID
 				}
 			}
-			c.cursor[ch] = maxRec
+			next[ch] = maxRec
 		}
 	}
 
+	c.pending = next
 	if len(out) == 0 {
 		return nil, nil
 	}
-	_ = saveCursorWin(c.cursorPath, c.cursor)
 	return json.Marshal(payload{Events: out})
 }
 
+// Commit grava o cursor do último Collect (ver ports.Checkpointer).
+func (c *winCollector) Commit() error {
+	if c.pending == nil {
+		return nil
+	}
+	c.cursor, c.pending = c.pending, nil
+	return saveCursorWin(c.cursorPath, c.cursor)
+}
+
 func (c *winCollector) fetchChannel(ctx context.Context, channel string, lastRecord uint64, limit int, hostname string) []logEvent {
 	var events []logEvent
 	query := "*[System[EventRecordID>" + strconv.FormatUint(lastRecord, 10) + "]]"
@@ -0,0 +1,143 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/klauspost/compress/gzip"
+)
+
+// maxRotatedCandidates limita quantos irmãos rotacionados são examinados
+// por arquivo (os mais recentes primeiro).
+const maxRotatedCandidates = 8
+
+// identify devolve device+inode e o stat de f.
+func identify(f *os.File) (fileID, os.FileInfo, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return fileID{}, nil, err
+	}
+	var id fileID
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		id = fileID{Dev: uint64(st.Dev), Ino: uint64(st.Ino)}
+	}
+	return id, fi, nil
+}
+
+// rotatedReader é o resto de um arquivo rotacionado, posicionado no offset
+// do cursor.
+type rotatedReader struct {
+	io.Reader
+	name    string
+	closers []io.Closer
+}
+
+func (r *rotatedReader) Close() error {
+	for _, c := range slices.Backward(r.closers) {
+		_ = c.Close()
+	}
+	return nil
+}
+
+// openRotated procura, entre os irmãos de path (path.1, path-20240101,
+// path.2.gz, ...), o arquivo que cur descreve: o mesmo inode renomeado,
+// a cópia do copytruncate (fingerprint igual e tamanho ≥ offset) ou a versão
+// comprimida (fingerprint do conteúdo descomprimido). Devolve nil se não
+// achar, e aí o que faltava ler se perdeu.
+func openRotated(path string, cur fileCursor) *rotatedReader {
+	for _, name := range rotatedCandidates(path) {
+		if r := openCandidate(name, cur); r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+func openCandidate(name string, cur fileCursor) *rotatedReader {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil
+	}
+	if strings.HasSuffix(name, ".gz") {
+		// Sem fingerprint não há como reconhecer o conteúdo comprimido.
+		if cur.HeadLen == 0 {
+			_ = f.Close()
+			return nil
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil || !cur.headMatches(zr) {
+			_ = f.Close()
+			return nil
+		}
+		// Reabre para ler do começo: o fingerprint consumiu o início.
+		if _, err := f.Seek(0, io.SeekStart); err == nil {
+			err = zr.Reset(f)
+		}
+		if err == nil {
+			_, err = io.CopyN(io.Discard, zr, cur.Offset)
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil
+		}
+		return &rotatedReader{Reader: zr, name: name, closers: []io.Closer{f, zr}}
+	}
+
+	id, fi, err := identify(f)
+	match := err == nil && id == cur.fileID
+	if !match && err == nil && fi.Size() >= cur.Offset && cur.HeadLen > 0 {
+		match = cur.headMatches(io.NewSectionReader(f, 0, int64(cur.HeadLen)))
+	}
+	if !match {
+		_ = f.Close()
+		return nil
+	}
+	if _, err := f.Seek(cur.Offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil
+	}
+	return &rotatedReader{Reader: f, name: name, closers: []io.Closer{f}}
+}
+
+// rotatedCandidates lista os irmãos rotacionados de path, do mais recente
+// ao mais antigo.
+func rotatedCandidates(path string) []string {
+	var names []string
+	for _, pattern := range []string{path + ".*", path + "-*"} {
+		m, _ := filepath.Glob(pattern)
+		names = append(names, m...)
+	}
+	type cand struct {
+		name  string
+		mtime int64
+	}
+	cands := make([]cand, 0, len(names))
+	for _, n := range names {
+		fi, err := os.Stat(n)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		cands = append(cands, cand{n, fi.ModTime().UnixNano()})
+	}
+	slices.SortFunc(cands, func(a, b cand) int {
+		switch {
+		case a.mtime > b.mtime:
+			return -1
+		case a.mtime < b.mtime:
+			return 1
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	out := make([]string, 0, min(len(cands), maxRotatedCandidates))
+	for _, c := range cands[:min(len(cands), maxRotatedCandidates)] {
+		out = append(out, c.name)
+	}
+	return out
+}
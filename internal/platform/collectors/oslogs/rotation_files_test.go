@@ -0,0 +1,230 @@
+//go:build !windows
+// +build !windows
+
+package oslogs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+)
+
+func newTestCollector(t *testing.T, files []string, mod func(*config.Config)) *collector {
+	t.Helper()
+	cfg := config.Defaults()
+	cfg.OSLogFiles = files
+	cfg.OSLogCursorPath = filepath.Join(t.TempDir(), "oslogs.cursor")
+	cfg.OSLogParse = false
+	if mod != nil {
+		mod(&cfg)
+	}
+	return New(cfg).(*collector)
+}
+
+// collect roda um Collect e confirma o cursor, como o agendador faz depois
+// de gravar o lote no outbox; devolve as mensagens.
+func collect(t *testing.T, c *collector) []string {
+	t.Helper()
+	raw, err := c.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if raw == nil {
+		return nil
+	}
+	var p payload
+	if err := json.Unmarshal(raw, &p); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	out := make([]string, 0, len(p.Events))
+	for _, e := range p.Events {
+		out = append(out, e.Message)
+	}
+	return out
+}
+
+func write(t *testing.T, path, s string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(s), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func appendTo(t *testing.T, path, s string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func gzipFile(t *testing.T, src, dst string) {
+	t.Helper()
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(src); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	cases := []struct {
+		name string
+		// rotate roda depois de "c" ser escrito e antes da segunda coleta.
+		rotate func(t *testing.T, path string)
+		want   []string
+	}{
+		{"sem rotação", func(t *testing.T, path string) {
+			appendTo(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"rename e create", func(t *testing.T, path string) {
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			write(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"rename com data", func(t *testing.T, path string) {
+			if err := os.Rename(path, path+"-20251018"); err != nil {
+				t.Fatal(err)
+			}
+			write(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"copytruncate", func(t *testing.T, path string) {
+			raw, _ := os.ReadFile(path)
+			write(t, path+".1", string(raw))
+			write(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"copytruncate com o novo já maior que o offset", func(t *testing.T, path string) {
+			raw, _ := os.ReadFile(path)
+			write(t, path+".1", string(raw))
+			write(t, path, "dddddddd\n")
+		}, []string{"c\n", "dddddddd\n"}},
+		{"rotacionado e comprimido", func(t *testing.T, path string) {
+			if err := os.Rename(path, path+".1"); err != nil {
+				t.Fatal(err)
+			}
+			gzipFile(t, path+".1", path+".1.gz")
+			write(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"copytruncate e comprimido", func(t *testing.T, path string) {
+			raw, _ := os.ReadFile(path)
+			write(t, path+".1", string(raw))
+			gzipFile(t, path+".1", path+".1.gz")
+			write(t, path, "d\n")
+		}, []string{"c\n", "d\n"}},
+		{"rotacionado para fora do diretório", func(t *testing.T, path string) {
+			if err := os.Rename(path, filepath.Join(t.TempDir(), "elsewhere")); err != nil {
+				t.Fatal(err)
+			}
+			write(t, path, "d\n")
+		}, []string{"d\n"}},
+		{"irmão com outro conteúdo é ignorado", func(t *testing.T, path string) {
+			write(t, path+".1", "z\nb\nc\n")
+			write(t, path, "d\n")
+		}, []string{"d\n"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log")
+			write(t, path, "a\nb\n")
+			c := newTestCollector(t, []string{path}, nil)
+			if got := collect(t, c); !slices.Equal(got, []string{"a\n", "b\n"}) {
+				t.Fatalf("first collect = %q", got)
+			}
+			appendTo(t, path, "c\n")
+			tc.rotate(t, path)
+			if got := collect(t, c); !slices.Equal(got, tc.want) {
+				t.Fatalf("after rotation = %q, want %q", got, tc.want)
+			}
+			if got := collect(t, c); got != nil {
+				t.Fatalf("third collect re-read %q", got)
+			}
+		})
+	}
+}
+
+// Renomeado e ainda não recriado: o resto sai do rotacionado e o novo
+// arquivo é lido do início quando aparece.
+func TestRotatedBeforeRecreate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	write(t, path, "a\n")
+	c := newTestCollector(t, []string{path}, nil)
+	collect(t, c)
+	appendTo(t, path, "b\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, c); !slices.Equal(got, []string{"b\n"}) {
+		t.Fatalf("while missing = %q", got)
+	}
+	c.discover(c.cursor)
+	if _, ok := c.cursor[path]; !ok {
+		t.Fatal("cursor forgotten while the rotated file still exists")
+	}
+	write(t, path, "c\n")
+	if got := collect(t, c); !slices.Equal(got, []string{"c\n"}) {
+		t.Fatalf("after recreate = %q", got)
+	}
+}
+
+func TestCursorPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	write(t, path, "a\nb\n")
+	c := newTestCollector(t, []string{path}, nil)
+
+	// Sem Commit o lote é relido (o outbox pode não ter gravado).
+	if _, err := c.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(t, c); !slices.Equal(got, []string{"a\n", "b\n"}) {
+		t.Fatalf("collect after an uncommitted batch = %q", got)
+	}
+
+	// Outro processo retoma do cursor gravado.
+	appendTo(t, path, "c\n")
+	again := newTestCollector(t, []string{path}, func(cfg *config.Config) { cfg.OSLogCursorPath = c.cursorPath })
+	if got := collect(t, again); !slices.Equal(got, []string{"c\n"}) {
+		t.Fatalf("restarted collector = %q", got)
+	}
+
+	// Formato antigo (só o offset): adota o arquivo atual nesse offset.
+	legacy := filepath.Join(t.TempDir(), "legacy.cursor")
+	write(t, legacy, `{"`+path+`": 4}`)
+	old := newTestCollector(t, []string{path}, func(cfg *config.Config) { cfg.OSLogCursorPath = legacy })
+	if got := collect(t, old); !slices.Equal(got, []string{"c\n"}) {
+		t.Fatalf("legacy cursor = %q", got)
+	}
+	if cur := loadCursor(legacy)[path]; !cur.known() || cur.Offset != 6 || cur.HeadLen != 6 {
+		t.Fatalf("upgraded cursor = %+v", cur)
+	}
+}
@@ -0,0 +1,174 @@
+//go:build linux
+// +build linux
+
+package oslogs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+	"golang.org/x/sys/unix"
+)
+
+// Garante conformidade.
+var _ ports.Waker = (*collector)(nil)
+
+// wakeGap é o intervalo mínimo entre dois wakes: uma rajada de escritas vira
+// uma coleta, e linhas novas saem em menos de um segundo.
+const wakeGap = 500 * time.Millisecond
+
+const inotifyMask = unix.IN_MODIFY | unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM
+
+// Watch observa com inotify os diretórios dos arquivos seguidos e chama
+// wake quando um deles muda ou aparece um arquivo que o scan incluiria.
+// Sem inotify (desligado, ou sem watches disponíveis) a coleta segue só
+// pelo intervalo.
+func (c *collector) Watch(ctx context.Context, wake func()) {
+	if !c.inotify {
+		return
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return
+	}
+	// Não-bloqueante: o os.File usa o poller do runtime e o Close destrava a leitura.
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	w := &inotify{fd: fd, byWD: map[int]string{}, byDir: map[string]int{}}
+	kick := make(chan struct{}, 1)
+	w.sync(c.dirs())
+	go c.readEvents(f, w, kick)
+
+	resync := time.NewTicker(c.rescan)
+	defer resync.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resync.C:
+			w.sync(c.dirs())
+		case <-kick:
+			wake()
+			w.sync(c.dirs())
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wakeGap):
+			}
+		}
+	}
+}
+
+func (c *collector) dirs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.watchDirs
+}
+
+// relevant diz se a mudança em path interessa: arquivo seguido ou, num
+// create/move, arquivo novo que o scan incluiria (pede um rescan).
+func (c *collector) relevant(path string, mask uint32) bool {
+	c.mu.Lock()
+	followed := c.watched[path]
+	c.mu.Unlock()
+	if followed {
+		return true
+	}
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) == 0 {
+		return false
+	}
+	if mask&unix.IN_ISDIR != 0 || c.disc.wants(path) {
+		c.rescanNow.Store(true)
+		return true
+	}
+	return false
+}
+
+// readEvents lê o inotify até o descritor ser fechado.
+func (c *collector) readEvents(f *os.File, w *inotify, kick chan<- struct{}) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+		hit := false
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+			switch {
+			case ev.Mask&unix.IN_Q_OVERFLOW != 0:
+				c.rescanNow.Store(true)
+				hit = true
+			case ev.Mask&unix.IN_IGNORED != 0:
+				w.forget(int(ev.Wd))
+			default:
+				dir := w.dir(int(ev.Wd))
+				if dir != "" && c.relevant(filepath.Join(dir, string(bytes.TrimRight(name, "\x00"))), ev.Mask) {
+					hit = true
+				}
+			}
+		}
+		if hit {
+			select {
+			case kick <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// inotify mantém os watches em sincronia com os diretórios do scan.
+type inotify struct {
+	fd    int
+	mu    sync.Mutex
+	byWD  map[int]string
+	byDir map[string]int
+}
+
+func (w *inotify) sync(dirs []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	want := make(map[string]bool, len(dirs))
+	for _, d := range dirs {
+		want[d] = true
+		if _, ok := w.byDir[d]; ok {
+			continue
+		}
+		// ENOSPC (max_user_watches) ou diretório ausente: fica sem wake.
+		wd, err := unix.InotifyAddWatch(w.fd, d, inotifyMask)
+		if err != nil {
+			continue
+		}
+		w.byWD[wd], w.byDir[d] = d, wd
+	}
+	for d, wd := range w.byDir {
+		if !want[d] {
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.byDir, d)
+			delete(w.byWD, wd)
+		}
+	}
+}
+
+func (w *inotify) dir(wd int) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.byWD[wd]
+}
+
+func (w *inotify) forget(wd int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if d, ok := w.byWD[wd]; ok {
+		delete(w.byDir, d)
+		delete(w.byWD, wd)
+	}
+}
@@ -32,17 +32,22 @@ import (
 )
 
 type collector struct {
+	interval   time.Duration
 	queueStats func() (int, int64)
+	dropped    func() int64
 	prefs      func() config.CollectPrefs
 }
 
-func New(queueStats func() (int, int64), prefsProvider func() config.CollectPrefs) ports.Collector {
-	return &collector{queueStats: queueStats, prefs: prefsProvider}
+func New(interval time.Duration, queueStats func() (int, int64), dropped func() int64, prefsProvider func() config.CollectPrefs) ports.Collector {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &collector{interval: interval, queueStats: queueStats, dropped: dropped, prefs: prefsProvider}
 }
 
 func (c *collector) Name() string { return "sysmetrics" }
 
-func (c *collector) Interval() time.Duration { return 10 * time.Second }
+func (c *collector) Interval() time.Duration { return c.interval }
 
 type snapshot struct {
 	Capabilities map[string]bool `json:"capabilities,omitempty"`
@@ -233,9 +238,10 @@ type serviceSnap struct {
 }
 
 type agentSnap struct {
-	QueueItems int    `json:"queue_items,omitempty"`
-	QueueBytes int64  `json:"queue_bytes,omitempty"`
-	Version    string `json:"version,omitempty"`
+	QueueItems   int    `json:"queue_items,omitempty"`
+	QueueBytes   int64  `json:"queue_bytes,omitempty"`
+	QueueDropped int64  `json:"queue_dropped,omitempty"`
+	Version      string `json:"version,omitempty"`
 }
 
 type timeSyncSnap struct {
@@ -576,6 +582,9 @@ func (c *collector) Collect(ctx context.Context) ([]byte, error) {
 			items, bytes := c.queueStats()
 			agentInfo.QueueItems = items
 			agentInfo.QueueBytes = bytes
+			if c.dropped != nil {
+				agentInfo.QueueDropped = c.dropped()
+			}
 			s.Capabilities["agent"] = true
 		} else {
 			s.Capabilities["agent"] = false
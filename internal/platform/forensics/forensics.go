@@ -0,0 +1,450 @@
+package forensics
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	gnet "github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+// collector gera bundles tar.gz em cfg.ArtifactsDir. Cada arquivo entra com
+// SHA-256 no manifest.json; o bundle inteiro também é hasheado.
+type collector struct {
+	dir          string
+	maxFile      int64
+	maxBundle    int64
+	historyLines int
+}
+
+func New(cfg config.Config) ports.ArtifactCollector {
+	return &collector{
+		dir:          cfg.ArtifactsDir,
+		maxFile:      int64(cfg.ArtifactsMaxFileMB) << 20,
+		maxBundle:    int64(cfg.ArtifactsMaxBundleMB) << 20,
+		historyLines: cfg.ArtifactsHistoryLines,
+	}
+}
+
+// Garante conformidade.
+var _ ports.ArtifactCollector = (*collector)(nil)
+
+var errBundleFull = errors.New("bundle size limit reached")
+
+// bundle acompanha o tar em construção e o orçamento de bytes.
+type bundle struct {
+	tw       *tar.Writer
+	budget   int64
+	manifest []entities.ArtifactEntry
+}
+
+func (c *collector) Build(ctx context.Context, id string, req entities.ArtifactRequest) (entities.ArtifactBundle, error) {
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return entities.ArtifactBundle{}, err
+	}
+	out := entities.ArtifactBundle{ID: id, Path: filepath.Join(c.dir, id+".tar.gz"), CreatedAt: time.Now().UTC()}
+	f, err := os.OpenFile(out.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return out, err
+	}
+	h := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, h))
+	b := &bundle{tw: tar.NewWriter(gz), budget: c.maxBundle}
+
+	err = c.fill(ctx, b, req)
+	raw, _ := json.MarshalIndent(b.manifest, "", "  ")
+	if e := b.addBytes("manifest.json", "", raw, true); err == nil {
+		err = e
+	}
+	if e := b.tw.Close(); err == nil {
+		err = e
+	}
+	if e := gz.Close(); err == nil {
+		err = e
+	}
+	if e := f.Sync(); err == nil {
+		err = e
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		_ = os.Remove(out.Path)
+		return out, err
+	}
+	st, err := os.Stat(out.Path)
+	if err != nil {
+		return out, err
+	}
+	out.Size = st.Size()
+	out.SHA256 = hex.EncodeToString(h.Sum(nil))
+	for _, e := range b.manifest {
+		if e.Skipped != "" {
+			out.Skipped++
+		} else {
+			out.Entries++
+		}
+	}
+	return out, nil
+}
+
+// fill adiciona os artefatos pedidos; só erros de escrita do bundle (ou
+// cancelamento) abortam, o resto vira "skipped" no manifest.
+func (c *collector) fill(ctx context.Context, b *bundle, req entities.ArtifactRequest) error {
+	var procs []*process.Process
+	if req.Processes || req.ExeHashes {
+		procs, _ = process.ProcessesWithContext(ctx)
+	}
+	steps := []struct {
+		on  bool
+		run func() error
+	}{
+		{req.Processes, func() error { return b.addJSON("processes.json", processList(ctx, procs)) }},
+		{req.Connections, func() error { return b.addJSON("connections.json", connections(ctx)) }},
+		{req.ExeHashes, func() error { return b.addJSON("exe_hashes.json", exeHashes(ctx, procs, c.maxFile)) }},
+		{req.ShellHistory, func() error { return c.addHistory(ctx, b) }},
+		{len(req.Paths) > 0, func() error { return c.addPaths(ctx, b, req.Paths) }},
+	}
+	for _, s := range steps {
+		if !s.on {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.run(); err != nil && !errors.Is(err, errBundleFull) {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *collector) addPaths(ctx context.Context, b *bundle, patterns []string) error {
+	seen := map[string]bool{}
+	for _, pat := range patterns {
+		matches, err := filepath.Glob(pat)
+		if err != nil || len(matches) == 0 {
+			reason := "no match"
+			if err != nil {
+				reason = err.Error()
+			}
+			b.skip(pat, reason)
+			continue
+		}
+		for _, m := range matches {
+			err := filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err != nil {
+					b.skip(p, err.Error())
+					return nil
+				}
+				if d.IsDir() || seen[p] {
+					return nil
+				}
+				seen[p] = true
+				if !d.Type().IsRegular() {
+					b.skip(p, "not a regular file")
+					return nil
+				}
+				return b.addFile("files/"+entryName(p), p, c.maxFile)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addHistory inclui as últimas historyLines linhas dos históricos de shell
+// de cada usuário.
+func (c *collector) addHistory(ctx context.Context, b *bundle) error {
+	for _, p := range historyFiles() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lines, err := tail(p, c.historyLines)
+		if err != nil {
+			b.skip(p, err.Error())
+			continue
+		}
+		name := "shell_history/" + entryName(p)
+		if err := b.addBytes(name, p, []byte(strings.Join(lines, "\n")), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func historyFiles() []string {
+	homes, names := unixHomes(), unixHistory
+	if runtime.GOOS == "windows" {
+		homes, _ = filepath.Glob(`C:\Users\*`)
+		names = []string{`AppData\Roaming\Microsoft\Windows\PowerShell\PSReadLine\ConsoleHost_history.txt`}
+	}
+	var out []string
+	for _, h := range homes {
+		for _, n := range names {
+			p := filepath.Join(h, filepath.FromSlash(n))
+			if st, err := os.Stat(p); err == nil && st.Mode().IsRegular() {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+var unixHistory = []string{".bash_history", ".zsh_history", ".sh_history", ".ash_history", ".local/share/fish/fish_history", ".python_history", ".mysql_history", ".psql_history"}
+
+func unixHomes() []string {
+	if runtime.GOOS == "darwin" {
+		homes, _ := filepath.Glob("/Users/*")
+		return append(homes, "/var/root")
+	}
+	homes, _ := filepath.Glob("/home/*")
+	return append(homes, "/root")
+}
+
+func tail(p string, n int) ([]string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if n > 0 && len(lines) > 2*n {
+			lines = append(lines[:0], lines[len(lines)-n:]...)
+		}
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, sc.Err()
+}
+
+type procInfo struct {
+	PID        int32  `json:"pid"`
+	PPID       int32  `json:"ppid"`
+	Name       string `json:"name,omitempty"`
+	Exe        string `json:"exe,omitempty"`
+	Cmdline    string `json:"cmdline,omitempty"`
+	User       string `json:"user,omitempty"`
+	CreateTime int64  `json:"create_time_ms,omitempty"`
+}
+
+func processList(ctx context.Context, procs []*process.Process) []procInfo {
+	out := make([]procInfo, 0, len(procs))
+	for _, p := range procs {
+		pi := procInfo{PID: p.Pid}
+		pi.PPID, _ = p.PpidWithContext(ctx)
+		pi.Name, _ = p.NameWithContext(ctx)
+		pi.Exe, _ = p.ExeWithContext(ctx)
+		pi.Cmdline, _ = p.CmdlineWithContext(ctx)
+		pi.User, _ = p.UsernameWithContext(ctx)
+		pi.CreateTime, _ = p.CreateTimeWithContext(ctx)
+		out = append(out, pi)
+	}
+	return out
+}
+
+type connInfo struct {
+	Family string `json:"family"`
+	Type   string `json:"type"`
+	Local  string `json:"local"`
+	Remote string `json:"remote,omitempty"`
+	Status string `json:"status,omitempty"`
+	PID    int32  `json:"pid,omitempty"`
+}
+
+func connections(ctx context.Context) []connInfo {
+	conns, err := gnet.ConnectionsWithContext(ctx, "inet")
+	if err != nil {
+		return nil
+	}
+	out := make([]connInfo, 0, len(conns))
+	for _, c := range conns {
+		ci := connInfo{Family: "ipv4", Type: "tcp", Status: c.Status, PID: c.Pid}
+		if c.Family == 10 || c.Family == 23 || c.Family == 30 { // AF_INET6 (linux/windows/darwin)
+			ci.Family = "ipv6"
+		}
+		if c.Type == 2 { // SOCK_DGRAM
+			ci.Type = "udp"
+		}
+		ci.Local = fmt.Sprintf("%s:%d", c.Laddr.IP, c.Laddr.Port)
+		if c.Raddr.IP != "" {
+			ci.Remote = fmt.Sprintf("%s:%d", c.Raddr.IP, c.Raddr.Port)
+		}
+		out = append(out, ci)
+	}
+	return out
+}
+
+type exeHash struct {
+	Exe    string  `json:"exe"`
+	SHA256 string  `json:"sha256,omitempty"`
+	Size   int64   `json:"size,omitempty"`
+	PIDs   []int32 `json:"pids"`
+	Error  string  `json:"error,omitempty"`
+}
+
+func exeHashes(ctx context.Context, procs []*process.Process, maxSize int64) []exeHash {
+	byExe := map[string][]int32{}
+	for _, p := range procs {
+		if exe, err := p.ExeWithContext(ctx); err == nil && exe != "" {
+			byExe[exe] = append(byExe[exe], p.Pid)
+		}
+	}
+	out := make([]exeHash, 0, len(byExe))
+	for exe, pids := range byExe {
+		if ctx.Err() != nil {
+			break
+		}
+		e := exeHash{Exe: exe, PIDs: pids}
+		sum, size, err := hashFile(exe, maxSize)
+		if err != nil {
+			e.Error = err.Error()
+		}
+		e.SHA256, e.Size = sum, size
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Exe < out[j].Exe })
+	return out
+}
+
+func hashFile(p string, maxSize int64) (string, int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if st.Size() > maxSize {
+		return "", st.Size(), fmt.Errorf("larger than %d MB", maxSize>>20)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", st.Size(), err
+	}
+	return hex.EncodeToString(h.Sum(nil)), st.Size(), nil
+}
+
+func (b *bundle) skip(source, reason string) {
+	b.manifest = append(b.manifest, entities.ArtifactEntry{Source: source, Skipped: reason})
+}
+
+func (b *bundle) addJSON(name string, v any) error {
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return b.addBytes(name, "", raw, false)
+}
+
+// addBytes grava um item em memória; force ignora o orçamento (manifest).
+func (b *bundle) addBytes(name, source string, data []byte, force bool) error {
+	size := int64(len(data))
+	if !force && size > b.budget {
+		b.skip(name, errBundleFull.Error())
+		return errBundleFull
+	}
+	if err := b.tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: size, ModTime: time.Now()}); err != nil {
+		return err
+	}
+	if _, err := b.tw.Write(data); err != nil {
+		return err
+	}
+	b.budget -= size
+	if name != "manifest.json" {
+		sum := sha256.Sum256(data)
+		b.manifest = append(b.manifest, entities.ArtifactEntry{Name: name, Source: source, Size: size, SHA256: hex.EncodeToString(sum[:])})
+	}
+	return nil
+}
+
+// addFile copia o arquivo para o bundle hasheando no caminho. Arquivos que
+// mudam durante a cópia (logs) são limitados ao tamanho lido no Stat; os que
+// encolhem são completados com zeros e marcados como truncados.
+func (b *bundle) addFile(name, p string, maxSize int64) error {
+	f, err := os.Open(p)
+	if err != nil {
+		b.skip(p, err.Error())
+		return nil
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		b.skip(p, err.Error())
+		return nil
+	}
+	size := st.Size()
+	if size > maxSize {
+		b.skip(p, fmt.Sprintf("larger than %d MB", maxSize>>20))
+		return nil
+	}
+	if size > b.budget {
+		b.skip(p, errBundleFull.Error())
+		return errBundleFull
+	}
+	hdr := &tar.Header{Name: name, Mode: int64(st.Mode().Perm()), Size: size, ModTime: st.ModTime()}
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := sha256.New()
+	w := io.MultiWriter(b.tw, h)
+	n, err := io.Copy(w, io.LimitReader(f, size))
+	if err == nil && n < size {
+		// Truncado durante a cópia: completa com zeros para manter o tar
+		// válido; tamanho e hash do manifest são os do item no tar.
+		_, err = io.CopyN(w, zeros{}, size-n)
+	}
+	if err != nil {
+		return err
+	}
+	b.budget -= size
+	b.manifest = append(b.manifest, entities.ArtifactEntry{Name: name, Source: p, Size: size, SHA256: hex.EncodeToString(h.Sum(nil)), Truncated: n < size})
+	return nil
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// entryName converte um caminho do host em nome relativo no tar
+// ("/var/log/x" → "var/log/x", `C:\x` → "C/x").
+func entryName(p string) string {
+	p = filepath.ToSlash(p)
+	p = strings.Replace(p, ":", "", 1)
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
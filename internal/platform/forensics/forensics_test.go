@@ -0,0 +1,129 @@
+package forensics
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// readBundle devolve os nomes no tar e o manifest.
+func readBundle(t *testing.T, path string) ([]string, []entities.ArtifactEntry) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var names []string
+	var manifest []entities.ArtifactEntry
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+		if h.Name == "manifest.json" {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return names, manifest
+}
+
+func TestBuildBundleCap(t *testing.T) {
+	src := t.TempDir()
+	for name, size := range map[string]int{"a.log": 30 << 10, "b.log": 30 << 10, "c.log": 30 << 10, "huge.bin": 2 << 20} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(strings.Repeat("x", size)), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		name        string
+		maxBundle   int64
+		paths       []string
+		wantFiles   []string
+		wantSkipped map[string]string // origem -> motivo
+	}{
+		{
+			name: "cabe tudo", maxBundle: 1 << 20, paths: []string{filepath.Join(src, "*.log")},
+			wantFiles: []string{"a.log", "b.log", "c.log"},
+		},
+		{
+			name: "orçamento esgotado para de incluir", maxBundle: 64 << 10, paths: []string{filepath.Join(src, "*.log")},
+			wantFiles:   []string{"a.log", "b.log"},
+			wantSkipped: map[string]string{filepath.Join(src, "c.log"): "bundle size limit reached"},
+		},
+		{
+			name: "arquivo acima do limite por arquivo", maxBundle: 1 << 20, paths: []string{filepath.Join(src, "huge.bin"), filepath.Join(src, "a.log")},
+			wantFiles:   []string{"a.log"},
+			wantSkipped: map[string]string{filepath.Join(src, "huge.bin"): "larger than 1 MB"},
+		},
+		{
+			name: "glob sem resultado", maxBundle: 1 << 20, paths: []string{filepath.Join(src, "*.gz")},
+			wantSkipped: map[string]string{filepath.Join(src, "*.gz"): "no match"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &collector{dir: t.TempDir(), maxFile: 1 << 20, maxBundle: tc.maxBundle}
+			b, err := c.Build(context.Background(), "art-1", entities.ArtifactRequest{Paths: tc.paths})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names, manifest := readBundle(t, b.Path)
+			var files []string
+			for _, n := range names {
+				if n != "manifest.json" {
+					files = append(files, filepath.Base(n))
+				}
+			}
+			if !slices.Equal(files, tc.wantFiles) {
+				t.Errorf("files = %v, want %v", files, tc.wantFiles)
+			}
+			skipped := map[string]string{}
+			for _, e := range manifest {
+				if e.Skipped != "" {
+					skipped[e.Source] = e.Skipped
+				}
+			}
+			if len(skipped) != len(tc.wantSkipped) {
+				t.Errorf("skipped = %v, want %v", skipped, tc.wantSkipped)
+			}
+			for src, reason := range tc.wantSkipped {
+				if skipped[src] != reason {
+					t.Errorf("skipped[%s] = %q, want %q", src, skipped[src], reason)
+				}
+			}
+			if b.Entries != len(tc.wantFiles) || b.Skipped != len(tc.wantSkipped) {
+				t.Errorf("entries, skipped = %d, %d", b.Entries, b.Skipped)
+			}
+			raw, _ := os.ReadFile(b.Path)
+			sum := sha256.Sum256(raw)
+			if hex.EncodeToString(sum[:]) != b.SHA256 || int64(len(raw)) != b.Size {
+				t.Errorf("bundle hash/size do not match the file")
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package logparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// access casa o Common Log Format e, com referer e user agent, o combined
+// (padrão do nginx e do apache).
+var access = regexp.MustCompile(`^(\S+) (\S+) (\S+) \[([^\]]+)\] "((?:[^"\\]|\\.)*)" (\d{3}) (\d+|-)(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+const accessTime = "02/Jan/2006:15:04:05 -0700"
+
+func parseAccess(line string, _ time.Time) (Record, bool) {
+	m := access.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	t, err := time.Parse(accessTime, m[4])
+	if err != nil {
+		return Record{}, false
+	}
+	r := Record{Format: "common", Time: t}
+	r.set("client_ip", m[1])
+	if m[3] != "-" {
+		r.set("user", m[3])
+	}
+	// "GET /path HTTP/1.1"; requisições malformadas ficam inteiras.
+	if method, rest, ok := strings.Cut(m[5], " "); ok {
+		path, proto, _ := strings.Cut(rest, " ")
+		r.set("method", method)
+		r.set("path", path)
+		if proto != "" {
+			r.set("protocol", proto)
+		}
+	} else {
+		r.set("request", m[5])
+	}
+	status, _ := strconv.Atoi(m[6])
+	r.set("status", status)
+	if n, err := strconv.ParseInt(m[7], 10, 64); err == nil {
+		r.set("bytes", n)
+	}
+	// Só o combined termina o trecho casado em aspas (o user agent).
+	if strings.HasSuffix(m[0], `"`) {
+		r.Format = "combined"
+		if m[8] != "-" && m[8] != "" {
+			r.set("referer", m[8])
+		}
+		if m[9] != "-" && m[9] != "" {
+			r.set("user_agent", m[9])
+		}
+	}
+	switch {
+	case status >= 500:
+		r.Severity = "err"
+	case status >= 400:
+		r.Severity = "warning"
+	default:
+		r.Severity = "info"
+	}
+	return r, true
+}
@@ -0,0 +1,91 @@
+package logparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mensagens do sshd no auth.log/secure que interessam à detecção.
+var (
+	sshdAuth    = regexp.MustCompile(`^(Accepted|Failed) (\S+) for (invalid user )?(\S*) from (\S+) port (\d+)`)
+	sshdInvalid = regexp.MustCompile(`^Invalid user (\S*) from (\S+)(?: port (\d+))?`)
+	sshdClosed  = regexp.MustCompile(`^(Disconnected from|Connection closed by|Connection reset by) (?:(invalid|authenticating) user (\S*) )?(?:user (\S+) )?(\S+) port (\d+)`)
+)
+
+// enrichSSHD extrai ação, usuário e origem das tentativas de login.
+func enrichSSHD(r *Record) {
+	msg := r.Content
+	if m := sshdAuth.FindStringSubmatch(msg); m != nil {
+		r.set("action", strings.ToLower(m[1]))
+		r.set("method", m[2])
+		r.set("user", m[4])
+		if m[3] != "" {
+			r.set("invalid_user", true)
+		}
+		setAddr(r, m[5], m[6])
+		return
+	}
+	if m := sshdInvalid.FindStringSubmatch(msg); m != nil {
+		r.set("action", "invalid_user")
+		r.set("user", m[1])
+		r.set("invalid_user", true)
+		setAddr(r, m[2], m[3])
+		return
+	}
+	if m := sshdClosed.FindStringSubmatch(msg); m != nil {
+		r.set("action", "disconnected")
+		if user := m[3] + m[4]; user != "" {
+			r.set("user", user)
+		}
+		if m[2] == "invalid" {
+			r.set("invalid_user", true)
+		}
+		setAddr(r, m[5], m[6])
+	}
+}
+
+func setAddr(r *Record, ip, port string) {
+	r.set("src_ip", ip)
+	if p, err := strconv.Atoi(port); err == nil {
+		r.set("src_port", p)
+	}
+}
+
+// sudoKeys traduz as chaves do log do sudo para os nomes dos campos.
+var sudoKeys = map[string]string{
+	"TTY":   "tty",
+	"PWD":   "pwd",
+	"USER":  "target_user",
+	"GROUP": "target_group",
+}
+
+// enrichSudo lê "usuario : [motivo ; ]TTY=.. ; PWD=.. ; USER=.. ; COMMAND=..".
+// O motivo aparece nas negações (ex.: "3 incorrect password attempts").
+func enrichSudo(r *Record) {
+	user, rest, ok := strings.Cut(strings.TrimSpace(r.Content), " : ")
+	if !ok || strings.ContainsAny(user, " \t") {
+		return
+	}
+	r.set("user", user)
+	// COMMAND é o último e pode conter " ; ".
+	if i := strings.Index(rest, "COMMAND="); i >= 0 {
+		r.set("command", rest[i+len("COMMAND="):])
+		rest = strings.TrimSuffix(rest[:i], " ; ")
+	}
+	for _, part := range strings.Split(rest, " ; ") {
+		k, v, ok := strings.Cut(part, "=")
+		if name, known := sudoKeys[k]; ok && known {
+			r.set(name, v)
+			continue
+		}
+		if part = strings.TrimSpace(part); part != "" {
+			r.set("reason", part)
+		}
+	}
+	if _, denied := r.Fields["reason"]; denied {
+		r.set("action", "denied")
+	} else {
+		r.set("action", "command")
+	}
+}
@@ -0,0 +1,135 @@
+package logparse
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chaves usuais de bibliotecas de log estruturado (zap, logrus, slog, pino,
+// bunyan, logstash), na ordem de preferência.
+var (
+	jsonTimeKeys    = []string{"time", "timestamp", "@timestamp", "ts", "t"}
+	jsonLevelKeys   = []string{"level", "severity", "lvl", "log.level"}
+	jsonHostKeys    = []string{"host", "hostname"}
+	jsonProgramKeys = []string{"program", "app", "service"}
+	jsonMessageKeys = []string{"msg", "message"}
+	jsonPIDKeys     = []string{"pid"}
+)
+
+// parseJSON reconhece um objeto JSON por linha. As chaves reconhecidas
+// viram campos do Record; as demais ficam em Fields como vieram.
+func parseJSON(line string, _ time.Time) (Record, bool) {
+	if !strings.HasPrefix(line, "{") {
+		return Record{}, false
+	}
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return Record{}, false
+	}
+	r := Record{Format: "json"}
+	take(obj, jsonTimeKeys, func(v any) bool {
+		r.Time = jsonTime(v)
+		return !r.Time.IsZero()
+	})
+	take(obj, jsonLevelKeys, func(v any) bool {
+		r.Severity = jsonLevel(v)
+		return r.Severity != ""
+	})
+	take(obj, jsonHostKeys, func(v any) bool {
+		r.Host, _ = v.(string)
+		return r.Host != ""
+	})
+	take(obj, jsonProgramKeys, func(v any) bool {
+		r.Program, _ = v.(string)
+		return r.Program != ""
+	})
+	take(obj, jsonMessageKeys, func(v any) bool {
+		r.Content, _ = v.(string)
+		return r.Content != ""
+	})
+	take(obj, jsonPIDKeys, func(v any) bool {
+		n, _ := v.(json.Number)
+		pid, err := n.Int64()
+		r.PID = int(pid)
+		return err == nil
+	})
+	if len(obj) > 0 {
+		r.Fields = obj
+	}
+	return r, true
+}
+
+// take entrega a use o valor da primeira chave presente que ele aceitar, e
+// a remove de obj; valores de outro tipo (ex.: host como objeto) ficam.
+func take(obj map[string]any, keys []string, use func(v any) bool) {
+	for _, k := range keys {
+		if v, ok := obj[k]; ok && use(v) {
+			delete(obj, k)
+			return
+		}
+	}
+}
+
+// jsonTime aceita RFC 3339 ou época numérica em s, ms, µs ou ns (deduzida
+// pela magnitude).
+func jsonTime(v any) time.Time {
+	switch x := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, x); err == nil {
+			return t
+		}
+		if f, err := strconv.ParseFloat(x, 64); err == nil {
+			return epoch(f)
+		}
+	case json.Number:
+		if f, err := x.Float64(); err == nil {
+			return epoch(f)
+		}
+	}
+	return time.Time{}
+}
+
+func epoch(f float64) time.Time {
+	switch a := math.Abs(f); {
+	case a >= 1e17:
+		return time.Unix(0, int64(f))
+	case a >= 1e14:
+		return time.UnixMicro(int64(f))
+	case a >= 1e11:
+		return time.UnixMilli(int64(f))
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// jsonLevel aceita o nome do nível ou o número do pino/bunyan (10=trace ...
+// 60=fatal).
+func jsonLevel(v any) string {
+	switch x := v.(type) {
+	case string:
+		return normalizeSeverity(x)
+	case json.Number:
+		n, err := x.Int64()
+		if err != nil {
+			return ""
+		}
+		switch {
+		case n >= 60:
+			return "crit"
+		case n >= 50:
+			return "err"
+		case n >= 40:
+			return "warning"
+		case n >= 30:
+			return "info"
+		case n >= 10:
+			return "debug"
+		}
+	}
+	return ""
+}
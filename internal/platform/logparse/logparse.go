@@ -0,0 +1,120 @@
+// Package logparse reconhece os formatos de log mais comuns (syslog RFC 5424
+// e 3164, access log common/combined do nginx e apache, JSON por linha) e
+// extrai deles timestamp, host, programa, PID, severidade e campos, para o
+// backend não precisar reinterpretar a linha crua.
+package logparse
+
+import (
+	"strings"
+	"time"
+)
+
+// Record é o que o parser extraiu de uma mensagem. Campos vazios não foram
+// encontrados; Time zero indica que a linha não trazia horário.
+type Record struct {
+	Format   string // rfc5424, rfc3164, combined, common ou json
+	Time     time.Time
+	Host     string
+	Program  string
+	PID      int
+	Severity string // nomes do syslog: emerg, alert, crit, err, warning, notice, info, debug
+	Facility string
+	Content  string // a mensagem sem o cabeçalho
+	Fields   map[string]any
+}
+
+// parsers são tentados em ordem; o primeiro que reconhece a linha vence.
+var parsers = []func(line string, now time.Time) (Record, bool){
+	parseJSON,
+	parse5424,
+	parse3164,
+	parseAccess,
+}
+
+// enrichers extraem campos do conteúdo de programas conhecidos.
+var enrichers = map[string]func(r *Record){
+	"sshd": enrichSSHD,
+	"sudo": enrichSudo,
+}
+
+// Parse reconhece msg (uma linha, ou um evento multilinha cujo cabeçalho
+// está na primeira). now resolve o ano do RFC 3164, que não o traz.
+func Parse(msg string, now time.Time) (Record, bool) {
+	msg = strings.TrimRight(msg, "\r\n")
+	if msg == "" {
+		return Record{}, false
+	}
+	for _, p := range parsers {
+		r, ok := p(msg, now)
+		if !ok {
+			continue
+		}
+		Enrich(&r)
+		return r, true
+	}
+	return Record{}, false
+}
+
+// Enrich extrai do Content os campos de programas conhecidos (sshd, sudo).
+// Parse já o aplica; serve a fontes que trazem programa e mensagem
+// separados, como o journal.
+func Enrich(r *Record) {
+	if fn := enrichers[r.Program]; fn != nil {
+		fn(r)
+	}
+}
+
+func (r *Record) set(key string, v any) {
+	if r.Fields == nil {
+		r.Fields = map[string]any{}
+	}
+	r.Fields[key] = v
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Severity devolve o nome do nível syslog n (0-7); fora disso, vazio.
+func Severity(n int) string {
+	if n < 0 || n >= len(severities) {
+		return ""
+	}
+	return severities[n]
+}
+
+// Facility devolve o nome da facility syslog n (0-23); fora disso, vazio.
+func Facility(n int) string {
+	if n < 0 || n >= len(facilities) {
+		return ""
+	}
+	return facilities[n]
+}
+
+// normalizeSeverity traduz os níveis usuais de bibliotecas de log para os
+// nomes do syslog; desconhecidos voltam vazios.
+func normalizeSeverity(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "emerg", "emergency", "panic":
+		return "emerg"
+	case "alert":
+		return "alert"
+	case "crit", "critical", "fatal":
+		return "crit"
+	case "err", "error", "eror":
+		return "err"
+	case "warn", "warning":
+		return "warning"
+	case "notice":
+		return "notice"
+	case "info", "information", "informational":
+		return "info"
+	case "debug", "trace", "dbug":
+		return "debug"
+	}
+	return ""
+}
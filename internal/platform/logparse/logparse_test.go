@@ -0,0 +1,227 @@
+package logparse
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fields = map[string]any
+
+func local(y int, mo time.Month, d, h, mi, s int) time.Time {
+	return time.Date(y, mo, d, h, mi, s, 0, time.Local)
+}
+
+func TestParse(t *testing.T) {
+	now := local(2025, 10, 18, 12, 0, 0)
+	cases := []struct {
+		name string
+		line string
+		now  time.Time // zero = now
+		want Record
+	}{
+		// RFC 3164 e o formato de arquivo do rsyslog.
+		{
+			name: "3164 com PRI e sshd falho",
+			line: "<34>Oct 18 10:00:00 web1 sshd[123]: Failed password for invalid user admin from 10.0.0.5 port 2222 ssh2\n",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sshd", PID: 123,
+				Severity: "crit", Facility: "auth",
+				Content: "Failed password for invalid user admin from 10.0.0.5 port 2222 ssh2",
+				Fields:  fields{"action": "failed", "method": "password", "user": "admin", "invalid_user": true, "src_ip": "10.0.0.5", "src_port": 2222},
+			},
+		},
+		{
+			name: "3164 sem PRI com dia de um dígito",
+			line: "Oct  8 09:15:01 web1 CRON[999]: (root) CMD (run-parts /etc/cron.hourly)",
+			want: Record{Format: "rfc3164", Time: local(2025, 10, 8, 9, 15, 1), Host: "web1", Program: "CRON", PID: 999, Content: "(root) CMD (run-parts /etc/cron.hourly)"},
+		},
+		{
+			name: "3164 com horário RFC 3339",
+			line: "2025-10-18T10:00:00.123456+00:00 web1 kernel: [    1.000000] Linux version 6.1",
+			want: Record{Format: "rfc3164", Time: time.Date(2025, 10, 18, 10, 0, 0, 123456000, time.UTC), Host: "web1", Program: "kernel", Content: "[    1.000000] Linux version 6.1"},
+		},
+		{
+			name: "3164 na virada do ano",
+			line: "Dec 31 23:59:59 h app: x",
+			now:  local(2026, 1, 1, 0, 10, 0),
+			want: Record{Format: "rfc3164", Time: local(2025, 12, 31, 23, 59, 59), Host: "h", Program: "app", Content: "x"},
+		},
+		{
+			name: "sudo",
+			line: "Oct 18 10:00:00 web1 sudo:    alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/ls -la ; echo",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sudo",
+				Content: "   alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/bin/ls -la ; echo",
+				Fields:  fields{"user": "alice", "tty": "pts/0", "pwd": "/home/alice", "target_user": "root", "command": "/bin/ls -la ; echo", "action": "command"},
+			},
+		},
+		{
+			name: "sudo negado",
+			line: "Oct 18 10:00:00 web1 sudo:      bob : 3 incorrect password attempts ; TTY=pts/1 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sudo",
+				Content: "     bob : 3 incorrect password attempts ; TTY=pts/1 ; PWD=/tmp ; USER=root ; COMMAND=/bin/sh",
+				Fields:  fields{"user": "bob", "reason": "3 incorrect password attempts", "tty": "pts/1", "pwd": "/tmp", "target_user": "root", "command": "/bin/sh", "action": "denied"},
+			},
+		},
+		{
+			name: "sshd aceito",
+			line: "Oct 18 10:00:00 web1 sshd[5]: Accepted publickey for deploy from 192.0.2.1 port 50000 ssh2: ED25519 SHA256:abc",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sshd", PID: 5,
+				Content: "Accepted publickey for deploy from 192.0.2.1 port 50000 ssh2: ED25519 SHA256:abc",
+				Fields:  fields{"action": "accepted", "method": "publickey", "user": "deploy", "src_ip": "192.0.2.1", "src_port": 50000},
+			},
+		},
+		{
+			name: "sshd usuário inválido",
+			line: "Oct 18 10:00:00 web1 sshd[5]: Invalid user test from 198.51.100.7 port 4444",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sshd", PID: 5,
+				Content: "Invalid user test from 198.51.100.7 port 4444",
+				Fields:  fields{"action": "invalid_user", "user": "test", "invalid_user": true, "src_ip": "198.51.100.7", "src_port": 4444},
+			},
+		},
+		{
+			name: "sshd desconectado",
+			line: "Oct 18 10:00:00 web1 sshd[5]: Connection closed by invalid user guest 203.0.113.9 port 1022 [preauth]",
+			want: Record{
+				Format: "rfc3164", Time: local(2025, 10, 18, 10, 0, 0), Host: "web1", Program: "sshd", PID: 5,
+				Content: "Connection closed by invalid user guest 203.0.113.9 port 1022 [preauth]",
+				Fields:  fields{"action": "disconnected", "user": "guest", "invalid_user": true, "src_ip": "203.0.113.9", "src_port": 1022},
+			},
+		},
+
+		// RFC 5424.
+		{
+			name: "5424 com dados estruturados",
+			line: `<165>1 2025-10-18T10:00:00.5Z host app 42 ID47 [exampleSDID@32473 iut="3" eventSource="App\"li\]cation"][meta seq="1"] ` + "\ufeffAn app event",
+			want: Record{
+				Format: "rfc5424", Time: time.Date(2025, 10, 18, 10, 0, 0, 500000000, time.UTC), Host: "host", Program: "app", PID: 42,
+				Severity: "notice", Facility: "local4", Content: "An app event",
+				Fields: fields{"msgid": "ID47", "exampleSDID@32473.iut": "3", "exampleSDID@32473.eventSource": `App"li]cation`, "meta.seq": "1"},
+			},
+		},
+		{
+			name: "5424 com valores nulos",
+			line: "<13>1 - - - - - - msg",
+			want: Record{Format: "rfc5424", Severity: "notice", Facility: "user", Content: "msg"},
+		},
+		{
+			name: "5424 sem PRI",
+			line: "1 2025-10-18T10:00:00Z h p - - - hello",
+			want: Record{Format: "rfc5424", Time: time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC), Host: "h", Program: "p", Content: "hello"},
+		},
+		{
+			name: "5424 com dados estruturados quebrados",
+			line: `<13>1 2025-10-18T10:00:00Z h p - - [bad x="1`,
+		},
+
+		// Access log.
+		{
+			name: "combined",
+			line: `203.0.113.5 - frank [18/Oct/2025:10:00:00 -0300] "GET /a?b=1 HTTP/1.1" 404 512 "https://ref.example/" "curl/8.0"`,
+			want: Record{
+				Format: "combined", Time: time.Date(2025, 10, 18, 13, 0, 0, 0, time.UTC), Severity: "warning",
+				Fields: fields{"client_ip": "203.0.113.5", "user": "frank", "method": "GET", "path": "/a?b=1", "protocol": "HTTP/1.1", "status": 404, "bytes": int64(512), "referer": "https://ref.example/", "user_agent": "curl/8.0"},
+			},
+		},
+		{
+			name: "common sem bytes",
+			line: `10.0.0.1 - - [18/Oct/2025:10:00:00 +0000] "POST /x HTTP/1.0" 503 -`,
+			want: Record{
+				Format: "common", Time: time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC), Severity: "err",
+				Fields: fields{"client_ip": "10.0.0.1", "method": "POST", "path": "/x", "protocol": "HTTP/1.0", "status": 503},
+			},
+		},
+		{
+			name: "requisição malformada",
+			line: `10.0.0.1 - - [18/Oct/2025:10:00:00 +0000] "\x16\x03\x01" 400 0 "-" "-"`,
+			want: Record{
+				Format: "combined", Time: time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC), Severity: "warning",
+				Fields: fields{"client_ip": "10.0.0.1", "request": `\x16\x03\x01`, "status": 400, "bytes": int64(0)},
+			},
+		},
+
+		// JSON por linha.
+		{
+			name: "JSON com chaves usuais",
+			line: `{"time":"2025-10-18T10:00:00Z","level":"WARN","msg":"disk almost full","host":"h1","service":"api","pid":7,"disk":"/dev/sda"}`,
+			want: Record{
+				Format: "json", Time: time.Date(2025, 10, 18, 10, 0, 0, 0, time.UTC), Severity: "warning", Host: "h1", Program: "api", PID: 7,
+				Content: "disk almost full", Fields: fields{"disk": "/dev/sda"},
+			},
+		},
+		{
+			name: "JSON do pino",
+			line: `{"level":50,"time":1760781600000,"msg":"x","hostname":"h","pid":1}`,
+			want: Record{Format: "json", Time: time.UnixMilli(1760781600000), Severity: "err", Host: "h", PID: 1, Content: "x"},
+		},
+		{
+			name: "JSON com época em segundos",
+			line: `{"ts":1760781600.5,"message":"y","severity":"trace"}`,
+			want: Record{Format: "json", Time: time.Unix(1760781600, 500000000), Severity: "debug", Content: "y"},
+		},
+		{
+			name: "JSON com host não textual",
+			line: `{"host":{"name":"h"},"msg":"z"}`,
+			want: Record{Format: "json", Content: "z", Fields: fields{"host": map[string]any{"name": "h"}}},
+		},
+		{name: "JSON inválido", line: `{"a":`},
+		{name: "texto livre", line: "hello world"},
+		{name: "vazio", line: "\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			n := tc.now
+			if n.IsZero() {
+				n = now
+			}
+			got, ok := Parse(tc.line, n)
+			if ok != (tc.want.Format != "") {
+				t.Fatalf("Parse ok = %v, record %+v", ok, got)
+			}
+			if !got.Time.Equal(tc.want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, tc.want.Time)
+			}
+			got.Time, tc.want.Time = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("record = %+v\nwant     %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// Enrich vale para fontes que trazem programa e mensagem separados.
+func TestEnrich(t *testing.T) {
+	r := Record{Program: "sshd", Content: "Disconnected from user root 192.0.2.4 port 22"}
+	Enrich(&r)
+	want := fields{"action": "disconnected", "user": "root", "src_ip": "192.0.2.4", "src_port": 22}
+	if !reflect.DeepEqual(r.Fields, want) {
+		t.Fatalf("fields = %v, want %v", r.Fields, want)
+	}
+	other := Record{Program: "nginx", Content: "Accepted password for x from 1.2.3.4 port 1"}
+	if Enrich(&other); other.Fields != nil {
+		t.Fatalf("enriched an unknown program: %v", other.Fields)
+	}
+}
+
+func TestSeverityAndFacility(t *testing.T) {
+	cases := []struct {
+		n        int
+		sev, fac string
+	}{
+		{0, "emerg", "kern"},
+		{7, "debug", "news"},
+		{10, "", "authpriv"},
+		{23, "", "local7"},
+		{24, "", ""},
+		{-1, "", ""},
+	}
+	for _, tc := range cases {
+		if s, f := Severity(tc.n), Facility(tc.n); s != tc.sev || f != tc.fac {
+			t.Errorf("Severity/Facility(%d) = %q, %q; want %q, %q", tc.n, s, f, tc.sev, tc.fac)
+		}
+	}
+}
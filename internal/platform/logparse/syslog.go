@@ -0,0 +1,155 @@
+package logparse
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rfc3164 casa "<PRI>Mmm dd hh:mm:ss host tag[pid]: msg", com PRI opcional
+// (arquivos do rsyslog não o gravam) e o horário também no formato RFC 3339
+// (RSYSLOG_FileFormat, padrão em distros novas).
+var rfc3164 = regexp.MustCompile(`(?s)^(?:<(\d{1,3})>)?` +
+	`([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}|\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})) ` +
+	`(\S+) ([^\s:\[]+)(?:\[(\d+)\])?:[ \t]?(.*)$`)
+
+func parse3164(line string, now time.Time) (Record, bool) {
+	m := rfc3164.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	r := Record{Format: "rfc3164", Host: m[3], Program: m[4], Content: m[6]}
+	r.Severity, r.Facility = priority(m[1])
+	r.PID, _ = strconv.Atoi(m[5])
+	if t, err := time.Parse(time.RFC3339Nano, m[2]); err == nil {
+		r.Time = t
+	} else {
+		r.Time = stampTime(m[2], now)
+	}
+	return r, true
+}
+
+// stampTime completa o ano do "Mmm dd hh:mm:ss" (hora local do host). Uma
+// data mais de um dia no futuro é do ano anterior (virada de ano).
+func stampTime(s string, now time.Time) time.Time {
+	t, err := time.ParseInLocation(time.Stamp, s, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	year := now.Year()
+	if time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local).After(now.Add(24 * time.Hour)) {
+		year--
+	}
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+}
+
+// parse5424 reconhece "<PRI>1 timestamp host app procid msgid [sd] msg";
+// o PRI é opcional pelo mesmo motivo do 3164. Os parâmetros dos elementos
+// estruturados viram campos "id.param".
+func parse5424(line string, _ time.Time) (Record, bool) {
+	pri := ""
+	rest := line
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 2 || end > 4 {
+			return Record{}, false
+		}
+		pri, rest = rest[1:end], rest[end+1:]
+	}
+	if !strings.HasPrefix(rest, "1 ") {
+		return Record{}, false
+	}
+	parts := strings.SplitN(rest[2:], " ", 6)
+	if len(parts) < 6 {
+		return Record{}, false
+	}
+	r := Record{Format: "rfc5424"}
+	if parts[0] != "-" {
+		t, err := time.Parse(time.RFC3339Nano, parts[0])
+		if err != nil {
+			return Record{}, false
+		}
+		r.Time = t
+	}
+	r.Host, r.Program = nilValue(parts[1]), nilValue(parts[2])
+	r.PID, _ = strconv.Atoi(parts[3])
+	if id := nilValue(parts[4]); id != "" {
+		r.set("msgid", id)
+	}
+	msg, ok := structuredData(parts[5], &r)
+	if !ok {
+		return Record{}, false
+	}
+	r.Content = strings.TrimPrefix(msg, "\ufeff")
+	r.Severity, r.Facility = priority(pri)
+	return r, true
+}
+
+// structuredData consome o STRUCTURED-DATA do início de s e devolve o resto
+// (a mensagem).
+func structuredData(s string, r *Record) (string, bool) {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(s[1:], " "), true
+	}
+	for strings.HasPrefix(s, "[") {
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return "", false
+		}
+		id := s[1:end]
+		s = s[end:]
+		for strings.HasPrefix(s, " ") {
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return "", false
+			}
+			name := s[1:eq]
+			val, n, ok := sdValue(s[eq+2:])
+			if !ok {
+				return "", false
+			}
+			r.set(id+"."+name, val)
+			s = s[eq+2+n:]
+		}
+		if !strings.HasPrefix(s, "]") {
+			return "", false
+		}
+		s = s[1:]
+	}
+	return strings.TrimPrefix(s, " "), true
+}
+
+// sdValue lê um PARAM-VALUE até a aspa que o fecha, tratando os escapes
+// \" \\ \]; n inclui a aspa final.
+func sdValue(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0:
+			b.WriteByte(s[i+1])
+			i++
+		case c == '"':
+			return b.String(), i + 1, true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// priority decodifica o PRI em severidade e facility.
+func priority(pri string) (severity, facility string) {
+	n, err := strconv.Atoi(pri)
+	if err != nil || n < 0 || n > 191 {
+		return "", ""
+	}
+	return Severity(n % 8), Facility(n / 8)
+}
@@ -0,0 +1,379 @@
+//go:build linux
+// +build linux
+
+package response
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+const (
+	nftTable        = "aiceberg"
+	nftIsolateTable = "aiceberg_isolate"
+	iptBlockChain   = "AICEBERG-BLOCK"
+	iptIsolateChain = "AICEBERG-ISOLATE"
+	cmdTimeout      = 10 * time.Second
+)
+
+// firewall usa nftables (tabelas próprias inet aiceberg/aiceberg_isolate) ou
+// iptables/ip6tables (chains AICEBERG-*), sem tocar nas regras do host.
+type firewall struct {
+	mode    string // auto|nftables|iptables
+	mu      sync.Mutex
+	backend string
+}
+
+func NewFirewall(mode string) ports.Firewall { return &firewall{mode: mode} }
+
+// Garante conformidade.
+var _ ports.Firewall = (*firewall)(nil)
+
+func (f *firewall) Backend() string {
+	b, err := f.detect()
+	if err != nil {
+		return "none"
+	}
+	return b
+}
+
+func (f *firewall) detect() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.backend != "" {
+		return f.backend, nil
+	}
+	switch f.mode {
+	case "nftables", "iptables":
+		f.backend = f.mode
+	default:
+		if _, err := exec.LookPath("nft"); err == nil {
+			f.backend = "nftables"
+		} else if _, err := exec.LookPath("iptables"); err == nil {
+			f.backend = "iptables"
+		} else {
+			return "", errors.New("neither nft nor iptables found")
+		}
+	}
+	return f.backend, nil
+}
+
+func (f *firewall) BlockIP(ip string) error {
+	b, err := f.detect()
+	if err != nil {
+		return err
+	}
+	v6, err := family(ip)
+	if err != nil {
+		return err
+	}
+	if b == "nftables" {
+		if err := nftEnsure(); err != nil {
+			return err
+		}
+		return run("nft", "add", "element", "inet", nftTable, nftSet(v6), "{ "+ip+" }")
+	}
+	// Sem a regra de um dos sentidos o bloqueio fica pela metade e não
+	// entra no registro de ações ativas: desfaz o que já foi aplicado.
+	if err := iptBlock(iptCmd(v6), ip); err != nil {
+		return withRollback(err, f.UnblockIP(ip))
+	}
+	return nil
+}
+
+func (f *firewall) UnblockIP(ip string) error {
+	b, err := f.detect()
+	if err != nil {
+		return err
+	}
+	v6, err := family(ip)
+	if err != nil {
+		return err
+	}
+	if b == "nftables" {
+		if run("nft", "get", "element", "inet", nftTable, nftSet(v6), "{ "+ip+" }") != nil {
+			return nil
+		}
+		return run("nft", "delete", "element", "inet", nftTable, nftSet(v6), "{ "+ip+" }")
+	}
+	ipt := iptCmd(v6)
+	for _, dir := range []string{"-s", "-d"} {
+		rule := []string{iptBlockChain, dir, ip, "-j", "DROP"}
+		for run(ipt, append([]string{"-C"}, rule...)...) == nil {
+			if err := run(ipt, append([]string{"-D"}, rule...)...); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *firewall) Isolate(allow []string) error {
+	b, err := f.detect()
+	if err != nil {
+		return err
+	}
+	var v4, v6 []string
+	for _, ip := range slices.Concat(allow, dnsServers()) {
+		is6, err := family(ip)
+		if err != nil {
+			return err
+		}
+		if is6 {
+			v6 = append(v6, ip)
+		} else {
+			v4 = append(v4, ip)
+		}
+	}
+	if b == "nftables" {
+		// Recria a tabela inteira numa transação: nunca fica meio aplicada.
+		var sb strings.Builder
+		if nftExists(nftIsolateTable) {
+			fmt.Fprintf(&sb, "delete table inet %s\n", nftIsolateTable)
+		}
+		fmt.Fprintf(&sb, "table inet %s {\n", nftIsolateTable)
+		fmt.Fprintf(&sb, "  set allow4 { type ipv4_addr; flags interval;%s }\n", nftElements(v4))
+		fmt.Fprintf(&sb, "  set allow6 { type ipv6_addr; flags interval;%s }\n", nftElements(v6))
+		sb.WriteString("  chain input { type filter hook input priority -20; policy drop;\n" +
+			"    iif lo accept\n    icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-advert } accept\n" +
+			"    ip saddr @allow4 accept\n    ip6 saddr @allow6 accept\n  }\n")
+		sb.WriteString("  chain output { type filter hook output priority -20; policy drop;\n" +
+			"    oif lo accept\n    icmpv6 type { nd-neighbor-solicit, nd-neighbor-advert, nd-router-solicit } accept\n" +
+			"    ip daddr @allow4 accept\n    ip6 daddr @allow6 accept\n  }\n}\n")
+		return runInput(sb.String(), "nft", "-f", "-")
+	}
+	// Sem transação no iptables: qualquer falha remove as chains das duas
+	// famílias em vez de deixar o host meio isolado.
+	if err := iptIsolate(v4, v6); err != nil {
+		return withRollback(err, f.Release())
+	}
+	return nil
+}
+
+// iptIsolate preenche as chains de isolamento de iptables e ip6tables e só
+// então as liga em INPUT/OUTPUT.
+func iptIsolate(v4, v6 []string) error {
+	fams := []struct {
+		ipt   string
+		allow []string
+		v6    bool
+	}{{"iptables", v4, false}, {"ip6tables", v6, true}}
+	for _, fam := range fams {
+		if err := iptNewChain(fam.ipt, iptIsolateChain); err != nil {
+			return err
+		}
+		rules := [][]string{{"-F", iptIsolateChain}, {"-A", iptIsolateChain, "-i", "lo", "-j", "ACCEPT"}, {"-A", iptIsolateChain, "-o", "lo", "-j", "ACCEPT"}}
+		if fam.v6 {
+			rules = append(rules, []string{"-A", iptIsolateChain, "-p", "ipv6-icmp", "-j", "ACCEPT"})
+		}
+		for _, ip := range fam.allow {
+			rules = append(rules, []string{"-A", iptIsolateChain, "-s", ip, "-j", "ACCEPT"}, []string{"-A", iptIsolateChain, "-d", ip, "-j", "ACCEPT"})
+		}
+		rules = append(rules, []string{"-A", iptIsolateChain, "-j", "DROP"})
+		for _, r := range rules {
+			if err := run(fam.ipt, r...); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fam := range fams {
+		if err := iptHook(fam.ipt, iptIsolateChain, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iptBlock garante a chain de bloqueios e as regras DROP de origem e destino.
+func iptBlock(ipt, ip string) error {
+	if err := iptEnsureChain(ipt, iptBlockChain, 1); err != nil {
+		return err
+	}
+	for _, dir := range []string{"-s", "-d"} {
+		rule := []string{iptBlockChain, dir, ip, "-j", "DROP"}
+		if run(ipt, append([]string{"-C"}, rule...)...) == nil {
+			continue
+		}
+		if err := run(ipt, append([]string{"-A"}, rule...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withRollback junta ao erro da aplicação o do rollback, se houver.
+func withRollback(err, rerr error) error {
+	if rerr != nil {
+		return fmt.Errorf("%w (rollback: %v)", err, rerr)
+	}
+	return err
+}
+
+func (f *firewall) Release() error {
+	b, err := f.detect()
+	if err != nil {
+		return err
+	}
+	if b == "nftables" {
+		if !nftExists(nftIsolateTable) {
+			return nil
+		}
+		return run("nft", "delete", "table", "inet", nftIsolateTable)
+	}
+	for _, ipt := range []string{"iptables", "ip6tables"} {
+		if err := iptRemoveChain(ipt, iptIsolateChain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dnsServers lista os resolvers do sistema, liberados no isolamento para
+// que o host da API continue resolvendo. Com systemd-resolved (127.0.0.53)
+// vale o upstream de /run/systemd/resolve/resolv.conf.
+func dnsServers() []string {
+	var out []string
+	for _, path := range []string{"/etc/resolv.conf", "/run/systemd/resolve/resolv.conf"} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			f := strings.Fields(line)
+			if len(f) < 2 || f[0] != "nameserver" {
+				continue
+			}
+			if ip := net.ParseIP(f[1]); ip != nil && !ip.IsLoopback() && !slices.Contains(out, ip.String()) {
+				out = append(out, ip.String())
+			}
+		}
+	}
+	return out
+}
+
+func family(ip string) (bool, error) {
+	if _, n, err := net.ParseCIDR(ip); err == nil {
+		return n.IP.To4() == nil, nil
+	}
+	p := net.ParseIP(ip)
+	if p == nil {
+		return false, fmt.Errorf("invalid ip %q", ip)
+	}
+	return p.To4() == nil, nil
+}
+
+func nftSet(v6 bool) string {
+	if v6 {
+		return "blocked6"
+	}
+	return "blocked4"
+}
+
+func nftElements(ips []string) string {
+	if len(ips) == 0 {
+		return ""
+	}
+	return " elements = { " + strings.Join(ips, ", ") + " };"
+}
+
+func nftExists(table string) bool {
+	return run("nft", "list", "table", "inet", table) == nil
+}
+
+// nftEnsure cria a tabela de bloqueios (sets + chains) se ainda não existir.
+func nftEnsure() error {
+	if nftExists(nftTable) {
+		return nil
+	}
+	script := "table inet " + nftTable + " {\n" +
+		"  set blocked4 { type ipv4_addr; flags interval; }\n" +
+		"  set blocked6 { type ipv6_addr; flags interval; }\n" +
+		"  chain input { type filter hook input priority -10; policy accept;\n" +
+		"    ip saddr @blocked4 drop\n    ip6 saddr @blocked6 drop\n  }\n" +
+		"  chain output { type filter hook output priority -10; policy accept;\n" +
+		"    ip daddr @blocked4 drop\n    ip6 daddr @blocked6 drop\n  }\n}\n"
+	return runInput(script, "nft", "-f", "-")
+}
+
+func iptCmd(v6 bool) string {
+	if v6 {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// iptEnsureChain cria chain (se preciso) e o salto para ela no topo de
+// INPUT e OUTPUT.
+func iptEnsureChain(ipt, chain string, pos int) error {
+	if err := iptNewChain(ipt, chain); err != nil {
+		return err
+	}
+	return iptHook(ipt, chain, pos)
+}
+
+func iptNewChain(ipt, chain string) error {
+	if run(ipt, "-n", "-L", chain) == nil {
+		return nil
+	}
+	return run(ipt, "-N", chain)
+}
+
+// iptHook insere o salto para chain na posição pos de INPUT e OUTPUT.
+func iptHook(ipt, chain string, pos int) error {
+	for _, hook := range []string{"INPUT", "OUTPUT"} {
+		if run(ipt, "-C", hook, "-j", chain) == nil {
+			continue
+		}
+		if err := run(ipt, "-I", hook, fmt.Sprint(pos), "-j", chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func iptRemoveChain(ipt, chain string) error {
+	if run(ipt, "-n", "-L", chain) != nil {
+		return nil
+	}
+	for _, hook := range []string{"INPUT", "OUTPUT"} {
+		for run(ipt, "-C", hook, "-j", chain) == nil {
+			if err := run(ipt, "-D", hook, "-j", chain); err != nil {
+				return err
+			}
+		}
+	}
+	if err := run(ipt, "-F", chain); err != nil {
+		return err
+	}
+	return run(ipt, "-X", chain)
+}
+
+func run(name string, args ...string) error {
+	return runInput("", name, args...)
+}
+
+func runInput(stdin, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
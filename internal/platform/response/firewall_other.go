@@ -0,0 +1,25 @@
+//go:build !linux
+// +build !linux
+
+package response
+
+import (
+	"errors"
+
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+var errUnsupported = errors.New("firewall actions are only supported on Linux (nftables/iptables)")
+
+type firewall struct{}
+
+func NewFirewall(string) ports.Firewall { return firewall{} }
+
+// Garante conformidade.
+var _ ports.Firewall = firewall{}
+
+func (firewall) Backend() string        { return "none" }
+func (firewall) BlockIP(string) error   { return errUnsupported }
+func (firewall) UnblockIP(string) error { return errUnsupported }
+func (firewall) Isolate([]string) error { return errUnsupported }
+func (firewall) Release() error         { return errUnsupported }
@@ -0,0 +1,40 @@
+package response
+
+import (
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+type processKiller struct{}
+
+func NewProcessKiller() ports.ProcessKiller { return processKiller{} }
+
+// Garante conformidade.
+var _ ports.ProcessKiller = processKiller{}
+
+func (processKiller) Lookup(pid int32) (entities.ProcessInfo, error) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return entities.ProcessInfo{}, err
+	}
+	info := entities.ProcessInfo{PID: pid}
+	info.Name, _ = p.Name()
+	info.Exe, _ = p.Exe()
+	info.Cmdline, _ = p.Cmdline()
+	info.User, _ = p.Username()
+	return info, nil
+}
+
+// Kill envia SIGTERM (ou SIGKILL com force); no Windows ambos terminam o processo.
+func (processKiller) Kill(pid int32, force bool) error {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return err
+	}
+	if force {
+		return p.Kill()
+	}
+	return p.Terminate()
+}
@@ -0,0 +1,61 @@
+//go:build !windows
+// +build !windows
+
+package scripts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+var interpreters = map[string][]string{
+	"bash": {"bash"},
+	"sh":   {"sh"},
+	"pwsh": {"pwsh", "-NoProfile", "-NonInteractive", "-File"},
+}
+
+var extensions = map[string]string{"bash": ".sh", "sh": ".sh", "pwsh": ".ps1"}
+
+// command monta a execução: ambiente mínimo, grupo de processos próprio (o
+// timeout mata filhos também), usuário configurado e limite de CPU via
+// ulimit aplicado antes do exec do interpretador.
+func command(ctx context.Context, s entities.Script, dir, path string, lim limits) (*exec.Cmd, error) {
+	argv := append(append([]string{}, interpreters[s.Interpreter]...), path)
+	argv = append(argv, s.Args...)
+	if secs := int(lim.cpu / time.Second); secs > 0 {
+		argv = append([]string{"/bin/sh", "-c", `ulimit -t "$0" && exec "$@"`, strconv.Itoa(secs)}, argv...)
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin", "LANG=C"}
+	attr := &syscall.SysProcAttr{Setpgid: true}
+
+	if lim.user != "" {
+		u, err := user.Lookup(lim.user)
+		if err != nil {
+			return nil, fmt.Errorf("scripts user: %w", err)
+		}
+		uid, _ := strconv.Atoi(u.Uid)
+		gid, _ := strconv.Atoi(u.Gid)
+		attr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+		for _, p := range []string{dir, path} {
+			if err := os.Chown(p, uid, gid); err != nil {
+				return nil, fmt.Errorf("scripts user: %w", err)
+			}
+		}
+		cmd.Env = append(cmd.Env, "HOME="+u.HomeDir, "USER="+u.Username)
+	}
+	cmd.SysProcAttr = attr
+	cmd.Cancel = func() error { return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL) }
+	return cmd, nil
+}
+
+// confine não tem o que fazer após o start: os limites já vêm do ulimit.
+func confine(*exec.Cmd, limits) (func(), error) { return func() {}, nil }
@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package scripts
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+var psArgs = []string{"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass", "-File"}
+
+var interpreters = map[string][]string{
+	"powershell": append([]string{"powershell.exe"}, psArgs...),
+	"pwsh":       append([]string{"pwsh.exe"}, psArgs...),
+}
+
+var extensions = map[string]string{"powershell": ".ps1", "pwsh": ".ps1"}
+
+func command(ctx context.Context, s entities.Script, _, path string, lim limits) (*exec.Cmd, error) {
+	if lim.user != "" {
+		return nil, errors.New("scripts user is not supported on Windows")
+	}
+	argv := append(append([]string{}, interpreters[s.Interpreter]...), path)
+	argv = append(argv, s.Args...)
+	return exec.CommandContext(ctx, argv[0], argv[1:]...), nil
+}
+
+// confine coloca o processo num Job Object com limite de CPU; fechar o job
+// (release) mata o que ainda estiver rodando, inclusive filhos.
+func confine(cmd *exec.Cmd, lim limits) (func(), error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if lim.cpu > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_TIME
+		info.BasicLimitInformation.PerJobUserTimeLimit = int64(lim.cpu / 100) // unidades de 100ns
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	h, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(cmd.Process.Pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	defer windows.CloseHandle(h)
+	if err := windows.AssignProcessToJobObject(job, h); err != nil {
+		windows.CloseHandle(job)
+		return nil, err
+	}
+	return func() { windows.CloseHandle(job) }, nil
+}
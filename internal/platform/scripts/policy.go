@@ -0,0 +1,87 @@
+package scripts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+// policy é a allowlist local (YAML ou JSON). Só scripts cujo SHA-256 consta
+// aqui podem rodar, mesmo que o comando venha assinado pelo backend, e só
+// com uma das listas de argumentos de args (sem args, nenhum argumento):
+//
+//	scripts:
+//	  - name: netstat-snapshot
+//	    sha256: 3b0c...e1
+//	    interpreters: [bash, sh]
+//	    args: [[], ["-n"], ["-n", "--listening"]]
+type policy struct {
+	Scripts []policyEntry `yaml:"scripts" json:"scripts"`
+}
+
+type policyEntry struct {
+	Name         string     `yaml:"name" json:"name"`
+	SHA256       string     `yaml:"sha256" json:"sha256"`
+	Interpreters []string   `yaml:"interpreters" json:"interpreters"`
+	Args         [][]string `yaml:"args" json:"args"`
+}
+
+// loadPolicy relê a allowlist a cada uso: editar o arquivo vale na hora.
+func loadPolicy(path string) (policy, error) {
+	var p policy
+	if path == "" {
+		return p, errors.New("scripts allowlist not configured")
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("scripts allowlist: %w", err)
+	}
+	if err := yaml.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("scripts allowlist %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// allow procura o script na allowlist pelo hash do conteúdo e confere
+// interpretador e argumentos.
+func (p policy) allow(s entities.Script) (policyEntry, error) {
+	sum := Hash(s.Body)
+	for _, e := range p.Scripts {
+		if !strings.EqualFold(strings.TrimSpace(e.SHA256), sum) {
+			continue
+		}
+		if len(e.Interpreters) > 0 && !slices.ContainsFunc(e.Interpreters, func(in string) bool {
+			return strings.EqualFold(in, s.Interpreter)
+		}) {
+			return e, fmt.Errorf("interpreter %q not allowed for script %s", s.Interpreter, e.Name)
+		}
+		if !e.allowArgs(s.Args) {
+			return e, fmt.Errorf("args %q not allowed for script %s", s.Args, e.Name)
+		}
+		return e, nil
+	}
+	return policyEntry{}, fmt.Errorf("script sha256 %s not in allowlist", sum)
+}
+
+// allowArgs aceita args iguais (em ordem) a uma das listas da entrada; sem
+// listas, só a execução sem argumentos.
+func (e policyEntry) allowArgs(args []string) bool {
+	if len(args) == 0 {
+		return len(e.Args) == 0 || slices.ContainsFunc(e.Args, func(a []string) bool { return len(a) == 0 })
+	}
+	return slices.ContainsFunc(e.Args, func(a []string) bool { return slices.Equal(a, args) })
+}
+
+// Hash retorna o SHA-256 (hex) do corpo do script, como listado na allowlist.
+func Hash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
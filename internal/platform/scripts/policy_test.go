@@ -0,0 +1,115 @@
+package scripts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+)
+
+const (
+	netstat = "netstat -an\n"
+	uptime  = "uptime\n"
+)
+
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scripts.allowlist.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPolicyAllow(t *testing.T) {
+	// O hash em maiúsculas e com espaços também casa.
+	path := writePolicy(t, `
+scripts:
+  - name: netstat
+    sha256: "`+Hash(netstat)+`"
+    interpreters: [bash, sh]
+    args: [[], ["-n"], ["-n", "--listening"]]
+  - name: uptime
+    sha256: " `+strings.ToUpper(Hash(uptime))+` "
+`)
+	p, err := loadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		script  entities.Script
+		want    string // nome da entrada casada
+		wantErr string
+	}{
+		{"sem args", entities.Script{Interpreter: "bash", Body: netstat}, "netstat", ""},
+		{"lista exata", entities.Script{Interpreter: "sh", Body: netstat, Args: []string{"-n", "--listening"}}, "netstat", ""},
+		{"interpretador sem caixa", entities.Script{Interpreter: "BASH", Body: netstat, Args: []string{"-n"}}, "netstat", ""},
+		{"ordem diferente", entities.Script{Interpreter: "bash", Body: netstat, Args: []string{"--listening", "-n"}}, "", "not allowed for script netstat"},
+		{"prefixo de uma lista", entities.Script{Interpreter: "bash", Body: netstat, Args: []string{"--listening"}}, "", "not allowed for script netstat"},
+		{"arg extra", entities.Script{Interpreter: "bash", Body: netstat, Args: []string{"-n", "; rm -rf /"}}, "", "not allowed for script netstat"},
+		{"interpretador fora da lista", entities.Script{Interpreter: "pwsh", Body: netstat}, "", `interpreter "pwsh" not allowed`},
+		{"corpo alterado", entities.Script{Interpreter: "bash", Body: netstat + "curl evil\n"}, "", "not in allowlist"},
+		{"hash normalizado", entities.Script{Interpreter: "pwsh", Body: uptime}, "uptime", ""},
+		{"sem listas de args: nenhum argumento", entities.Script{Interpreter: "sh", Body: uptime, Args: []string{"-p"}}, "", "not allowed for script uptime"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := p.allow(tc.script)
+			if tc.wantErr == "" {
+				if err != nil || e.Name != tc.want {
+					t.Fatalf("allow = %q, %v; want %q", e.Name, err, tc.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("allow = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"não configurada", "", "not configured"},
+		{"ausente", filepath.Join(t.TempDir(), "missing.yml"), "scripts allowlist:"},
+		{"YAML inválido", writePolicy(t, "scripts: [\n"), "scripts allowlist "},
+		{"formato errado", writePolicy(t, "scripts: netstat\n"), "scripts allowlist "},
+	}
+	for _, tc := range cases {
+		if _, err := loadPolicy(tc.path); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: loadPolicy = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+// Check relê o arquivo: uma entrada removida deixa de valer sem restart.
+func TestCheckRereadsAllowlist(t *testing.T) {
+	var in string
+	for name := range interpreters {
+		in = name
+		break
+	}
+	entry := "scripts:\n  - name: uptime\n    sha256: " + Hash(uptime) + "\n"
+	path := writePolicy(t, entry)
+	r := &runner{allowlist: path}
+	s := entities.Script{Interpreter: in, Body: uptime}
+	if err := r.Check(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("scripts: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Check(s); err == nil {
+		t.Fatal("Check passed after the entry was removed")
+	}
+	if err := r.Check(entities.Script{Interpreter: "cobol", Body: uptime}); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("Check with an unknown interpreter = %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package scripts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/you/aiceberg_agent/internal/common/config"
+	"github.com/you/aiceberg_agent/internal/domain/entities"
+	"github.com/you/aiceberg_agent/internal/domain/ports"
+)
+
+const (
+	// chunkSize e chunkEvery definem quando a saída acumulada é repassada.
+	chunkSize  = 16 << 10
+	chunkEvery = time.Second
+	// killGrace é a espera entre o fim do prazo e o kill forçado.
+	killGrace = 5 * time.Second
+)
+
+// limits são os limites de uma execução.
+type limits struct {
+	user      string
+	timeout   time.Duration
+	cpu       time.Duration
+	maxOutput int64
+}
+
+type runner struct {
+	allowlist string
+	lim       limits
+}
+
+func New(cfg config.Config) ports.ScriptRunner {
+	return &runner{
+		allowlist: cfg.ScriptsAllowlistPath,
+		lim: limits{
+			user:      cfg.ScriptsUser,
+			timeout:   cfg.ScriptsTimeout,
+			cpu:       cfg.ScriptsCPUTime,
+			maxOutput: int64(cfg.ScriptsMaxOutputKB) << 10,
+		},
+	}
+}
+
+// Garante conformidade.
+var _ ports.ScriptRunner = (*runner)(nil)
+
+func (r *runner) Check(s entities.Script) error {
+	if _, ok := interpreters[s.Interpreter]; !ok {
+		return fmt.Errorf("interpreter %q not supported on this platform", s.Interpreter)
+	}
+	p, err := loadPolicy(r.allowlist)
+	if err != nil {
+		return err
+	}
+	_, err = p.allow(s)
+	return err
+}
+
+func (r *runner) Run(ctx context.Context, s entities.Script, out func(stream string, chunk []byte)) (entities.ScriptResult, error) {
+	var res entities.ScriptResult
+	if err := r.Check(s); err != nil {
+		return res, err
+	}
+	lim := r.lim
+	if d := time.Duration(s.TimeoutSec) * time.Second; d > 0 && d < lim.timeout {
+		lim.timeout = d
+	}
+
+	dir, err := os.MkdirTemp("", "aiceberg-script-")
+	if err != nil {
+		return res, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "script"+extensions[s.Interpreter])
+	if err := os.WriteFile(path, []byte(s.Body), 0o500); err != nil {
+		return res, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, lim.timeout)
+	defer cancel()
+	cmd, err := command(ctx, s, dir, path, lim)
+	if err != nil {
+		return res, err
+	}
+	cmd.Dir = dir
+	cmd.WaitDelay = killGrace
+
+	budget := &budget{left: lim.maxOutput}
+	stdout := &chunker{stream: "stdout", out: out, budget: budget}
+	stderr := &chunker{stream: "stderr", out: out, budget: budget}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return res, err
+	}
+	release, err := confine(cmd, lim)
+	if err != nil {
+		_ = cmd.Cancel()
+		_ = cmd.Wait()
+		return res, err
+	}
+	defer release()
+
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(chunkEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				stdout.flush()
+				stderr.flush()
+			}
+		}
+	}()
+	waitErr := cmd.Wait()
+	close(done)
+	stdout.flush()
+	stderr.flush()
+
+	res.DurationMs = time.Since(start).Milliseconds()
+	res.OutputBytes = budget.used.Load()
+	res.Truncated = budget.truncated.Load()
+	res.TimedOut = errors.Is(ctx.Err(), context.DeadlineExceeded)
+	res.ExitCode = cmd.ProcessState.ExitCode()
+	var exitErr *exec.ExitError
+	if waitErr != nil && !errors.As(waitErr, &exitErr) && !res.TimedOut {
+		return res, waitErr
+	}
+	return res, nil
+}
+
+// budget limita a saída total (stdout+stderr) de uma execução.
+type budget struct {
+	left      int64
+	used      atomic.Int64
+	truncated atomic.Bool
+	mu        sync.Mutex
+}
+
+func (b *budget) take(n int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if int64(n) > b.left {
+		n = int(b.left)
+		b.truncated.Store(true)
+	}
+	b.left -= int64(n)
+	b.used.Add(int64(n))
+	return n
+}
+
+// chunker acumula a saída de um stream e a repassa em pedaços de até
+// chunkSize ou a cada chunkEvery. O excedente ao budget é descartado, mas
+// continua sendo lido para o processo não travar no pipe.
+type chunker struct {
+	stream string
+	out    func(stream string, chunk []byte)
+	budget *budget
+	mu     sync.Mutex
+	buf    []byte
+}
+
+var _ io.Writer = (*chunker)(nil)
+
+func (c *chunker) Write(p []byte) (int, error) {
+	n := c.budget.take(len(p))
+	c.mu.Lock()
+	c.buf = append(c.buf, p[:n]...)
+	full := len(c.buf) >= chunkSize
+	c.mu.Unlock()
+	if full {
+		c.flush()
+	}
+	return len(p), nil
+}
+
+func (c *chunker) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.buf) > 0 {
+		n := min(len(c.buf), chunkSize)
+		c.out(c.stream, append([]byte(nil), c.buf[:n]...))
+		c.buf = c.buf[n:]
+	}
+	c.buf = nil
+}
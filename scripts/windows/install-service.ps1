@@ -6,4 +6,6 @@ param(
 $serviceName = 'AIcebergAgent'
 $cmd = '"' + $BinPath + '" -config "' + $ConfigPath + '"'
 sc.exe create $serviceName binPath= $cmd start= auto
+# Reinicia após falha/saída não-zero (inclui o comando remoto "restart").
+sc.exe failure $serviceName reset= 86400 actions= restart/5000/restart/5000/restart/5000
 sc.exe start $serviceName